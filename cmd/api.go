@@ -10,6 +10,7 @@ import (
 
 	"github.com/fatih/color"
 	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
 )
 
 var apiCmd = &cobra.Command{
@@ -124,7 +125,7 @@ func makeAPIRequest(method, path, body string, headers []string, pretty, verbose
 	}
 
 	// Make request
-	client := &http.Client{}
+	client := newHTTPClient()
 	resp, err := client.Do(req)
 	if err != nil {
 		return fmt.Errorf("request failed: %w", err)
@@ -179,58 +180,15 @@ func makeAPIRequest(method, path, body string, headers []string, pretty, verbose
 	return nil
 }
 
+// getAPIEndpoint returns the endpoint from --endpoint, the environment,
+// the active profile or config.yaml
 func getAPIEndpoint() string {
-	// Try to get from flag
-	if endpoint := rootCmd.Flag("endpoint").Value.String(); endpoint != "" {
-		return endpoint
-	}
-	// Default
-	return "https://api.darkstorage.io"
+	return strings.TrimSuffix(viper.GetString("endpoint"), "/")
 }
 
+// getAuthToken returns the API key or OAuth access token
 func getAuthToken() string {
-	// Try to get from API key flag
-	if apiKey := rootCmd.Flag("api-key").Value.String(); apiKey != "" {
-		return apiKey
-	}
-
-	// Try to load from config
-	cfg, err := loadConfig()
-	if err == nil && cfg.AccessToken != "" {
-		return cfg.AccessToken
-	}
-
-	return ""
-}
-
-func loadConfig() (*Config, error) {
-	configPath := getConfigPath()
-	data, err := os.ReadFile(configPath)
-	if err != nil {
-		return nil, err
-	}
-
-	var cfg Config
-	if err := json.Unmarshal(data, &cfg); err != nil {
-		return nil, err
-	}
-
-	return &cfg, nil
-}
-
-func getConfigPath() string {
-	if configFile := rootCmd.Flag("config").Value.String(); configFile != "" {
-		return configFile
-	}
-
-	home, _ := os.UserHomeDir()
-	return home + "/.darkstorage/config.json"
-}
-
-type Config struct {
-	AccessToken  string `json:"access_token"`
-	RefreshToken string `json:"refresh_token"`
-	Endpoint     string `json:"endpoint"`
+	return viper.GetString("api_key")
 }
 
 func min(a, b int) int {
@@ -0,0 +1,180 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/darkstorage/cli/internal/devapi"
+	"github.com/fatih/color"
+	"github.com/spf13/pflag"
+)
+
+const testAPIToken = "dev-token"
+
+// runAPICommand runs the command named by args against endpoint and returns
+// what it printed to stdout. Commands exit on errors, so only successful
+// runs can be tested this way.
+func runAPICommand(t *testing.T, endpoint string, args ...string) string {
+	t.Helper()
+	cmd, rest, err := rootCmd.Find(args)
+	if err != nil {
+		t.Fatalf("Find(%q) error = %v", args, err)
+	}
+	// Flags persist between runs; put them back afterwards
+	t.Cleanup(func() {
+		cmd.Flags().VisitAll(func(f *pflag.Flag) {
+			if f.Changed {
+				f.Value.Set(f.DefValue)
+				f.Changed = false
+			}
+		})
+	})
+	if err := cmd.ParseFlags(append(rest, "--endpoint", endpoint, "--api-key", testAPIToken)); err != nil {
+		t.Fatalf("ParseFlags(%q) error = %v", args, err)
+	}
+	if err := cmd.ValidateArgs(cmd.Flags().Args()); err != nil {
+		t.Fatalf("%s: %v", cmd.CommandPath(), err)
+	}
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout, colorOutput := os.Stdout, color.Output
+	os.Stdout, color.Output = w, w
+	defer func() { os.Stdout, color.Output = stdout, colorOutput }()
+	output := make(chan string)
+	go func() {
+		data, _ := io.ReadAll(r)
+		output <- string(data)
+	}()
+
+	cmd.SetContext(context.Background())
+	cmd.Run(cmd, cmd.Flags().Args())
+	w.Close()
+	return <-output
+}
+
+func newFakeAPI(t *testing.T) (*devapi.Server, string) {
+	t.Helper()
+	fake := devapi.New(devapi.DefaultFixtures(time.Now()), testAPIToken)
+	srv := httptest.NewServer(fake)
+	t.Cleanup(srv.Close)
+	return fake, srv.URL
+}
+
+func TestAPICommandOutput(t *testing.T) {
+	_, endpoint := newFakeAPI(t)
+
+	tests := []struct {
+		args []string
+		// want are printed, wantJSON is the number of items in the JSON
+		// array printed, -1 if the output is not one
+		want     []string
+		wantJSON int
+	}{
+		{args: []string{"trash", "list"}, want: []string{"q3-report.pdf", "old-notes.txt", "critical"}, wantJSON: -1},
+		{args: []string{"trash", "list", "--json"}, wantJSON: 2},
+		{args: []string{"trash", "info", "5f1c2a9e"}, want: []string{"documents/reports/q3-report.pdf"}, wantJSON: -1},
+		{args: []string{"audit", "list", "--json"}, wantJSON: 4},
+		{args: []string{"audit", "user", "alice@example.com", "--json"}, wantJSON: 1},
+		{args: []string{"audit", "file", "documents/reports/q3-report.pdf", "--downloads", "--json"}, wantJSON: 1},
+		{args: []string{"audit", "export", "--format", "csv"}, want: []string{"timestamp,event_type", "PERMISSION_GRANT"}, wantJSON: -1},
+		{args: []string{"audit", "summary"}, want: []string{"4"}, wantJSON: -1},
+		{args: []string{"shares", "list", "--json"}, wantJSON: 2},
+		{args: []string{"shares", "stats", "3c2b1a0f"}, want: []string{"12"}, wantJSON: -1},
+		{args: []string{"groups", "list", "--json"}, wantJSON: 1},
+		{args: []string{"groups", "members", "g1a2b3c4", "--json"}, wantJSON: 3},
+		{args: []string{"perms", "list", "file-design", "--json"}, wantJSON: 2},
+		{args: []string{"perms", "check", "file-design"}, want: []string{"Owner:  yes"}, wantJSON: -1},
+		{args: []string{"scan", "status", "file-installer"}, want: []string{"infected", "Win.Trojan.Agent-1234"}, wantJSON: -1},
+		{args: []string{"scan", "threats"}, want: []string{"downloads/setup.exe"}, wantJSON: -1},
+		{args: []string{"scan", "quarantine", "list"}, want: []string{"setup.exe"}, wantJSON: -1},
+		{args: []string{"compartment", "list"}, want: []string{"classified", "pii", "SECRET"}, wantJSON: -1},
+		{args: []string{"compartment", "files", "classified"}, want: []string{"my-bucket/classified/report-2026.pdf"}, wantJSON: -1},
+	}
+	for _, tt := range tests {
+		t.Run(strings.Join(tt.args, " "), func(t *testing.T) {
+			got := runAPICommand(t, endpoint, tt.args...)
+			for _, want := range tt.want {
+				if !strings.Contains(got, want) {
+					t.Errorf("output does not contain %q:\n%s", want, got)
+				}
+			}
+			if tt.wantJSON < 0 {
+				return
+			}
+			var items []json.RawMessage
+			if err := json.Unmarshal([]byte(got), &items); err != nil {
+				t.Fatalf("output is not a JSON array: %v\n%s", err, got)
+			}
+			if len(items) != tt.wantJSON {
+				t.Errorf("printed %d items, want %d", len(items), tt.wantJSON)
+			}
+		})
+	}
+}
+
+func TestAPICommandChanges(t *testing.T) {
+	fake, endpoint := newFakeAPI(t)
+
+	commands := [][]string{
+		{"trash", "recover", "5f1c2a9e", "--to", "restored/q3.pdf"},
+		{"trash", "purge", "9a8b7c6d5e4f", "--force"},
+		{"share", "bucket/notes.txt", "--expires", "24h", "--max-downloads", "2"},
+		{"shares", "revoke", "3c2b1a0f"},
+		{"groups", "create", "design", "--description", "Design team"},
+		{"groups", "add-member", "g1a2b3c4", "carol@example.com", "--role", "admin"},
+		{"groups", "remove-member", "g1a2b3c4", "bob@example.com"},
+		{"groups", "delete", "g1a2b3c4", "--force"},
+		{"perms", "grant", "file-design", "--user", "carol@example.com", "--level", "editor"},
+		{"perms", "revoke", "file-design", "p9e8d7c6b5a4f3e2"},
+		{"scan", "file", "bucket/dir/new.bin", "--type", "antivirus"},
+		{"scan", "quarantine", "release", "q0a1b2c3"},
+		{"compartment", "create", "legal", "--level", "CONFIDENTIAL", "--compliance", "SOC2", "--require-mfa"},
+		{"compartment", "assign", "bucket/contracts/a.pdf", "legal"},
+		{"compartment", "grant", "legal", "alice@example.com", "--access", "write"},
+		{"compartment", "revoke", "classified", "alice@example.com"},
+		{"compartment", "delete", "pii", "--force"},
+	}
+	for _, args := range commands {
+		runAPICommand(t, endpoint, args...)
+	}
+
+	state, err := fake.Snapshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+	checks := []struct {
+		name      string
+		got, want interface{}
+	}{
+		{"deleted files", len(state.DeletedFiles), 0},
+		{"share links", len(state.ShareLinks), 3},
+		{"revoked link active", state.ShareLinks[0].IsActive, false},
+		{"new link file", state.ShareLinks[2].FileName, "notes.txt"},
+		{"groups", len(state.Groups), 1},
+		{"new group", state.Groups[0].Description, "Design team"},
+		{"design permissions", len(state.Permissions["file-design"]), 2},
+		{"granted level", state.Permissions["file-design"][1].Level, "editor"},
+		{"scans", len(state.Scans), 3},
+		{"quarantined files", len(state.Quarantine), 0},
+		{"compartments", len(state.Compartments), 2},
+		{"legal compliance", strings.Join(state.Compartments[1].Compliance, ","), "SOC2"},
+		{"legal requires MFA", state.Compartments[1].Policies.RequireMFA, true},
+		{"legal files", len(state.CompartmentFiles["legal"]), 1},
+		{"alice's legal access", state.CompartmentUsers["legal"]["alice@example.com"], "write"},
+		{"classified users", len(state.CompartmentUsers["classified"]), 1},
+	}
+	for _, tt := range checks {
+		if tt.got != tt.want {
+			t.Errorf("%s = %v, want %v", tt.name, tt.got, tt.want)
+		}
+	}
+}
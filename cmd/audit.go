@@ -5,9 +5,11 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"sort"
 	"text/tabwriter"
 	"time"
 
+	apiv1 "github.com/darkstorage/cli/internal/api/v1"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
@@ -23,59 +25,20 @@ var auditListCmd = &cobra.Command{
 	Short: "List recent audit events",
 	Run: func(cmd *cobra.Command, args []string) {
 		eventType, _ := cmd.Flags().GetString("type")
+		user, _ := cmd.Flags().GetString("user")
+		resource, _ := cmd.Flags().GetString("resource")
 		from, _ := cmd.Flags().GetString("from")
 		to, _ := cmd.Flags().GetString("to")
 		limit, _ := cmd.Flags().GetInt("limit")
 
-		client := newAPIClient()
-		path := fmt.Sprintf("/audit?limit=%d", limit)
-		if eventType != "" {
-			path += "&event_type=" + eventType
-		}
-		if from != "" {
-			path += "&from=" + from
-		}
-		if to != "" {
-			path += "&to=" + to
-		}
-
-		resp, err := client.get(path)
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-			os.Exit(1)
-		}
-
-		if viper.GetBool("json") {
-			fmt.Println(string(resp))
-			return
-		}
-
-		var result struct {
-			Events []struct {
-				ID           string    `json:"id"`
-				EventType    string    `json:"event_type"`
-				ResourceType string    `json:"resource_type"`
-				ResourceName string    `json:"resource_name"`
-				ActorEmail   string    `json:"actor_email"`
-				IPAddress    string    `json:"ip_address"`
-				CreatedAt    time.Time `json:"created_at"`
-			} `json:"events"`
-		}
-		json.Unmarshal(resp, &result)
-
-		if len(result.Events) == 0 {
-			fmt.Println("No audit events found")
-			return
-		}
-
-		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
-		fmt.Fprintln(w, "TIME\tEVENT\tRESOURCE\tUSER\tIP")
-		for _, e := range result.Events {
-			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n",
-				e.CreatedAt.Format("01-02 15:04"),
-				e.EventType, e.ResourceName, e.ActorEmail, e.IPAddress)
-		}
-		w.Flush()
+		listAuditEvents(cmd, apiv1.AuditListOptions{
+			PageOptions: apiv1.PageOptions{Limit: limit},
+			EventType:   eventType,
+			ActorEmail:  user,
+			Resource:    resource,
+			From:        from,
+			To:          to,
+		})
 	},
 }
 
@@ -86,45 +49,54 @@ var auditExportCmd = &cobra.Command{
 		format, _ := cmd.Flags().GetString("format")
 		from, _ := cmd.Flags().GetString("from")
 		to, _ := cmd.Flags().GetString("to")
+		eventType, _ := cmd.Flags().GetString("type")
+		user, _ := cmd.Flags().GetString("user")
+		output, _ := cmd.Flags().GetString("output")
 
-		client := newAPIClient()
-		path := "/audit?limit=10000"
-		if from != "" {
-			path += "&from=" + from
-		}
-		if to != "" {
-			path += "&to=" + to
+		if format != "csv" && format != "json" {
+			fmt.Fprintf(os.Stderr, "Error: unsupported export format %q (use csv or json)\n", format)
+			os.Exit(1)
 		}
 
-		resp, err := client.get(path)
+		client := newAPIClient()
+		opts := apiv1.AuditListOptions{
+			PageOptions: apiv1.PageOptions{Limit: 1000},
+			EventType:   eventType,
+			ActorEmail:  user,
+			From:        from,
+			To:          to,
+		}
+		events, err := apiv1.Collect(cmd.Context(), 0, client.AuditEvents(opts))
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 			os.Exit(1)
 		}
 
-		var result struct {
-			Events []struct {
-				ID           string    `json:"id"`
-				EventType    string    `json:"event_type"`
-				ResourceType string    `json:"resource_type"`
-				ResourceName string    `json:"resource_name"`
-				ActorEmail   string    `json:"actor_email"`
-				IPAddress    string    `json:"ip_address"`
-				UserAgent    string    `json:"user_agent"`
-				CreatedAt    time.Time `json:"created_at"`
-			} `json:"events"`
+		out := os.Stdout
+		if output != "" {
+			f, err := os.Create(output)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+			defer f.Close()
+			out = f
 		}
-		json.Unmarshal(resp, &result)
 
 		if format == "json" {
-			fmt.Println(string(resp))
+			enc := json.NewEncoder(out)
+			enc.SetIndent("", "  ")
+			if err := enc.Encode(events); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
 			return
 		}
 
 		// CSV output
-		w := csv.NewWriter(os.Stdout)
+		w := csv.NewWriter(out)
 		w.Write([]string{"timestamp", "event_type", "resource_type", "resource_name", "actor_email", "ip_address", "user_agent"})
-		for _, e := range result.Events {
+		for _, e := range events {
 			w.Write([]string{
 				e.CreatedAt.Format(time.RFC3339),
 				e.EventType,
@@ -136,6 +108,10 @@ var auditExportCmd = &cobra.Command{
 			})
 		}
 		w.Flush()
+		if err := w.Error(); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
 	},
 }
 
@@ -144,32 +120,29 @@ var auditSummaryCmd = &cobra.Command{
 	Short: "Get audit summary statistics",
 	Run: func(cmd *cobra.Command, args []string) {
 		client := newAPIClient()
-		resp, err := client.get("/audit/summary")
+		result, err := client.GetAuditSummary(cmd.Context())
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 			os.Exit(1)
 		}
 
 		if viper.GetBool("json") {
-			fmt.Println(string(resp))
+			printJSON(result)
 			return
 		}
 
-		var result struct {
-			TotalEvents    int `json:"total_events"`
-			EventsByType   map[string]int `json:"events_by_type"`
-			EventsToday    int `json:"events_today"`
-			EventsThisWeek int `json:"events_this_week"`
-		}
-		json.Unmarshal(resp, &result)
-
 		fmt.Printf("Total Events:     %d\n", result.TotalEvents)
 		fmt.Printf("Events Today:     %d\n", result.EventsToday)
 		fmt.Printf("Events This Week: %d\n", result.EventsThisWeek)
 		if len(result.EventsByType) > 0 {
 			fmt.Println("\nEvents by Type:")
-			for k, v := range result.EventsByType {
-				fmt.Printf("  %s: %d\n", k, v)
+			types := make([]string, 0, len(result.EventsByType))
+			for k := range result.EventsByType {
+				types = append(types, k)
+			}
+			sort.Strings(types)
+			for _, k := range types {
+				fmt.Printf("  %s: %d\n", k, result.EventsByType[k])
 			}
 		}
 	},
@@ -182,13 +155,18 @@ var auditFileCmd = &cobra.Command{
 
 Examples:
   darkstorage audit file my-bucket/contract.pdf
-  darkstorage audit file my-bucket/contract.pdf --accessed-by
   darkstorage audit file my-bucket/contract.pdf --downloads`,
 	Args: cobra.ExactArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
-		// Implementation would query backend
-		fmt.Printf("Audit history for: %s\n", args[0])
-		fmt.Println("(This would show complete file history from backend)")
+		limit, _ := cmd.Flags().GetInt("limit")
+		opts := apiv1.AuditListOptions{
+			PageOptions: apiv1.PageOptions{Limit: limit},
+			Resource:    args[0],
+		}
+		if downloads, _ := cmd.Flags().GetBool("downloads"); downloads {
+			opts.EventType = "FILE_DOWNLOAD"
+		}
+		listAuditEvents(cmd, opts)
 	},
 }
 
@@ -199,13 +177,16 @@ var auditUserCmd = &cobra.Command{
 
 Examples:
   darkstorage audit user alice@example.com
-  darkstorage audit user alice@example.com --files
-  darkstorage audit user alice@example.com --logins`,
+  darkstorage audit user alice@example.com --type FILE_DOWNLOAD`,
 	Args: cobra.ExactArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
-		// Implementation would query backend
-		fmt.Printf("Activity for user: %s\n", args[0])
-		fmt.Println("(This would show user activity from backend)")
+		eventType, _ := cmd.Flags().GetString("type")
+		limit, _ := cmd.Flags().GetInt("limit")
+		listAuditEvents(cmd, apiv1.AuditListOptions{
+			PageOptions: apiv1.PageOptions{Limit: limit},
+			EventType:   eventType,
+			ActorEmail:  args[0],
+		})
 	},
 }
 
@@ -268,7 +249,7 @@ func init() {
 	auditListCmd.Flags().Int("risk-score", 0, "Minimum risk score (0-100)")
 
 	// Export command flags
-	auditExportCmd.Flags().String("format", "csv", "Export format: csv, json, pdf")
+	auditExportCmd.Flags().String("format", "csv", "Export format: csv, json")
 	auditExportCmd.Flags().String("from", "", "Start date (YYYY-MM-DD)")
 	auditExportCmd.Flags().String("to", "", "End date (YYYY-MM-DD)")
 	auditExportCmd.Flags().String("output", "", "Output file path")
@@ -276,14 +257,12 @@ func init() {
 	auditExportCmd.Flags().String("user", "", "Filter by user")
 
 	// File command flags
-	auditFileCmd.Flags().Bool("accessed-by", false, "Show who accessed the file")
-	auditFileCmd.Flags().Bool("downloads", false, "Show download history")
-	auditFileCmd.Flags().Bool("permission-changes", false, "Show permission changes")
+	auditFileCmd.Flags().Bool("downloads", false, "Show download history only")
+	auditFileCmd.Flags().Int("limit", 50, "Number of results")
 
 	// User command flags
-	auditUserCmd.Flags().Bool("files", false, "Show files accessed")
-	auditUserCmd.Flags().Bool("logins", false, "Show login history")
-	auditUserCmd.Flags().Bool("permissions-granted", false, "Show permissions granted by user")
+	auditUserCmd.Flags().String("type", "", "Filter by event type")
+	auditUserCmd.Flags().Int("limit", 50, "Number of results")
 
 	// Stream command flags
 	auditStreamCmd.Flags().String("type", "", "Filter event types")
@@ -293,3 +272,36 @@ func init() {
 	auditViolationsCmd.Flags().String("severity", "", "Filter by severity (low, medium, high, critical)")
 	auditViolationsCmd.Flags().String("type", "", "Filter by violation type")
 }
+
+// listAuditEvents fetches one page of events and prints it as a table or JSON
+func listAuditEvents(cmd *cobra.Command, opts apiv1.AuditListOptions) {
+	client := newAPIClient()
+	result, err := client.ListAuditEvents(cmd.Context(), opts)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	if viper.GetBool("json") {
+		printJSON(result.Events)
+		return
+	}
+
+	if len(result.Events) == 0 {
+		fmt.Println("No audit events found")
+		return
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "TIME\tEVENT\tRESOURCE\tUSER\tIP")
+	for _, e := range result.Events {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n",
+			e.CreatedAt.Format("01-02 15:04"),
+			e.EventType, e.ResourceName, e.ActorEmail, e.IPAddress)
+	}
+	w.Flush()
+
+	if result.NextCursor != "" {
+		fmt.Printf("\nShowing the first %d events; raise --limit to see more\n", len(result.Events))
+	}
+}
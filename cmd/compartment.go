@@ -1,14 +1,15 @@
 package cmd
 
 import (
-	"encoding/json"
 	"fmt"
 	"os"
 	"strings"
 
+	apiv1 "github.com/darkstorage/cli/internal/api/v1"
 	"github.com/fatih/color"
 	"github.com/olekukonko/tablewriter"
 	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
 )
 
 var compartmentCmd = &cobra.Command{
@@ -44,34 +45,34 @@ Examples:
 		requireMFA, _ := cmd.Flags().GetBool("require-mfa")
 		encryption, _ := cmd.Flags().GetString("encryption")
 
-		payload := map[string]interface{}{
-			"name":        name,
-			"level":       level,
-			"compliance":  strings.Split(compliance, ","),
-			"description": description,
-			"policies": map[string]interface{}{
-				"require_mfa": requireMFA,
-				"encryption":  encryption,
+		req := apiv1.CreateCompartmentRequest{
+			Name:        name,
+			Level:       level,
+			Compliance:  splitList(compliance),
+			Description: description,
+			Policies: apiv1.CompartmentPolicies{
+				RequireMFA: requireMFA,
+				Encryption: encryption,
 			},
 		}
 
-		jsonData, _ := json.Marshal(payload)
-		endpoint := getAPIEndpoint()
+		client := newAPIClient()
+		created, err := client.CreateCompartment(cmd.Context(), req)
+		if err != nil {
+			color.Red("Error: %v", err)
+			os.Exit(1)
+		}
 
-		color.Green("Creating compartment: %s", name)
-		if level != "" {
-			color.Cyan("  Level: %s", level)
+		color.Green("✓ Compartment created: %s", created.Name)
+		if created.Level != "" {
+			color.Cyan("  Level: %s", created.Level)
 		}
-		if compliance != "" {
-			color.Cyan("  Compliance: %s", compliance)
+		if len(created.Compliance) > 0 {
+			color.Cyan("  Compliance: %s", strings.Join(created.Compliance, ", "))
 		}
-		if requireMFA {
+		if created.Policies.RequireMFA {
 			color.Cyan("  MFA Required: Yes")
 		}
-
-		color.Yellow("\nAPI Call: POST %s/compartments", endpoint)
-		color.Yellow("Payload: %s", string(jsonData))
-		color.Green("\n✓ Compartment created: %s", name)
 	},
 }
 
@@ -84,40 +85,20 @@ Examples:
   darkstorage compartment list
   darkstorage compartment list --json`,
 	Run: func(cmd *cobra.Command, args []string) {
-		// Mock data for demonstration
-		compartments := []map[string]interface{}{
-			{
-				"name":        "classified",
-				"level":       "SECRET",
-				"files":       142,
-				"users":       5,
-				"created":     "2026-02-15",
-				"compliance":  "ITAR",
-				"require_mfa": true,
-			},
-			{
-				"name":        "pii",
-				"level":       "CONFIDENTIAL",
-				"files":       1028,
-				"users":       12,
-				"created":     "2026-01-10",
-				"compliance":  "HIPAA, GDPR",
-				"require_mfa": true,
-			},
-			{
-				"name":        "public",
-				"level":       "PUBLIC",
-				"files":       5432,
-				"users":       50,
-				"created":     "2026-01-01",
-				"compliance":  "",
-				"require_mfa": false,
-			},
+		client := newAPIClient()
+		compartments, err := apiv1.Collect(cmd.Context(), 0, client.Compartments())
+		if err != nil {
+			color.Red("Error: %v", err)
+			os.Exit(1)
 		}
 
-		if jsonOutput, _ := cmd.Flags().GetBool("json"); jsonOutput {
-			data, _ := json.MarshalIndent(compartments, "", "  ")
-			fmt.Println(string(data))
+		if viper.GetBool("json") {
+			printJSON(compartments)
+			return
+		}
+
+		if len(compartments) == 0 {
+			fmt.Println("No compartments found")
 			return
 		}
 
@@ -127,15 +108,15 @@ Examples:
 
 		for _, c := range compartments {
 			mfa := "No"
-			if c["require_mfa"].(bool) {
+			if c.Policies.RequireMFA {
 				mfa = "Yes"
 			}
 			table.Append([]string{
-				c["name"].(string),
-				c["level"].(string),
-				fmt.Sprintf("%d", c["files"].(int)),
-				fmt.Sprintf("%d", c["users"].(int)),
-				c["compliance"].(string),
+				c.Name,
+				c.Level,
+				fmt.Sprintf("%d", c.FileCount),
+				fmt.Sprintf("%d", c.UserCount),
+				strings.Join(c.Compliance, ", "),
 				mfa,
 			})
 		}
@@ -157,14 +138,20 @@ Examples:
 		compartment := args[1]
 		recursive, _ := cmd.Flags().GetBool("recursive")
 
-		color.Green("Assigning to compartment '%s': %s", compartment, path)
-		if recursive {
-			color.Cyan("  Mode: Recursive")
+		client := newAPIClient()
+		result, err := client.AssignCompartment(cmd.Context(), compartment, apiv1.AssignCompartmentRequest{
+			Path:      path,
+			Recursive: recursive,
+		})
+		if err != nil {
+			color.Red("Error: %v", err)
+			os.Exit(1)
 		}
 
-		endpoint := getAPIEndpoint()
-		color.Yellow("\nAPI Call: POST %s/compartments/%s/assign", endpoint, compartment)
-		color.Green("\n✓ Assigned: %s → %s", path, compartment)
+		color.Green("✓ Assigned: %s → %s", path, compartment)
+		if recursive {
+			color.Cyan("  Files assigned: %d", result.FilesAssigned)
+		}
 	},
 }
 
@@ -181,34 +168,38 @@ Examples:
 		compartment := args[0]
 		limit, _ := cmd.Flags().GetInt("limit")
 
-		// Mock data
-		files := []map[string]interface{}{
-			{"path": "my-bucket/classified/report-2026.pdf", "size": "2.4 MB", "modified": "2026-02-28"},
-			{"path": "my-bucket/classified/analysis.docx", "size": "1.1 MB", "modified": "2026-02-27"},
-			{"path": "my-bucket/classified/data.xlsx", "size": "850 KB", "modified": "2026-02-26"},
+		client := newAPIClient()
+		result, err := client.ListCompartmentFiles(cmd.Context(), compartment, apiv1.PageOptions{Limit: limit})
+		if err != nil {
+			color.Red("Error: %v", err)
+			os.Exit(1)
+		}
+
+		if viper.GetBool("json") {
+			printJSON(result)
+			return
 		}
 
 		fmt.Printf("Files in compartment '%s':\n\n", compartment)
 
 		table := tablewriter.NewWriter(os.Stdout)
-		table.SetHeader([]string{"Path", "Size", "Modified"})
+		table.SetHeader([]string{"Path", "Size", "Assigned"})
 		table.SetBorder(false)
 
-		count := 0
-		for _, f := range files {
-			if limit > 0 && count >= limit {
-				break
-			}
+		for _, f := range result.Files {
 			table.Append([]string{
-				f["path"].(string),
-				f["size"].(string),
-				f["modified"].(string),
+				f.Path,
+				formatBytes(f.Size),
+				f.AssignedAt.Format("2006-01-02"),
 			})
-			count++
 		}
 		table.Render()
 
-		fmt.Printf("\nTotal: %d files\n", len(files))
+		total := result.Total
+		if total < len(result.Files) {
+			total = len(result.Files)
+		}
+		fmt.Printf("\nTotal: %d files\n", total)
 	},
 }
 
@@ -226,11 +217,17 @@ Examples:
 		user := args[1]
 		access, _ := cmd.Flags().GetString("access")
 
-		color.Green("Granting %s access to '%s' for %s", access, compartment, user)
+		client := newAPIClient()
+		err := client.GrantCompartmentAccess(cmd.Context(), compartment, apiv1.GrantCompartmentRequest{
+			User:   user,
+			Access: access,
+		})
+		if err != nil {
+			color.Red("Error: %v", err)
+			os.Exit(1)
+		}
 
-		endpoint := getAPIEndpoint()
-		color.Yellow("\nAPI Call: POST %s/compartments/%s/grant", endpoint, compartment)
-		color.Green("\n✓ Access granted: %s → %s (%s)", user, compartment, access)
+		color.Green("✓ Access granted: %s → %s (%s)", user, compartment, access)
 	},
 }
 
@@ -246,11 +243,13 @@ Examples:
 		compartment := args[0]
 		user := args[1]
 
-		color.Yellow("Revoking access to '%s' from %s", compartment, user)
+		client := newAPIClient()
+		if err := client.RevokeCompartmentAccess(cmd.Context(), compartment, user); err != nil {
+			color.Red("Error: %v", err)
+			os.Exit(1)
+		}
 
-		endpoint := getAPIEndpoint()
-		color.Yellow("\nAPI Call: DELETE %s/compartments/%s/users/%s", endpoint, compartment, user)
-		color.Green("\n✓ Access revoked: %s from %s", user, compartment)
+		color.Green("✓ Access revoked: %s from %s", user, compartment)
 	},
 }
 
@@ -272,11 +271,13 @@ Examples:
 			os.Exit(1)
 		}
 
-		color.Yellow("Deleting compartment: %s", compartment)
+		client := newAPIClient()
+		if err := client.DeleteCompartment(cmd.Context(), compartment); err != nil {
+			color.Red("Error: %v", err)
+			os.Exit(1)
+		}
 
-		endpoint := getAPIEndpoint()
-		color.Yellow("\nAPI Call: DELETE %s/compartments/%s", endpoint, compartment)
-		color.Green("\n✓ Compartment deleted: %s", compartment)
+		color.Green("✓ Compartment deleted: %s", compartment)
 	},
 }
 
@@ -300,9 +301,6 @@ func init() {
 	// Delete flags
 	compartmentDeleteCmd.Flags().BoolP("force", "f", false, "Force deletion without confirmation")
 
-	// List flags
-	compartmentListCmd.Flags().Bool("json", false, "Output as JSON")
-
 	// Add subcommands
 	compartmentCmd.AddCommand(compartmentCreateCmd)
 	compartmentCmd.AddCommand(compartmentListCmd)
@@ -314,3 +312,14 @@ func init() {
 
 	rootCmd.AddCommand(compartmentCmd)
 }
+
+// splitList splits a comma-separated flag value, dropping empty entries
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -1,10 +1,11 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
 	"os"
-	"path/filepath"
 
+	"github.com/darkstorage/cli/internal/config"
 	"github.com/fatih/color"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
@@ -20,16 +21,22 @@ var configSetCmd = &cobra.Command{
 	Short: "Set configuration values",
 	Long: `Set configuration values for the Dark Storage CLI.
 
+Secrets (the API key and storage keys) are kept in the credential store,
+not in config.yaml.
+
 Examples:
   darkstorage config set --key YOUR_API_KEY
   darkstorage config set --endpoint https://api.darkstorage.io
-  darkstorage config set --key YOUR_KEY --endpoint https://custom.api.url`,
+  darkstorage config set --key YOUR_KEY --endpoint https://custom.api.url
+  darkstorage config set --storage-access-key AKIA... --storage-secret-key ...`,
 	Run: func(cmd *cobra.Command, args []string) {
 		key, _ := cmd.Flags().GetString("key")
 		endpoint, _ := cmd.Flags().GetString("endpoint")
+		accessKey, _ := cmd.Flags().GetString("storage-access-key")
+		secretKey, _ := cmd.Flags().GetString("storage-secret-key")
 
 		// Validate that at least one flag is provided
-		if key == "" && endpoint == "" {
+		if key == "" && endpoint == "" && accessKey == "" && secretKey == "" {
 			color.Yellow("No configuration values provided.")
 			fmt.Println("Use --key or --endpoint to set values.")
 			fmt.Println("\nExamples:")
@@ -39,37 +46,36 @@ Examples:
 		}
 
 		updated := []string{}
+		values := map[string]string{}
 
 		if key != "" {
 			// Basic validation for API key
 			if len(key) < 20 {
 				color.Yellow("Warning: API key seems too short (< 20 characters)")
 			}
-			viper.Set("api_key", key)
+			// A new API key replaces any OAuth session
+			values["api_key"] = key
+			values["refresh_token"] = ""
+			values["token_expiry"] = ""
 			updated = append(updated, "API key")
 		}
 
 		if endpoint != "" {
-			viper.Set("endpoint", endpoint)
+			values["endpoint"] = endpoint
 			updated = append(updated, "Endpoint")
 		}
 
-		// Ensure config directory exists
-		home, err := os.UserHomeDir()
-		if err != nil {
-			color.Red("Error getting home directory: %v", err)
-			os.Exit(1)
+		if accessKey != "" {
+			values["storage.access_key"] = accessKey
+			updated = append(updated, "Storage access key")
 		}
 
-		configDir := filepath.Join(home, ".darkstorage")
-		if err := os.MkdirAll(configDir, 0700); err != nil {
-			color.Red("Error creating config directory: %v", err)
-			os.Exit(1)
+		if secretKey != "" {
+			values["storage.secret_key"] = secretKey
+			updated = append(updated, "Storage secret key")
 		}
 
-		configPath := filepath.Join(configDir, "config.yaml")
-
-		if err := viper.WriteConfigAs(configPath); err != nil {
+		if err := saveCredentials(values); err != nil {
 			color.Red("Error saving config: %v", err)
 			os.Exit(1)
 		}
@@ -78,7 +84,8 @@ Examples:
 		for _, item := range updated {
 			fmt.Printf("  - %s\n", item)
 		}
-		fmt.Printf("\nConfig file: %s\n", configPath)
+		fmt.Printf("\nConfig file: %s\n", configFilePath())
+		fmt.Printf("Credentials: %s\n", credentialStore())
 	},
 }
 
@@ -87,10 +94,10 @@ var configShowCmd = &cobra.Command{
 	Short: "Show current configuration",
 	Long:  `Display the current Dark Storage CLI configuration settings.`,
 	Run: func(cmd *cobra.Command, args []string) {
-		home, _ := os.UserHomeDir()
-		configPath := filepath.Join(home, ".darkstorage", "config.yaml")
+		configPath := configFilePath()
 
 		fmt.Println("Current configuration:")
+		fmt.Printf("  Profile:  %s\n", profileName())
 		fmt.Printf("  Endpoint: %s\n", viper.GetString("endpoint"))
 
 		apiKey := viper.GetString("api_key")
@@ -105,6 +112,7 @@ var configShowCmd = &cobra.Command{
 		}
 
 		fmt.Printf("\nConfig file: %s\n", configPath)
+		fmt.Printf("Credentials: %s\n", credentialStore())
 
 		// Check if config file exists
 		if _, err := os.Stat(configPath); os.IsNotExist(err) {
@@ -113,11 +121,65 @@ var configShowCmd = &cobra.Command{
 	},
 }
 
+var configValidateCmd = &cobra.Command{
+	Use:   "validate",
+	Short: "Check the configuration for errors",
+	Long: `Check config.yaml, together with DARKSTORAGE_* environment variables and
+the selected profile, against the settings schema. Each problem names the
+offending key. Unknown keys, usually typos, are reported as warnings.
+
+Exits with status 1 if the configuration is invalid.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		result := struct {
+			File    string               `json:"file"`
+			Valid   bool                 `json:"valid"`
+			Errors  []*config.FieldError `json:"errors,omitempty"`
+			Unknown []string             `json:"unknown_keys,omitempty"`
+			Error   string               `json:"error,omitempty"`
+		}{File: configFilePath(), Valid: true}
+
+		if _, err := config.Load(viper.GetViper()); err != nil {
+			result.Valid = false
+			var verr config.ValidationError
+			if errors.As(err, &verr) {
+				result.Errors = verr
+			} else {
+				result.Error = err.Error()
+			}
+		}
+		result.Unknown = config.UnknownKeys(readConfigFile())
+
+		if viper.GetBool("json") {
+			printJSON(result)
+		} else {
+			for _, fe := range result.Errors {
+				color.Red("✗ %s", fe)
+			}
+			if result.Error != "" {
+				color.Red("✗ %s", result.Error)
+			}
+			for _, key := range result.Unknown {
+				color.Yellow("! %s: unknown setting", key)
+			}
+			if result.Valid {
+				color.Green("✓ %s is valid", result.File)
+			}
+		}
+		if !result.Valid {
+			os.Exit(1)
+		}
+	},
+}
+
 func init() {
 	rootCmd.AddCommand(configCmd)
 	configCmd.AddCommand(configSetCmd)
 	configCmd.AddCommand(configShowCmd)
+	configCmd.AddCommand(configValidateCmd)
 
 	configSetCmd.Flags().String("key", "", "API key")
 	configSetCmd.Flags().String("endpoint", "", "API endpoint")
+	configSetCmd.Flags().String("storage-access-key", "", "storage (S3) access key")
+	configSetCmd.Flags().String("storage-secret-key", "", "storage (S3) secret key")
 }
@@ -0,0 +1,138 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/darkstorage/cli/internal/config"
+	"github.com/darkstorage/cli/internal/credentials"
+	"github.com/spf13/viper"
+)
+
+var (
+	credentialStoreOnce sync.Once
+	sharedStore         credentials.Store
+)
+
+// credentialStore returns the store for secrets: the encrypted file in
+// ~/.darkstorage, or the helper named by credentials.helper
+func credentialStore() credentials.Store {
+	credentialStoreOnce.Do(func() {
+		sharedStore = credentials.Open(credentials.Options{
+			Dir:        configDir(),
+			Helper:     viper.GetString("credentials.helper"),
+			Passphrase: os.Getenv(credentials.PassphraseEnv),
+		})
+	})
+	return sharedStore
+}
+
+// configDir returns ~/.darkstorage
+func configDir() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ".darkstorage"
+	}
+	return filepath.Join(home, ".darkstorage")
+}
+
+// configFilePath returns the config file in use, or where it will be
+// created
+func configFilePath() string {
+	if configFile := viper.ConfigFileUsed(); configFile != "" {
+		return configFile
+	}
+	return filepath.Join(configDir(), "config.yaml")
+}
+
+// activeProfile returns the selected profile, or "" for the top-level
+// settings
+func activeProfile() string {
+	return config.ActiveProfile(viper.GetViper())
+}
+
+// loadCredentials moves any plaintext secrets out of the config file, then
+// fills in secrets not given by flags, environment or config from the
+// credential store
+func loadCredentials() {
+	store := credentialStore()
+
+	if configFile := viper.ConfigFileUsed(); configFile != "" {
+		moved, err := credentials.MigrateConfig(store, configFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: could not move credentials out of %s: %v\n", configFile, err)
+		} else if len(moved) > 0 {
+			fmt.Fprintf(os.Stderr, "Moved %s from %s to the credential store (%s)\n",
+				strings.Join(moved, ", "), configFile, store)
+		}
+	}
+
+	if err := credentials.Load(viper.GetViper(), store, activeProfile()); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: reading credentials: %v\n", err)
+	}
+}
+
+// saveCredentials sets the given keys for this run and persists them in
+// the active profile. See saveProfileSettings.
+func saveCredentials(values map[string]string) error {
+	settings := make(map[string]interface{}, len(values))
+	for key, value := range values {
+		settings[key] = value
+	}
+	return saveProfileSettings(activeProfile(), settings)
+}
+
+// saveProfileSettings persists settings for profile ("" for the top-level
+// settings): secrets go to the credential store and everything else into
+// the config file. Only these keys are written, so flags like --trace or
+// --json used on this run aren't persisted with them. Empty secrets are
+// erased.
+func saveProfileSettings(profile string, values map[string]interface{}) error {
+	store := credentialStore()
+	settings := make(map[string]interface{})
+	for key, value := range values {
+		if profile == activeProfile() {
+			viper.Set(key, value)
+		}
+		fullKey := config.ProfileKey(profile, key)
+		if !credentials.IsSecret(key) {
+			settings[fullKey] = value
+			continue
+		}
+		if err := credentials.Set(store, credentials.StoreKey(profile, key), fmt.Sprint(value)); err != nil {
+			return fmt.Errorf("saving %s: %w", key, err)
+		}
+	}
+	return writeConfigValues(settings)
+}
+
+// writeConfigValues merges values into the config file, keeping its
+// comments and layout
+func writeConfigValues(values map[string]interface{}) error {
+	if len(values) == 0 {
+		return nil
+	}
+
+	file, err := config.OpenYAML(configFilePath())
+	if err != nil {
+		return err
+	}
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if err := file.Set(key, values[key]); err != nil {
+			return err
+		}
+	}
+	if err := file.Save(); err != nil {
+		return fmt.Errorf("writing config file: %w", err)
+	}
+	return nil
+}
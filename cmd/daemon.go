@@ -0,0 +1,224 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/darkstorage/cli/internal/config"
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+const (
+	daemonBinary = "darkstorage-daemon"
+	serviceName  = "darkstorage-daemon"
+)
+
+var daemonCmd = &cobra.Command{
+	Use:   "daemon",
+	Short: "Manage the sync daemon process",
+	Long: `Commands for running the sync daemon as a service. Use 'darkstorage sync'
+to control what the running daemon does.`,
+}
+
+var daemonInstallServiceCmd = &cobra.Command{
+	Use:   "install-service",
+	Short: "Install the daemon as a systemd user service",
+	Long: `Write a systemd user unit that runs the sync daemon while you are logged
+in, restarting it if it crashes or stops answering.
+
+With socket activation (the default) systemd also creates the daemon's IPC
+socket, so commands sent before the daemon is up wait for it instead of
+failing. The daemon reports readiness and status to systemd and pings its
+watchdog; a daemon that hangs for longer than --watchdog is restarted.
+
+Examples:
+  darkstorage daemon install-service --now
+  darkstorage daemon install-service --watchdog 2m --socket-activation=false
+  systemctl --user status darkstorage-daemon`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		if runtime.GOOS != "linux" {
+			color.Red("Error: systemd services are only supported on Linux")
+			os.Exit(1)
+		}
+
+		daemonPath, _ := cmd.Flags().GetString("daemon-path")
+		watchdog, _ := cmd.Flags().GetDuration("watchdog")
+		socketActivation, _ := cmd.Flags().GetBool("socket-activation")
+		now, _ := cmd.Flags().GetBool("now")
+		force, _ := cmd.Flags().GetBool("force")
+
+		if daemonPath == "" {
+			var err error
+			if daemonPath, err = findDaemonBinary(); err != nil {
+				color.Red("Error: %v", err)
+				os.Exit(1)
+			}
+		}
+		daemonPath, err := filepath.Abs(daemonPath)
+		if err != nil {
+			color.Red("Error: %v", err)
+			os.Exit(1)
+		}
+
+		unitDir, err := systemdUserDir()
+		if err != nil {
+			color.Red("Error: %v", err)
+			os.Exit(1)
+		}
+		dataDir, err := config.GetDefaultDataDir()
+		if err != nil {
+			color.Red("Error: %v", err)
+			os.Exit(1)
+		}
+
+		units := map[string]string{
+			serviceName + ".service": serviceUnit(daemonPath, watchdog, socketActivation),
+		}
+		if socketActivation {
+			units[serviceName+".socket"] = socketUnit(filepath.Join(dataDir, "daemon.sock"))
+		}
+
+		if err := os.MkdirAll(unitDir, 0755); err != nil {
+			color.Red("Error: %v", err)
+			os.Exit(1)
+		}
+		for _, name := range []string{serviceName + ".service", serviceName + ".socket"} {
+			unit, ok := units[name]
+			if !ok {
+				continue
+			}
+			path := filepath.Join(unitDir, name)
+			if _, err := os.Stat(path); err == nil && !force {
+				color.Red("Error: %s already exists (use --force to replace it)", path)
+				os.Exit(1)
+			}
+			if err := os.WriteFile(path, []byte(unit), 0644); err != nil {
+				color.Red("Error: %v", err)
+				os.Exit(1)
+			}
+			color.Green("✓ Wrote %s", path)
+		}
+
+		enable := []string{"--user", "enable", "--now", serviceName + ".service"}
+		if socketActivation {
+			enable = append(enable, serviceName+".socket")
+		}
+
+		if !now {
+			fmt.Println("\nStop any daemon started by hand, then enable the service with:")
+			fmt.Println("  systemctl --user daemon-reload")
+			fmt.Printf("  systemctl %s\n", strings.Join(enable, " "))
+			return
+		}
+
+		if client, err := newDaemonClient(); err == nil {
+			if _, err := client.GetStatus(); err == nil {
+				color.Red("Error: a daemon is already running; stop it first with '%s stop'", daemonBinary)
+				os.Exit(1)
+			}
+		}
+		for _, systemctlArgs := range [][]string{{"--user", "daemon-reload"}, enable} {
+			out, err := exec.Command("systemctl", systemctlArgs...).CombinedOutput()
+			if err != nil {
+				color.Red("Error: systemctl %s: %v\n%s", strings.Join(systemctlArgs, " "), err, out)
+				os.Exit(1)
+			}
+		}
+		color.Green("✓ Service enabled and started")
+		fmt.Printf("Check it with: systemctl --user status %s\n", serviceName)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(daemonCmd)
+	daemonCmd.AddCommand(daemonInstallServiceCmd)
+
+	daemonInstallServiceCmd.Flags().String("daemon-path", "", "daemon executable (default: next to darkstorage, or in PATH)")
+	daemonInstallServiceCmd.Flags().Duration("watchdog", time.Minute, "restart the daemon if it hangs this long, 0 to disable")
+	daemonInstallServiceCmd.Flags().Bool("socket-activation", true, "let systemd create the IPC socket")
+	daemonInstallServiceCmd.Flags().Bool("now", false, "enable and start the service with systemctl")
+	daemonInstallServiceCmd.Flags().Bool("force", false, "replace existing unit files")
+}
+
+// findDaemonBinary looks for the daemon next to this executable, then in
+// PATH
+func findDaemonBinary() (string, error) {
+	if exe, err := os.Executable(); err == nil {
+		candidate := filepath.Join(filepath.Dir(exe), daemonBinary)
+		if _, err := os.Stat(candidate); err == nil {
+			return candidate, nil
+		}
+	}
+	path, err := exec.LookPath(daemonBinary)
+	if err != nil {
+		return "", fmt.Errorf("%s not found next to darkstorage or in PATH (use --daemon-path)", daemonBinary)
+	}
+	return path, nil
+}
+
+// systemdUserDir returns where user units are installed
+func systemdUserDir() (string, error) {
+	if dir := os.Getenv("XDG_CONFIG_HOME"); dir != "" {
+		return filepath.Join(dir, "systemd", "user"), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", errors.New("cannot find home directory")
+	}
+	return filepath.Join(home, ".config", "systemd", "user"), nil
+}
+
+func serviceUnit(daemonPath string, watchdog time.Duration, socketActivation bool) string {
+	var b strings.Builder
+	b.WriteString("[Unit]\n")
+	b.WriteString("Description=Dark Storage sync daemon\n")
+	if socketActivation {
+		b.WriteString("Requires=" + serviceName + ".socket\n")
+		b.WriteString("After=" + serviceName + ".socket\n")
+	}
+
+	b.WriteString("\n[Service]\n")
+	b.WriteString("Type=notify\n")
+	b.WriteString("ExecStart=" + systemdQuote(daemonPath) + " start --foreground\n")
+	b.WriteString("ExecReload=/bin/kill -HUP $MAINPID\n")
+	b.WriteString("Restart=on-failure\n")
+	b.WriteString("RestartSec=5\n")
+	if watchdog > 0 {
+		fmt.Fprintf(&b, "WatchdogSec=%d\n", int(watchdog.Round(time.Second).Seconds()))
+	}
+	// Leave time to finish transfers in flight before systemd kills it
+	stopTimeout := viper.GetDuration("daemon.shutdown_timeout") + 15*time.Second
+	fmt.Fprintf(&b, "TimeoutStopSec=%d\n", int(stopTimeout.Seconds()))
+
+	b.WriteString("\n[Install]\n")
+	b.WriteString("WantedBy=default.target\n")
+	return b.String()
+}
+
+func socketUnit(socketPath string) string {
+	var b strings.Builder
+	b.WriteString("[Unit]\n")
+	b.WriteString("Description=Dark Storage sync daemon socket\n")
+	b.WriteString("\n[Socket]\n")
+	b.WriteString("ListenStream=" + strings.ReplaceAll(socketPath, "%", "%%") + "\n")
+	b.WriteString("SocketMode=0600\n")
+	b.WriteString("\n[Install]\n")
+	b.WriteString("WantedBy=sockets.target\n")
+	return b.String()
+}
+
+// systemdQuote quotes an ExecStart= argument, escaping the specifiers
+// systemd would otherwise expand
+func systemdQuote(s string) string {
+	s = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "%", "%%").Replace(s)
+	return `"` + s + `"`
+}
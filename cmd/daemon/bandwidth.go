@@ -0,0 +1,173 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/darkstorage/cli/internal/bandwidth"
+	"github.com/darkstorage/cli/internal/config"
+	"github.com/darkstorage/cli/internal/ipc"
+)
+
+// bandwidthShaper holds the limiters every client shares at the rates the
+// schedule gives for the current time, or lower while throttled
+type bandwidthShaper struct {
+	up   *bandwidth.Limiter
+	down *bandwidth.Limiter
+
+	mu       sync.Mutex
+	schedule bandwidth.Schedule
+	// rule is the schedule window in force, -1 for the defaults
+	rule int
+	// ceiling caps the schedule's limits; 0 leaves them as they are
+	ceiling bandwidth.Limits
+}
+
+func newBandwidthShaper() *bandwidthShaper {
+	return &bandwidthShaper{
+		up:   bandwidth.NewLimiter(0),
+		down: bandwidth.NewLimiter(0),
+		rule: -1,
+	}
+}
+
+// setSchedule replaces the schedule, taking effect at once
+func (s *bandwidthShaper) setSchedule(schedule bandwidth.Schedule) {
+	s.mu.Lock()
+	s.schedule = schedule
+	s.mu.Unlock()
+	s.apply(time.Now())
+}
+
+// setCeiling caps the limits, taking effect at once. The zero Limits
+// remove the cap.
+func (s *bandwidthShaper) setCeiling(ceiling bandwidth.Limits) {
+	s.mu.Lock()
+	s.ceiling = ceiling
+	s.mu.Unlock()
+	s.apply(time.Now())
+}
+
+// apply sets the limiters to the schedule's limits at now, within the
+// ceiling
+func (s *bandwidthShaper) apply(now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	limits, rule := s.schedule.At(now)
+	limits.Up = lowerRate(limits.Up, s.ceiling.Up)
+	limits.Down = lowerRate(limits.Down, s.ceiling.Down)
+	if limits.Up == s.up.Rate() && limits.Down == s.down.Rate() && rule == s.rule {
+		return
+	}
+	s.up.SetRate(limits.Up)
+	s.down.SetRate(limits.Down)
+	s.rule = rule
+	args := []interface{}{"up", rateString(limits.Up), "down", rateString(limits.Down)}
+	if rule >= 0 {
+		// Numbered from 1, as 'sync bandwidth' shows them
+		args = append(args, "rule", rule+1)
+	}
+	slog.Info("bandwidth limits changed", args...)
+}
+
+// current returns the limits in force and the rule they come from
+func (s *bandwidthShaper) current() (bandwidth.Limits, int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return bandwidth.Limits{Up: s.up.Rate(), Down: s.down.Rate()}, s.rule
+}
+
+// follow applies the schedule at the start of every minute, the
+// precision of its rules
+func (s *bandwidthShaper) follow() {
+	for {
+		now := time.Now()
+		time.Sleep(now.Truncate(time.Minute).Add(time.Minute).Sub(now))
+		s.apply(time.Now())
+	}
+}
+
+// bandwidthSchedule turns the configured limits, in KB/s, into a
+// schedule. The configuration has been validated already.
+func bandwidthSchedule(d config.DaemonSettings) bandwidth.Schedule {
+	schedule := bandwidth.Schedule{
+		Default: bandwidth.Limits{Up: int64(d.BandwidthLimitUp) * 1024, Down: int64(d.BandwidthLimitDown) * 1024},
+	}
+	for _, rule := range d.BandwidthSchedule {
+		days, _ := bandwidth.ParseDays(rule.Days)
+		start, _ := bandwidth.ParseClock(rule.Start)
+		end, _ := bandwidth.ParseClock(rule.End)
+		schedule.Windows = append(schedule.Windows, bandwidth.Window{
+			Days:   days,
+			Start:  start,
+			End:    end,
+			Limits: bandwidth.Limits{Up: int64(rule.Up) * 1024, Down: int64(rule.Down) * 1024},
+		})
+	}
+	return schedule
+}
+
+// lowerRate returns the stricter of two rates, 0 being unlimited
+func lowerRate(a, b int64) int64 {
+	if a <= 0 || (b > 0 && b < a) {
+		return b
+	}
+	return a
+}
+
+func rateString(rate int64) string {
+	if rate <= 0 {
+		return "unlimited"
+	}
+	return fmt.Sprintf("%d KB/s", rate/1024)
+}
+
+func (d *Daemon) handleGetBandwidth(data json.RawMessage) (interface{}, error) {
+	d.configMu.RLock()
+	cfg := d.config
+	d.configMu.RUnlock()
+
+	limits, rule := d.bandwidth.current()
+	throttled := ""
+	if action, reason := d.throttle.current(); action != config.ThrottleNone {
+		throttled = reason
+	}
+	resp := &ipc.GetBandwidthResponse{
+		Up:          int(limits.Up / 1024),
+		Down:        int(limits.Down / 1024),
+		Rule:        rule,
+		Throttled:   throttled,
+		DefaultUp:   cfg.Daemon.BandwidthLimitUp,
+		DefaultDown: cfg.Daemon.BandwidthLimitDown,
+		Schedule:    []ipc.BandwidthRule{},
+		Folders:     []ipc.FolderBandwidth{},
+	}
+	for _, r := range cfg.Daemon.BandwidthSchedule {
+		resp.Schedule = append(resp.Schedule, ipc.BandwidthRule{
+			Days:  r.Days,
+			Start: r.Start,
+			End:   r.End,
+			Up:    r.Up,
+			Down:  r.Down,
+		})
+	}
+
+	folders, err := d.db.ListSyncFolders()
+	if err != nil {
+		return nil, err
+	}
+	for _, folder := range folders {
+		if folder.BandwidthLimit != nil && *folder.BandwidthLimit > 0 {
+			resp.Folders = append(resp.Folders, ipc.FolderBandwidth{
+				FolderID:  folder.ID,
+				LocalPath: folder.LocalPath,
+				Limit:     *folder.BandwidthLimit,
+			})
+		}
+	}
+	return resp, nil
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"log/slog"
+	"time"
+
+	"github.com/darkstorage/cli/internal/db"
+	"github.com/darkstorage/cli/internal/ipc"
+	syncpkg "github.com/darkstorage/cli/internal/sync"
+)
+
+// queuePollInterval is how often the queue size is checked for changes
+const queuePollInterval = time.Second
+
+// observers passes the engine's work on to each observer in turn
+type observers []syncpkg.Observer
+
+func (o observers) TransferStarted(op *db.QueueOperation, size int64) {
+	for _, obs := range o {
+		obs.TransferStarted(op, size)
+	}
+}
+
+func (o observers) TransferProgress(op *db.QueueOperation, bytes, size int64) {
+	for _, obs := range o {
+		obs.TransferProgress(op, bytes, size)
+	}
+}
+
+func (o observers) TransferCompleted(op *db.QueueOperation, err error) {
+	for _, obs := range o {
+		obs.TransferCompleted(op, err)
+	}
+}
+
+func (o observers) ConflictCreated(conflict *db.Conflict) {
+	for _, obs := range o {
+		obs.ConflictCreated(conflict)
+	}
+}
+
+func (o observers) FolderPaused(folderID int, reason string) {
+	for _, obs := range o {
+		obs.FolderPaused(folderID, reason)
+	}
+}
+
+// eventPublisher passes the engine's work on to IPC subscribers
+type eventPublisher struct {
+	server *ipc.Server
+}
+
+func transferEvent(op *db.QueueOperation) *ipc.TransferEvent {
+	return &ipc.TransferEvent{
+		FolderID:  op.SyncFolderID,
+		Path:      op.RelativePath,
+		Operation: op.Operation,
+	}
+}
+
+func (p *eventPublisher) TransferStarted(op *db.QueueOperation, size int64) {
+	event := transferEvent(op)
+	event.Size = size
+	p.server.Publish(ipc.TopicTransferStarted, event)
+}
+
+func (p *eventPublisher) TransferProgress(op *db.QueueOperation, bytes, size int64) {
+	event := transferEvent(op)
+	event.Bytes, event.Size = bytes, size
+	p.server.Publish(ipc.TopicTransferProgress, event)
+}
+
+func (p *eventPublisher) TransferCompleted(op *db.QueueOperation, err error) {
+	event := transferEvent(op)
+	if err != nil {
+		event.Error = err.Error()
+	}
+	p.server.Publish(ipc.TopicTransferCompleted, event)
+}
+
+func (p *eventPublisher) ConflictCreated(conflict *db.Conflict) {
+	p.server.Publish(ipc.TopicConflictCreated, &ipc.ConflictEvent{
+		ID:       conflict.ID,
+		FolderID: conflict.SyncFolderID,
+		Path:     conflict.RelativePath,
+	})
+}
+
+func (p *eventPublisher) FolderPaused(folderID int, reason string) {
+	p.server.Publish(ipc.TopicFolderPaused, &ipc.FolderPausedEvent{
+		FolderID: folderID,
+		Reason:   reason,
+	})
+}
+
+// watchQueueSize publishes the queue size whenever it changes. Polling
+// keeps this off the hot path of scans that queue thousands of files.
+func (d *Daemon) watchQueueSize() {
+	ticker := time.NewTicker(queuePollInterval)
+	defer ticker.Stop()
+
+	last := -1
+	for range ticker.C {
+		size, err := d.db.GetQueueSize()
+		if err != nil {
+			slog.Error("failed to get queue size", "error", err)
+			continue
+		}
+		if size != last {
+			last = size
+			d.ipcServer.Publish(ipc.TopicQueueSize, &ipc.QueueSizeEvent{Size: size})
+		}
+	}
+}
@@ -0,0 +1,246 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"strings"
+
+	"github.com/darkstorage/cli/internal/config"
+	"github.com/darkstorage/cli/internal/db"
+	"github.com/darkstorage/cli/internal/ipc"
+	syncpkg "github.com/darkstorage/cli/internal/sync"
+)
+
+// getFolder returns a folder or an error naming the missing ID
+func (d *Daemon) getFolder(id int) (*db.SyncFolder, error) {
+	folder, err := d.db.GetSyncFolder(id)
+	if err != nil {
+		return nil, err
+	}
+	if folder == nil {
+		return nil, &syncpkg.NotFoundError{What: "folder", ID: id}
+	}
+	return folder, nil
+}
+
+// folderStatus describes one folder for the status command. transfers are
+// the engine's running operations.
+func (d *Daemon) folderStatus(folder *db.SyncFolder, transfers []syncpkg.Transfer) (ipc.SyncFolderStatus, error) {
+	status := ipc.SyncFolderStatus{
+		ID:            folder.ID,
+		Name:          filepath.Base(folder.LocalPath),
+		LocalPath:     folder.LocalPath,
+		RemotePath:    folder.RemotePath,
+		Direction:     folder.Direction,
+		Status:        ipc.FolderIdle,
+		Profile:       folder.Profile,
+		ConfigManaged: folder.ConfigManaged,
+	}
+
+	var err error
+	status.FilesPending, status.FilesFailed, err = d.db.FolderQueueCounts(folder.ID)
+	if err != nil {
+		return status, err
+	}
+	if status.LastSync, err = d.db.GetLastSyncTime(folder.ID); err != nil {
+		return status, err
+	}
+	if status.FilesFailed > 0 {
+		if status.ErrorMessage, err = d.db.GetLastQueueError(folder.ID); err != nil {
+			return status, err
+		}
+	}
+
+	running := false
+	for _, t := range transfers {
+		if t.Op.SyncFolderID == folder.ID {
+			running = true
+			break
+		}
+	}
+
+	action, reason := d.throttle.current()
+	switch {
+	case folder.Paused:
+		status.Status = ipc.FolderPaused
+		if folder.PauseReason != nil {
+			status.ErrorMessage = *folder.PauseReason
+		}
+	case !folder.Enabled:
+		status.Status = ipc.FolderDisabled
+	case action == config.ThrottlePause:
+		// Resumes by itself once the conditions pass
+		status.Status = ipc.FolderPaused
+		status.ErrorMessage = reason
+	case running:
+		status.Status = ipc.FolderSyncing
+	case status.FilesPending > 0:
+		status.Status = ipc.FolderPending
+	case status.FilesFailed > 0:
+		status.Status = ipc.FolderError
+	}
+	if action == config.ThrottleSlow && folder.Enabled {
+		status.Throttled = reason
+	}
+	return status, nil
+}
+
+func (d *Daemon) handlePauseFolder(data json.RawMessage) (interface{}, error) {
+	var req ipc.PauseFolderRequest
+	if err := json.Unmarshal(data, &req); err != nil {
+		return nil, err
+	}
+
+	if req.Reason == "" {
+		req.Reason = "paused by user"
+	}
+	if err := d.engine.PauseFolder(req.FolderID, req.Reason); err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+func (d *Daemon) handleUpdateSyncFolder(data json.RawMessage) (interface{}, error) {
+	var req ipc.UpdateSyncFolderRequest
+	if err := json.Unmarshal(data, &req); err != nil {
+		return nil, err
+	}
+
+	folder, err := d.getFolder(req.ID)
+	if err != nil {
+		return nil, err
+	}
+	if folder.ConfigManaged {
+		return nil, fmt.Errorf("%s is declared in config.yaml, change it under sync_folders there", folder.LocalPath)
+	}
+
+	if req.RemotePath != nil {
+		folder.RemotePath = *req.RemotePath
+	}
+	if req.Direction != nil {
+		folder.Direction = *req.Direction
+	}
+	if req.Excludes != nil {
+		folder.ExcludePatterns = strings.Join(*req.Excludes, "\n")
+	}
+	if req.ConflictResolution != nil {
+		folder.ConflictResolution = *req.ConflictResolution
+	}
+	if req.BandwidthLimit != nil {
+		folder.BandwidthLimit = positiveOrNil(*req.BandwidthLimit)
+	}
+	if req.SyncInterval != nil {
+		folder.SyncInterval = positiveOrNil(*req.SyncInterval)
+	}
+	if req.IncludePaths != nil {
+		folder.IncludePaths = syncpkg.FormatIncludePaths(*req.IncludePaths)
+	}
+	if req.Placeholders != nil {
+		folder.Placeholders = *req.Placeholders
+	}
+	if req.Enabled != nil {
+		folder.Enabled = *req.Enabled
+	}
+	if req.Profile != nil {
+		folder.Profile = *req.Profile
+		if folder.Profile == config.DefaultProfile {
+			folder.Profile = ""
+		}
+		if _, err := loadProfileConfig(d.dataDir, folder.Profile); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := validateFolder(folder); err != nil {
+		return nil, err
+	}
+	if err := d.db.UpdateSyncFolder(folder); err != nil {
+		return nil, err
+	}
+	d.refreshFolder(folder)
+
+	return nil, nil
+}
+
+// refreshFolder applies a folder's changed settings to the watcher and
+// resyncs it
+func (d *Daemon) refreshFolder(folder *db.SyncFolder) {
+	if !folder.Enabled {
+		if err := d.watcher.RemoveFolder(folder.ID); err != nil {
+			slog.Error("failed to stop watching folder", "path", folder.LocalPath, "error", err)
+		}
+		return
+	}
+	if err := d.watcher.AddFolder(folder); err != nil {
+		slog.Error("failed to watch folder", "path", folder.LocalPath, "error", err)
+		return
+	}
+	go func(id int, path string) {
+		if err := d.engine.SyncFolder(id); err != nil {
+			slog.Error("sync failed", "path", path, "error", err)
+		}
+	}(folder.ID, folder.LocalPath)
+}
+
+func (d *Daemon) handleFolderStats(data json.RawMessage) (interface{}, error) {
+	var req ipc.FolderStatsRequest
+	if err := json.Unmarshal(data, &req); err != nil {
+		return nil, err
+	}
+
+	if _, err := d.getFolder(req.FolderID); err != nil {
+		return nil, err
+	}
+	stats, err := d.db.GetFolderStats(req.FolderID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ipc.FolderStatsResponse{
+		FolderID:         req.FolderID,
+		Files:            stats.Files,
+		SyncedFiles:      stats.SyncedFiles,
+		PendingFiles:     stats.PendingFiles,
+		ErrorFiles:       stats.ErrorFiles,
+		DehydratedFiles:  stats.DehydratedFiles,
+		LocalBytes:       stats.LocalBytes,
+		QueuedOperations: stats.QueuedOperations,
+		FailedOperations: stats.FailedOperations,
+		Conflicts:        stats.Conflicts,
+		Uploads:          stats.Uploads,
+		Downloads:        stats.Downloads,
+		Deletes:          stats.Deletes,
+		Errors:           stats.Errors,
+		BytesTransferred: stats.BytesTransferred,
+		LastSync:         stats.LastSync,
+	}, nil
+}
+
+// validateFolder checks the settings config.yaml would check for a folder
+// declared there
+func validateFolder(folder *db.SyncFolder) error {
+	switch folder.Direction {
+	case "", "bidirectional", "upload_only", "download_only":
+	default:
+		return ipc.Errorf(ipc.CodeInvalidParams, "direction %q is not bidirectional, upload_only or download_only", folder.Direction)
+	}
+	switch folder.ConflictResolution {
+	case "", "keep_local", "keep_remote", "keep_both", "manual":
+	default:
+		return ipc.Errorf(ipc.CodeInvalidParams, "conflict resolution %q is not keep_local, keep_remote, keep_both or manual", folder.ConflictResolution)
+	}
+	if folder.RemotePath == "" {
+		return ipc.Errorf(ipc.CodeInvalidParams, "remote path is required")
+	}
+	return nil
+}
+
+func positiveOrNil(n int) *int {
+	if n <= 0 {
+		return nil
+	}
+	return &n
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/darkstorage/cli/internal/config"
+	"github.com/darkstorage/cli/internal/credentials"
+	"github.com/darkstorage/cli/internal/db"
+	"github.com/darkstorage/cli/internal/hooks"
+	"github.com/darkstorage/cli/internal/ipc"
+)
+
+// hookShutdownTimeout bounds how long shutdown waits for hooks to finish
+const hookShutdownTimeout = 10 * time.Second
+
+// syncSettleDelay is how long a folder's queue must stay empty before
+// sync_complete is sent, so operations finishing together are reported
+// once
+const syncSettleDelay = 2 * time.Second
+
+// hookObserver turns the engine's work into hook events
+type hookObserver struct {
+	d *Daemon
+
+	mu sync.Mutex
+	// runs counts each folder's operations since its queue last emptied
+	runs map[int]*hooks.SyncSummary
+	// settling holds the timers that send sync_complete
+	settling map[int]*time.Timer
+}
+
+func newHookObserver(d *Daemon) *hookObserver {
+	return &hookObserver{
+		d:        d,
+		runs:     make(map[int]*hooks.SyncSummary),
+		settling: make(map[int]*time.Timer),
+	}
+}
+
+// event starts an event about a folder, naming it by its local path
+func (o *hookObserver) event(eventType string, folderID int) *hooks.Event {
+	event := &hooks.Event{Type: eventType, Time: time.Now(), FolderID: folderID}
+	if folder, err := o.d.db.GetSyncFolder(folderID); err == nil && folder != nil {
+		event.Folder = folder.LocalPath
+	}
+	return event
+}
+
+func (o *hookObserver) TransferStarted(*db.QueueOperation, int64)         {}
+func (o *hookObserver) TransferProgress(*db.QueueOperation, int64, int64) {}
+
+func (o *hookObserver) TransferCompleted(op *db.QueueOperation, err error) {
+	cancelled := err != nil && errorClass(err) == "cancelled"
+
+	o.mu.Lock()
+	run, ok := o.runs[op.SyncFolderID]
+	if !ok {
+		run = &hooks.SyncSummary{}
+		o.runs[op.SyncFolderID] = run
+	}
+	switch {
+	case err == nil:
+		run.Completed++
+	case cancelled:
+		run.Cancelled++
+	default:
+		run.Failed++
+	}
+	o.mu.Unlock()
+
+	if err != nil && !cancelled {
+		event := o.event(hooks.EventTransferFailed, op.SyncFolderID)
+		event.Path, event.Operation, event.Error = op.RelativePath, op.Operation, err.Error()
+		o.d.hooks.Fire(event)
+	}
+
+	o.settle(op.SyncFolderID)
+}
+
+// settle sends sync_complete for a folder once nothing of it is left
+// waiting in the queue for syncSettleDelay
+func (o *hookObserver) settle(folderID int) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if timer, ok := o.settling[folderID]; ok {
+		timer.Reset(syncSettleDelay)
+		return
+	}
+	o.settling[folderID] = time.AfterFunc(syncSettleDelay, func() {
+		waiting, _, err := o.d.db.FolderQueueCounts(folderID)
+
+		o.mu.Lock()
+		delete(o.settling, folderID)
+		// Still syncing; the next operation to finish tries again
+		if err != nil || waiting > 0 {
+			o.mu.Unlock()
+			return
+		}
+		run := o.runs[folderID]
+		delete(o.runs, folderID)
+		o.mu.Unlock()
+
+		event := o.event(hooks.EventSyncComplete, folderID)
+		event.Summary = run
+		o.d.hooks.Fire(event)
+	})
+}
+
+func (o *hookObserver) ConflictCreated(conflict *db.Conflict) {
+	event := o.event(hooks.EventConflict, conflict.SyncFolderID)
+	event.Path, event.ConflictID = conflict.RelativePath, conflict.ID
+	o.d.hooks.Fire(event)
+}
+
+func (o *hookObserver) FolderPaused(folderID int, reason string) {
+	event := o.event(hooks.EventFolderPaused, folderID)
+	event.Reason = reason
+	o.d.hooks.Fire(event)
+}
+
+// hookList converts the configured hooks, filling in defaults and the
+// webhook secrets kept in the credential store
+func (d *Daemon) hookList(cfg *config.DaemonConfig) []hooks.Hook {
+	var store credentials.Store
+	if v, err := config.NewViper(d.dataDir); err != nil {
+		slog.Error("not reading hook secrets", "error", err)
+	} else {
+		store = credentialStore(d.dataDir, v)
+	}
+
+	list := make([]hooks.Hook, 0, len(cfg.Hooks))
+	for _, hc := range cfg.Hooks {
+		hook := hooks.Hook{
+			Name:    hc.Name,
+			Events:  hc.Events,
+			Command: hc.Command,
+			URL:     hc.URL,
+			// Left in config.yaml only if moving it to the store failed
+			Secret:  hc.Secret,
+			Timeout: hc.Timeout,
+			Retries: hooks.DefaultRetries,
+		}
+		if hook.Secret == "" && hook.URL != "" && store != nil {
+			secret, err := credentials.HookSecret(store, hc.Name)
+			if err != nil {
+				slog.Error("failed to read hook secret", "hook", hc.Name, "store", store.String(), "error", err)
+			}
+			hook.Secret = secret
+		}
+		if hc.Retries != nil {
+			hook.Retries = *hc.Retries
+		}
+		list = append(list, hook)
+	}
+	return list
+}
+
+func hookEntry(hook hooks.Hook) ipc.HookEntry {
+	timeout := hook.Timeout
+	if timeout == 0 {
+		timeout = hooks.DefaultTimeout
+	}
+	entry := ipc.HookEntry{
+		Name:    hook.Name,
+		Events:  hook.Events,
+		Command: hook.Command,
+		URL:     hook.URL,
+		Signed:  hook.Secret != "",
+		Timeout: timeout.String(),
+	}
+	if hook.URL != "" {
+		entry.Retries = hook.Retries
+	}
+	return entry
+}
+
+func (d *Daemon) handleListHooks(data json.RawMessage) (interface{}, error) {
+	entries := []ipc.HookEntry{}
+	for _, hook := range d.hooks.Hooks() {
+		entries = append(entries, hookEntry(hook))
+	}
+	return &ipc.ListHooksResponse{Hooks: entries}, nil
+}
+
+// handleTestHook sends a test event to one hook and reports whether it
+// got through
+func (d *Daemon) handleTestHook(data json.RawMessage) (interface{}, error) {
+	var req ipc.TestHookRequest
+	if err := json.Unmarshal(data, &req); err != nil {
+		return nil, err
+	}
+
+	for _, hook := range d.hooks.Hooks() {
+		if hook.Name != req.Name {
+			continue
+		}
+		slog.Info("testing hook", "hook", hook.Name)
+		if err := d.hooks.Test(context.Background(), hook); err != nil {
+			return nil, ipc.Errorf(ipc.CodeFailed, "hook %s failed: %v", hook.Name, err)
+		}
+		return nil, nil
+	}
+	return nil, ipc.Errorf(ipc.CodeNotFound, "no hook named %q", req.Name)
+}
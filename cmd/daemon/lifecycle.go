@@ -0,0 +1,237 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/darkstorage/cli/internal/config"
+	"github.com/darkstorage/cli/internal/ipc"
+)
+
+// startupTimeout bounds how long `start` waits for a background daemon to
+// answer on its socket
+const startupTimeout = 15 * time.Second
+
+var errLocked = errors.New("locked by another process")
+
+// pidFile is kept locked by the running daemon, so a second daemon on the
+// same data directory refuses to start rather than share the database. The
+// file is left in place on exit; an unlocked PID file is stale.
+type pidFile struct {
+	f *os.File
+}
+
+func acquirePIDFile(path string) (*pidFile, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, err
+	}
+	if err := lockFile(f); err != nil {
+		f.Close()
+		if errors.Is(err, errLocked) {
+			pid, _ := readPID(path)
+			return nil, fmt.Errorf("daemon already running (pid %d)", pid)
+		}
+		return nil, err
+	}
+
+	if err := f.Truncate(0); err != nil {
+		f.Close()
+		return nil, err
+	}
+	if _, err := f.WriteAt([]byte(strconv.Itoa(os.Getpid())+"\n"), 0); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &pidFile{f: f}, nil
+}
+
+func (p *pidFile) release() {
+	p.f.Truncate(0)
+	p.f.Close()
+}
+
+func readPID(path string) (int, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(strings.TrimSpace(string(data)))
+}
+
+// runningPID returns the PID of the daemon holding the lock on path
+func runningPID(path string) (int, bool) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, false
+	}
+	defer f.Close()
+
+	if err := lockFile(f); !errors.Is(err, errLocked) {
+		return 0, false
+	}
+	pid, err := readPID(path)
+	return pid, err == nil
+}
+
+// waitForExit waits up to timeout for the daemon to release its PID file
+func waitForExit(path string, timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		if _, running := runningPID(path); !running {
+			return true
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	return false
+}
+
+// lifecycleConfig holds what start and stop need to find a daemon
+type lifecycleConfig struct {
+	dataDir         string
+	socketPath      string
+	pidFile         string
+	logFile         string
+	shutdownTimeout time.Duration
+}
+
+// loadLifecycleConfig reads the daemon's files from config.yaml, falling
+// back to the defaults if it is invalid so a running daemon can still be
+// stopped
+func loadLifecycleConfig() *lifecycleConfig {
+	dataDir, err := config.GetDefaultDataDir()
+	if err != nil {
+		log.Fatalf("Failed to get data directory: %v", err)
+	}
+
+	lc := &lifecycleConfig{
+		dataDir:         dataDir,
+		socketPath:      filepath.Join(dataDir, "daemon.sock"),
+		pidFile:         filepath.Join(dataDir, "daemon.pid"),
+		logFile:         filepath.Join(dataDir, "daemon.log"),
+		shutdownTimeout: 30 * time.Second,
+	}
+	cfg, err := config.LoadDaemonConfig()
+	if err != nil {
+		log.Printf("Using default daemon files: %v", err)
+		return lc
+	}
+	lc.pidFile = cfg.Daemon.PIDFile
+	lc.logFile = cfg.Daemon.LogFile
+	lc.shutdownTimeout = cfg.Daemon.ShutdownTimeout
+	return lc
+}
+
+func startDaemon(args []string) {
+	flags := flag.NewFlagSet("start", flag.ExitOnError)
+	foreground := flags.Bool("foreground", false, "stay attached to the terminal and log to stderr")
+	flags.BoolVar(foreground, "f", false, "shorthand for --foreground")
+	flags.Parse(args)
+
+	if *foreground {
+		runDaemon()
+		return
+	}
+	startBackground()
+}
+
+// startBackground starts the daemon as a detached process logging to the
+// configured log file, and waits until it answers on its socket
+func startBackground() {
+	lc := loadLifecycleConfig()
+	if pid, running := runningPID(lc.pidFile); running {
+		fmt.Printf("Daemon is already running (pid %d)\n", pid)
+		os.Exit(1)
+	}
+
+	exe, err := os.Executable()
+	if err != nil {
+		log.Fatalf("Failed to find daemon executable: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(lc.logFile), 0700); err != nil {
+		log.Fatalf("Failed to create log directory: %v", err)
+	}
+	logFile, err := os.OpenFile(lc.logFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		log.Fatalf("Failed to open log file: %v", err)
+	}
+
+	cmd := exec.Command(exe, "start", "--foreground")
+	cmd.Stdout = logFile
+	cmd.Stderr = logFile
+	detach(cmd)
+	err = cmd.Start()
+	logFile.Close()
+	if err != nil {
+		log.Fatalf("Failed to start daemon: %v", err)
+	}
+
+	exited := make(chan error, 1)
+	go func() { exited <- cmd.Wait() }()
+
+	client := ipc.NewClient(lc.socketPath)
+	deadline := time.Now().Add(startupTimeout)
+	for {
+		select {
+		case err := <-exited:
+			log.Fatalf("Daemon exited during startup (%v), see %s", err, lc.logFile)
+		case <-time.After(200 * time.Millisecond):
+		}
+		if _, err := client.GetStatus(); err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			log.Fatalf("Daemon did not start within %s, see %s", startupTimeout, lc.logFile)
+		}
+	}
+
+	fmt.Printf("Dark Storage daemon started (pid %d)\n", cmd.Process.Pid)
+	fmt.Printf("Log file: %s\n", lc.logFile)
+}
+
+// stopDaemon asks the daemon to shut down over its socket, falling back to
+// SIGTERM, and waits for it to finish transfers in flight and exit
+func stopDaemon() {
+	lc := loadLifecycleConfig()
+	pid, running := runningPID(lc.pidFile)
+	if !running {
+		fmt.Println("Daemon is not running")
+		return
+	}
+
+	fmt.Printf("Stopping daemon (pid %d)...\n", pid)
+	if err := ipc.NewClient(lc.socketPath).Shutdown(); err != nil {
+		log.Printf("Shutdown request failed (%v), sending SIGTERM", err)
+		if err := terminate(pid); err != nil {
+			log.Fatalf("Failed to stop daemon: %v", err)
+		}
+	}
+
+	// Allow for the daemon's own drain timeout before interrupting it
+	if !waitForExit(lc.pidFile, lc.shutdownTimeout+10*time.Second) {
+		log.Printf("Daemon still running, interrupting transfers")
+		if err := terminate(pid); err != nil {
+			log.Fatalf("Failed to stop daemon: %v", err)
+		}
+		if !waitForExit(lc.pidFile, 10*time.Second) {
+			log.Fatalf("Daemon (pid %d) did not stop", pid)
+		}
+	}
+	fmt.Println("Daemon stopped")
+}
+
+func restartDaemon() {
+	stopDaemon()
+	startBackground()
+}
@@ -2,43 +2,80 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"log/slog"
+	"net/http"
 	"os"
 	"os/signal"
 	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
 	"syscall"
 	"time"
 
 	"github.com/darkstorage/cli/internal/api"
 	"github.com/darkstorage/cli/internal/config"
+	"github.com/darkstorage/cli/internal/credentials"
 	"github.com/darkstorage/cli/internal/db"
+	"github.com/darkstorage/cli/internal/fsmeta"
+	"github.com/darkstorage/cli/internal/hooks"
 	"github.com/darkstorage/cli/internal/ipc"
+	"github.com/darkstorage/cli/internal/logging"
+	"github.com/darkstorage/cli/internal/storage"
 	syncpkg "github.com/darkstorage/cli/internal/sync"
+	"github.com/darkstorage/cli/internal/systemd"
 	"github.com/spf13/viper"
 )
 
 type Daemon struct {
 	db        *db.DB
+	dataDir   string
 	client    *api.Client
+	clientsMu sync.Mutex
+	clients   map[string]*api.Client
 	engine    *syncpkg.Engine
 	watcher   *Watcher
 	ipcServer *ipc.Server
-	config    *config.DaemonConfig
 	startTime time.Time
+	metrics   *daemonMetrics
+	hooks     *hooks.Dispatcher
+	bandwidth *bandwidthShaper
+	throttle  *throttler
+
+	// metricsMu guards the metrics listener, which reload may move
+	metricsMu     sync.Mutex
+	metricsServer *http.Server
+	metricsAddr   string
+
+	// configMu guards config, which reload replaces
+	configMu sync.RWMutex
+	config   *config.DaemonConfig
+	reloadMu sync.Mutex
+	// configData is config.yaml as last loaded
+	configData []byte
+
+	shutdown     chan struct{}
+	shutdownOnce sync.Once
 }
 
 func main() {
 	if len(os.Args) > 1 {
 		switch os.Args[1] {
 		case "start":
-			runDaemon()
+			startDaemon(os.Args[2:])
 		case "stop":
 			stopDaemon()
+		case "restart":
+			restartDaemon()
 		case "status":
 			daemonStatus()
+		case "resume":
+			resumeFolder(os.Args[2:])
 		default:
-			fmt.Println("Usage: darkstorage-daemon {start|stop|status}")
+			fmt.Println("Usage: darkstorage-daemon {start [--foreground]|stop|restart|status|resume <folder-id> [--discard-pending]}")
 			os.Exit(1)
 		}
 	} else {
@@ -47,97 +84,350 @@ func main() {
 }
 
 func runDaemon() {
+	if warning := config.MoveLegacyEnv(); warning != "" {
+		log.Printf("Warning: %s", warning)
+	}
 	dataDir, err := config.GetDefaultDataDir()
 	if err != nil {
 		log.Fatalf("Failed to get data directory: %v", err)
 	}
 
+	migrated, err := config.MigrateLegacy(dataDir, filepath.Join(dataDir, "config.yaml"))
+	if err != nil {
+		log.Printf("Failed to migrate old config files: %v", err)
+	}
+	for _, path := range migrated {
+		log.Printf("Merged %s into config.yaml", path)
+	}
+	// The merge leaves config.json's tokens in config.yaml; the CLI moves
+	// them too, but the daemon may run first
+	if moved, err := migrateCredentials(dataDir); err != nil {
+		log.Printf("Failed to move credentials out of config.yaml: %v", err)
+	} else if len(moved) > 0 {
+		log.Printf("Moved %s from config.yaml to the credential store", strings.Join(moved, ", "))
+	}
+
 	cfg, err := config.LoadDaemonConfig()
 	if err != nil {
-		log.Printf("Failed to load config, using defaults: %v", err)
-		cfg = &config.DaemonConfig{}
+		log.Fatalf("Failed to load config: %v", err)
 	}
 
+	if err := logging.Configure(logOptions(cfg)); err != nil {
+		log.Printf("Logging to stderr only: %v", err)
+	}
+	defer logging.Close()
+	slog.SetDefault(logging.For(logging.Daemon))
+
+	pid, err := acquirePIDFile(cfg.Daemon.PIDFile)
+	if err != nil {
+		fatal("failed to start", "error", err)
+	}
+	defer pid.release()
+
 	database, err := db.New(dataDir)
 	if err != nil {
-		log.Fatalf("Failed to initialize database: %v", err)
+		fatal("failed to initialize database", "error", err)
 	}
 	defer database.Close()
 
-	apiKey := viper.GetString("api_key")
-	endpoint := viper.GetString("endpoint")
-	if endpoint == "" {
-		endpoint = "https://api.darkstorage.io"
+	daemon := &Daemon{
+		db:        database,
+		config:    cfg,
+		dataDir:   dataDir,
+		clients:   make(map[string]*api.Client),
+		startTime: time.Now(),
+		shutdown:  make(chan struct{}),
 	}
+	daemon.metrics = newDaemonMetrics(daemon.startTime)
+	daemon.hooks = hooks.NewDispatcher()
+	defer daemon.hooks.Stop(hookShutdownTimeout)
+	daemon.bandwidth = newBandwidthShaper()
+	daemon.throttle = newThrottler(daemon)
 
-	client := api.NewClient(endpoint, apiKey)
+	client, err := daemon.clientForProfile("")
+	if err != nil {
+		fatal("failed to load configuration", "error", err)
+	}
 	engine := syncpkg.NewEngine(database, client)
+	engine.SetClientResolver(daemon.clientForProfile)
 
 	socketPath := filepath.Join(dataDir, "daemon.sock")
 	ipcServer := ipc.NewServer(socketPath)
+	engine.SetObserver(observers{&eventPublisher{server: ipcServer}, daemon.metrics, newHookObserver(daemon)})
 
-	daemon := &Daemon{
-		db:        database,
-		client:    client,
-		engine:    engine,
-		ipcServer: ipcServer,
-		config:    cfg,
-		startTime: time.Now(),
-	}
+	daemon.client = client
+	daemon.engine = engine
+	daemon.ipcServer = ipcServer
+	daemon.applySettings(cfg)
+	defer daemon.serveMetrics("")
 
 	daemon.setupIPCHandlers()
 
-	if err := ipcServer.Start(); err != nil {
-		log.Fatalf("Failed to start IPC server: %v", err)
+	// Under systemd socket activation the socket already exists
+	listeners, err := systemd.Listeners()
+	if err != nil {
+		fatal("failed to start IPC server", "error", err)
+	}
+	if len(listeners) > 0 {
+		ipcServer.StartListener(listeners[0])
+		for _, l := range listeners[1:] {
+			l.Close()
+		}
+	} else if err := ipcServer.Start(); err != nil {
+		fatal("failed to start IPC server", "error", err)
 	}
 	defer ipcServer.Stop()
 
-	watcher, err := NewWatcher(engine, syncpkg.DefaultDebounceDelay)
+	watcher, err := NewWatcher(engine, cfg.Daemon.DebounceDelay)
 	if err != nil {
-		log.Fatalf("Failed to create watcher: %v", err)
+		fatal("failed to create watcher", "error", err)
 	}
+	watcher.metrics = daemon.metrics
 	daemon.watcher = watcher
 	defer watcher.Stop()
 
+	if err := daemon.reconcileFolders(cfg.SyncFolders, false); err != nil {
+		slog.Error("failed to add sync folders from config", "error", err)
+	}
+
 	folders, err := database.ListSyncFolders()
 	if err != nil {
-		log.Fatalf("Failed to list sync folders: %v", err)
+		fatal("failed to list sync folders", "error", err)
 	}
 
 	for _, folder := range folders {
 		if folder.Enabled {
 			if err := watcher.AddFolder(folder); err != nil {
-				log.Printf("Failed to watch folder %s: %v", folder.LocalPath, err)
+				slog.Error("failed to watch folder", "path", folder.LocalPath, "error", err)
 			}
 		}
 	}
 
 	watcher.Start()
 
+	// Pick up changes made while the daemon was not running
+	go func() {
+		for _, folder := range folders {
+			if !folder.Enabled {
+				continue
+			}
+			if err := engine.SyncFolder(folder.ID); err != nil {
+				slog.Error("initial sync failed", "path", folder.LocalPath, "error", err)
+			}
+		}
+	}()
+
 	go daemon.queueWorker()
+	go daemon.watchQueueSize()
+	go daemon.bandwidth.follow()
+	go daemon.throttle.follow()
+
+	slog.Info("daemon started", "pid", os.Getpid(), "socket", socketPath, "folders", len(folders))
+
+	daemon.notifyReady()
+	go daemon.runWatchdog(socketPath)
 
-	fmt.Printf("Dark Storage daemon started\n")
-	fmt.Printf("IPC socket: %s\n", socketPath)
-	fmt.Printf("Watching %d folder(s)\n", len(folders))
+	daemon.configData, _ = os.ReadFile(daemon.configPath())
+	configWatcher, err := watchConfigFile(daemon.configPath(), func() {
+		daemon.reloadIfChanged("config file changed")
+	})
+	if err != nil {
+		slog.Warn("not watching config file, use SIGHUP to reload", "error", err)
+	} else {
+		defer configWatcher.Close()
+	}
 
 	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
-	<-sigChan
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
+wait:
+	for {
+		select {
+		case sig := <-sigChan:
+			if sig != syscall.SIGHUP {
+				break wait
+			}
+			daemon.reload("SIGHUP")
+		case <-daemon.shutdown:
+			break wait
+		}
+	}
+
+	// Let transfers in flight finish; a second signal interrupts them
+	daemon.configMu.RLock()
+	timeout := daemon.config.Daemon.ShutdownTimeout
+	daemon.configMu.RUnlock()
+	slog.Info("shutting down, waiting for transfers to finish", "timeout", timeout)
+	notify("STOPPING=1\nSTATUS=Waiting for transfers to finish")
+
+	drained := make(chan bool, 1)
+	go func() { drained <- engine.Shutdown(timeout) }()
+	select {
+	case ok := <-drained:
+		if !ok {
+			slog.Warn("transfers still running were interrupted and will resume on next start", "timeout", timeout)
+		}
+	case <-sigChan:
+		slog.Warn("interrupting transfers in flight")
+		engine.Stop()
+		<-drained
+	}
+}
+
+// fatal logs msg as an error and exits. Deferred cleanup doesn't run.
+func fatal(msg string, args ...interface{}) {
+	slog.Error(msg, args...)
+	os.Exit(1)
+}
+
+// requestShutdown makes runDaemon shut down as if signalled
+func (d *Daemon) requestShutdown() {
+	d.shutdownOnce.Do(func() { close(d.shutdown) })
+}
 
-	fmt.Println("\nShutting down...")
+// clientForProfile returns the API client for a sync folder's profile, ""
+// being the top-level account. Clients are created on first use and shared
+// by all folders with the same profile.
+func (d *Daemon) clientForProfile(profile string) (*api.Client, error) {
+	d.clientsMu.Lock()
+	defer d.clientsMu.Unlock()
+
+	if client, ok := d.clients[profile]; ok {
+		return client, nil
+	}
+
+	v, err := loadProfileConfig(d.dataDir, profile)
+	if err != nil {
+		return nil, err
+	}
+
+	endpoint := v.GetString("endpoint")
+	if endpoint == "" {
+		endpoint = "https://api.darkstorage.io"
+	}
+
+	client := api.NewClient(endpoint, v.GetString("api_key"))
+	if backend, err := newStorageBackend(v); err != nil {
+		slog.Error("storage backend unavailable, transfers will fail", "profile", profile, "error", err)
+	} else {
+		client.SetStorageBackend(backend)
+	}
+	d.configMu.RLock()
+	cfg := d.config
+	d.configMu.RUnlock()
+
+	client.SetEncryption(v.GetString("encryption"))
+	client.SetMetadataOptions(fsmeta.Options{
+		Owner:  cfg.Metadata.PreserveOwner,
+		Xattrs: cfg.Metadata.Xattrs,
+	})
+	client.SetBandwidthLimiters(d.bandwidth.up, d.bandwidth.down)
+
+	d.clients[profile] = client
+	return client, nil
+}
+
+// loadProfileConfig reads the CLI's config.yaml and credential store, which
+// hold the endpoint and the API and storage keys the daemon syncs with, as
+// seen by profile
+func loadProfileConfig(dataDir, profile string) (*viper.Viper, error) {
+	v, err := config.NewViper(dataDir)
+	if err != nil {
+		return nil, err
+	}
+	if err := config.ApplyProfile(v, profile); err != nil {
+		return nil, err
+	}
+
+	store := credentialStore(dataDir, v)
+	if err := credentials.Load(v, store, profile); err != nil {
+		slog.Error("failed to read credentials", "store", store.String(), "error", err)
+	}
+	return v, nil
+}
+
+// credentialStore opens the store the CLI keeps secrets in
+func credentialStore(dataDir string, v *viper.Viper) credentials.Store {
+	return credentials.Open(credentials.Options{
+		Dir:        dataDir,
+		Helper:     v.GetString("credentials.helper"),
+		Passphrase: os.Getenv(credentials.PassphraseEnv),
+	})
+}
+
+// migrateCredentials moves plaintext secrets in config.yaml into the
+// credential store, returning the keys moved
+func migrateCredentials(dataDir string) ([]string, error) {
+	v, err := config.NewViper(dataDir)
+	if err != nil {
+		return nil, err
+	}
+	return credentials.MigrateConfig(credentialStore(dataDir, v), filepath.Join(dataDir, "config.yaml"))
+}
+
+func newStorageBackend(v *viper.Viper) (storage.StorageBackend, error) {
+	cfg, err := config.LoadStorageConfigFrom(v)
+	if err != nil {
+		return nil, err
+	}
+
+	return storage.NewTraditionalBackend(&storage.TraditionalConfig{
+		Endpoint:  cfg.Endpoint,
+		AccessKey: cfg.AccessKey,
+		SecretKey: cfg.SecretKey,
+		UseSSL:    cfg.UseSSL,
+		Region:    cfg.Region,
+	})
 }
 
 func (d *Daemon) setupIPCHandlers() {
-	d.ipcServer.RegisterHandler("status", d.handleStatus)
-	d.ipcServer.RegisterHandler("add_sync_folder", d.handleAddSyncFolder)
-	d.ipcServer.RegisterHandler("remove_sync_folder", d.handleRemoveSyncFolder)
-	d.ipcServer.RegisterHandler("get_activity", d.handleGetActivity)
-	d.ipcServer.RegisterHandler("force_sync", d.handleForceSync)
-	d.ipcServer.RegisterHandler("get_config", d.handleGetConfig)
-	d.ipcServer.RegisterHandler("set_config", d.handleSetConfig)
+	d.register("status", d.handleStatus)
+	d.register("add_sync_folder", d.handleAddSyncFolder)
+	d.register("remove_sync_folder", d.handleRemoveSyncFolder)
+	d.register("update_sync_folder", d.handleUpdateSyncFolder)
+	d.register("get_activity", d.handleGetActivity)
+	d.register("force_sync", d.handleForceSync)
+	d.register("get_config", d.handleGetConfig)
+	d.register("set_config", d.handleSetConfig)
+	d.register("shutdown", d.handleShutdown)
+	d.register("pause_folder", d.handlePauseFolder)
+	d.register("resume_folder", d.handleResumeFolder)
+	d.register("folder_stats", d.handleFolderStats)
+	d.register("list_conflicts", d.handleListConflicts)
+	d.register("resolve_conflict", d.handleResolveConflict)
+	d.register("list_queue", d.handleListQueue)
+	d.register("get_queue_item", d.handleGetQueueItem)
+	d.register("retry_queue_item", d.handleRetryQueueItem)
+	d.register("cancel_queue_item", d.handleCancelQueueItem)
+	d.register("hydrate", d.handleHydrate)
+	d.register("dehydrate", d.handleDehydrate)
+	d.register("get_bandwidth", d.handleGetBandwidth)
+	d.register("list_hooks", d.handleListHooks)
+	d.register("test_hook", d.handleTestHook)
 }
 
-func (d *Daemon) handleStatus(data json.RawMessage) (*ipc.Response, error) {
+// register serves method with handler, reporting IDs that name nothing
+// with ipc.CodeNotFound
+func (d *Daemon) register(method string, handler ipc.HandlerFunc) {
+	d.ipcServer.RegisterHandler(method, func(params json.RawMessage) (interface{}, error) {
+		result, err := handler(params)
+		var notFound *syncpkg.NotFoundError
+		if errors.As(err, &notFound) {
+			return nil, ipc.Errorf(ipc.CodeNotFound, "%v", err)
+		}
+		return result, err
+	})
+}
+
+func (d *Daemon) handleStatus(data json.RawMessage) (interface{}, error) {
+	status, err := d.status()
+	if err != nil {
+		return nil, err
+	}
+
+	return status, nil
+}
+
+func (d *Daemon) status() (*ipc.StatusResponse, error) {
 	folders, err := d.db.ListSyncFolders()
 	if err != nil {
 		return nil, err
@@ -148,46 +438,75 @@ func (d *Daemon) handleStatus(data json.RawMessage) (*ipc.Response, error) {
 		return nil, err
 	}
 
+	transfers := d.engine.Transfers()
+
 	var folderStatuses []ipc.SyncFolderStatus
 	for _, folder := range folders {
-		folderStatuses = append(folderStatuses, ipc.SyncFolderStatus{
-			ID:         folder.ID,
-			LocalPath:  folder.LocalPath,
-			RemotePath: folder.RemotePath,
-			Status:     "idle",
+		folderStatus, err := d.folderStatus(folder, transfers)
+		if err != nil {
+			return nil, err
+		}
+		folderStatuses = append(folderStatuses, folderStatus)
+	}
+
+	var transferStatuses []ipc.TransferStatus
+	for _, t := range transfers {
+		transferStatuses = append(transferStatuses, ipc.TransferStatus{
+			ID:        t.Op.ID,
+			FolderID:  t.Op.SyncFolderID,
+			Path:      t.Op.RelativePath,
+			Operation: t.Op.Operation,
+			Bytes:     t.Bytes,
+			Size:      t.Size,
+			StartedAt: t.StartedAt,
 		})
 	}
 
-	status := &ipc.StatusResponse{
+	return &ipc.StatusResponse{
 		DaemonRunning: true,
 		SyncFolders:   folderStatuses,
 		QueueSize:     queueSize,
-		Uptime:        time.Since(d.startTime).String(),
-	}
-
-	responseData, err := json.Marshal(status)
-	if err != nil {
-		return nil, err
-	}
-
-	return &ipc.Response{
-		Success: true,
-		Data:    responseData,
+		Uptime:        time.Since(d.startTime).Round(time.Second).String(),
+		Transfers:     transferStatuses,
 	}, nil
 }
 
-func (d *Daemon) handleAddSyncFolder(data json.RawMessage) (*ipc.Response, error) {
+func (d *Daemon) handleAddSyncFolder(data json.RawMessage) (interface{}, error) {
 	var req ipc.AddSyncFolderRequest
 	if err := json.Unmarshal(data, &req); err != nil {
 		return nil, err
 	}
 
+	profile := req.Profile
+	if profile == config.DefaultProfile {
+		profile = ""
+	}
+	// Also checks that the profile exists
+	v, err := loadProfileConfig(d.dataDir, profile)
+	if err != nil {
+		return nil, err
+	}
+	if req.RemotePath == "" {
+		req.RemotePath = v.GetString("bucket")
+		if req.RemotePath == "" {
+			return nil, fmt.Errorf("remote path is required (profile has no default bucket)")
+		}
+	}
+
 	folder := &db.SyncFolder{
 		LocalPath:          req.LocalPath,
 		RemotePath:         req.RemotePath,
 		Direction:          req.Direction,
 		Enabled:            true,
 		ConflictResolution: req.ConflictResolution,
+		ExcludePatterns:    strings.Join(req.Excludes, "\n"),
+		BandwidthLimit:     positiveOrNil(req.BandwidthLimit),
+		IncludePaths:       syncpkg.FormatIncludePaths(req.IncludePaths),
+		Placeholders:       req.Placeholders,
+		Profile:            profile,
+	}
+	if err := validateFolder(folder); err != nil {
+		return nil, err
 	}
 
 	if err := d.db.CreateSyncFolder(folder); err != nil {
@@ -198,24 +517,29 @@ func (d *Daemon) handleAddSyncFolder(data json.RawMessage) (*ipc.Response, error
 		return nil, err
 	}
 
-	result := &ipc.AddSyncFolderResponse{ID: folder.ID}
-	responseData, err := json.Marshal(result)
-	if err != nil {
-		return nil, err
-	}
+	go func() {
+		if err := d.engine.SyncFolder(folder.ID); err != nil {
+			slog.Error("initial sync failed", "path", folder.LocalPath, "error", err)
+		}
+	}()
 
-	return &ipc.Response{
-		Success: true,
-		Data:    responseData,
-	}, nil
+	return &ipc.AddSyncFolderResponse{ID: folder.ID}, nil
 }
 
-func (d *Daemon) handleRemoveSyncFolder(data json.RawMessage) (*ipc.Response, error) {
+func (d *Daemon) handleRemoveSyncFolder(data json.RawMessage) (interface{}, error) {
 	var req ipc.RemoveSyncFolderRequest
 	if err := json.Unmarshal(data, &req); err != nil {
 		return nil, err
 	}
 
+	folder, err := d.getFolder(req.ID)
+	if err != nil {
+		return nil, err
+	}
+	if folder.ConfigManaged {
+		return nil, fmt.Errorf("%s is declared in config.yaml, remove it from sync_folders there", folder.LocalPath)
+	}
+
 	if err := d.watcher.RemoveFolder(req.ID); err != nil {
 		return nil, err
 	}
@@ -224,10 +548,10 @@ func (d *Daemon) handleRemoveSyncFolder(data json.RawMessage) (*ipc.Response, er
 		return nil, err
 	}
 
-	return &ipc.Response{Success: true}, nil
+	return nil, nil
 }
 
-func (d *Daemon) handleGetActivity(data json.RawMessage) (*ipc.Response, error) {
+func (d *Daemon) handleGetActivity(data json.RawMessage) (interface{}, error) {
 	var req ipc.GetActivityRequest
 	if err := json.Unmarshal(data, &req); err != nil {
 		return nil, err
@@ -237,7 +561,13 @@ func (d *Daemon) handleGetActivity(data json.RawMessage) (*ipc.Response, error)
 		req.Limit = 50
 	}
 
-	activities, err := d.db.GetRecentActivity(req.Limit)
+	var activities []*db.Activity
+	var err error
+	if req.FolderID != nil {
+		activities, err = d.db.GetActivityByFolder(*req.FolderID, req.Limit)
+	} else {
+		activities, err = d.db.GetRecentActivity(req.Limit)
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -260,59 +590,139 @@ func (d *Daemon) handleGetActivity(data json.RawMessage) (*ipc.Response, error)
 		entries = append(entries, entry)
 	}
 
-	result := &ipc.GetActivityResponse{Activities: entries}
-	responseData, err := json.Marshal(result)
-	if err != nil {
-		return nil, err
-	}
-
-	return &ipc.Response{
-		Success: true,
-		Data:    responseData,
-	}, nil
+	return &ipc.GetActivityResponse{Activities: entries}, nil
 }
 
-func (d *Daemon) handleForceSync(data json.RawMessage) (*ipc.Response, error) {
+func (d *Daemon) handleForceSync(data json.RawMessage) (interface{}, error) {
 	var req ipc.ForceSyncRequest
 	if err := json.Unmarshal(data, &req); err != nil {
 		return nil, err
 	}
 
+	if _, err := d.getFolder(req.FolderID); err != nil {
+		return nil, err
+	}
 	go d.engine.SyncFolder(req.FolderID)
 
-	return &ipc.Response{Success: true}, nil
+	return nil, nil
 }
 
-func (d *Daemon) handleGetConfig(data json.RawMessage) (*ipc.Response, error) {
+func (d *Daemon) handleGetConfig(data json.RawMessage) (interface{}, error) {
+	d.configMu.RLock()
+	cfg := d.config
+	d.configMu.RUnlock()
+
 	configMap := map[string]interface{}{
-		"daemon":        d.config.Daemon,
-		"notifications": d.config.Notifications,
-		"api":           d.config.API,
+		"daemon":            cfg.Daemon,
+		"sync_folders":      cfg.SyncFolders,
+		"notifications":     cfg.Notifications,
+		"anomaly_detection": cfg.AnomalyDetection,
+		"metadata":          cfg.Metadata,
+	}
+
+	return &ipc.GetConfigResponse{Config: configMap}, nil
+}
+
+func (d *Daemon) handleSetConfig(data json.RawMessage) (interface{}, error) {
+	var req ipc.SetConfigRequest
+	if err := json.Unmarshal(data, &req); err != nil {
+		return nil, err
+	}
+
+	settings := make(map[string]interface{})
+	config.FlattenSettings("", req.Config, settings)
+	for key := range settings {
+		if credentials.IsSecret(key) {
+			return nil, fmt.Errorf("%s is a credential, set it with 'darkstorage config set'", key)
+		}
+	}
+
+	if err := config.UpdateDaemonConfig(d.dataDir, req.Config); err != nil {
+		return nil, err
+	}
+	// Apply now rather than waiting for the file watcher, so the caller
+	// sees the new settings in effect
+	if err := d.reload("set_config"); err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+func (d *Daemon) handleShutdown(data json.RawMessage) (interface{}, error) {
+	d.requestShutdown()
+	return nil, nil
+}
+
+func (d *Daemon) handleResumeFolder(data json.RawMessage) (interface{}, error) {
+	var req ipc.ResumeFolderRequest
+	if err := json.Unmarshal(data, &req); err != nil {
+		return nil, err
 	}
 
-	result := &ipc.GetConfigResponse{Config: configMap}
-	responseData, err := json.Marshal(result)
+	discarded, err := d.engine.ResumeFolder(req.FolderID, req.DiscardPending)
 	if err != nil {
 		return nil, err
 	}
 
-	return &ipc.Response{
-		Success: true,
-		Data:    responseData,
-	}, nil
+	return &ipc.ResumeFolderResponse{Discarded: discarded}, nil
 }
 
-func (d *Daemon) handleSetConfig(data json.RawMessage) (*ipc.Response, error) {
-	var req ipc.SetConfigRequest
+func (d *Daemon) handleHydrate(data json.RawMessage) (interface{}, error) {
+	return d.applyToPaths(data, d.engine.Hydrate)
+}
+
+func (d *Daemon) handleDehydrate(data json.RawMessage) (interface{}, error) {
+	return d.applyToPaths(data, d.engine.Dehydrate)
+}
+
+// applyToPaths resolves each local path to its sync folder and runs fn on
+// the path relative to that folder
+func (d *Daemon) applyToPaths(data json.RawMessage, fn func(folderID int, relPath string) (int, error)) (interface{}, error) {
+	var req ipc.HydrateRequest
 	if err := json.Unmarshal(data, &req); err != nil {
 		return nil, err
 	}
 
-	if err := config.SaveDaemonConfig(d.config); err != nil {
+	folders, err := d.db.ListSyncFolders()
+	if err != nil {
 		return nil, err
 	}
 
-	return &ipc.Response{Success: true}, nil
+	total := 0
+	for _, path := range req.Paths {
+		folder, relPath := findFolderForPath(folders, path)
+		if folder == nil {
+			return nil, fmt.Errorf("%s is not inside a sync folder", path)
+		}
+
+		n, err := fn(folder.ID, relPath)
+		if err != nil {
+			return nil, err
+		}
+		total += n
+	}
+
+	return &ipc.HydrateResponse{Files: total}, nil
+}
+
+// findFolderForPath returns the innermost sync folder containing path
+func findFolderForPath(folders []*db.SyncFolder, path string) (*db.SyncFolder, string) {
+	var match *db.SyncFolder
+	var matchRel string
+	for _, folder := range folders {
+		rel, err := filepath.Rel(folder.LocalPath, path)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			continue
+		}
+		if match == nil || len(folder.LocalPath) > len(match.LocalPath) {
+			match, matchRel = folder, rel
+		}
+	}
+	if matchRel == "." {
+		matchRel = ""
+	}
+	return match, matchRel
 }
 
 func (d *Daemon) queueWorker() {
@@ -321,15 +731,11 @@ func (d *Daemon) queueWorker() {
 
 	for range ticker.C {
 		if err := d.engine.ProcessQueue(); err != nil {
-			log.Printf("Queue processing error: %v", err)
+			slog.Error("queue processing failed", "error", err)
 		}
 	}
 }
 
-func stopDaemon() {
-	fmt.Println("Stopping daemon...")
-}
-
 func daemonStatus() {
 	dataDir, err := config.GetDefaultDataDir()
 	if err != nil {
@@ -349,4 +755,43 @@ func daemonStatus() {
 	fmt.Printf("Uptime: %s\n", status.Uptime)
 	fmt.Printf("Queue Size: %d\n", status.QueueSize)
 	fmt.Printf("Sync Folders: %d\n", len(status.SyncFolders))
+	for _, folder := range status.SyncFolders {
+		if folder.Status == "paused" {
+			fmt.Printf("  [%d] %s: paused (%s)\n", folder.ID, folder.LocalPath, folder.ErrorMessage)
+		}
+		if folder.Throttled != "" {
+			fmt.Printf("  [%d] %s: throttled (%s)\n", folder.ID, folder.LocalPath, folder.Throttled)
+		}
+	}
+}
+
+func resumeFolder(args []string) {
+	discard := len(args) == 2 && args[1] == "--discard-pending"
+	if len(args) != 1 && !discard {
+		fmt.Println("Usage: darkstorage-daemon resume <folder-id> [--discard-pending]")
+		os.Exit(1)
+	}
+
+	folderID, err := strconv.Atoi(args[0])
+	if err != nil {
+		log.Fatalf("Invalid folder ID: %s", args[0])
+	}
+
+	dataDir, err := config.GetDefaultDataDir()
+	if err != nil {
+		log.Fatalf("Failed to get data directory: %v", err)
+	}
+
+	socketPath := filepath.Join(dataDir, "daemon.sock")
+	client := ipc.NewClient(socketPath)
+
+	discarded, err := client.ResumeFolder(folderID, discard)
+	if err != nil {
+		log.Fatalf("Failed to resume folder: %v", err)
+	}
+
+	fmt.Printf("Folder %d resumed\n", folderID)
+	if discard {
+		fmt.Printf("Discarded %d pending operation(s)\n", discarded)
+	}
 }
@@ -0,0 +1,311 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/darkstorage/cli/internal/db"
+	"github.com/darkstorage/cli/internal/metrics"
+	"github.com/darkstorage/cli/internal/storage"
+	syncpkg "github.com/darkstorage/cli/internal/sync"
+)
+
+// healthCheckTimeout bounds each check made by /healthz and /readyz
+const healthCheckTimeout = 5 * time.Second
+
+// durationBuckets are the upper bounds, in seconds, of the operation
+// duration histogram
+var durationBuckets = []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 300}
+
+// queueStatuses are always reported, so a status doesn't disappear from
+// graphs when no operation has it
+var queueStatuses = []string{
+	syncpkg.QueuePending,
+	syncpkg.QueueProcessing,
+	syncpkg.QueueCompleted,
+	syncpkg.QueueFailed,
+	syncpkg.QueueCancelled,
+}
+
+// daemonMetrics counts the daemon's work for /metrics. It observes the
+// engine alongside the event publisher.
+type daemonMetrics struct {
+	registry      *metrics.Registry
+	queue         *metrics.GaugeVec
+	inFlight      *metrics.GaugeVec
+	bytes         *metrics.CounterVec
+	duration      *metrics.HistogramVec
+	errors        *metrics.CounterVec
+	conflicts     *metrics.CounterVec
+	pauses        *metrics.CounterVec
+	watcherEvents *metrics.CounterVec
+	watcherErrors *metrics.CounterVec
+
+	mu        sync.Mutex
+	transfers map[int]*transferMetrics
+}
+
+// transferMetrics follows one running operation
+type transferMetrics struct {
+	started time.Time
+	bytes   int64
+}
+
+func newDaemonMetrics(startTime time.Time) *daemonMetrics {
+	r := metrics.NewRegistry()
+	m := &daemonMetrics{
+		registry: r,
+		queue: r.NewGaugeVec("darkstorage_queue_operations",
+			"Operations in the sync queue by status.", "status"),
+		inFlight: r.NewGaugeVec("darkstorage_transfers_in_flight",
+			"Operations running now."),
+		bytes: r.NewCounterVec("darkstorage_transferred_bytes_total",
+			"Bytes moved by transfers, including ones that later failed.", "folder_id", "direction"),
+		duration: r.NewHistogramVec("darkstorage_operation_duration_seconds",
+			"Time taken by queued operations that started.", durationBuckets, "operation", "result"),
+		errors: r.NewCounterVec("darkstorage_operation_errors_total",
+			"Failed operations by class of error.", "class"),
+		conflicts: r.NewCounterVec("darkstorage_conflicts_total",
+			"Conflicts detected."),
+		pauses: r.NewCounterVec("darkstorage_folder_pauses_total",
+			"Times a folder was paused, by the user or by anomaly detection."),
+		watcherEvents: r.NewCounterVec("darkstorage_watcher_events_total",
+			"File system events seen in sync folders, before debouncing.", "type"),
+		watcherErrors: r.NewCounterVec("darkstorage_watcher_errors_total",
+			"Errors reported by the file system watcher."),
+		transfers: make(map[int]*transferMetrics),
+	}
+	r.NewGaugeVec("darkstorage_start_time_seconds",
+		"When the daemon started, in seconds since the Unix epoch.").Set(float64(startTime.Unix()))
+	return m
+}
+
+func (m *daemonMetrics) TransferStarted(op *db.QueueOperation, size int64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.transfers[op.ID] = &transferMetrics{started: time.Now()}
+}
+
+func (m *daemonMetrics) TransferProgress(op *db.QueueOperation, bytes, size int64) {
+	m.mu.Lock()
+	t, ok := m.transfers[op.ID]
+	var delta int64
+	if ok && bytes > t.bytes {
+		delta, t.bytes = bytes-t.bytes, bytes
+	}
+	m.mu.Unlock()
+
+	if delta > 0 {
+		m.bytes.Add(float64(delta), strconv.Itoa(op.SyncFolderID), op.Operation)
+	}
+}
+
+func (m *daemonMetrics) TransferCompleted(op *db.QueueOperation, err error) {
+	m.mu.Lock()
+	t, ok := m.transfers[op.ID]
+	delete(m.transfers, op.ID)
+	m.mu.Unlock()
+
+	result := "success"
+	if err != nil {
+		result = "error"
+		m.errors.Inc(errorClass(err))
+	}
+	// Operations that failed before starting, e.g. for a missing folder,
+	// have no duration worth recording
+	if ok {
+		m.duration.Observe(time.Since(t.started).Seconds(), op.Operation, result)
+	}
+}
+
+func (m *daemonMetrics) ConflictCreated(*db.Conflict) {
+	m.conflicts.Inc()
+}
+
+func (m *daemonMetrics) FolderPaused(int, string) {
+	m.pauses.Inc()
+}
+
+// watcherEvent counts a file system event; m may be nil
+func (m *daemonMetrics) watcherEvent(eventType syncpkg.EventType) {
+	if m != nil {
+		m.watcherEvents.Inc(eventType.String())
+	}
+}
+
+// watcherError counts a watcher error; m may be nil
+func (m *daemonMetrics) watcherError() {
+	if m != nil {
+		m.watcherErrors.Inc()
+	}
+}
+
+// errorClass sorts operation errors into a few classes worth alerting on
+// separately
+func errorClass(err error) string {
+	var netErr net.Error
+	isNet := errors.As(err, &netErr)
+	switch {
+	case errors.Is(err, context.Canceled):
+		return "cancelled"
+	case errors.Is(err, context.DeadlineExceeded), isNet && netErr.Timeout():
+		return "timeout"
+	case storage.IsAccessDenied(err):
+		return "auth"
+	case storage.IsNotFound(err), errors.Is(err, fs.ErrNotExist):
+		return "not_found"
+	case isNet:
+		return "network"
+	case errors.Is(err, fs.ErrPermission):
+		return "permission"
+	default:
+		return "other"
+	}
+}
+
+// handleMetrics refreshes the gauges read from the database and engine,
+// then writes every metric
+func (d *Daemon) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	counts, err := d.db.QueueCountsByStatus()
+	if err != nil {
+		slog.Error("failed to count queued operations", "error", err)
+	}
+	for _, status := range queueStatuses {
+		d.metrics.queue.Set(float64(counts[status]), status)
+	}
+	d.metrics.inFlight.Set(float64(len(d.engine.Transfers())))
+
+	d.metrics.registry.Handler().ServeHTTP(w, r)
+}
+
+// handleHealthz reports whether the daemon can use its database
+func (d *Daemon) handleHealthz(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), healthCheckTimeout)
+	defer cancel()
+
+	writeChecks(w, []healthCheck{{"database", d.db.Ping(ctx)}})
+}
+
+// handleReadyz reports whether the daemon can sync: its database and the
+// storage of every profile enabled folders use must be reachable, and it
+// must not be shutting down
+func (d *Daemon) handleReadyz(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), healthCheckTimeout)
+	defer cancel()
+
+	checks := []healthCheck{{"database", d.db.Ping(ctx)}}
+	select {
+	case <-d.shutdown:
+		checks = append(checks, healthCheck{"daemon", fmt.Errorf("shutting down")})
+	default:
+	}
+
+	profiles := map[string]bool{}
+	folders, err := d.db.ListSyncFolders()
+	if err == nil {
+		for _, folder := range folders {
+			if folder.Enabled {
+				profiles[folder.Profile] = true
+			}
+		}
+	}
+	if len(profiles) == 0 {
+		profiles[""] = true
+	}
+	names := make([]string, 0, len(profiles))
+	for profile := range profiles {
+		names = append(names, profile)
+	}
+	sort.Strings(names)
+
+	for _, profile := range names {
+		name := "storage"
+		if profile != "" {
+			name = fmt.Sprintf("storage (profile %s)", profile)
+		}
+		client, err := d.clientForProfile(profile)
+		if err == nil {
+			err = client.Ping(ctx)
+		}
+		checks = append(checks, healthCheck{name, err})
+	}
+
+	writeChecks(w, checks)
+}
+
+type healthCheck struct {
+	name string
+	err  error
+}
+
+// writeChecks writes one line per check, with status 503 if any failed
+func writeChecks(w http.ResponseWriter, checks []healthCheck) {
+	status := http.StatusOK
+	for _, c := range checks {
+		if c.err != nil {
+			status = http.StatusServiceUnavailable
+		}
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(status)
+	for _, c := range checks {
+		if c.err != nil {
+			fmt.Fprintf(w, "%s: %v\n", c.name, c.err)
+		} else {
+			fmt.Fprintf(w, "%s: ok\n", c.name)
+		}
+	}
+}
+
+// serveMetrics moves the metrics and health listener to addr, stopping it
+// if addr is empty. A listener already on addr is left running.
+func (d *Daemon) serveMetrics(addr string) {
+	d.metricsMu.Lock()
+	defer d.metricsMu.Unlock()
+
+	if addr == d.metricsAddr {
+		return
+	}
+	if d.metricsServer != nil {
+		d.metricsServer.Close()
+		d.metricsServer = nil
+	}
+	d.metricsAddr = addr
+	if addr == "" {
+		return
+	}
+
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		slog.Error("not serving metrics", "address", addr, "error", err)
+		// Retried on the next reload
+		d.metricsAddr = ""
+		return
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", d.handleMetrics)
+	mux.HandleFunc("/healthz", d.handleHealthz)
+	mux.HandleFunc("/readyz", d.handleReadyz)
+	server := &http.Server{
+		Handler:           mux,
+		ReadHeaderTimeout: healthCheckTimeout,
+	}
+	go func() {
+		if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			slog.Error("metrics server failed", "error", err)
+		}
+	}()
+	d.metricsServer = server
+	slog.Info("serving metrics", "url", fmt.Sprintf("http://%s/metrics", listener.Addr()))
+}
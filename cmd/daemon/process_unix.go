@@ -0,0 +1,32 @@
+//go:build !windows
+
+package main
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+// lockFile takes an exclusive lock on f without waiting, failing with
+// errLocked if another process holds it. The lock is released when f is
+// closed or the process exits.
+func lockFile(f *os.File) error {
+	err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+	if errors.Is(err, syscall.EWOULDBLOCK) {
+		return errLocked
+	}
+	return err
+}
+
+// detach makes cmd run in its own session, so it outlives the terminal
+// that started it
+func detach(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+}
+
+// terminate asks the process to shut down
+func terminate(pid int) error {
+	return syscall.Kill(pid, syscall.SIGTERM)
+}
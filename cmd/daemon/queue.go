@@ -0,0 +1,155 @@
+package main
+
+import (
+	"encoding/json"
+
+	"github.com/darkstorage/cli/internal/db"
+	"github.com/darkstorage/cli/internal/ipc"
+	syncpkg "github.com/darkstorage/cli/internal/sync"
+)
+
+// defaultQueueLimit caps list_queue when the request sets no limit
+const defaultQueueLimit = 100
+
+// queueEntry describes op, with its progress if it is among transfers
+func queueEntry(op *db.QueueOperation, transfers []syncpkg.Transfer) ipc.QueueEntry {
+	entry := ipc.QueueEntry{
+		ID:          op.ID,
+		FolderID:    op.SyncFolderID,
+		Path:        op.RelativePath,
+		Operation:   op.Operation,
+		Priority:    op.Priority,
+		Attempts:    op.Attempts,
+		MaxAttempts: op.MaxAttempts,
+		Status:      op.Status,
+		CreatedAt:   op.CreatedAt,
+		StartedAt:   op.StartedAt,
+		CompletedAt: op.CompletedAt,
+	}
+	if op.ErrorMessage != nil {
+		entry.Error = *op.ErrorMessage
+	}
+	for _, t := range transfers {
+		if t.Op.ID == op.ID {
+			entry.Bytes, entry.Size = t.Bytes, t.Size
+		}
+	}
+	return entry
+}
+
+func (d *Daemon) handleListQueue(data json.RawMessage) (interface{}, error) {
+	var req ipc.ListQueueRequest
+	if err := json.Unmarshal(data, &req); err != nil {
+		return nil, err
+	}
+
+	if req.Limit == 0 {
+		req.Limit = defaultQueueLimit
+	}
+
+	ops, err := d.db.ListQueueOperations(req.FolderID, req.Status, req.Limit)
+	if err != nil {
+		return nil, err
+	}
+
+	transfers := d.engine.Transfers()
+	entries := make([]ipc.QueueEntry, 0, len(ops))
+	for _, op := range ops {
+		entries = append(entries, queueEntry(op, transfers))
+	}
+
+	return &ipc.ListQueueResponse{Operations: entries}, nil
+}
+
+func (d *Daemon) handleGetQueueItem(data json.RawMessage) (interface{}, error) {
+	var req ipc.QueueItemRequest
+	if err := json.Unmarshal(data, &req); err != nil {
+		return nil, err
+	}
+
+	op, err := d.db.GetQueueOperation(req.ID)
+	if err != nil {
+		return nil, err
+	}
+	if op == nil {
+		return nil, &syncpkg.NotFoundError{What: "queue operation", ID: req.ID}
+	}
+
+	entry := queueEntry(op, d.engine.Transfers())
+	return &entry, nil
+}
+
+func (d *Daemon) handleRetryQueueItem(data json.RawMessage) (interface{}, error) {
+	var req ipc.QueueItemRequest
+	if err := json.Unmarshal(data, &req); err != nil {
+		return nil, err
+	}
+
+	if err := d.engine.RetryOperation(req.ID); err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+func (d *Daemon) handleCancelQueueItem(data json.RawMessage) (interface{}, error) {
+	var req ipc.QueueItemRequest
+	if err := json.Unmarshal(data, &req); err != nil {
+		return nil, err
+	}
+
+	if err := d.engine.CancelOperation(req.ID); err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+func (d *Daemon) handleListConflicts(data json.RawMessage) (interface{}, error) {
+	var req ipc.ListConflictsRequest
+	if err := json.Unmarshal(data, &req); err != nil {
+		return nil, err
+	}
+
+	conflicts, err := d.db.GetUnresolvedConflicts()
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]ipc.ConflictEntry, 0, len(conflicts))
+	for _, c := range conflicts {
+		if req.FolderID != 0 && c.SyncFolderID != req.FolderID {
+			continue
+		}
+		entry := ipc.ConflictEntry{
+			ID:               c.ID,
+			FolderID:         c.SyncFolderID,
+			Path:             c.RelativePath,
+			LocalModifiedAt:  c.LocalModifiedAt,
+			RemoteModifiedAt: c.RemoteModifiedAt,
+			CreatedAt:        c.CreatedAt,
+		}
+		if c.LocalHash != nil {
+			entry.LocalHash = *c.LocalHash
+		}
+		if c.RemoteHash != nil {
+			entry.RemoteHash = *c.RemoteHash
+		}
+		entries = append(entries, entry)
+	}
+
+	return &ipc.ListConflictsResponse{Conflicts: entries}, nil
+}
+
+func (d *Daemon) handleResolveConflict(data json.RawMessage) (interface{}, error) {
+	var req ipc.ResolveConflictRequest
+	if err := json.Unmarshal(data, &req); err != nil {
+		return nil, err
+	}
+
+	if err := d.engine.ResolveConflict(req.ID, req.Resolution); err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
@@ -0,0 +1,297 @@
+package main
+
+import (
+	"bytes"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/darkstorage/cli/internal/api"
+	"github.com/darkstorage/cli/internal/config"
+	"github.com/darkstorage/cli/internal/db"
+	"github.com/darkstorage/cli/internal/logging"
+	syncpkg "github.com/darkstorage/cli/internal/sync"
+	"github.com/fsnotify/fsnotify"
+)
+
+// configSettleDelay lets editors finish writing config.yaml before it is
+// read again
+const configSettleDelay = 500 * time.Millisecond
+
+// reload re-reads config.yaml and applies it to the running daemon. An
+// invalid file is logged and the running configuration kept.
+func (d *Daemon) reload(reason string) error {
+	d.reloadMu.Lock()
+	defer d.reloadMu.Unlock()
+
+	notify("RELOADING=1")
+	defer d.notifyReady()
+
+	// Secrets just written into the file, such as a new hook's, go to the
+	// credential store before the file is read
+	if moved, err := migrateCredentials(d.dataDir); err != nil {
+		slog.Error("failed to move credentials out of config.yaml", "error", err)
+	} else if len(moved) > 0 {
+		slog.Info("moved credentials to the credential store", "keys", moved)
+	}
+
+	d.configData, _ = os.ReadFile(d.configPath())
+	cfg, err := config.LoadDaemonConfig()
+	if err != nil {
+		slog.Error("not reloading configuration", "reason", reason, "error", err)
+		return err
+	}
+
+	d.configMu.Lock()
+	d.config = cfg
+	d.configMu.Unlock()
+
+	// Clients are rebuilt on next use with the new endpoints and
+	// credentials. Transfers in flight finish with the old ones.
+	d.clientsMu.Lock()
+	d.clients = make(map[string]*api.Client)
+	d.clientsMu.Unlock()
+
+	d.applySettings(cfg)
+	if err := d.reconcileFolders(cfg.SyncFolders, true); err != nil {
+		slog.Error("failed to update sync folders from config", "error", err)
+	}
+
+	slog.Info("configuration reloaded", "reason", reason)
+	return nil
+}
+
+// reloadIfChanged reloads unless config.yaml still holds what was last
+// loaded, as it does after set_config has written and applied it
+func (d *Daemon) reloadIfChanged(reason string) {
+	data, err := os.ReadFile(d.configPath())
+	d.reloadMu.Lock()
+	unchanged := err == nil && bytes.Equal(data, d.configData)
+	d.reloadMu.Unlock()
+	if !unchanged {
+		d.reload(reason)
+	}
+}
+
+func (d *Daemon) configPath() string {
+	return filepath.Join(d.dataDir, "config.yaml")
+}
+
+// applySettings pushes the settings that can change while running to the
+// engine, watcher, logger, hooks, bandwidth limiters, throttler and
+// metrics listener
+func (d *Daemon) applySettings(cfg *config.DaemonConfig) {
+	if err := logging.Configure(logOptions(cfg)); err != nil {
+		slog.Error("failed to apply log settings", "error", err)
+	}
+	d.hooks.SetHooks(d.hookList(cfg))
+	d.bandwidth.setSchedule(bandwidthSchedule(cfg.Daemon))
+	d.throttle.setSettings(cfg.Throttling)
+	d.engine.SetWorkers(cfg.Daemon.WorkerThreads)
+	d.engine.SetAnomalyConfig(syncpkg.AnomalyConfig{
+		Enabled:   cfg.AnomalyDetection.Enabled,
+		Window:    cfg.AnomalyDetection.Window,
+		MinFiles:  cfg.AnomalyDetection.MinFiles,
+		Threshold: cfg.AnomalyDetection.Threshold,
+	})
+	if d.watcher != nil {
+		d.watcher.SetDebounceDelay(cfg.Daemon.DebounceDelay)
+	}
+	d.serveMetrics(cfg.Daemon.MetricsAddress)
+	slog.Debug("settings applied",
+		"workers", cfg.Daemon.WorkerThreads,
+		"debounce_delay", cfg.Daemon.DebounceDelay,
+		"bandwidth_limit_up", cfg.Daemon.BandwidthLimitUp,
+		"bandwidth_limit_down", cfg.Daemon.BandwidthLimitDown,
+		"bandwidth_rules", len(cfg.Daemon.BandwidthSchedule))
+}
+
+// logOptions turns the log settings into logging options. Records also go
+// to stderr unless it is the log file already, as when started in the
+// background.
+func logOptions(cfg *config.DaemonConfig) logging.Options {
+	d := cfg.Daemon
+	return logging.Options{
+		Level:  d.LogLevel,
+		Levels: d.LogLevels,
+		Format: d.LogFormat,
+		File:   d.LogFile,
+		Rotation: logging.Rotation{
+			MaxSize:  int64(d.LogMaxSize) * 1024 * 1024,
+			Interval: d.LogRotateInterval,
+			MaxFiles: d.LogMaxFiles,
+			MaxAge:   d.LogMaxAge,
+		},
+		Stderr: !stderrIs(d.LogFile),
+	}
+}
+
+// stderrIs reports whether stderr is the file at path
+func stderrIs(path string) bool {
+	stderr, err := os.Stderr.Stat()
+	if err != nil {
+		return false
+	}
+	file, err := os.Stat(path)
+	return err == nil && os.SameFile(stderr, file)
+}
+
+// reconcileFolders makes the database match the folders declared under
+// sync_folders. Folders added over IPC are left alone unless the file
+// declares the same local path, in which case the file takes them over;
+// folders that came from the file and are no longer in it are removed.
+// With live set, the watcher is updated and changed folders are synced.
+func (d *Daemon) reconcileFolders(declared []config.SyncFolderConfig, live bool) error {
+	existing, err := d.db.ListSyncFolders()
+	if err != nil {
+		return err
+	}
+	byPath := make(map[string]*db.SyncFolder, len(existing))
+	for _, folder := range existing {
+		byPath[filepath.Clean(folder.LocalPath)] = folder
+	}
+
+	wanted := make(map[string]bool, len(declared))
+	var changed []*db.SyncFolder
+	for _, fc := range declared {
+		folder := folderFromConfig(fc)
+		wanted[folder.LocalPath] = true
+
+		current, ok := byPath[folder.LocalPath]
+		switch {
+		case !ok:
+			if err := d.db.CreateSyncFolder(folder); err != nil {
+				return err
+			}
+			slog.Info("added sync folder from config", "path", folder.LocalPath)
+		case sameFolderSettings(current, folder):
+			continue
+		default:
+			folder.ID = current.ID
+			folder.Paused, folder.PauseReason = current.Paused, current.PauseReason
+			if err := d.db.UpdateSyncFolder(folder); err != nil {
+				return err
+			}
+			slog.Info("updated sync folder from config", "path", folder.LocalPath)
+		}
+		changed = append(changed, folder)
+	}
+
+	var removed []*db.SyncFolder
+	for _, folder := range existing {
+		if folder.ConfigManaged && !wanted[filepath.Clean(folder.LocalPath)] {
+			removed = append(removed, folder)
+		}
+	}
+
+	for _, folder := range removed {
+		if live {
+			if err := d.watcher.RemoveFolder(folder.ID); err != nil {
+				slog.Error("failed to stop watching folder", "path", folder.LocalPath, "error", err)
+			}
+		}
+		if err := d.db.DeleteSyncFolder(folder.ID); err != nil {
+			return err
+		}
+		slog.Info("removed sync folder no longer in config", "path", folder.LocalPath)
+	}
+
+	if !live {
+		return nil
+	}
+	for _, folder := range changed {
+		d.refreshFolder(folder)
+	}
+	return nil
+}
+
+func folderFromConfig(fc config.SyncFolderConfig) *db.SyncFolder {
+	folder := &db.SyncFolder{
+		LocalPath:          filepath.Clean(fc.LocalPath),
+		RemotePath:         fc.RemotePath,
+		Direction:          fc.Direction,
+		Enabled:            fc.IsEnabled(),
+		ConflictResolution: fc.ConflictResolution,
+		ExcludePatterns:    strings.Join(fc.Excludes, "\n"),
+		IncludePaths:       syncpkg.FormatIncludePaths(fc.IncludePaths),
+		Placeholders:       fc.Placeholders,
+		Profile:            fc.Profile,
+		ConfigManaged:      true,
+	}
+	if folder.Profile == config.DefaultProfile {
+		folder.Profile = ""
+	}
+	if fc.BandwidthLimit > 0 {
+		limit := fc.BandwidthLimit
+		folder.BandwidthLimit = &limit
+	}
+	if fc.SyncInterval > 0 {
+		interval := fc.SyncInterval
+		folder.SyncInterval = &interval
+	}
+	return folder
+}
+
+// sameFolderSettings reports whether b would leave a unchanged
+func sameFolderSettings(a, b *db.SyncFolder) bool {
+	return a.RemotePath == b.RemotePath &&
+		a.Direction == b.Direction &&
+		a.Enabled == b.Enabled &&
+		a.ConflictResolution == b.ConflictResolution &&
+		a.ExcludePatterns == b.ExcludePatterns &&
+		a.IncludePaths == b.IncludePaths &&
+		a.Placeholders == b.Placeholders &&
+		a.Profile == b.Profile &&
+		a.ConfigManaged == b.ConfigManaged &&
+		equalIntPtr(a.BandwidthLimit, b.BandwidthLimit) &&
+		equalIntPtr(a.SyncInterval, b.SyncInterval)
+}
+
+func equalIntPtr(a, b *int) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+// watchConfigFile calls onChange after path is written, renamed over or
+// created. The directory is watched, since editors and atomic writes
+// replace the file rather than modifying it.
+func watchConfigFile(path string, onChange func()) (*fsnotify.Watcher, error) {
+	fw, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	if err := fw.Add(filepath.Dir(path)); err != nil {
+		fw.Close()
+		return nil, err
+	}
+
+	go func() {
+		var timer *time.Timer
+		for {
+			select {
+			case event, ok := <-fw.Events:
+				if !ok {
+					return
+				}
+				if filepath.Clean(event.Name) != filepath.Clean(path) ||
+					event.Op&(fsnotify.Write|fsnotify.Create|fsnotify.Rename) == 0 {
+					continue
+				}
+				if timer != nil {
+					timer.Stop()
+				}
+				timer = time.AfterFunc(configSettleDelay, onChange)
+			case err, ok := <-fw.Errors:
+				if !ok {
+					return
+				}
+				slog.Warn("config watcher error", "error", err)
+			}
+		}
+	}()
+	return fw, nil
+}
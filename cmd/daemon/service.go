@@ -0,0 +1,54 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/darkstorage/cli/internal/ipc"
+	"github.com/darkstorage/cli/internal/systemd"
+)
+
+// notify passes state to systemd when the daemon runs as a notify service
+func notify(state string) {
+	if _, err := systemd.Notify(state); err != nil {
+		slog.Warn("failed to notify systemd", "error", err)
+	}
+}
+
+// notifyReady tells systemd the daemon has started
+func (d *Daemon) notifyReady() {
+	status, err := d.status()
+	if err != nil {
+		notify("READY=1")
+		return
+	}
+	notify("READY=1\n" + statusLine(status))
+}
+
+func statusLine(status *ipc.StatusResponse) string {
+	return fmt.Sprintf("STATUS=Watching %d folder(s), %d operation(s) queued",
+		len(status.SyncFolders), status.QueueSize)
+}
+
+// runWatchdog pings the systemd watchdog at half of WatchdogSec, but only
+// while the daemon still answers status requests on its own socket, so one
+// that hangs is restarted. The status line is refreshed at the same time.
+func (d *Daemon) runWatchdog(socketPath string) {
+	interval := systemd.WatchdogInterval()
+	if interval == 0 {
+		return
+	}
+
+	client := ipc.NewClient(socketPath)
+	ticker := time.NewTicker(interval / 2)
+	defer ticker.Stop()
+	for range ticker.C {
+		status, err := client.GetStatus()
+		if err != nil {
+			slog.Warn("health check failed, not pinging watchdog", "error", err)
+			continue
+		}
+		notify("WATCHDOG=1\n" + statusLine(status))
+	}
+}
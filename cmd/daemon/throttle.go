@@ -0,0 +1,188 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/darkstorage/cli/internal/bandwidth"
+	"github.com/darkstorage/cli/internal/conditions"
+	"github.com/darkstorage/cli/internal/config"
+)
+
+// meteredProbeTimeout bounds throttling.metered_command
+const meteredProbeTimeout = 10 * time.Second
+
+// throttler pauses or slows transfers while the connection is metered, the
+// machine is on battery or its load is high, and lets them run freely
+// again once that passes
+type throttler struct {
+	d *Daemon
+
+	mu       sync.Mutex
+	settings config.ThrottleSettings
+	// action is the strictest taken for the conditions that hold, reason
+	// names them
+	action string
+	reason string
+	// lastErr is the last probe failure logged, so it isn't repeated at
+	// every check
+	lastErr string
+
+	wake chan struct{}
+}
+
+func newThrottler(d *Daemon) *throttler {
+	return &throttler{d: d, action: config.ThrottleNone, wake: make(chan struct{}, 1)}
+}
+
+// setSettings replaces the settings and checks again at once
+func (t *throttler) setSettings(settings config.ThrottleSettings) {
+	t.mu.Lock()
+	t.settings = settings
+	t.mu.Unlock()
+	select {
+	case t.wake <- struct{}{}:
+	default:
+	}
+}
+
+// current returns the action taken and why, config.ThrottleNone and ""
+// when transfers run freely
+func (t *throttler) current() (action, reason string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.action, t.reason
+}
+
+// follow checks the conditions every throttling.check_interval, and as
+// soon as the settings change
+func (t *throttler) follow() {
+	for {
+		t.check()
+		t.mu.Lock()
+		interval := t.settings.CheckInterval
+		t.mu.Unlock()
+		select {
+		case <-time.After(interval):
+		case <-t.wake:
+		}
+	}
+}
+
+// check looks at the conditions and applies the strictest action among
+// those that hold
+func (t *throttler) check() {
+	t.mu.Lock()
+	s := t.settings
+	t.mu.Unlock()
+
+	action := config.ThrottleNone
+	var reasons []string
+	take := func(a, reason string) {
+		if a == config.ThrottleNone {
+			return
+		}
+		if a == config.ThrottlePause {
+			action = a
+		} else if action == config.ThrottleNone {
+			action = a
+		}
+		reasons = append(reasons, reason)
+	}
+
+	if s.OnMetered != config.ThrottleNone {
+		if reason := t.metered(s); reason != "" {
+			take(s.OnMetered, reason)
+		}
+	}
+	if s.OnBattery != config.ThrottleNone {
+		onBattery, err := conditions.OnBattery()
+		t.logError("battery", err)
+		if onBattery {
+			take(s.OnBattery, "on battery")
+		}
+	}
+	if s.MaxLoad > 0 && s.OnHighLoad != config.ThrottleNone {
+		load, err := conditions.Load()
+		t.logError("load", err)
+		if err == nil && load > s.MaxLoad {
+			take(s.OnHighLoad, fmt.Sprintf("load above %g per CPU", s.MaxLoad))
+		}
+	}
+
+	t.set(action, strings.Join(reasons, ", "), s)
+}
+
+// metered describes the metered connection in use, "" if there is none
+func (t *throttler) metered(s config.ThrottleSettings) string {
+	if len(s.MeteredInterfaces) > 0 {
+		name, err := conditions.MeteredInterface(s.MeteredInterfaces)
+		t.logError("network interfaces", err)
+		if name != "" {
+			return "metered connection on " + name
+		}
+	}
+	if len(s.MeteredCommand) > 0 {
+		ctx, cancel := context.WithTimeout(context.Background(), meteredProbeTimeout)
+		defer cancel()
+		metered, err := conditions.ProbeMetered(ctx, s.MeteredCommand)
+		t.logError("metered_command", err)
+		if metered {
+			return "metered connection"
+		}
+	}
+	return ""
+}
+
+// logError logs a failed probe unless it failed the same way last time
+func (t *throttler) logError(probe string, err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if err == nil || err == conditions.ErrUnsupported {
+		return
+	}
+	msg := probe + ": " + err.Error()
+	if msg == t.lastErr {
+		return
+	}
+	t.lastErr = msg
+	slog.Warn("failed to check throttling condition", "probe", probe, "error", err)
+}
+
+// set pauses the engine or caps the shared limiters for action. Transfers
+// running when a pause starts finish at the throttled rate.
+func (t *throttler) set(action, reason string, s config.ThrottleSettings) {
+	t.mu.Lock()
+	unchanged := action == t.action && reason == t.reason
+	t.action, t.reason = action, reason
+	t.mu.Unlock()
+
+	ceiling := bandwidth.Limits{}
+	if action != config.ThrottleNone {
+		ceiling = bandwidth.Limits{Up: int64(s.LimitUp) * 1024, Down: int64(s.LimitDown) * 1024}
+	}
+	// The limits may have changed even if the conditions haven't
+	t.d.bandwidth.setCeiling(ceiling)
+	if unchanged {
+		return
+	}
+
+	if action == config.ThrottlePause {
+		t.d.engine.Hold(reason)
+	} else {
+		t.d.engine.Hold("")
+	}
+	switch action {
+	case config.ThrottlePause:
+		slog.Info("pausing transfers", "reason", reason)
+	case config.ThrottleSlow:
+		slog.Info("throttling transfers", "reason", reason,
+			"up", rateString(ceiling.Up), "down", rateString(ceiling.Down))
+	default:
+		slog.Info("resuming transfers at full speed")
+	}
+}
@@ -1,15 +1,18 @@
 package main
 
 import (
-	"fmt"
 	"sync"
 	"time"
 
+	"github.com/darkstorage/cli/internal/atomicfile"
 	"github.com/darkstorage/cli/internal/db"
+	"github.com/darkstorage/cli/internal/logging"
 	syncpkg "github.com/darkstorage/cli/internal/sync"
 	"github.com/fsnotify/fsnotify"
 )
 
+var watcherLog = logging.For(logging.Watcher)
+
 type Watcher struct {
 	watcher       *fsnotify.Watcher
 	engine        *syncpkg.Engine
@@ -18,6 +21,8 @@ type Watcher struct {
 	debounceDelay time.Duration
 	eventBuffer   map[string]*pendingEvent
 	bufferMu      sync.Mutex
+	// metrics counts events and errors; set before Start
+	metrics *daemonMetrics
 }
 
 type pendingEvent struct {
@@ -49,7 +54,7 @@ func (w *Watcher) AddFolder(folder *db.SyncFolder) error {
 	}
 
 	w.folders[folder.ID] = folder
-	fmt.Printf("Watching: %s\n", folder.LocalPath)
+	watcherLog.Info("watching folder", "folder_id", folder.ID, "path", folder.LocalPath)
 	return nil
 }
 
@@ -70,6 +75,14 @@ func (w *Watcher) RemoveFolder(folderID int) error {
 	return nil
 }
 
+// SetDebounceDelay changes how long events must settle before they are
+// processed. Events already waiting keep their timers.
+func (w *Watcher) SetDebounceDelay(delay time.Duration) {
+	w.bufferMu.Lock()
+	defer w.bufferMu.Unlock()
+	w.debounceDelay = delay
+}
+
 func (w *Watcher) Start() {
 	go w.eventLoop()
 }
@@ -91,12 +104,18 @@ func (w *Watcher) eventLoop() {
 			if !ok {
 				return
 			}
-			fmt.Printf("Watcher error: %v\n", err)
+			w.metrics.watcherError()
+			watcherLog.Error("watcher error", "error", err)
 		}
 	}
 }
 
 func (w *Watcher) handleEvent(event fsnotify.Event) {
+	// In-progress downloads; the final rename is checked by the engine
+	if atomicfile.IsTemp(event.Name) {
+		return
+	}
+
 	w.mu.RLock()
 	var folderID int
 	var found bool
@@ -128,6 +147,8 @@ func (w *Watcher) handleEvent(event fsnotify.Event) {
 	} else if event.Op&fsnotify.Rename != 0 {
 		eventType = syncpkg.EventRename
 	}
+	w.metrics.watcherEvent(eventType)
+	watcherLog.Debug("file system event", "folder_id", folderID, "type", eventType.String(), "path", event.Name)
 
 	fileEvent := &syncpkg.FileEvent{
 		Path:      event.Name,
@@ -150,6 +171,6 @@ func (w *Watcher) handleEvent(event fsnotify.Event) {
 
 func (w *Watcher) processEvent(event *syncpkg.FileEvent, folderID int) {
 	if err := w.engine.ProcessFileEvent(event, folderID); err != nil {
-		fmt.Printf("Error processing event: %v\n", err)
+		watcherLog.Error("failed to process event", "folder_id", folderID, "path", event.Path, "error", err)
 	}
 }
@@ -0,0 +1,236 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/darkstorage/cli/internal/config"
+	"github.com/darkstorage/cli/internal/logging"
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+// logPollInterval is how often --follow checks the log file for more
+const logPollInterval = 500 * time.Millisecond
+
+var daemonLogsCmd = &cobra.Command{
+	Use:   "logs",
+	Short: "Show the daemon's log",
+	Long: `Show the last lines of the daemon's log file (daemon.log_file), in the
+format it was written in (daemon.log_format).
+
+--level hides records below a level and --component keeps only records from
+the daemon, engine, watcher, ipc, hooks or storage. Lines that aren't log
+records, such as a crash's stack trace, are always shown.
+
+Examples:
+  darkstorage daemon logs
+  darkstorage daemon logs --follow --level warn
+  darkstorage daemon logs -n 200 --component engine --component watcher`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		follow, _ := cmd.Flags().GetBool("follow")
+		lines, _ := cmd.Flags().GetInt("lines")
+		levelName, _ := cmd.Flags().GetString("level")
+		components, _ := cmd.Flags().GetStringSlice("component")
+
+		filter := &logFilter{}
+		if levelName != "" {
+			level, err := logging.ParseLevel(levelName)
+			if err != nil {
+				color.Red("Error: --level: %v", err)
+				os.Exit(1)
+			}
+			filter.level = &level
+		}
+		for _, c := range components {
+			c = strings.ToLower(c)
+			if !containsString(logging.Components, c) {
+				color.Red("Error: unknown component %q: use %s", c, strings.Join(logging.Components, ", "))
+				os.Exit(1)
+			}
+			filter.components = append(filter.components, c)
+		}
+
+		path, err := daemonLogFile()
+		if err != nil {
+			color.Red("Error: %v", err)
+			os.Exit(1)
+		}
+		if _, err := os.Stat(path); os.IsNotExist(err) && !follow {
+			color.Red("Error: %s does not exist; the daemon hasn't logged anything yet", path)
+			os.Exit(1)
+		}
+
+		offset, err := printLastLines(path, lines, filter)
+		if err != nil {
+			color.Red("Error: %v", err)
+			os.Exit(1)
+		}
+		if follow {
+			if err := followLog(path, offset, filter); err != nil {
+				color.Red("Error: %v", err)
+				os.Exit(1)
+			}
+		}
+	},
+}
+
+func init() {
+	daemonCmd.AddCommand(daemonLogsCmd)
+
+	daemonLogsCmd.Flags().BoolP("follow", "f", false, "keep printing lines as they are written")
+	daemonLogsCmd.Flags().IntP("lines", "n", 50, "lines to show before following, 0 for none")
+	daemonLogsCmd.Flags().String("level", "", "hide records below this level: debug, info, warn or error")
+	daemonLogsCmd.Flags().StringSlice("component", nil, "only records from this component (repeatable)")
+}
+
+// daemonLogFile is the log file the daemon writes
+func daemonLogFile() (string, error) {
+	cfg, err := config.LoadDaemonConfig()
+	if err != nil {
+		return "", err
+	}
+	if cfg.Daemon.LogFile == "" {
+		return "", fmt.Errorf("daemon.log_file is not set, so the daemon only logs to stderr")
+	}
+	return cfg.Daemon.LogFile, nil
+}
+
+// logFilter chooses the lines to show
+type logFilter struct {
+	level      *slog.Level
+	components []string
+}
+
+func (f *logFilter) match(line string) bool {
+	parsed := logging.Parse(line)
+	if f.level != nil && parsed.HasLevel && parsed.Level < *f.level {
+		return false
+	}
+	if len(f.components) > 0 && parsed.Component != "" && !containsString(f.components, parsed.Component) {
+		return false
+	}
+	return true
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// printLastLines prints the last n matching lines, reaching back into the
+// newest rotated file if the current one has too few. It returns how much
+// of the current file it read.
+func printLastLines(path string, n int, filter *logFilter) (int64, error) {
+	files := []string{path}
+	if rotated := logging.RotatedFiles(path); len(rotated) > 0 {
+		files = []string{rotated[len(rotated)-1], path}
+	}
+
+	var last []string
+	var offset int64
+	for _, name := range files {
+		f, err := os.Open(name)
+		if os.IsNotExist(err) && name == path {
+			// Not written yet; --follow waits for it
+			continue
+		}
+		if err != nil {
+			return 0, err
+		}
+		reader := bufio.NewReader(f)
+		for {
+			line, err := reader.ReadString('\n')
+			if !strings.HasSuffix(line, "\n") {
+				// A partial line is printed once complete, when following
+				break
+			}
+			if name == path {
+				offset += int64(len(line))
+			}
+			line = strings.TrimSuffix(line, "\n")
+			if n > 0 && filter.match(line) {
+				if len(last) == n {
+					last = last[1:]
+				}
+				last = append(last, line)
+			}
+			if err != nil {
+				break
+			}
+		}
+		f.Close()
+	}
+
+	for _, line := range last {
+		fmt.Println(line)
+	}
+	return offset, nil
+}
+
+// followLog prints matching lines written to path from offset on,
+// starting over when the file is rotated or truncated. It returns only on
+// error.
+func followLog(path string, offset int64, filter *logFilter) error {
+	var f *os.File
+	var reader *bufio.Reader
+	var partial string
+	defer func() {
+		if f != nil {
+			f.Close()
+		}
+	}()
+
+	for ; ; time.Sleep(logPollInterval) {
+		if f == nil {
+			var err error
+			if f, err = os.Open(path); os.IsNotExist(err) {
+				continue
+			} else if err != nil {
+				return err
+			}
+			if _, err := f.Seek(offset, io.SeekStart); err != nil {
+				return err
+			}
+			reader = bufio.NewReader(f)
+		}
+
+		// Check for rotation before reading, so lines written to the old
+		// file up to its rename are still printed
+		current, statErr := os.Stat(path)
+		opened, err := f.Stat()
+		if err != nil {
+			return err
+		}
+
+		for {
+			chunk, err := reader.ReadString('\n')
+			partial += chunk
+			if err != nil {
+				break
+			}
+			offset += int64(len(partial))
+			if line := strings.TrimSuffix(partial, "\n"); filter.match(line) {
+				fmt.Println(line)
+			}
+			partial = ""
+		}
+
+		// Move to the new file once the log rotates, or start over if it
+		// was truncated
+		if statErr == nil && (!os.SameFile(current, opened) || current.Size() < offset) {
+			f.Close()
+			f, offset, partial = nil, 0, ""
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/darkstorage/cli/internal/devapi"
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+var devCmd = &cobra.Command{
+	Use:   "dev",
+	Short: "Developer tools",
+	Long:  `Tools for developing against Dark Storage without the hosted service.`,
+}
+
+var devAPIServerCmd = &cobra.Command{
+	Use:   "api-server",
+	Short: "Run a local fake of the /v1 REST API",
+	Long: `Serve an in-memory fake of the /v1 endpoints used by trash, audit,
+shares, groups, perms, scan, compartment and whoami.
+
+The server starts with a small built-in data set, or with the state from a
+fixtures file. Changes are kept in memory; use --dump to save the final state
+on exit, which can be loaded again with --fixtures.
+
+Examples:
+  darkstorage dev api-server
+  darkstorage dev api-server --addr 127.0.0.1:9000 --fixtures demo.json
+  darkstorage --endpoint http://127.0.0.1:8787 --api-key dev trash list
+
+The server also implements the OAuth login endpoints:
+  DARKSTORAGE_OAUTH_AUTHORIZE_URL=http://127.0.0.1:8787/oauth/authorize \
+    darkstorage --endpoint http://127.0.0.1:8787 login`,
+	Run: func(cmd *cobra.Command, args []string) {
+		addr, _ := cmd.Flags().GetString("addr")
+		fixturesPath, _ := cmd.Flags().GetString("fixtures")
+		empty, _ := cmd.Flags().GetBool("empty")
+		token, _ := cmd.Flags().GetString("token")
+		dumpPath, _ := cmd.Flags().GetString("dump")
+		tokenTTL, _ := cmd.Flags().GetDuration("token-ttl")
+
+		var fixtures *devapi.Fixtures
+		switch {
+		case fixturesPath != "":
+			var err error
+			fixtures, err = devapi.LoadFixturesFile(fixturesPath)
+			if err != nil {
+				color.Red("Error: loading fixtures: %v", err)
+				os.Exit(1)
+			}
+		case empty:
+			fixtures = devapi.EmptyFixtures()
+		default:
+			fixtures = devapi.DefaultFixtures(time.Now())
+		}
+
+		server := devapi.New(fixtures, token)
+		server.SetTokenTTL(tokenTTL)
+
+		listener, err := net.Listen("tcp", addr)
+		if err != nil {
+			color.Red("Error: %v", err)
+			os.Exit(1)
+		}
+		httpServer := &http.Server{
+			Handler:           server,
+			ReadHeaderTimeout: 10 * time.Second,
+		}
+
+		endpoint := "http://" + listener.Addr().String()
+		color.Green("✓ Fake API listening on %s", endpoint)
+		fmt.Printf("  Try: darkstorage --endpoint %s --api-key dev trash list\n", endpoint)
+		fmt.Println("  Press Ctrl+C to stop")
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		errCh := make(chan error, 1)
+		go func() {
+			errCh <- httpServer.Serve(listener)
+		}()
+
+		select {
+		case err := <-errCh:
+			if !errors.Is(err, http.ErrServerClosed) {
+				color.Red("Error: %v", err)
+				os.Exit(1)
+			}
+		case <-ctx.Done():
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			httpServer.Shutdown(shutdownCtx)
+			cancel()
+		}
+
+		if dumpPath != "" {
+			if err := dumpFixtures(server, dumpPath); err != nil {
+				color.Red("Error: saving state: %v", err)
+				os.Exit(1)
+			}
+			color.Green("✓ State saved to %s", dumpPath)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(devCmd)
+	devCmd.AddCommand(devAPIServerCmd)
+
+	devAPIServerCmd.Flags().String("addr", "127.0.0.1:8787", "listen address")
+	devAPIServerCmd.Flags().String("fixtures", "", "JSON file to seed the server state from")
+	devAPIServerCmd.Flags().Bool("empty", false, "start with no data instead of the built-in fixtures")
+	devAPIServerCmd.Flags().String("token", "", "require this bearer token (default: accept any)")
+	devAPIServerCmd.Flags().String("dump", "", "write the final state to this file on exit")
+	devAPIServerCmd.Flags().Duration("token-ttl", time.Hour, "lifetime of access tokens issued by the OAuth endpoints")
+}
+
+func dumpFixtures(server *devapi.Server, path string) error {
+	snapshot, err := server.Snapshot()
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(snapshot, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0600)
+}
@@ -141,7 +141,7 @@ func unifiedDiff(text1, text2, file1, file2 string) {
 }
 
 func binaryDiff(data1, data2 []byte) {
-	fmt.Println("Binary files - showing hex diff:\n")
+	fmt.Print("Binary files - showing hex diff:\n\n")
 
 	maxLen := len(data1)
 	if len(data2) > maxLen {
@@ -151,7 +151,7 @@ func binaryDiff(data1, data2 []byte) {
 	// Limit output to first 256 bytes
 	if maxLen > 256 {
 		maxLen = 256
-		fmt.Println("(Showing first 256 bytes only)\n")
+		fmt.Print("(Showing first 256 bytes only)\n\n")
 	}
 
 	differences := 0
@@ -6,6 +6,7 @@ import (
 	"net/http"
 	"os"
 
+	"github.com/darkstorage/cli/internal/filetype"
 	"github.com/fatih/color"
 	"github.com/spf13/cobra"
 )
@@ -66,19 +67,8 @@ Examples:
 		}
 
 		// Detect additional formats by magic bytes
-		if len(buffer) >= 4 {
-			// Check for common archive formats
-			if buffer[0] == 0x50 && buffer[1] == 0x4B && buffer[2] == 0x03 && buffer[3] == 0x04 {
-				fmt.Println("  Format: ZIP/JAR/APK/DOCX/XLSX")
-			} else if buffer[0] == 0x1F && buffer[1] == 0x8B {
-				fmt.Println("  Format: GZIP")
-			} else if buffer[0] == 0x42 && buffer[1] == 0x5A && buffer[2] == 0x68 {
-				fmt.Println("  Format: BZIP2")
-			} else if buffer[0] == 'u' && buffer[1] == 's' && buffer[2] == 't' && buffer[3] == 'a' {
-				if len(buffer) >= 263 && buffer[257] == 'u' && buffer[258] == 's' && buffer[259] == 't' && buffer[260] == 'a' && buffer[261] == 'r' {
-					fmt.Println("  Format: TAR archive")
-				}
-			}
+		if format := filetype.Detect(buffer[:n]); format != "" {
+			fmt.Printf("  Format: %s\n", format)
 		}
 	},
 }
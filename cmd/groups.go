@@ -1,11 +1,11 @@
 package cmd
 
 import (
-	"encoding/json"
 	"fmt"
 	"os"
 	"text/tabwriter"
 
+	apiv1 "github.com/darkstorage/cli/internal/api/v1"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
@@ -21,36 +21,26 @@ var groupsListCmd = &cobra.Command{
 	Short: "List your groups",
 	Run: func(cmd *cobra.Command, args []string) {
 		client := newAPIClient()
-		resp, err := client.get("/groups")
+		groups, err := apiv1.Collect(cmd.Context(), 0, client.Groups())
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 			os.Exit(1)
 		}
 
 		if viper.GetBool("json") {
-			fmt.Println(string(resp))
+			printJSON(groups)
 			return
 		}
 
-		var result struct {
-			Groups []struct {
-				ID          string `json:"id"`
-				Name        string `json:"name"`
-				MemberCount int    `json:"member_count"`
-				MyRole      string `json:"my_role"`
-			} `json:"groups"`
-		}
-		json.Unmarshal(resp, &result)
-
-		if len(result.Groups) == 0 {
+		if len(groups) == 0 {
 			fmt.Println("No groups found")
 			return
 		}
 
 		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
 		fmt.Fprintln(w, "ID\tNAME\tMEMBERS\tMY ROLE")
-		for _, g := range result.Groups {
-			fmt.Fprintf(w, "%s\t%s\t%d\t%s\n", g.ID[:8], g.Name, g.MemberCount, g.MyRole)
+		for _, g := range groups {
+			fmt.Fprintf(w, "%s\t%s\t%d\t%s\n", shortID(g.ID), g.Name, g.MemberCount, g.MyRole)
 		}
 		w.Flush()
 	},
@@ -65,23 +55,16 @@ var groupsCreateCmd = &cobra.Command{
 		description, _ := cmd.Flags().GetString("description")
 
 		client := newAPIClient()
-		body := map[string]string{
-			"name":        name,
-			"description": description,
-		}
-
-		resp, err := client.post("/groups", body)
+		group, err := client.CreateGroup(cmd.Context(), apiv1.CreateGroupRequest{
+			Name:        name,
+			Description: description,
+		})
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 			os.Exit(1)
 		}
 
-		var result struct {
-			ID   string `json:"id"`
-			Name string `json:"name"`
-		}
-		json.Unmarshal(resp, &result)
-		fmt.Printf("Created group '%s' (ID: %s)\n", result.Name, result.ID[:8])
+		fmt.Printf("Created group '%s' (ID: %s)\n", group.Name, shortID(group.ID))
 	},
 }
 
@@ -95,12 +78,10 @@ var groupsAddMemberCmd = &cobra.Command{
 		role, _ := cmd.Flags().GetString("role")
 
 		client := newAPIClient()
-		body := map[string]string{
-			"email": email,
-			"role":  role,
-		}
-
-		_, err := client.post(fmt.Sprintf("/groups/%s/members", groupID), body)
+		err := client.AddGroupMember(cmd.Context(), groupID, apiv1.AddGroupMemberRequest{
+			Email: email,
+			Role:  role,
+		})
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 			os.Exit(1)
@@ -119,7 +100,7 @@ var groupsRemoveMemberCmd = &cobra.Command{
 		email := args[1]
 
 		client := newAPIClient()
-		err := client.delete(fmt.Sprintf("/groups/%s/members/%s", groupID, email))
+		err := client.RemoveGroupMember(cmd.Context(), groupID, email)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 			os.Exit(1)
@@ -136,30 +117,20 @@ var groupsMembersCmd = &cobra.Command{
 	Run: func(cmd *cobra.Command, args []string) {
 		groupID := args[0]
 		client := newAPIClient()
-		resp, err := client.get(fmt.Sprintf("/groups/%s/members", groupID))
+		members, err := apiv1.Collect(cmd.Context(), 0, client.GroupMembers(groupID))
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 			os.Exit(1)
 		}
 
 		if viper.GetBool("json") {
-			fmt.Println(string(resp))
+			printJSON(members)
 			return
 		}
 
-		var result struct {
-			Members []struct {
-				Email  string `json:"email"`
-				Name   string `json:"name"`
-				Role   string `json:"role"`
-				Status string `json:"status"`
-			} `json:"members"`
-		}
-		json.Unmarshal(resp, &result)
-
 		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
 		fmt.Fprintln(w, "EMAIL\tNAME\tROLE\tSTATUS")
-		for _, m := range result.Members {
+		for _, m := range members {
 			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", m.Email, m.Name, m.Role, m.Status)
 		}
 		w.Flush()
@@ -185,7 +156,7 @@ var groupsDeleteCmd = &cobra.Command{
 		}
 
 		client := newAPIClient()
-		err := client.delete(fmt.Sprintf("/groups/%s", groupID))
+		err := client.DeleteGroup(cmd.Context(), groupID)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 			os.Exit(1)
@@ -1,7 +1,7 @@
 package main
 
 import (
-	"encoding/json"
+	"context"
 	"fmt"
 	"log"
 	"path/filepath"
@@ -55,7 +55,9 @@ func main() {
 	application.makeUI()
 	application.refreshStatus()
 
-	// Auto-refresh every 5 seconds
+	// Refresh when the daemon reports changes, and poll slowly to notice
+	// it going away
+	go application.watchEvents()
 	go application.autoRefresh()
 
 	myWindow.ShowAndRun()
@@ -340,29 +342,47 @@ func (a *App) refreshStatus() {
 }
 
 func (a *App) refreshActivity() {
-	req := &ipc.Command{
-		Type: "get_activity",
-		Data: []byte(`{"limit": 20}`),
-	}
-
-	resp, err := a.ipcClient.SendCommand(req)
+	activities, err := a.ipcClient.GetActivity(&ipc.GetActivityRequest{Limit: 20})
 	if err != nil {
 		return
 	}
 
-	if resp.Success {
-		var activityResp ipc.GetActivityResponse
-		if err := json.Unmarshal(resp.Data, &activityResp); err == nil {
-			a.activities = activityResp.Activities
-			if a.activityList != nil {
-				a.activityList.Refresh()
+	a.activities = activities
+	if a.activityList != nil {
+		a.activityList.Refresh()
+	}
+}
+
+// watchEvents refreshes the views as the daemon reports changes
+func (a *App) watchEvents() {
+	topics := []string{
+		ipc.TopicTransferCompleted,
+		ipc.TopicFolderPaused,
+		ipc.TopicConflictCreated,
+		ipc.TopicQueueSize,
+	}
+	err := a.ipcClient.Subscribe(context.Background(), topics, func(event *ipc.Event) {
+		switch event.Topic {
+		case ipc.TopicQueueSize:
+			var queue ipc.QueueSizeEvent
+			if err := event.Decode(&queue); err == nil {
+				a.queueSizeLabel.SetText(fmt.Sprintf("Queue: %d pending", queue.Size))
 			}
+		case ipc.TopicTransferCompleted:
+			a.refreshActivity()
+		default:
+			// Connected, or a folder or conflict changed
+			a.refreshStatus()
+			a.refreshActivity()
 		}
+	})
+	if err != nil {
+		log.Printf("Event subscription ended: %v", err)
 	}
 }
 
 func (a *App) autoRefresh() {
-	ticker := time.NewTicker(5 * time.Second)
+	ticker := time.NewTicker(30 * time.Second)
 	defer ticker.Stop()
 
 	for range ticker.C {
@@ -230,7 +230,7 @@ Examples:
 			},
 		}
 
-		fmt.Println("Integrity Tracking Status:\n")
+		fmt.Print("Integrity Tracking Status:\n\n")
 
 		table := tablewriter.NewWriter(os.Stdout)
 		table.SetHeader([]string{"Path", "Algorithm", "Files", "Last Scan", "Violations", "Interval"})
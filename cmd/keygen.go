@@ -129,9 +129,6 @@ Examples:
 		fmt.Println("  2. Use it to authenticate:")
 		fmt.Printf("     %s\n", color.BlueString("darkstorage login --key %s", key.KeyPrefix+"..."))
 		fmt.Println()
-		fmt.Println("  3. Or save to config file:")
-		fmt.Printf("     %s\n", color.BlueString("echo 'api_key: %s' > ~/.darkstorage/config.yaml", apiKeyValue))
-		fmt.Println()
 	},
 }
 
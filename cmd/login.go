@@ -2,12 +2,15 @@ package cmd
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"net/http"
+	"net/url"
 	"os"
-	"path/filepath"
+	"strings"
+	"sync"
 	"time"
 
+	"github.com/darkstorage/cli/internal/auth"
 	"github.com/fatih/color"
 	"github.com/pkg/browser"
 	"github.com/spf13/cobra"
@@ -17,9 +20,10 @@ import (
 // Auth Config Constants
 const (
 	ClientID     = "darkstorage-cli"
-	RedirectPort = "4321"
 	RedirectPath = "/callback"
-	AuthBaseURL  = "https://console.darkstorage.io/auth/login"
+	AuthorizeURL = "https://console.darkstorage.io/oauth/authorize"
+	TokenPath    = "/v1/oauth/token"
+	DevicePath   = "/v1/oauth/device/code"
 )
 
 var loginCmd = &cobra.Command{
@@ -27,22 +31,30 @@ var loginCmd = &cobra.Command{
 	Short: "Log in to Dark Storage",
 	Long: `Log in to Dark Storage using your preferred method:
   - OAuth/SSO via browser (default)
+  - Device code, for machines without a browser (use --device flag)
   - API key (use --key flag)
 
 Examples:
   darkstorage login                    # OAuth/SSO login via browser
+  darkstorage login --device           # Enter a code on another device
   darkstorage login --key YOUR_API_KEY # Login with API key
   darkstorage login --provider google  # Login with specific OAuth provider`,
 	Run: func(cmd *cobra.Command, args []string) {
 		apiKey, _ := cmd.Flags().GetString("key")
 		provider, _ := cmd.Flags().GetString("provider")
+		port, _ := cmd.Flags().GetInt("port")
+		noBrowser, _ := cmd.Flags().GetBool("no-browser")
+		device, _ := cmd.Flags().GetBool("device")
 
 		if apiKey != "" {
 			// API Key Login
 			loginWithAPIKey(apiKey)
+		} else if device {
+			// Device Authorization Grant
+			performDeviceFlow(cmd.Context())
 		} else {
 			// OAuth/SSO Login
-			performOAuthFlow(provider)
+			performOAuthFlow(cmd.Context(), provider, port, !noBrowser)
 		}
 	},
 }
@@ -52,6 +64,9 @@ func init() {
 
 	loginCmd.Flags().String("key", "", "API key for direct authentication")
 	loginCmd.Flags().String("provider", "afterdark", "OAuth provider (afterdark, google, github)")
+	loginCmd.Flags().Int("port", 0, "local port for the OAuth callback (default: any free port)")
+	loginCmd.Flags().Bool("no-browser", false, "print the login URL instead of opening a browser")
+	loginCmd.Flags().Bool("device", false, "log in by entering a code on another device (for SSH sessions and servers)")
 }
 
 func loginWithAPIKey(apiKey string) {
@@ -61,157 +76,226 @@ func loginWithAPIKey(apiKey string) {
 		os.Exit(1)
 	}
 
-	// Save API key to config
-	viper.Set("api_key", apiKey)
-
-	home, err := os.UserHomeDir()
+	// API keys don't expire or refresh
+	err := saveCredentials(map[string]string{
+		"endpoint":      viper.GetString("endpoint"),
+		"api_key":       apiKey,
+		"refresh_token": "",
+		"token_expiry":  "",
+	})
 	if err != nil {
-		color.Red("Error getting home directory: %v", err)
+		color.Red("Error saving credentials: %v", err)
 		os.Exit(1)
 	}
 
-	configPath := filepath.Join(home, ".darkstorage")
-	if err := os.MkdirAll(configPath, 0700); err != nil {
-		color.Red("Error creating config directory: %v", err)
-		os.Exit(1)
-	}
+	color.Green("✓ Successfully logged in with API key")
+	fmt.Printf("  Credentials saved to: %s\n", credentialStore())
+}
 
-	configFile := filepath.Join(configPath, "config.yaml")
-	if err := viper.WriteConfigAs(configFile); err != nil {
-		color.Red("Error saving config: %v", err)
-		os.Exit(1)
+// oauthConfig returns the OAuth client settings. The URLs can be pointed at
+// another server (e.g. `darkstorage dev api-server`) via oauth.authorize_url,
+// oauth.token_url and oauth.device_url.
+func oauthConfig(redirectURL string) *auth.Config {
+	authorizeURL := viper.GetString("oauth.authorize_url")
+	if authorizeURL == "" {
+		authorizeURL = AuthorizeURL
+	}
+	tokenURL := viper.GetString("oauth.token_url")
+	if tokenURL == "" {
+		tokenURL = strings.TrimSuffix(viper.GetString("endpoint"), "/") + TokenPath
+	}
+	deviceURL := viper.GetString("oauth.device_url")
+	if deviceURL == "" {
+		deviceURL = strings.TrimSuffix(viper.GetString("endpoint"), "/") + DevicePath
 	}
 
-	color.Green("✓ Successfully logged in with API key")
-	fmt.Printf("  Config saved to: %s\n", configFile)
+	return &auth.Config{
+		ClientID:      ClientID,
+		AuthURL:       authorizeURL,
+		TokenURL:      tokenURL,
+		DeviceAuthURL: deviceURL,
+		RedirectURL:   redirectURL,
+	}
 }
 
-func performOAuthFlow(provider string) {
+func performOAuthFlow(ctx context.Context, provider string, port int, openBrowser bool) {
 	fmt.Printf("Starting OAuth login with provider: %s\n", provider)
 
 	// 1. Setup Local Callback Server
-	tokenChan := make(chan string)
-	errChan := make(chan error)
-
-	server := &http.Server{Addr: ":" + RedirectPort}
-
-	http.HandleFunc(RedirectPath, func(w http.ResponseWriter, r *http.Request) {
-		// Check for error parameters
-		if errVal := r.URL.Query().Get("error"); errVal != "" {
-			errDesc := r.URL.Query().Get("error_description")
-			fmt.Fprintf(w, `
-<!DOCTYPE html>
-<html>
-<head><title>Authentication Failed</title></head>
-<body style="font-family: system-ui; padding: 2rem; text-align: center;">
-	<h1 style="color: #dc2626;">Authentication Failed</h1>
-	<p>%s</p>
-	<p style="color: #666;">You can close this window and return to the terminal.</p>
-</body>
-</html>`, errDesc)
-			errChan <- fmt.Errorf("auth error: %s - %s", errVal, errDesc)
-			return
-		}
+	state := auth.NewState()
+	verifier := auth.NewVerifier()
 
-		// Retrieve token
-		token := r.URL.Query().Get("token")
-		if token == "" {
-			w.WriteHeader(http.StatusBadRequest)
-			fmt.Fprint(w, `
-<!DOCTYPE html>
-<html>
-<head><title>Authentication Failed</title></head>
-<body style="font-family: system-ui; padding: 2rem; text-align: center;">
-	<h1 style="color: #dc2626;">No Token Received</h1>
-	<p style="color: #666;">You can close this window and return to the terminal.</p>
-</body>
-</html>`)
-			errChan <- fmt.Errorf("no token in callback")
-			return
-		}
+	callback, err := auth.ListenCallback(port, RedirectPath, state)
+	if err != nil {
+		color.Red("Error starting callback server: %v", err)
+		os.Exit(1)
+	}
+	defer callback.Close()
 
-		w.Write([]byte(`
-<!DOCTYPE html>
-<html>
-<head><title>Authentication Successful</title></head>
-<body style="font-family: system-ui; padding: 2rem; text-align: center;">
-	<h1 style="color: #16a34a;">✓ Authentication Successful!</h1>
-	<p>You can now close this window and return to the terminal.</p>
-</body>
-</html>`))
-		tokenChan <- token
+	oauth := oauthConfig(callback.RedirectURL())
+
+	// 2. Open Browser
+	targetURL := oauth.AuthCodeURL(state, verifier, url.Values{
+		"provider": {provider},
+		"mode":     {"login"},
 	})
 
-	go func() {
-		if err := server.ListenAndServe(); err != http.ErrServerClosed {
-			errChan <- err
+	if openBrowser {
+		fmt.Printf("\nOpening browser for authentication...\n")
+		fmt.Printf("If the browser doesn't open, visit:\n  %s\n\n", targetURL)
+		if err := browser.OpenURL(targetURL); err != nil {
+			color.Yellow("Warning: Failed to open browser automatically")
+			fmt.Printf("Please visit the URL above manually.\n\n")
 		}
-	}()
+	} else {
+		fmt.Printf("\nVisit this URL to authenticate:\n  %s\n", targetURL)
+		fmt.Printf("(On a remote machine, use 'darkstorage login --device' instead.)\n\n")
+	}
 
-	// Wait a moment for server to start
-	time.Sleep(100 * time.Millisecond)
+	fmt.Println("Waiting for authentication...")
 
-	// 2. Open Browser
-	redirectURL := fmt.Sprintf("http://localhost:%s%s", RedirectPort, RedirectPath)
-	targetURL := fmt.Sprintf("%s?client_id=%s&redirect_uri=%s&provider=%s&mode=login",
-		AuthBaseURL, ClientID, redirectURL, provider)
+	// 3. Wait for result
+	waitCtx, cancel := context.WithTimeout(ctx, 5*time.Minute)
+	defer cancel()
 
-	fmt.Printf("\nOpening browser for authentication...\n")
-	fmt.Printf("If the browser doesn't open, visit:\n  %s\n\n", targetURL)
+	code, err := callback.Wait(waitCtx)
+	if errors.Is(err, context.DeadlineExceeded) {
+		color.Red("\n✗ Authentication timed out after 5 minutes")
+		os.Exit(1)
+	}
+	if err != nil {
+		color.Red("\n✗ Authentication failed: %v", err)
+		os.Exit(1)
+	}
 
-	if err := browser.OpenURL(targetURL); err != nil {
-		color.Yellow("Warning: Failed to open browser automatically")
-		fmt.Printf("Please visit the URL above manually.\n\n")
+	// 4. Exchange the code for tokens
+	token, err := oauth.Exchange(ctx, newBaseHTTPClient(), code, verifier)
+	if err != nil {
+		color.Red("\n✗ Authentication failed: %v", err)
+		os.Exit(1)
 	}
 
-	fmt.Println("Waiting for authentication...")
+	fmt.Println()
+	finishLogin(token)
+}
 
-	// 3. Wait for result
-	select {
-	case token := <-tokenChan:
-		server.Shutdown(context.Background())
-
-		fmt.Println()
-		if err := saveToken(token); err != nil {
-			color.Red("✗ Failed to save token: %v", err)
-			os.Exit(1)
-		}
+// performDeviceFlow logs in with the device authorization grant: the user
+// enters a short code on any device with a browser while we poll for the
+// result. Nothing here needs a local browser or an inbound connection.
+func performDeviceFlow(ctx context.Context) {
+	oauth := oauthConfig("")
+	client := newBaseHTTPClient()
 
-		color.Green("✓ Successfully logged in!")
-		home, _ := os.UserHomeDir()
-		configPath := filepath.Join(home, ".darkstorage", "config.yaml")
-		fmt.Printf("  Config saved to: %s\n", configPath)
-		fmt.Println("\nYou can now use the Dark Storage CLI.")
+	dc, err := oauth.DeviceAuth(ctx, client)
+	if err != nil {
+		color.Red("Error starting device login: %v", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("To log in, visit:\n  %s\n\n", dc.VerificationURI)
+	fmt.Printf("and enter the code: ")
+	color.New(color.Bold).Println(dc.UserCode)
+	if dc.VerificationURIComplete != "" {
+		fmt.Printf("\nOr open this link, which includes the code:\n  %s\n", dc.VerificationURIComplete)
+	}
+	if dc.Expiry.IsZero() {
+		fmt.Println("\nWaiting for authentication...")
+	} else {
+		fmt.Printf("\nWaiting for authentication (code expires in %s)...\n", formatLifetime(time.Until(dc.Expiry)))
+	}
 
-	case err := <-errChan:
-		server.Shutdown(context.Background())
+	token, err := oauth.PollDevice(ctx, client, dc)
+	if err != nil {
 		color.Red("\n✗ Authentication failed: %v", err)
 		os.Exit(1)
+	}
 
-	case <-time.After(5 * time.Minute):
-		server.Shutdown(context.Background())
-		color.Red("\n✗ Authentication timed out after 5 minutes")
+	fmt.Println()
+	finishLogin(token)
+}
+
+// finishLogin saves the tokens from a completed OAuth flow
+func finishLogin(token *auth.Token) {
+	if err := saveToken(token); err != nil {
+		color.Red("✗ Failed to save token: %v", err)
 		os.Exit(1)
 	}
-}
 
-func saveToken(token string) error {
-	viper.Set("api_key", token)
+	color.Green("✓ Successfully logged in!")
+	if !token.Expiry.IsZero() {
+		fmt.Printf("  Access token valid for %s (refreshed automatically)\n", formatLifetime(time.Until(token.Expiry)))
+	}
+	fmt.Printf("  Credentials saved to: %s\n", credentialStore())
+	fmt.Println("\nYou can now use the Dark Storage CLI.")
+}
 
-	home, err := os.UserHomeDir()
-	if err != nil {
-		return fmt.Errorf("getting home directory: %w", err)
+// saveToken stores OAuth tokens in the credential store
+func saveToken(token *auth.Token) error {
+	expiry := ""
+	if !token.Expiry.IsZero() {
+		expiry = token.Expiry.UTC().Format(time.RFC3339)
 	}
+	return saveCredentials(map[string]string{
+		"endpoint":      viper.GetString("endpoint"),
+		"api_key":       token.AccessToken,
+		"refresh_token": token.RefreshToken,
+		"token_expiry":  expiry,
+	})
+}
 
-	configPath := filepath.Join(home, ".darkstorage")
-	if err := os.MkdirAll(configPath, 0700); err != nil {
-		return fmt.Errorf("creating config directory: %w", err)
+// storedToken returns the saved OAuth token, or nil when logged in with an
+// API key (or not at all)
+func storedToken() *auth.Token {
+	accessToken := viper.GetString("api_key")
+	refreshToken := viper.GetString("refresh_token")
+	if accessToken == "" || refreshToken == "" {
+		return nil
 	}
 
-	configFile := filepath.Join(configPath, "config.yaml")
-	if err := viper.WriteConfigAs(configFile); err != nil {
-		return fmt.Errorf("writing config file: %w", err)
+	token := &auth.Token{AccessToken: accessToken, RefreshToken: refreshToken}
+	if expiry := viper.GetString("token_expiry"); expiry != "" {
+		if t, err := time.Parse(time.RFC3339, expiry); err == nil {
+			token.Expiry = t
+		}
 	}
+	return token
+}
 
-	return nil
+var (
+	tokenSourceOnce   sync.Once
+	sharedTokenSource *auth.TokenSource
+)
+
+// oauthTokenSource returns the refreshing token source shared by all API
+// clients in this process, or nil when OAuth isn't in use. An explicit
+// --api-key always wins.
+func oauthTokenSource() *auth.TokenSource {
+	tokenSourceOnce.Do(func() {
+		if rootCmd.PersistentFlags().Changed("api-key") {
+			return
+		}
+		token := storedToken()
+		if token == nil {
+			return
+		}
+
+		sharedTokenSource = auth.NewTokenSource(oauthConfig(""), newBaseHTTPClient(), token, saveToken)
+	})
+	return sharedTokenSource
+}
+
+// formatLifetime renders a token lifetime such as 59m, 1h or 2h5m
+func formatLifetime(d time.Duration) string {
+	if d <= 0 {
+		return "expired"
+	}
+	d = d.Round(time.Minute)
+	if d < time.Minute {
+		return "less than a minute"
+	}
+	s := strings.TrimSuffix(d.String(), "0s")
+	if strings.HasSuffix(s, "h0m") {
+		s = strings.TrimSuffix(s, "0m")
+	}
+	return s
 }
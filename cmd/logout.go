@@ -3,7 +3,6 @@ package cmd
 import (
 	"fmt"
 	"os"
-	"path/filepath"
 
 	"github.com/fatih/color"
 	"github.com/spf13/cobra"
@@ -31,20 +30,12 @@ func performLogout() {
 		return
 	}
 
-	// Clear the API key
-	viper.Set("api_key", "")
-
-	// Save the updated config
-	home, err := os.UserHomeDir()
-	if err != nil {
-		color.Red("Error getting home directory: %v", err)
-		os.Exit(1)
-	}
-
-	configPath := filepath.Join(home, ".darkstorage")
-	configFile := filepath.Join(configPath, "config.yaml")
-
-	if err := viper.WriteConfigAs(configFile); err != nil {
+	// Clear the API key and any OAuth tokens
+	if err := saveCredentials(map[string]string{
+		"api_key":       "",
+		"refresh_token": "",
+		"token_expiry":  "",
+	}); err != nil {
 		color.Red("Error saving config: %v", err)
 		os.Exit(1)
 	}
@@ -1,11 +1,11 @@
 package cmd
 
 import (
-	"encoding/json"
 	"fmt"
 	"os"
 	"text/tabwriter"
 
+	apiv1 "github.com/darkstorage/cli/internal/api/v1"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
@@ -23,46 +23,31 @@ var permsListCmd = &cobra.Command{
 	Run: func(cmd *cobra.Command, args []string) {
 		fileID := args[0]
 		client := newAPIClient()
-		resp, err := client.get(fmt.Sprintf("/files/%s/permissions", fileID))
+		result, err := client.ListPermissions(cmd.Context(), fileID)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 			os.Exit(1)
 		}
 
 		if viper.GetBool("json") {
-			fmt.Println(string(resp))
+			printJSON(result.Permissions)
 			return
 		}
 
-		var result struct {
-			Permissions []struct {
-				ID           string `json:"id"`
-				GranteeType  string `json:"grantee_type"`
-				GranteeName  string `json:"grantee_name"`
-				GranteeEmail string `json:"grantee_email"`
-				Level        string `json:"level"`
-				CanRead      bool   `json:"can_read"`
-				CanWrite     bool   `json:"can_write"`
-				CanDelete    bool   `json:"can_delete"`
-				CanShare     bool   `json:"can_share"`
-			} `json:"permissions"`
-		}
-		json.Unmarshal(resp, &result)
-
 		if len(result.Permissions) == 0 {
 			fmt.Println("No permissions set")
 			return
 		}
 
 		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
-		fmt.Fprintln(w, "TYPE\tGRANTEE\tLEVEL\tREAD\tWRITE\tDELETE\tSHARE")
+		fmt.Fprintln(w, "ID\tTYPE\tGRANTEE\tLEVEL\tREAD\tWRITE\tDELETE\tSHARE")
 		for _, p := range result.Permissions {
 			grantee := p.GranteeName
 			if p.GranteeEmail != "" {
 				grantee = p.GranteeEmail
 			}
-			fmt.Fprintf(w, "%s\t%s\t%s\t%v\t%v\t%v\t%v\n",
-				p.GranteeType, grantee, p.Level,
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%v\t%v\t%v\t%v\n",
+				p.ID, p.GranteeType, grantee, p.Level,
 				boolMark(p.CanRead), boolMark(p.CanWrite),
 				boolMark(p.CanDelete), boolMark(p.CanShare))
 		}
@@ -85,20 +70,17 @@ var permsGrantCmd = &cobra.Command{
 			os.Exit(1)
 		}
 
-		client := newAPIClient()
-		body := map[string]interface{}{
-			"level": level,
-		}
+		req := apiv1.GrantPermissionRequest{Level: level}
 		if user != "" {
-			body["grantee_type"] = "user"
-			body["grantee_email"] = user
+			req.GranteeType = "user"
+			req.GranteeEmail = user
 		} else {
-			body["grantee_type"] = "group"
-			body["grantee_id"] = group
+			req.GranteeType = "group"
+			req.GranteeID = group
 		}
 
-		_, err := client.post(fmt.Sprintf("/files/%s/permissions", fileID), body)
-		if err != nil {
+		client := newAPIClient()
+		if err := client.GrantPermission(cmd.Context(), fileID, req); err != nil {
 			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 			os.Exit(1)
 		}
@@ -116,8 +98,7 @@ var permsRevokeCmd = &cobra.Command{
 		permID := args[1]
 
 		client := newAPIClient()
-		err := client.delete(fmt.Sprintf("/files/%s/permissions/%s", fileID, permID))
-		if err != nil {
+		if err := client.RevokePermission(cmd.Context(), fileID, permID); err != nil {
 			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 			os.Exit(1)
 		}
@@ -133,21 +114,16 @@ var permsCheckCmd = &cobra.Command{
 	Run: func(cmd *cobra.Command, args []string) {
 		fileID := args[0]
 		client := newAPIClient()
-		resp, err := client.get(fmt.Sprintf("/files/%s/permissions/effective", fileID))
+		result, err := client.GetEffectivePermissions(cmd.Context(), fileID)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 			os.Exit(1)
 		}
 
-		var result struct {
-			CanRead   bool `json:"can_read"`
-			CanWrite  bool `json:"can_write"`
-			CanDelete bool `json:"can_delete"`
-			CanShare  bool `json:"can_share"`
-			CanAdmin  bool `json:"can_admin"`
-			IsOwner   bool `json:"is_owner"`
+		if viper.GetBool("json") {
+			printJSON(result)
+			return
 		}
-		json.Unmarshal(resp, &result)
 
 		fmt.Printf("Read:   %s\n", boolMark(result.CanRead))
 		fmt.Printf("Write:  %s\n", boolMark(result.CanWrite))
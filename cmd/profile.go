@@ -0,0 +1,246 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/darkstorage/cli/internal/config"
+	"github.com/darkstorage/cli/internal/credentials"
+	"github.com/darkstorage/cli/internal/storage"
+	"github.com/fatih/color"
+	"github.com/olekukonko/tablewriter"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var profileCmd = &cobra.Command{
+	Use:   "profile",
+	Short: "Manage profiles for multiple accounts and endpoints",
+	Long: `Profiles keep separate credentials, API endpoint, storage backend and
+defaults for each account, e.g. staging, production and a customer tenant.
+
+Select a profile for one command with --profile or DARKSTORAGE_PROFILE, or
+make it the default with 'darkstorage profile use'. The settings at the top
+of config.yaml form the "default" profile.
+
+Examples:
+  darkstorage profile add staging --endpoint https://api.staging.darkstorage.io
+  darkstorage --profile staging login
+  darkstorage profile use staging
+  darkstorage profile list`,
+}
+
+var profileAddCmd = &cobra.Command{
+	Use:   "add <name>",
+	Short: "Create a profile",
+	Long: `Create a profile. Log in to it afterwards with
+'darkstorage --profile <name> login', or pass --key.
+
+Examples:
+  darkstorage profile add staging --endpoint https://api.staging.darkstorage.io
+  darkstorage profile add tenant --storage-endpoint s3.tenant.example --bucket backups --encryption sse-s3`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		name := args[0]
+		if err := config.ValidateProfileName(name); err != nil {
+			color.Red("Error: %v", err)
+			os.Exit(1)
+		}
+
+		file := readConfigFile()
+		if file.IsSet("profiles." + name) {
+			color.Red("Error: profile %s already exists", name)
+			os.Exit(1)
+		}
+
+		endpoint, _ := cmd.Flags().GetString("endpoint")
+		encryption, _ := cmd.Flags().GetString("encryption")
+		if err := storage.ValidateEncryption(encryption); err != nil {
+			color.Red("Error: %v", err)
+			os.Exit(1)
+		}
+
+		values := map[string]interface{}{"endpoint": endpoint}
+		for flag, key := range map[string]string{
+			"key":                "api_key",
+			"storage-endpoint":   "storage.endpoint",
+			"storage-region":     "storage.region",
+			"storage-access-key": "storage.access_key",
+			"storage-secret-key": "storage.secret_key",
+			"encryption":         "encryption",
+			"bucket":             "bucket",
+		} {
+			if value, _ := cmd.Flags().GetString(flag); value != "" {
+				values[key] = value
+			}
+		}
+		if cmd.Flags().Changed("storage-use-ssl") {
+			values["storage.use_ssl"], _ = cmd.Flags().GetBool("storage-use-ssl")
+		}
+
+		if err := saveProfileSettings(name, values); err != nil {
+			color.Red("Error: %v", err)
+			os.Exit(1)
+		}
+		color.Green("✓ Profile %s created", name)
+
+		if use, _ := cmd.Flags().GetBool("use"); use {
+			if err := writeConfigValues(map[string]interface{}{"profile": name}); err != nil {
+				color.Red("Error: %v", err)
+				os.Exit(1)
+			}
+			fmt.Printf("  Now using profile %s\n", name)
+		}
+		if _, ok := values["api_key"]; !ok {
+			fmt.Printf("  Log in with: darkstorage --profile %s login\n", name)
+		}
+	},
+}
+
+var profileListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List profiles",
+	Run: func(cmd *cobra.Command, args []string) {
+		file := readConfigFile()
+
+		defaultProfile := &config.Profile{Name: config.DefaultProfile, Endpoint: file.GetString("endpoint")}
+		file.UnmarshalKey("storage", &defaultProfile.Storage)
+		defaultProfile.Bucket = file.GetString("bucket")
+		defaultProfile.Encryption = file.GetString("encryption")
+
+		profiles := []*config.Profile{defaultProfile}
+		for _, name := range config.ProfileNames(file) {
+			profile, err := config.GetProfile(file, name)
+			if err != nil {
+				color.Red("Error: %v", err)
+				os.Exit(1)
+			}
+			profiles = append(profiles, profile)
+		}
+
+		if viper.GetBool("json") {
+			printJSON(profiles)
+			return
+		}
+
+		table := tablewriter.NewWriter(os.Stdout)
+		table.SetHeader([]string{"", "Name", "Endpoint", "Storage", "Bucket", "Encryption"})
+		table.SetBorder(false)
+		for _, p := range profiles {
+			marker := ""
+			if p.Name == profileName() {
+				marker = "*"
+			}
+			table.Append([]string{marker, p.Name, p.Endpoint, p.Storage.Endpoint, p.Bucket, p.Encryption})
+		}
+		table.Render()
+	},
+}
+
+var profileUseCmd = &cobra.Command{
+	Use:   "use <name>",
+	Short: "Set the default profile",
+	Long: `Set the profile used when --profile and DARKSTORAGE_PROFILE aren't given.
+Use "default" for the top-level settings.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		name := args[0]
+		if name != config.DefaultProfile && !readConfigFile().IsSet("profiles."+name) {
+			color.Red("Error: profile %s does not exist", name)
+			os.Exit(1)
+		}
+
+		if err := writeConfigValues(map[string]interface{}{"profile": name}); err != nil {
+			color.Red("Error: %v", err)
+			os.Exit(1)
+		}
+		color.Green("✓ Now using profile %s", name)
+	},
+}
+
+var profileRemoveCmd = &cobra.Command{
+	Use:   "remove <name>",
+	Short: "Delete a profile and its credentials",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		name := args[0]
+		force, _ := cmd.Flags().GetBool("force")
+
+		file, err := config.OpenYAML(configFilePath())
+		if err != nil {
+			color.Red("Error: %v", err)
+			os.Exit(1)
+		}
+		if name == config.DefaultProfile || !file.Remove("profiles."+name) {
+			color.Red("Error: profile %s does not exist", name)
+			os.Exit(1)
+		}
+
+		if !force {
+			fmt.Printf("This will delete profile %s and its stored credentials. Continue? [y/N]: ", name)
+			var confirm string
+			fmt.Scanln(&confirm)
+			if confirm != "y" && confirm != "Y" {
+				fmt.Println("Aborted")
+				return
+			}
+		}
+
+		if current, _ := file.Get("profile"); current == name {
+			file.Remove("profile")
+		}
+		if err := file.Save(); err != nil {
+			color.Red("Error: %v", err)
+			os.Exit(1)
+		}
+
+		store := credentialStore()
+		for _, key := range credentials.Keys {
+			if err := store.Erase(credentials.StoreKey(name, key)); err != nil {
+				color.Yellow("Warning: could not erase %s: %v", key, err)
+			}
+		}
+		color.Green("✓ Profile %s removed", name)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(profileCmd)
+	profileCmd.AddCommand(profileAddCmd)
+	profileCmd.AddCommand(profileListCmd)
+	profileCmd.AddCommand(profileUseCmd)
+	profileCmd.AddCommand(profileRemoveCmd)
+
+	profileAddCmd.Flags().String("endpoint", "https://api.darkstorage.io", "API endpoint")
+	profileAddCmd.Flags().String("key", "", "API key (or log in later with --profile <name> login)")
+	profileAddCmd.Flags().String("storage-endpoint", "", "storage (S3) endpoint")
+	profileAddCmd.Flags().String("storage-region", "", "storage region")
+	profileAddCmd.Flags().Bool("storage-use-ssl", true, "use TLS for storage")
+	profileAddCmd.Flags().String("storage-access-key", "", "storage (S3) access key")
+	profileAddCmd.Flags().String("storage-secret-key", "", "storage (S3) secret key")
+	profileAddCmd.Flags().String("encryption", "", "default server-side encryption for uploads: none or sse-s3")
+	profileAddCmd.Flags().String("bucket", "", "default bucket for put")
+	profileAddCmd.Flags().Bool("use", false, "make this the default profile")
+
+	profileRemoveCmd.Flags().Bool("force", false, "skip confirmation")
+}
+
+// profileName returns the active profile for display
+func profileName() string {
+	if profile := activeProfile(); profile != "" {
+		return profile
+	}
+	return config.DefaultProfile
+}
+
+// readConfigFile returns the config file's own values, without the active
+// profile, flags or environment applied
+func readConfigFile() *viper.Viper {
+	file := viper.New()
+	file.SetConfigFile(configFilePath())
+	if err := file.ReadInConfig(); err != nil && !os.IsNotExist(err) {
+		color.Red("Error reading config: %v", err)
+		os.Exit(1)
+	}
+	return file
+}
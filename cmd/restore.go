@@ -0,0 +1,532 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/darkstorage/cli/internal/api"
+	"github.com/darkstorage/cli/internal/atomicfile"
+	"github.com/darkstorage/cli/internal/bandwidth"
+	"github.com/darkstorage/cli/internal/config"
+	"github.com/darkstorage/cli/internal/db"
+	"github.com/darkstorage/cli/internal/fsmeta"
+	"github.com/darkstorage/cli/internal/storage"
+	syncpkg "github.com/darkstorage/cli/internal/sync"
+	"github.com/dustin/go-humanize"
+	"github.com/fatih/color"
+	"github.com/olekukonko/tablewriter"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var restoreCmd = &cobra.Command{
+	Use:   "restore <folder>",
+	Short: "Restore a sync folder to a point in time",
+	Long: `Roll a sync folder back to the state it had at a given time.
+
+The tree is reconstructed from the daemon's file history and the remote
+object versions, then written either back into the local folder or into a
+new remote prefix. Use --dry-run to preview what would change.
+
+The folder may be given as its local path or its sync folder ID. Timestamps
+accept RFC3339, "YYYY-MM-DD", "YYYY-MM-DD HH:MM[:SS]" or a relative age
+such as 90m, 6h or 2d.
+
+Examples:
+  darkstorage restore ~/Documents --at "2026-03-01 09:00" --dry-run
+  darkstorage restore ~/Documents --at 6h
+  darkstorage restore 3 --at 2026-03-01T09:00:00Z --to /tmp/documents-restored
+  darkstorage restore ~/Documents --at 2d --to-remote backups/documents-2026-03-01/`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		atValue, _ := cmd.Flags().GetString("at")
+		toPath, _ := cmd.Flags().GetString("to")
+		toRemote, _ := cmd.Flags().GetString("to-remote")
+		dryRun, _ := cmd.Flags().GetBool("dry-run")
+		keepExtra, _ := cmd.Flags().GetBool("keep-extra")
+
+		if toPath != "" && toRemote != "" {
+			color.Red("Error: --to and --to-remote are mutually exclusive")
+			os.Exit(1)
+		}
+
+		at, err := parseRestoreTime(atValue)
+		if err != nil {
+			color.Red("Error: %v", err)
+			os.Exit(1)
+		}
+
+		dataDir, err := config.GetDefaultDataDir()
+		if err != nil {
+			color.Red("Error: %v", err)
+			os.Exit(1)
+		}
+
+		database, err := db.New(dataDir)
+		if err != nil {
+			color.Red("Error opening sync database: %v", err)
+			os.Exit(1)
+		}
+		defer database.Close()
+
+		folder, err := findSyncFolder(database, args[0])
+		if err != nil {
+			color.Red("Error: %v", err)
+			os.Exit(1)
+		}
+
+		if err := initStorage(); err != nil {
+			color.Red("Error: %v", err)
+			os.Exit(1)
+		}
+
+		ctx := context.Background()
+
+		entries, err := buildRestoreTree(ctx, database, folder, at, storageBackend)
+		if err != nil {
+			color.Red("Error computing tree: %v", err)
+			os.Exit(1)
+		}
+
+		target := restoreTarget{localPath: folder.LocalPath}
+		if toPath != "" {
+			target.localPath = toPath
+		}
+		if toRemote != "" {
+			target.localPath = ""
+			target.remotePrefix = toRemote
+		}
+
+		actions, err := planRestore(entries, target, keepExtra, syncpkg.FolderExcludes(folder))
+		if err != nil {
+			color.Red("Error planning restore: %v", err)
+			os.Exit(1)
+		}
+
+		fmt.Printf("Restoring %s as of %s\n", folder.LocalPath, at.Format(time.RFC3339))
+		if target.remotePrefix != "" {
+			fmt.Printf("  Target: %s (remote)\n", target.remotePrefix)
+		} else {
+			fmt.Printf("  Target: %s\n", target.localPath)
+		}
+		fmt.Println()
+
+		printRestorePlan(actions)
+
+		if dryRun {
+			printRemovalHint(actions)
+			color.Yellow("\nDry run: no changes made")
+			return
+		}
+
+		restored, removed, failed := executeRestore(ctx, actions, target, storageBackend)
+
+		details := fmt.Sprintf("restored %d, removed %d, failed %d as of %s", restored, removed, failed, at.Format(time.RFC3339))
+		activity := &db.Activity{
+			SyncFolderID: &folder.ID,
+			Operation:    "restore",
+			Path:         folder.LocalPath,
+			Status:       "success",
+			Details:      &details,
+		}
+		if failed > 0 {
+			activity.Status = "error"
+		}
+		database.LogActivity(activity)
+
+		fmt.Println()
+		if failed > 0 {
+			color.Red("✗ Restore finished with %d error(s)", failed)
+			fmt.Printf("  Files restored: %d\n", restored)
+			fmt.Printf("  Files removed:  %d\n", removed)
+			os.Exit(1)
+		}
+
+		color.Green("✓ Restore complete!")
+		fmt.Printf("  Files restored: %d\n", restored)
+		fmt.Printf("  Files removed:  %d\n", removed)
+	},
+}
+
+// restoreEntry describes one file as it existed at the restore point
+type restoreEntry struct {
+	RelativePath string
+	RemotePath   string
+	VersionID    string
+	Size         int64
+	Hash         string
+}
+
+// restoreTarget is either a local directory or a remote prefix
+type restoreTarget struct {
+	localPath    string
+	remotePrefix string
+}
+
+// restoreAction is a single step of a restore plan
+type restoreAction struct {
+	Action string // restore, unchanged, remove, missing
+	Entry  *restoreEntry
+	Path   string
+}
+
+func findSyncFolder(database *db.DB, ref string) (*db.SyncFolder, error) {
+	if id, err := strconv.Atoi(ref); err == nil {
+		folder, err := database.GetSyncFolder(id)
+		if err != nil {
+			return nil, err
+		}
+		if folder != nil {
+			return folder, nil
+		}
+	}
+
+	folder, err := database.GetSyncFolderByPath(ref)
+	if err != nil {
+		return nil, err
+	}
+	if folder != nil {
+		return folder, nil
+	}
+
+	absPath, err := filepath.Abs(ref)
+	if err != nil {
+		return nil, err
+	}
+	folder, err = database.GetSyncFolderByPath(absPath)
+	if err != nil {
+		return nil, err
+	}
+	if folder == nil {
+		return nil, fmt.Errorf("no sync folder found for %s", ref)
+	}
+	return folder, nil
+}
+
+// parseRestoreTime accepts absolute timestamps or a relative age (e.g. 6h, 2d)
+func parseRestoreTime(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, fmt.Errorf("--at is required")
+	}
+
+	if strings.HasSuffix(value, "d") {
+		if days, err := strconv.Atoi(strings.TrimSuffix(value, "d")); err == nil {
+			return time.Now().Add(-time.Duration(days) * 24 * time.Hour), nil
+		}
+	}
+	if age, err := time.ParseDuration(value); err == nil {
+		return time.Now().Add(-age), nil
+	}
+
+	layouts := []string{
+		time.RFC3339,
+		"2006-01-02T15:04:05",
+		"2006-01-02 15:04:05",
+		"2006-01-02 15:04",
+		"2006-01-02",
+	}
+	for _, layout := range layouts {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return t, nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("unrecognized timestamp %q (use RFC3339, YYYY-MM-DD[ HH:MM[:SS]] or an age like 6h or 2d)", value)
+}
+
+// buildRestoreTree reconstructs the folder contents at the given time.
+// Remote object versions are authoritative for which files existed and which
+// version to fetch; the local file history fills in content hashes and covers
+// objects whose versions are no longer listed.
+func buildRestoreTree(ctx context.Context, database *db.DB, folder *db.SyncFolder, at time.Time, backend storage.StorageBackend) (map[string]*restoreEntry, error) {
+	prefix := strings.TrimSuffix(folder.RemotePath, "/") + "/"
+
+	versions, err := backend.List(ctx, prefix, &storage.ListOptions{
+		Recursive:       true,
+		IncludeVersions: true,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Pick the newest version of each object written at or before the restore point
+	latest := make(map[string]storage.FileInfo)
+	for _, v := range versions {
+		if v.IsDir || v.ModifiedAt.After(at) {
+			continue
+		}
+		if current, ok := latest[v.Path]; ok && !v.ModifiedAt.After(current.ModifiedAt) {
+			continue
+		}
+		latest[v.Path] = v
+	}
+
+	entries := make(map[string]*restoreEntry)
+	for path, v := range latest {
+		if v.IsDeleteMarker {
+			continue
+		}
+		relPath := filepath.FromSlash(strings.TrimPrefix(path, prefix))
+		entries[relPath] = &restoreEntry{
+			RelativePath: relPath,
+			RemotePath:   path,
+			VersionID:    v.VersionID,
+			Size:         v.Size,
+		}
+	}
+
+	history, err := database.GetFolderTreeAt(folder.ID, at)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, h := range history {
+		entry, ok := entries[h.RelativePath]
+		if !ok {
+			entry = &restoreEntry{
+				RelativePath: h.RelativePath,
+				RemotePath:   prefix + filepath.ToSlash(h.RelativePath),
+			}
+			if h.VersionID == nil {
+				// Known to exist locally at the time but no remote copy survives
+				entry.RemotePath = ""
+			}
+			entries[h.RelativePath] = entry
+		}
+		if h.VersionID != nil && *h.VersionID != "" {
+			entry.VersionID = *h.VersionID
+		}
+		if h.Hash != nil {
+			entry.Hash = *h.Hash
+		}
+		if h.Size != nil && entry.Size == 0 {
+			entry.Size = *h.Size
+		}
+	}
+
+	return entries, nil
+}
+
+// planRestore compares the reconstructed tree with the target and decides
+// what has to be written or removed. Excluded files and the sync engine's
+// temporary files were never synced, so they are never removed.
+func planRestore(entries map[string]*restoreEntry, target restoreTarget, keepExtra bool, excludes *syncpkg.ExcludeRules) ([]restoreAction, error) {
+	var actions []restoreAction
+
+	for _, entry := range entries {
+		if entry.RemotePath == "" {
+			actions = append(actions, restoreAction{Action: "missing", Entry: entry, Path: entry.RelativePath})
+			continue
+		}
+
+		if target.remotePrefix != "" {
+			actions = append(actions, restoreAction{Action: "restore", Entry: entry, Path: entry.RelativePath})
+			continue
+		}
+
+		action := "restore"
+		localPath := filepath.Join(target.localPath, entry.RelativePath)
+		if entry.Hash != "" {
+			if hash, err := syncpkg.HashFile(localPath); err == nil && hash == entry.Hash {
+				action = "unchanged"
+			}
+		}
+		actions = append(actions, restoreAction{Action: action, Entry: entry, Path: entry.RelativePath})
+	}
+
+	if target.remotePrefix == "" && !keepExtra {
+		err := filepath.Walk(target.localPath, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				if os.IsNotExist(err) {
+					return nil
+				}
+				return err
+			}
+			if info.IsDir() || atomicfile.IsTemp(path) {
+				return nil
+			}
+			relPath, err := filepath.Rel(target.localPath, path)
+			if err != nil {
+				return err
+			}
+			if excludes.ShouldExclude("/" + filepath.ToSlash(relPath)) {
+				return nil
+			}
+			if _, ok := entries[relPath]; !ok {
+				actions = append(actions, restoreAction{Action: "remove", Path: relPath})
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	sort.Slice(actions, func(i, j int) bool {
+		return actions[i].Path < actions[j].Path
+	})
+	return actions, nil
+}
+
+func printRestorePlan(actions []restoreAction) {
+	counts := make(map[string]int)
+
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{"Action", "Path", "Version", "Size"})
+	table.SetBorder(false)
+
+	for _, a := range actions {
+		counts[a.Action]++
+		if a.Action == "unchanged" {
+			continue
+		}
+
+		version, size := "-", "-"
+		if a.Entry != nil {
+			if a.Entry.VersionID != "" {
+				version = a.Entry.VersionID
+			}
+			size = humanize.Bytes(uint64(a.Entry.Size))
+		}
+		table.Append([]string{a.Action, a.Path, version, size})
+	}
+	table.Render()
+
+	fmt.Printf("\n%d to restore, %d to remove, %d unchanged",
+		counts["restore"], counts["remove"], counts["unchanged"])
+	if counts["missing"] > 0 {
+		color.Yellow(", %d without a remote version (cannot be restored)", counts["missing"])
+	} else {
+		fmt.Println()
+	}
+}
+
+// printRemovalHint warns that the removals in the plan are final, as the
+// files can't be got back afterwards
+func printRemovalHint(actions []restoreAction) {
+	removals := 0
+	for _, a := range actions {
+		if a.Action == "remove" {
+			removals++
+		}
+	}
+	if removals > 0 {
+		color.Yellow("\nThe %d local file(s) to remove did not exist at the restore point; keep them with --keep-extra", removals)
+	}
+}
+
+func executeRestore(ctx context.Context, actions []restoreAction, target restoreTarget, backend storage.StorageBackend) (restored, removed, failed int) {
+	for _, a := range actions {
+		var err error
+		switch a.Action {
+		case "restore":
+			if target.remotePrefix != "" {
+				dest := strings.TrimSuffix(target.remotePrefix, "/") + "/" + filepath.ToSlash(a.Path)
+				err = restoreToRemote(ctx, a.Entry, dest, backend)
+			} else {
+				err = restoreToLocal(ctx, a.Entry, filepath.Join(target.localPath, a.Path), backend)
+			}
+			if err == nil {
+				restored++
+				color.Green("✓ Restored: %s", a.Path)
+			}
+		case "remove":
+			err = os.Remove(filepath.Join(target.localPath, a.Path))
+			if err == nil {
+				removed++
+				color.Green("✓ Removed: %s", a.Path)
+			}
+		default:
+			continue
+		}
+
+		if err != nil {
+			failed++
+			color.Red("✗ %s: %v", a.Path, err)
+		}
+	}
+	return restored, removed, failed
+}
+
+func restoreToLocal(ctx context.Context, entry *restoreEntry, localPath string, backend storage.StorageBackend) error {
+	if err := os.MkdirAll(filepath.Dir(localPath), 0755); err != nil {
+		return err
+	}
+
+	outFile, err := atomicfile.Create(localPath)
+	if err != nil {
+		return err
+	}
+
+	_, download := transferLimiters()
+	result, err := backend.Download(ctx, entry.RemotePath, outFile, &storage.DownloadOptions{
+		VersionID: entry.VersionID,
+		Limiters:  []*bandwidth.Limiter{download},
+	})
+	if err != nil {
+		outFile.Abort()
+		return err
+	}
+
+	// History-only entries may not know their size
+	expected := atomicfile.Expected{Size: -1, MD5: atomicfile.MD5FromETag(result.ETag)}
+	if entry.Size > 0 {
+		expected.Size = entry.Size
+	}
+	return outFile.Commit(expected)
+}
+
+// restoreToRemote copies the restore point's version to dest, keeping its
+// metadata so the copy carries the same file attributes and checksum
+func restoreToRemote(ctx context.Context, entry *restoreEntry, dest string, backend storage.StorageBackend) error {
+	info, err := backend.StatVersion(ctx, entry.RemotePath, entry.VersionID)
+	if err != nil {
+		return err
+	}
+
+	metadata := make(map[string]string, len(info.Metadata)+1)
+	for k, v := range info.Metadata {
+		metadata[k] = v
+	}
+	if _, ok := fsmeta.Lookup(metadata, api.MetadataSHA256); !ok && entry.Hash != "" {
+		metadata[api.MetadataSHA256] = entry.Hash
+	}
+
+	upload, download := transferLimiters()
+	pr, pw := io.Pipe()
+
+	go func() {
+		_, err := backend.Download(ctx, entry.RemotePath, pw, &storage.DownloadOptions{
+			VersionID: entry.VersionID,
+			Limiters:  []*bandwidth.Limiter{download},
+		})
+		pw.CloseWithError(err)
+	}()
+
+	_, err = backend.Upload(ctx, pr, dest, &storage.UploadOptions{
+		ContentType:          info.ContentType,
+		Metadata:             metadata,
+		ServerSideEncryption: viper.GetString("encryption"),
+		Limiters:             []*bandwidth.Limiter{upload},
+	})
+	pr.CloseWithError(err)
+	return err
+}
+
+func init() {
+	rootCmd.AddCommand(restoreCmd)
+
+	restoreCmd.Flags().String("at", "", "point in time to restore (RFC3339, YYYY-MM-DD[ HH:MM], or age like 6h, 2d)")
+	restoreCmd.Flags().String("to", "", "restore into this local directory instead of the sync folder")
+	restoreCmd.Flags().String("to-remote", "", "restore into a new remote prefix (bucket/path/)")
+	restoreCmd.Flags().Bool("dry-run", false, "show what would be restored without changing anything")
+	restoreCmd.Flags().Bool("keep-extra", false, "keep local files that did not exist at the restore point")
+	restoreCmd.MarkFlagRequired("at")
+}
@@ -0,0 +1,139 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	syncpkg "github.com/darkstorage/cli/internal/sync"
+)
+
+func TestPlanRestore(t *testing.T) {
+	// local are the files in the folder now
+	local := map[string]string{
+		"same.txt":                        "kept",
+		"edited.txt":                      "edited since",
+		"docs/new.txt":                    "created since",
+		"build/out.o":                     "excluded",
+		"editor.swp":                      "excluded",
+		"docs/.darkstorage-a1b2.partial":  "download in progress",
+		"docs/.darkstorage-x.partial.bak": "not a download",
+	}
+
+	tests := []struct {
+		name         string
+		remotePrefix string
+		keepExtra    bool
+		excludes     []string
+		// entries are the files at the restore point, with the content they
+		// had; "" means there is no remote copy to restore from
+		entries map[string]string
+		want    []string
+	}{
+		{
+			name:    "restores changes and removes extras",
+			entries: map[string]string{"same.txt": "kept", "edited.txt": "original", "deleted.txt": "gone since"},
+			want: []string{
+				"remove build/out.o",
+				"restore deleted.txt",
+				"remove docs/.darkstorage-x.partial.bak",
+				"remove docs/new.txt",
+				"restore edited.txt",
+				"remove editor.swp",
+				"unchanged same.txt",
+			},
+		},
+		{
+			name:     "excluded files are left alone",
+			excludes: []string{"build/", "*.swp"},
+			entries:  map[string]string{"same.txt": "kept", "edited.txt": "original"},
+			want: []string{
+				"remove docs/.darkstorage-x.partial.bak",
+				"remove docs/new.txt",
+				"restore edited.txt",
+				"unchanged same.txt",
+			},
+		},
+		{
+			name:      "keep extra files",
+			keepExtra: true,
+			entries:   map[string]string{"same.txt": "kept", "edited.txt": "original"},
+			want: []string{
+				"restore edited.txt",
+				"unchanged same.txt",
+			},
+		},
+		{
+			name:      "no remote copy",
+			keepExtra: true,
+			entries:   map[string]string{"same.txt": "kept", "edited.txt": ""},
+			want: []string{
+				"missing edited.txt",
+				"unchanged same.txt",
+			},
+		},
+		{
+			name:         "remote target restores everything",
+			remotePrefix: "bucket/restored/",
+			entries:      map[string]string{"same.txt": "kept", "edited.txt": "original"},
+			want: []string{
+				"restore edited.txt",
+				"restore same.txt",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for rel, content := range local {
+				path := filepath.Join(dir, filepath.FromSlash(rel))
+				if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			entries := make(map[string]*restoreEntry)
+			for rel, content := range tt.entries {
+				entry := &restoreEntry{RelativePath: rel}
+				if content != "" {
+					entry.RemotePath = "bucket/folder/" + rel
+					entry.Hash = hashOf(t, content)
+				}
+				entries[rel] = entry
+			}
+
+			target := restoreTarget{localPath: dir, remotePrefix: tt.remotePrefix}
+			actions, err := planRestore(entries, target, tt.keepExtra, syncpkg.NewExcludeRules(tt.excludes))
+			if err != nil {
+				t.Fatalf("planRestore() error = %v", err)
+			}
+
+			var got []string
+			for _, a := range actions {
+				got = append(got, a.Action+" "+filepath.ToSlash(a.Path))
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("planRestore() =\n%q\nwant\n%q", got, tt.want)
+			}
+		})
+	}
+}
+
+// hashOf returns the hash the sync engine records for content
+func hashOf(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "file")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	hash, err := syncpkg.HashFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return hash
+}
@@ -1,10 +1,18 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
+	"net/http"
 	"os"
 	"path/filepath"
+	"sync"
 
+	"github.com/darkstorage/cli/internal/auth"
+	"github.com/darkstorage/cli/internal/bandwidth"
+	"github.com/darkstorage/cli/internal/config"
+	"github.com/darkstorage/cli/internal/transport"
+	"github.com/fatih/color"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
@@ -12,6 +20,11 @@ import (
 var (
 	cfgFile string
 	version string
+
+	// configErr is set when the configuration is invalid or the selected
+	// profile doesn't exist. Only the config and profile commands, which can
+	// fix that, run anyway.
+	configErr error
 )
 
 var rootCmd = &cobra.Command{
@@ -26,6 +39,18 @@ Get started:
   darkstorage login
   darkstorage ls
   darkstorage put ./file.txt my-bucket/`,
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		if configErr == nil || isConfigCommand(cmd) {
+			return
+		}
+		color.Red("Error: %v", configErr)
+		if errors.Is(configErr, config.ErrProfileNotFound) {
+			fmt.Println("See 'darkstorage profile list'")
+		} else {
+			fmt.Println("See 'darkstorage config validate'")
+		}
+		os.Exit(1)
+	},
 }
 
 func Execute() error {
@@ -42,38 +67,115 @@ func init() {
 	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is $HOME/.darkstorage/config.yaml)")
 	rootCmd.PersistentFlags().String("api-key", "", "API key (overrides config)")
 	rootCmd.PersistentFlags().String("endpoint", "https://api.darkstorage.io", "API endpoint")
+	rootCmd.PersistentFlags().String("profile", "", "configuration profile to use (default is the profile set by 'darkstorage profile use')")
 	rootCmd.PersistentFlags().BoolP("verbose", "v", false, "verbose output")
 	rootCmd.PersistentFlags().Bool("json", false, "output in JSON format")
+	rootCmd.PersistentFlags().Bool("trace", false, "dump API requests and responses to stderr (credentials redacted)")
+	rootCmd.PersistentFlags().Int("max-retries", 4, "retries for failed API requests")
+	rootCmd.PersistentFlags().Float64("rate-limit", 0, "maximum API requests per second (0 for unlimited)")
+	rootCmd.PersistentFlags().Int("limit-up", 0, "upload limit in KB/s shared by all transfers (0 for unlimited)")
+	rootCmd.PersistentFlags().Int("limit-down", 0, "download limit in KB/s shared by all transfers (0 for unlimited)")
 
 	viper.BindPFlag("api_key", rootCmd.PersistentFlags().Lookup("api-key"))
 	viper.BindPFlag("endpoint", rootCmd.PersistentFlags().Lookup("endpoint"))
+	viper.BindPFlag("profile", rootCmd.PersistentFlags().Lookup("profile"))
+	viper.BindPFlag("json", rootCmd.PersistentFlags().Lookup("json"))
+	viper.BindPFlag("trace", rootCmd.PersistentFlags().Lookup("trace"))
+	viper.BindPFlag("http.max_retries", rootCmd.PersistentFlags().Lookup("max-retries"))
+	viper.BindPFlag("http.rate_limit", rootCmd.PersistentFlags().Lookup("rate-limit"))
+	viper.BindPFlag("transfer.limit_up", rootCmd.PersistentFlags().Lookup("limit-up"))
+	viper.BindPFlag("transfer.limit_down", rootCmd.PersistentFlags().Lookup("limit-down"))
+}
+
+// isConfigCommand reports whether cmd is one of the config or profile
+// commands, which work on a broken configuration
+func isConfigCommand(cmd *cobra.Command) bool {
+	for c := cmd; c != nil; c = c.Parent() {
+		if c == configCmd || c == profileCmd {
+			return true
+		}
+	}
+	return false
+}
+
+// newHTTPClient returns a client for API calls with retries, rate limiting
+// and --trace output configured from flags and config. OAuth sessions are
+// refreshed transparently.
+func newHTTPClient() *http.Client {
+	client := newBaseHTTPClient()
+	if source := oauthTokenSource(); source != nil {
+		client.Transport = auth.Transport(source, client.Transport)
+	}
+	return client
+}
+
+// newBaseHTTPClient is newHTTPClient without credentials, for the OAuth
+// token endpoint itself
+func newBaseHTTPClient() *http.Client {
+	opts := transport.DefaultOptions()
+	opts.Retry.MaxRetries = viper.GetInt("http.max_retries")
+	opts.RateLimit = viper.GetFloat64("http.rate_limit")
+	opts.Burst = viper.GetInt("http.burst")
+	if viper.GetBool("trace") {
+		opts.Trace = os.Stderr
+	}
+	return transport.NewClient(opts)
+}
+
+var (
+	limitersOnce    sync.Once
+	uploadLimiter   *bandwidth.Limiter
+	downloadLimiter *bandwidth.Limiter
+)
+
+// transferLimiters returns the limiters every upload and download of this
+// command shares, configured in KB/s by --limit-up and --limit-down. Nil
+// means unlimited.
+func transferLimiters() (upload, download *bandwidth.Limiter) {
+	limitersOnce.Do(func() {
+		if up := viper.GetInt64("transfer.limit_up"); up > 0 {
+			uploadLimiter = bandwidth.NewLimiter(up * 1024)
+		}
+		if down := viper.GetInt64("transfer.limit_down"); down > 0 {
+			downloadLimiter = bandwidth.NewLimiter(down * 1024)
+		}
+	})
+	return uploadLimiter, downloadLimiter
 }
 
 func initConfig() {
+	if warning := config.MoveLegacyEnv(); warning != "" {
+		fmt.Fprintf(os.Stderr, "Warning: %s\n", warning)
+	}
+	config.Setup(viper.GetViper(), configDir())
+
 	if cfgFile != "" {
 		viper.SetConfigFile(cfgFile)
 	} else {
-		home, err := os.UserHomeDir()
-		if err != nil {
-			fmt.Fprintln(os.Stderr, err)
-			os.Exit(1)
-		}
+		os.MkdirAll(configDir(), 0700)
+		viper.SetConfigFile(filepath.Join(configDir(), "config.yaml"))
+	}
 
-		configPath := filepath.Join(home, ".darkstorage")
-		os.MkdirAll(configPath, 0700)
+	migrated, err := config.MigrateLegacy(configDir(), viper.ConfigFileUsed())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: could not migrate old config files: %v\n", err)
+	}
+	for _, path := range migrated {
+		fmt.Fprintf(os.Stderr, "Merged %s into %s\n", path, viper.ConfigFileUsed())
+	}
 
-		viper.AddConfigPath(configPath)
-		viper.SetConfigName("config")
-		viper.SetConfigType("yaml")
+	if err := viper.ReadInConfig(); err != nil && !os.IsNotExist(err) {
+		// A missing config file is okay for first run
+		fmt.Fprintln(os.Stderr, "Error reading config:", err)
 	}
 
-	viper.SetEnvPrefix("DARKSTORAGE")
-	viper.AutomaticEnv()
+	if err := config.ApplyProfile(viper.GetViper(), activeProfile()); err != nil {
+		configErr = err
+		return
+	}
+	loadCredentials()
 
-	if err := viper.ReadInConfig(); err != nil {
-		// Config file not found is okay for first run
-		if _, ok := err.(viper.ConfigFileNotFoundError); !ok {
-			fmt.Fprintln(os.Stderr, "Error reading config:", err)
-		}
+	if _, err := config.Load(viper.GetViper()); err != nil {
+		configErr = err
 	}
 }
@@ -1,11 +1,11 @@
 package cmd
 
 import (
-	"encoding/json"
 	"fmt"
 	"os"
 	"text/tabwriter"
 
+	apiv1 "github.com/darkstorage/cli/internal/api/v1"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
@@ -25,22 +25,20 @@ var scanFileCmd = &cobra.Command{
 		scanType, _ := cmd.Flags().GetString("type")
 
 		client := newAPIClient()
-		body := map[string]interface{}{
-			"scan_type": scanType,
-			"priority":  5,
-		}
-
-		resp, err := client.post(fmt.Sprintf("/files/%s/scan", filePath), body)
+		result, err := client.ScanFile(cmd.Context(), filePath, apiv1.ScanRequest{
+			ScanType: scanType,
+			Priority: 5,
+		})
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 			os.Exit(1)
 		}
 
-		var result struct {
-			Message string `json:"message"`
-			QueueID string `json:"queue_id"`
+		if viper.GetBool("json") {
+			printJSON(result)
+			return
 		}
-		json.Unmarshal(resp, &result)
+
 		fmt.Println(result.Message)
 		if result.QueueID != "" {
 			fmt.Printf("Queue ID: %s\n", result.QueueID)
@@ -55,28 +53,17 @@ var scanStatusCmd = &cobra.Command{
 	Run: func(cmd *cobra.Command, args []string) {
 		fileID := args[0]
 		client := newAPIClient()
-		resp, err := client.get(fmt.Sprintf("/files/%s/scan-result", fileID))
+		result, err := client.GetScanResult(cmd.Context(), fileID)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 			os.Exit(1)
 		}
 
 		if viper.GetBool("json") {
-			fmt.Println(string(resp))
+			printJSON(result)
 			return
 		}
 
-		var result struct {
-			Status     string `json:"status"`
-			ScanEngine string `json:"scan_engine"`
-			Severity   string `json:"severity"`
-			Threats    []struct {
-				Name     string `json:"name"`
-				Category string `json:"category"`
-			} `json:"threats"`
-		}
-		json.Unmarshal(resp, &result)
-
 		fmt.Printf("Status:   %s\n", result.Status)
 		fmt.Printf("Engine:   %s\n", result.ScanEngine)
 		fmt.Printf("Severity: %s\n", result.Severity)
@@ -94,35 +81,25 @@ var scanThreatsCmd = &cobra.Command{
 	Short: "List all detected threats",
 	Run: func(cmd *cobra.Command, args []string) {
 		client := newAPIClient()
-		resp, err := client.get("/scanner/threats")
+		threats, err := apiv1.Collect(cmd.Context(), 0, client.Threats())
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 			os.Exit(1)
 		}
 
 		if viper.GetBool("json") {
-			fmt.Println(string(resp))
+			printJSON(threats)
 			return
 		}
 
-		var result struct {
-			Threats []struct {
-				FileID   string `json:"file_id"`
-				FileName string `json:"file_name"`
-				Status   string `json:"status"`
-				Severity string `json:"severity"`
-			} `json:"threats"`
-		}
-		json.Unmarshal(resp, &result)
-
-		if len(result.Threats) == 0 {
+		if len(threats) == 0 {
 			fmt.Println("No threats detected")
 			return
 		}
 
 		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
 		fmt.Fprintln(w, "FILE\tSTATUS\tSEVERITY")
-		for _, t := range result.Threats {
+		for _, t := range threats {
 			fmt.Fprintf(w, "%s\t%s\t%s\n", t.FileName, t.Status, t.Severity)
 		}
 		w.Flush()
@@ -139,36 +116,26 @@ var scanQuarantineListCmd = &cobra.Command{
 	Short: "List quarantined files",
 	Run: func(cmd *cobra.Command, args []string) {
 		client := newAPIClient()
-		resp, err := client.get("/scanner/quarantine")
+		files, err := apiv1.Collect(cmd.Context(), 0, client.Quarantine())
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 			os.Exit(1)
 		}
 
 		if viper.GetBool("json") {
-			fmt.Println(string(resp))
+			printJSON(files)
 			return
 		}
 
-		var result struct {
-			Files []struct {
-				ID               string `json:"id"`
-				OriginalName     string `json:"original_name"`
-				QuarantineReason string `json:"quarantine_reason"`
-				FileSize         int64  `json:"file_size"`
-			} `json:"files"`
-		}
-		json.Unmarshal(resp, &result)
-
-		if len(result.Files) == 0 {
+		if len(files) == 0 {
 			fmt.Println("No quarantined files")
 			return
 		}
 
 		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
 		fmt.Fprintln(w, "ID\tNAME\tREASON\tSIZE")
-		for _, f := range result.Files {
-			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", f.ID[:8], f.OriginalName, f.QuarantineReason, formatBytes(f.FileSize))
+		for _, f := range files {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", shortID(f.ID), f.OriginalName, f.QuarantineReason, formatBytes(f.FileSize))
 		}
 		w.Flush()
 	},
@@ -181,8 +148,7 @@ var scanQuarantineReleaseCmd = &cobra.Command{
 	Run: func(cmd *cobra.Command, args []string) {
 		qID := args[0]
 		client := newAPIClient()
-		_, err := client.post(fmt.Sprintf("/scanner/quarantine/%s/release", qID), nil)
-		if err != nil {
+		if err := client.ReleaseQuarantine(cmd.Context(), qID); err != nil {
 			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 			os.Exit(1)
 		}
@@ -1,12 +1,14 @@
 package cmd
 
 import (
-	"encoding/json"
 	"fmt"
 	"os"
+	"strconv"
+	"strings"
 	"text/tabwriter"
 	"time"
 
+	apiv1 "github.com/darkstorage/cli/internal/api/v1"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
@@ -22,45 +24,39 @@ var shareCmd = &cobra.Command{
 	Short: "Create a share link for a file",
 	Args:  cobra.ExactArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
-		filePath := args[0]
 		expires, _ := cmd.Flags().GetString("expires")
 		password, _ := cmd.Flags().GetString("password")
 		maxDownloads, _ := cmd.Flags().GetInt("max-downloads")
 		oneTime, _ := cmd.Flags().GetBool("one-time")
 
-		client := newAPIClient()
-		body := map[string]interface{}{
-			"file_path": filePath,
+		req := apiv1.CreateShareLinkRequest{
+			FilePath:     args[0],
+			Password:     password,
+			MaxDownloads: maxDownloads,
+			OneTimeUse:   oneTime,
 		}
-
 		if expires != "" {
-			duration, _ := time.ParseDuration(expires)
-			expiresAt := time.Now().Add(duration)
-			body["expires_at"] = expiresAt.Format(time.RFC3339)
-		}
-		if password != "" {
-			body["password"] = password
-		}
-		if maxDownloads > 0 {
-			body["max_downloads"] = maxDownloads
-		}
-		if oneTime {
-			body["one_time_use"] = true
+			expiresAt, err := parseExpiry(expires)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+			req.ExpiresAt = &expiresAt
 		}
 
-		resp, err := client.post("/sharing/links", body)
+		client := newAPIClient()
+		link, err := client.CreateShareLink(cmd.Context(), req)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 			os.Exit(1)
 		}
 
-		var result struct {
-			URL   string `json:"url"`
-			Token string `json:"token"`
+		if viper.GetBool("json") {
+			printJSON(link)
+			return
 		}
-		json.Unmarshal(resp, &result)
 
-		fmt.Printf("Share link created:\n%s\n", result.URL)
+		fmt.Printf("Share link created:\n%s\n", link.URL)
 		if password != "" {
 			fmt.Println("(Password protected)")
 		}
@@ -72,38 +68,25 @@ var sharesListCmd = &cobra.Command{
 	Short: "List all share links",
 	Run: func(cmd *cobra.Command, args []string) {
 		client := newAPIClient()
-		resp, err := client.get("/sharing/links")
+		links, err := apiv1.Collect(cmd.Context(), 0, client.ShareLinks())
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 			os.Exit(1)
 		}
 
 		if viper.GetBool("json") {
-			fmt.Println(string(resp))
+			printJSON(links)
 			return
 		}
 
-		var result struct {
-			Links []struct {
-				ID            string     `json:"id"`
-				FileName      string     `json:"file_name"`
-				Token         string     `json:"token"`
-				ExpiresAt     *time.Time `json:"expires_at"`
-				DownloadCount int        `json:"download_count"`
-				MaxDownloads  *int       `json:"max_downloads"`
-				IsActive      bool       `json:"is_active"`
-			} `json:"links"`
-		}
-		json.Unmarshal(resp, &result)
-
-		if len(result.Links) == 0 {
+		if len(links) == 0 {
 			fmt.Println("No share links found")
 			return
 		}
 
 		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
 		fmt.Fprintln(w, "ID\tFILE\tDOWNLOADS\tEXPIRES\tSTATUS")
-		for _, l := range result.Links {
+		for _, l := range links {
 			expires := "Never"
 			if l.ExpiresAt != nil {
 				expires = l.ExpiresAt.Format("2006-01-02")
@@ -116,7 +99,7 @@ var sharesListCmd = &cobra.Command{
 			if !l.IsActive {
 				status = "Inactive"
 			}
-			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", l.ID[:8], l.FileName, downloads, expires, status)
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", shortID(l.ID), l.FileName, downloads, expires, status)
 		}
 		w.Flush()
 	},
@@ -127,10 +110,8 @@ var sharesRevokeCmd = &cobra.Command{
 	Short: "Revoke a share link",
 	Args:  cobra.ExactArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
-		linkID := args[0]
 		client := newAPIClient()
-		err := client.delete(fmt.Sprintf("/sharing/links/%s", linkID))
-		if err != nil {
+		if err := client.RevokeShareLink(cmd.Context(), args[0]); err != nil {
 			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 			os.Exit(1)
 		}
@@ -143,27 +124,18 @@ var sharesStatsCmd = &cobra.Command{
 	Short: "Get statistics for a share link",
 	Args:  cobra.ExactArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
-		linkID := args[0]
 		client := newAPIClient()
-		resp, err := client.get(fmt.Sprintf("/sharing/links/%s/stats", linkID))
+		result, err := client.GetShareLinkStats(cmd.Context(), args[0])
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 			os.Exit(1)
 		}
 
 		if viper.GetBool("json") {
-			fmt.Println(string(resp))
+			printJSON(result)
 			return
 		}
 
-		var result struct {
-			ViewCount      int   `json:"view_count"`
-			DownloadCount  int   `json:"download_count"`
-			BandwidthUsed  int64 `json:"bandwidth_used"`
-			UniqueVisitors int   `json:"unique_visitors"`
-		}
-		json.Unmarshal(resp, &result)
-
 		fmt.Printf("Views:           %d\n", result.ViewCount)
 		fmt.Printf("Downloads:       %d\n", result.DownloadCount)
 		fmt.Printf("Bandwidth Used:  %s\n", formatBytes(result.BandwidthUsed))
@@ -183,3 +155,17 @@ func init() {
 	shareCmd.Flags().Int("max-downloads", 0, "limit number of downloads")
 	shareCmd.Flags().Bool("one-time", false, "one-time download link")
 }
+
+// parseExpiry turns a lifetime such as 24h or 7d into an absolute time
+func parseExpiry(value string) (time.Time, error) {
+	if strings.HasSuffix(value, "d") {
+		if days, err := strconv.Atoi(strings.TrimSuffix(value, "d")); err == nil && days > 0 {
+			return time.Now().Add(time.Duration(days) * 24 * time.Hour), nil
+		}
+	}
+	lifetime, err := time.ParseDuration(value)
+	if err != nil || lifetime <= 0 {
+		return time.Time{}, fmt.Errorf("invalid --expires %q (use e.g. 24h or 7d)", value)
+	}
+	return time.Now().Add(lifetime), nil
+}
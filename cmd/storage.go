@@ -3,21 +3,39 @@ package cmd
 import (
 	"context"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"strings"
 
+	"github.com/darkstorage/cli/internal/atomicfile"
+	"github.com/darkstorage/cli/internal/bandwidth"
 	"github.com/darkstorage/cli/internal/config"
+	"github.com/darkstorage/cli/internal/fsmeta"
 	"github.com/darkstorage/cli/internal/storage"
 	"github.com/dustin/go-humanize"
 	"github.com/fatih/color"
 	"github.com/olekukonko/tablewriter"
 	"github.com/schollz/progressbar/v3"
 	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
 )
 
 var storageBackend storage.StorageBackend
 
+// metadataOptions controls which POSIX metadata put and get preserve
+var metadataOptions = fsmeta.DefaultOptions()
+
+// uploadEncryption is the server-side encryption put requests, from
+// --encryption or the profile's default
+var uploadEncryption string
+
+// setMetadataOptions reads the metadata flags shared by put and get
+func setMetadataOptions(cmd *cobra.Command) {
+	metadataOptions.Owner, _ = cmd.Flags().GetBool("preserve-owner")
+	metadataOptions.Xattrs, _ = cmd.Flags().GetStringSlice("xattrs")
+}
+
 // initStorage initializes the storage backend
 func initStorage() error {
 	if storageBackend != nil {
@@ -171,15 +189,22 @@ var mbCmd = &cobra.Command{
 
 // put command
 var putCmd = &cobra.Command{
-	Use:   "put <source> <destination>",
+	Use:   "put <source> [destination]",
 	Short: "Upload files to storage",
 	Long: `Upload files or directories to Dark Storage.
 
 Examples:
   darkstorage put ./file.txt test-bucket/
   darkstorage put ./folder/ test-bucket/folder/ --recursive
-  darkstorage put ./file.txt test-bucket/custom-name.txt`,
-	Args: cobra.ExactArgs(2),
+  darkstorage put ./file.txt test-bucket/custom-name.txt
+  darkstorage put ./file.txt                # the profile's default bucket
+  darkstorage put ./file.txt test-bucket/ --encryption sse-s3
+
+File mode, modification time and user xattrs are stored as object metadata
+and restored by get. Symbolic links are stored as links, not followed.
+
+--encryption defaults to the profile's encryption setting.`,
+	Args: cobra.RangeArgs(1, 2),
 	Run: func(cmd *cobra.Command, args []string) {
 		if err := initStorage(); err != nil {
 			color.Red("Error: %v", err)
@@ -187,8 +212,27 @@ Examples:
 		}
 
 		source := args[0]
-		dest := args[1]
+		var dest string
+		if len(args) > 1 {
+			dest = args[1]
+		} else if bucket := viper.GetString("bucket"); bucket != "" {
+			dest = bucket + "/"
+		} else {
+			color.Red("Error: no destination given and no default bucket set")
+			fmt.Println("Set one with: darkstorage profile add <name> --bucket <bucket>")
+			os.Exit(1)
+		}
 		recursive, _ := cmd.Flags().GetBool("recursive")
+		setMetadataOptions(cmd)
+
+		uploadEncryption = viper.GetString("encryption")
+		if cmd.Flags().Changed("encryption") {
+			uploadEncryption, _ = cmd.Flags().GetString("encryption")
+		}
+		if err := storage.ValidateEncryption(uploadEncryption); err != nil {
+			color.Red("Error: %v", err)
+			os.Exit(1)
+		}
 
 		info, err := os.Stat(source)
 		if err != nil {
@@ -237,6 +281,7 @@ Examples:
 		}
 
 		recursive, _ := cmd.Flags().GetBool("recursive")
+		setMetadataOptions(cmd)
 		ctx := context.Background()
 
 		// Check if recursive download is requested
@@ -254,7 +299,7 @@ Examples:
 		}
 
 		// Download single file
-		downloadFile(ctx, source, dest, stat.Size, storageBackend)
+		downloadFile(ctx, source, dest, stat.Size, stat.Metadata, storageBackend)
 	},
 }
 
@@ -394,12 +439,25 @@ var catCmd = &cobra.Command{
 
 // uploadFile uploads a single file with progress bar
 func uploadFile(ctx context.Context, source, dest string, size int64, backend storage.StorageBackend) {
-	file, err := os.Open(source)
+	metadata, err := fsmeta.Capture(source, metadataOptions)
 	if err != nil {
-		color.Red("Error opening file: %v", err)
+		color.Red("Error reading metadata: %v", err)
 		os.Exit(1)
 	}
-	defer file.Close()
+
+	// Symlinks are uploaded as empty objects carrying their target
+	var body io.Reader = strings.NewReader("")
+	if _, isLink := fsmeta.SymlinkTarget(metadata); isLink {
+		size = 0
+	} else {
+		file, err := os.Open(source)
+		if err != nil {
+			color.Red("Error opening file: %v", err)
+			os.Exit(1)
+		}
+		defer file.Close()
+		body = file
+	}
 
 	// Ensure destination has proper format (bucket/path)
 	if !strings.Contains(dest, "/") {
@@ -411,13 +469,17 @@ func uploadFile(ctx context.Context, source, dest string, size int64, backend st
 	// Progress bar
 	bar := progressbar.DefaultBytes(size, "Uploading "+filepath.Base(source))
 
+	upload, _ := transferLimiters()
 	opts := &storage.UploadOptions{
+		Metadata:             metadata,
+		ServerSideEncryption: uploadEncryption,
 		ProgressFunc: func(bytes int64) {
 			bar.Set64(bytes)
 		},
+		Limiters: []*bandwidth.Limiter{upload},
 	}
 
-	result, err := backend.Upload(ctx, file, dest, opts)
+	result, err := backend.Upload(ctx, body, dest, opts)
 	if err != nil {
 		fmt.Println()
 		color.Red("Error uploading %s: %v", filepath.Base(source), err)
@@ -483,7 +545,7 @@ func uploadDir(ctx context.Context, source, dest string, backend storage.Storage
 }
 
 // downloadFile downloads a single file with progress bar
-func downloadFile(ctx context.Context, source, dest string, size int64, backend storage.StorageBackend) {
+func downloadFile(ctx context.Context, source, dest string, size int64, metadata map[string]string, backend storage.StorageBackend) {
 	// Determine output filename
 	outputPath := dest
 	if info, err := os.Stat(dest); err == nil && info.IsDir() {
@@ -496,40 +558,73 @@ func downloadFile(ctx context.Context, source, dest string, size int64, backend
 		os.Exit(1)
 	}
 
-	// Create output file
-	outFile, err := os.Create(outputPath)
+	if target, isLink := fsmeta.SymlinkTarget(metadata); isLink {
+		if err := fsmeta.CreateSymlink(outputPath, target); err != nil {
+			color.Red("Error creating symlink: %v", err)
+			os.Exit(1)
+		}
+		applyMetadata(outputPath, metadata)
+		color.Green("✓ Linked: %s -> %s", filepath.Base(outputPath), target)
+		return
+	}
+
+	// Stream into a temp file beside the destination; it only replaces
+	// outputPath once the size and checksum are verified
+	outFile, err := atomicfile.Create(outputPath)
 	if err != nil {
 		color.Red("Error creating file: %v", err)
 		os.Exit(1)
 	}
-	defer outFile.Close()
 
 	// Progress bar
 	bar := progressbar.DefaultBytes(size, "Downloading "+filepath.Base(source))
 
+	_, download := transferLimiters()
 	opts := &storage.DownloadOptions{
 		ProgressFunc: func(bytes int64) {
 			bar.Set64(bytes)
 		},
+		Limiters: []*bandwidth.Limiter{download},
 	}
 
 	result, err := backend.Download(ctx, source, outFile, opts)
 	if err != nil {
+		outFile.Abort()
 		fmt.Println()
 		color.Red("Error downloading %s: %v", filepath.Base(source), err)
 		os.Exit(1)
 	}
 
+	expected := atomicfile.Expected{Size: size, MD5: atomicfile.MD5FromETag(result.ETag)}
+	if err := outFile.Commit(expected); err != nil {
+		fmt.Println()
+		color.Red("Error verifying %s: %v", filepath.Base(source), err)
+		os.Exit(1)
+	}
+	applyMetadata(outputPath, metadata)
+
 	fmt.Println()
 	color.Green("✓ Download complete: %s (%s)", filepath.Base(outputPath), humanize.Bytes(uint64(result.Size)))
 	fmt.Printf("  Saved to: %s\n", outputPath)
 }
 
+// applyMetadata restores stored POSIX metadata, warning on failure since the
+// content itself was downloaded successfully
+func applyMetadata(path string, metadata map[string]string) {
+	if len(metadata) == 0 {
+		return
+	}
+	if err := fsmeta.Apply(path, metadata, metadataOptions); err != nil {
+		color.Yellow("Warning: could not restore metadata for %s: %v", filepath.Base(path), err)
+	}
+}
+
 // downloadDir recursively downloads a directory
 func downloadDir(ctx context.Context, source, dest string, backend storage.StorageBackend) {
 	// List all files recursively
 	opts := &storage.ListOptions{
-		Recursive: true,
+		Recursive:       true,
+		IncludeMetadata: true,
 	}
 
 	files, err := backend.List(ctx, source, opts)
@@ -557,8 +652,16 @@ func downloadDir(ctx context.Context, source, dest string, backend storage.Stora
 		relPath = strings.TrimPrefix(relPath, "/")
 		localPath := filepath.Join(dest, relPath)
 
+		// Not every backend returns metadata in listings
+		metadata := file.Metadata
+		if len(metadata) == 0 {
+			if info, err := backend.Stat(ctx, file.Path); err == nil {
+				metadata = info.Metadata
+			}
+		}
+
 		// Download file
-		downloadFile(ctx, file.Path, localPath, file.Size, backend)
+		downloadFile(ctx, file.Path, localPath, file.Size, metadata, backend)
 
 		totalFiles++
 		totalSize += file.Size
@@ -627,9 +730,14 @@ func init() {
 	// put flags
 	putCmd.Flags().BoolP("recursive", "r", false, "upload directories recursively")
 	putCmd.Flags().String("content-type", "", "set content type")
+	putCmd.Flags().Bool("preserve-owner", false, "store file owner (uid/gid)")
+	putCmd.Flags().StringSlice("xattrs", metadataOptions.Xattrs, "extended attributes to store (glob patterns)")
+	putCmd.Flags().String("encryption", "", "server-side encryption: none or sse-s3 (default: the profile's setting)")
 
 	// get flags
 	getCmd.Flags().BoolP("recursive", "r", false, "download directories recursively")
+	getCmd.Flags().Bool("preserve-owner", false, "restore file owner (uid/gid, usually requires root)")
+	getCmd.Flags().StringSlice("xattrs", metadataOptions.Xattrs, "extended attributes to restore (glob patterns)")
 
 	// rm flags
 	rmCmd.Flags().BoolP("recursive", "r", false, "delete recursively")
@@ -0,0 +1,197 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+
+	"github.com/darkstorage/cli/internal/config"
+	"github.com/darkstorage/cli/internal/ipc"
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var syncCmd = &cobra.Command{
+	Use:   "sync",
+	Short: "Control the sync daemon",
+	Long:  `Commands that talk to the running sync daemon over its local socket.`,
+}
+
+var syncHydrateCmd = &cobra.Command{
+	Use:   "hydrate <path>...",
+	Short: "Download files that are only stored remotely",
+	Long: `Queue downloads for dehydrated files so they are available locally.
+
+Paths may be files or directories inside a sync folder; directories are
+hydrated recursively.
+
+Examples:
+  darkstorage sync hydrate ~/Archive/2024/report.pdf
+  darkstorage sync hydrate ~/Archive/2024`,
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		client, paths := syncClientAndPaths(args)
+
+		count, err := client.Hydrate(paths)
+		if err != nil {
+			color.Red("Error: %v", err)
+			os.Exit(1)
+		}
+
+		color.Green("✓ Queued %d file(s) for download", count)
+	},
+}
+
+var syncDehydrateCmd = &cobra.Command{
+	Use:   "dehydrate <path>...",
+	Short: "Free local space for files that are synced remotely",
+	Long: `Remove local copies of synced files, keeping them available remotely.
+
+Folders with placeholders enabled keep a zero-byte placeholder in place of
+each file. Files with changes that have not been uploaded yet are skipped.
+
+Examples:
+  darkstorage sync dehydrate ~/Archive/2023`,
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		client, paths := syncClientAndPaths(args)
+
+		count, err := client.Dehydrate(paths)
+		if err != nil {
+			color.Red("Error: %v", err)
+			os.Exit(1)
+		}
+
+		color.Green("✓ Dehydrated %d file(s)", count)
+	},
+}
+
+var syncEventsCmd = &cobra.Command{
+	Use:   "events",
+	Short: "Stream what the daemon is doing",
+	Long: `Print daemon events as they happen until interrupted, reconnecting if the
+daemon restarts.
+
+Topics: transfer.started, transfer.progress, transfer.completed,
+conflict.created, folder.paused, queue.size. --topic also accepts the part
+before the dot, e.g. "transfer". With --json each event is printed as one
+line of JSON.
+
+Examples:
+  darkstorage sync events
+  darkstorage sync events --topic transfer.completed --topic folder
+  darkstorage sync events --json | jq .`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		client, err := newDaemonClient()
+		if err != nil {
+			color.Red("Error: %v", err)
+			os.Exit(1)
+		}
+		topics, _ := cmd.Flags().GetStringSlice("topic")
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+
+		asJSON := viper.GetBool("json")
+		err = client.Subscribe(ctx, topics, func(event *ipc.Event) {
+			if event.Topic == ipc.TopicConnected {
+				if !asJSON {
+					color.Green("✓ Connected to daemon")
+				}
+				return
+			}
+			if asJSON {
+				data, _ := json.Marshal(event)
+				fmt.Println(string(data))
+				return
+			}
+			fmt.Printf("%s  %-19s %s\n", event.Time.Local().Format("15:04:05"), event.Topic, event.Data)
+		})
+		if err != nil && ctx.Err() == nil {
+			color.Red("Error: %v", err)
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(syncCmd)
+	syncCmd.AddCommand(syncHydrateCmd)
+	syncCmd.AddCommand(syncDehydrateCmd)
+	syncCmd.AddCommand(syncEventsCmd)
+
+	syncEventsCmd.Flags().StringSlice("topic", nil, "only show these topics (repeatable)")
+}
+
+func newDaemonClient() (*ipc.Client, error) {
+	dataDir, err := config.GetDefaultDataDir()
+	if err != nil {
+		return nil, err
+	}
+	return ipc.NewClient(filepath.Join(dataDir, "daemon.sock")), nil
+}
+
+// syncClientAndPaths connects to the daemon and resolves args to absolute
+// paths, exiting on failure
+func syncClientAndPaths(args []string) (*ipc.Client, []string) {
+	client := syncClient()
+
+	paths := make([]string, 0, len(args))
+	for _, arg := range args {
+		abs, err := filepath.Abs(arg)
+		if err != nil {
+			color.Red("Error: %v", err)
+			os.Exit(1)
+		}
+		paths = append(paths, abs)
+	}
+
+	return client, paths
+}
+
+// syncClient connects to the daemon, exiting with a hint if it is not
+// running
+func syncClient() *ipc.Client {
+	client, err := newDaemonClient()
+	if err != nil {
+		color.Red("Error: %v", err)
+		os.Exit(1)
+	}
+
+	if _, err := client.GetStatus(); err != nil {
+		color.Red("Error: daemon is not running: %v", err)
+		fmt.Println("Start it with: darkstorage-daemon start")
+		os.Exit(1)
+	}
+
+	return client
+}
+
+// folderArg resolves a sync folder given by ID or local path to its ID,
+// exiting if there is no such folder
+func folderArg(client *ipc.Client, arg string) int {
+	status, err := client.GetStatus()
+	if err != nil {
+		color.Red("Error: %v", err)
+		os.Exit(1)
+	}
+
+	id, idErr := strconv.Atoi(arg)
+	path, _ := filepath.Abs(arg)
+	for _, folder := range status.SyncFolders {
+		if (idErr == nil && folder.ID == id) || filepath.Clean(folder.LocalPath) == path {
+			return folder.ID
+		}
+	}
+
+	color.Red("Error: %s is not a sync folder", arg)
+	fmt.Println("List them with: darkstorage sync status")
+	os.Exit(1)
+	return 0
+}
@@ -0,0 +1,227 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/darkstorage/cli/internal/bandwidth"
+	"github.com/darkstorage/cli/internal/ipc"
+	"github.com/dustin/go-humanize"
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var syncBandwidthCmd = &cobra.Command{
+	Use:   "bandwidth",
+	Short: "Show the daemon's bandwidth limits",
+	Long: `Show the upload and download limits in force, the schedule that sets
+them and the folders with limits of their own.
+
+The limits are shared by all of the daemon's transfers. Outside the
+schedule's rules, daemon.bandwidth_limit_up and daemon.bandwidth_limit_down
+apply. A folder's own limit (sync update --bandwidth-limit) holds its
+transfers lower still, as do throttling.limit_up and throttling.limit_down
+while the daemon throttles on a metered connection, on battery or under
+high load.
+
+Examples:
+  darkstorage sync bandwidth
+  darkstorage sync bandwidth set --up 512 --down 0
+  darkstorage sync bandwidth schedule "weekdays 09:00-18:00 up=1024 down=1024"`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		bw, err := syncClient().GetBandwidth()
+		if err != nil {
+			color.Red("Error: %v", err)
+			os.Exit(1)
+		}
+
+		if viper.GetBool("json") {
+			printJSON(bw)
+			return
+		}
+
+		source := "default"
+		if bw.Rule >= 0 && bw.Rule < len(bw.Schedule) {
+			source = fmt.Sprintf("rule %d: %s", bw.Rule+1, formatBandwidthRule(bw.Schedule[bw.Rule]))
+		}
+		fmt.Printf("Upload:   %s\n", formatKBRate(bw.Up))
+		fmt.Printf("Download: %s\n", formatKBRate(bw.Down))
+		fmt.Printf("From:     %s\n", source)
+		if bw.Throttled != "" {
+			fmt.Printf("Throttle: %s\n", bw.Throttled)
+		}
+		fmt.Printf("Default:  up %s, down %s\n", formatKBRate(bw.DefaultUp), formatKBRate(bw.DefaultDown))
+
+		if len(bw.Schedule) > 0 {
+			fmt.Println("\nSchedule:")
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "  RULE\tDAYS\tTIME\tUP\tDOWN")
+			for i, rule := range bw.Schedule {
+				days := strings.Join(rule.Days, ",")
+				if days == "" {
+					days = "daily"
+				}
+				fmt.Fprintf(w, "  %d\t%s\t%s-%s\t%s\t%s\n",
+					i+1, days, rule.Start, rule.End, formatKBRate(rule.Up), formatKBRate(rule.Down))
+			}
+			w.Flush()
+		}
+
+		if len(bw.Folders) > 0 {
+			fmt.Println("\nFolders:")
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "  ID\tFOLDER\tLIMIT")
+			for _, folder := range bw.Folders {
+				fmt.Fprintf(w, "  %d\t%s\t%s\n", folder.FolderID, folder.LocalPath, formatKBRate(folder.Limit))
+			}
+			w.Flush()
+		}
+	},
+}
+
+var syncBandwidthSetCmd = &cobra.Command{
+	Use:   "set",
+	Short: "Change the default bandwidth limits",
+	Long: `Change the limits that apply outside the schedule's rules, in KB/s, 0 for
+unlimited. They are written to config.yaml and take effect at once, for
+transfers already running too.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		settings := make(map[string]interface{})
+		if cmd.Flags().Changed("up") {
+			settings["daemon.bandwidth_limit_up"], _ = cmd.Flags().GetInt("up")
+		}
+		if cmd.Flags().Changed("down") {
+			settings["daemon.bandwidth_limit_down"], _ = cmd.Flags().GetInt("down")
+		}
+		if len(settings) == 0 {
+			color.Red("Error: give --up, --down or both")
+			os.Exit(1)
+		}
+
+		if err := syncClient().SetConfig(settings); err != nil {
+			color.Red("Error: %v", err)
+			os.Exit(1)
+		}
+		color.Green("✓ Bandwidth limits updated")
+	},
+}
+
+var syncBandwidthScheduleCmd = &cobra.Command{
+	Use:   "schedule [rule...]",
+	Short: "Replace the bandwidth schedule",
+	Long: `Replace the rules that set the bandwidth limits at certain times of the
+week. The first rule covering the current time applies; outside them all,
+the default limits do.
+
+A rule is "[days] start-end [up=KB/s] [down=KB/s]". Days are names such as
+mon, ranges such as mon-fri, weekdays, weekends or daily, separated by
+commas; every day if left out. A rule ending before it starts runs past
+midnight. Unset limits are unlimited.
+
+Examples:
+  darkstorage sync bandwidth schedule "weekdays 09:00-18:00 up=1024 down=1024"
+  darkstorage sync bandwidth schedule "22:00-06:00 up=0" "sat,sun 10:00-16:00 up=256"
+  darkstorage sync bandwidth schedule --clear`,
+	Run: func(cmd *cobra.Command, args []string) {
+		clear, _ := cmd.Flags().GetBool("clear")
+		if clear == (len(args) > 0) {
+			color.Red("Error: give rules or --clear")
+			os.Exit(1)
+		}
+
+		rules := []ipc.BandwidthRule{}
+		for _, arg := range args {
+			rule, err := parseBandwidthRule(arg)
+			if err != nil {
+				color.Red("Error: %v", err)
+				os.Exit(1)
+			}
+			rules = append(rules, rule)
+		}
+
+		if err := syncClient().SetConfig(map[string]interface{}{"daemon.bandwidth_schedule": rules}); err != nil {
+			color.Red("Error: %v", err)
+			os.Exit(1)
+		}
+		if clear {
+			color.Green("✓ Bandwidth schedule cleared")
+		} else {
+			color.Green("✓ Bandwidth schedule set, %d rule(s)", len(rules))
+		}
+	},
+}
+
+// parseBandwidthRule reads a rule written as "[days] start-end [up=N]
+// [down=N]"
+func parseBandwidthRule(s string) (ipc.BandwidthRule, error) {
+	var rule ipc.BandwidthRule
+	for _, field := range strings.Fields(s) {
+		if key, value, ok := strings.Cut(field, "="); ok {
+			kb, err := strconv.Atoi(value)
+			if err != nil || kb < 0 {
+				return rule, fmt.Errorf("%q: %s is not a limit in KB/s", s, value)
+			}
+			switch key {
+			case "up":
+				rule.Up = kb
+			case "down":
+				rule.Down = kb
+			default:
+				return rule, fmt.Errorf("%q: unknown limit %s, use up or down", s, key)
+			}
+			continue
+		}
+
+		if start, end, ok := strings.Cut(field, "-"); ok && strings.Contains(field, ":") {
+			for _, clock := range []string{start, end} {
+				if _, err := bandwidth.ParseClock(clock); err != nil {
+					return rule, fmt.Errorf("%q: %v", s, err)
+				}
+			}
+			rule.Start, rule.End = start, end
+			continue
+		}
+
+		rule.Days = append(rule.Days, strings.Split(field, ",")...)
+		if _, err := bandwidth.ParseDays(rule.Days); err != nil {
+			return rule, fmt.Errorf("%q: %v", s, err)
+		}
+	}
+
+	if rule.Start == "" {
+		return rule, fmt.Errorf("%q: give the time as start-end, e.g. 09:00-18:00", s)
+	}
+	return rule, nil
+}
+
+func formatBandwidthRule(rule ipc.BandwidthRule) string {
+	days := strings.Join(rule.Days, ",")
+	if days == "" {
+		days = "daily"
+	}
+	return fmt.Sprintf("%s %s-%s", days, rule.Start, rule.End)
+}
+
+// formatKBRate describes a limit in KB/s, 0 being unlimited
+func formatKBRate(kb int) string {
+	if kb <= 0 {
+		return "unlimited"
+	}
+	return humanize.IBytes(uint64(kb)*1024) + "/s"
+}
+
+func init() {
+	syncCmd.AddCommand(syncBandwidthCmd)
+	syncBandwidthCmd.AddCommand(syncBandwidthSetCmd)
+	syncBandwidthCmd.AddCommand(syncBandwidthScheduleCmd)
+
+	syncBandwidthSetCmd.Flags().Int("up", 0, "upload limit in KB/s, 0 for unlimited")
+	syncBandwidthSetCmd.Flags().Int("down", 0, "download limit in KB/s, 0 for unlimited")
+	syncBandwidthScheduleCmd.Flags().Bool("clear", false, "remove every rule")
+}
@@ -0,0 +1,354 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+	"time"
+
+	"github.com/darkstorage/cli/internal/ipc"
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var syncStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show sync folders and transfers in progress",
+	Long: `Show each sync folder's state and the transfers the daemon is running.
+
+A folder is idle, syncing (transfers running), pending (operations queued),
+error (operations failed; see 'darkstorage sync queue --status failed'),
+paused or disabled. Folders the daemon pauses or throttles on a metered
+connection, on battery or under high load (see the throttling settings)
+show why, and carry on by themselves once that passes.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		client := syncClient()
+		status, err := client.GetStatus()
+		if err != nil {
+			color.Red("Error: %v", err)
+			os.Exit(1)
+		}
+
+		if viper.GetBool("json") {
+			printJSON(status)
+			return
+		}
+
+		fmt.Printf("Daemon running for %s, %d operation(s) queued\n\n", status.Uptime, status.QueueSize)
+		if len(status.SyncFolders) == 0 {
+			fmt.Println("No sync folders. Add one with: darkstorage sync add <path>")
+			return
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\tFOLDER\tREMOTE\tSTATUS\tPENDING\tLAST SYNC")
+		for _, f := range status.SyncFolders {
+			fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%d\t%s\n",
+				f.ID, f.LocalPath, f.RemotePath, f.Status, f.FilesPending, formatTimeOr(f.LastSync, "never"))
+		}
+		w.Flush()
+
+		for _, f := range status.SyncFolders {
+			if f.ErrorMessage != "" {
+				color.Yellow("  [%d] %s: %s", f.ID, f.Status, f.ErrorMessage)
+			}
+			if f.Throttled != "" {
+				color.Yellow("  [%d] throttled: %s", f.ID, f.Throttled)
+			}
+		}
+
+		if len(status.Transfers) > 0 {
+			fmt.Println("\nTransfers:")
+			for _, t := range status.Transfers {
+				fmt.Printf("  %-8s %s  %s\n", t.Operation, t.Path, formatProgress(t.Bytes, t.Size))
+			}
+		}
+	},
+}
+
+var syncAddCmd = &cobra.Command{
+	Use:   "add <local-path> [remote-path]",
+	Short: "Start syncing a folder",
+	Long: `Add a sync folder and start syncing it.
+
+The folder syncs with the account of the active profile (--profile). Without
+a remote path it syncs to the profile's default bucket.
+
+Examples:
+  darkstorage sync add ~/Documents my-bucket/documents
+  darkstorage sync add ~/Photos --direction upload_only --exclude "*.tmp"
+  darkstorage --profile work sync add ~/Work`,
+	Args: cobra.RangeArgs(1, 2),
+	Run: func(cmd *cobra.Command, args []string) {
+		localPath, err := filepath.Abs(args[0])
+		if err != nil {
+			color.Red("Error: %v", err)
+			os.Exit(1)
+		}
+		if info, err := os.Stat(localPath); err != nil || !info.IsDir() {
+			color.Red("Error: %s is not a directory", localPath)
+			os.Exit(1)
+		}
+
+		req := &ipc.AddSyncFolderRequest{
+			Name:      filepath.Base(localPath),
+			LocalPath: localPath,
+			Profile:   activeProfile(),
+		}
+		if len(args) > 1 {
+			req.RemotePath = args[1]
+		}
+		req.Direction, _ = cmd.Flags().GetString("direction")
+		req.ConflictResolution, _ = cmd.Flags().GetString("conflict")
+		req.Excludes, _ = cmd.Flags().GetStringSlice("exclude")
+		req.IncludePaths, _ = cmd.Flags().GetStringSlice("include")
+		req.BandwidthLimit, _ = cmd.Flags().GetInt("bandwidth-limit")
+		req.Placeholders, _ = cmd.Flags().GetBool("placeholders")
+
+		client := syncClient()
+		result, err := client.AddSyncFolder(req)
+		if err != nil {
+			color.Red("Error: %v", err)
+			os.Exit(1)
+		}
+
+		color.Green("✓ Added sync folder %d: %s", result.ID, localPath)
+	},
+}
+
+var syncRemoveCmd = &cobra.Command{
+	Use:     "remove <folder>",
+	Aliases: []string{"rm"},
+	Short:   "Stop syncing a folder",
+	Long: `Stop syncing a folder, given by ID or local path. Local and remote files
+are kept.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		client := syncClient()
+		id := folderArg(client, args[0])
+
+		if err := client.RemoveSyncFolder(id); err != nil {
+			color.Red("Error: %v", err)
+			os.Exit(1)
+		}
+
+		color.Green("✓ Removed sync folder %d", id)
+	},
+}
+
+var syncPauseCmd = &cobra.Command{
+	Use:   "pause <folder>",
+	Short: "Pause all transfers for a folder",
+	Long: `Pause a folder, given by ID or local path. Changes keep being recorded
+and are transferred once the folder is resumed.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		client := syncClient()
+		id := folderArg(client, args[0])
+		reason, _ := cmd.Flags().GetString("reason")
+
+		if err := client.PauseFolder(id, reason); err != nil {
+			color.Red("Error: %v", err)
+			os.Exit(1)
+		}
+
+		color.Green("✓ Paused sync folder %d", id)
+	},
+}
+
+var syncResumeCmd = &cobra.Command{
+	Use:   "resume <folder>",
+	Short: "Resume a paused folder",
+	Long: `Resume a folder paused with 'sync pause' or by ransomware detection.
+
+Check a folder paused by detection for unexpected changes before resuming
+it, or its uploads will replace the remote copies. --discard-pending
+cancels the uploads and deletes queued while it was paused instead; restore
+the damaged files (darkstorage restore) before the folder is next scanned,
+or they are queued again.
+
+Examples:
+  darkstorage sync resume 1
+  darkstorage sync resume ~/Documents --discard-pending`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		client := syncClient()
+		id := folderArg(client, args[0])
+		discard, _ := cmd.Flags().GetBool("discard-pending")
+
+		discarded, err := client.ResumeFolder(id, discard)
+		if err != nil {
+			color.Red("Error: %v", err)
+			os.Exit(1)
+		}
+
+		color.Green("✓ Resumed sync folder %d", id)
+		if discard {
+			fmt.Printf("  Discarded %d pending operation(s)\n", discarded)
+		}
+	},
+}
+
+var syncUpdateCmd = &cobra.Command{
+	Use:   "update <folder>",
+	Short: "Change a folder's sync settings",
+	Long: `Change the settings given as flags for a folder, given by ID or local
+path, and resync it. Settings not given are kept. Folders declared in
+config.yaml are changed there instead.
+
+Examples:
+  darkstorage sync update 1 --direction download_only
+  darkstorage sync update ~/Photos --exclude "*.tmp" --exclude "*.bak"
+  darkstorage sync update 2 --bandwidth-limit 0`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		flags := cmd.Flags()
+		req := &ipc.UpdateSyncFolderRequest{}
+		if flags.Changed("remote") {
+			v, _ := flags.GetString("remote")
+			req.RemotePath = &v
+		}
+		if flags.Changed("direction") {
+			v, _ := flags.GetString("direction")
+			req.Direction = &v
+		}
+		if flags.Changed("conflict") {
+			v, _ := flags.GetString("conflict")
+			req.ConflictResolution = &v
+		}
+		if flags.Changed("exclude") {
+			v, _ := flags.GetStringSlice("exclude")
+			req.Excludes = &v
+		}
+		if flags.Changed("include") {
+			v, _ := flags.GetStringSlice("include")
+			req.IncludePaths = &v
+		}
+		if flags.Changed("bandwidth-limit") {
+			v, _ := flags.GetInt("bandwidth-limit")
+			req.BandwidthLimit = &v
+		}
+		if flags.Changed("interval") {
+			v, _ := flags.GetInt("interval")
+			req.SyncInterval = &v
+		}
+		if flags.Changed("placeholders") {
+			v, _ := flags.GetBool("placeholders")
+			req.Placeholders = &v
+		}
+		if flags.Changed("enabled") {
+			v, _ := flags.GetBool("enabled")
+			req.Enabled = &v
+		}
+		if flags.Changed("folder-profile") {
+			v, _ := flags.GetString("folder-profile")
+			req.Profile = &v
+		}
+
+		client := syncClient()
+		req.ID = folderArg(client, args[0])
+
+		if err := client.UpdateSyncFolder(req); err != nil {
+			color.Red("Error: %v", err)
+			os.Exit(1)
+		}
+
+		color.Green("✓ Updated sync folder %d", req.ID)
+	},
+}
+
+var syncNowCmd = &cobra.Command{
+	Use:   "now <folder>",
+	Short: "Scan a folder for changes now",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		client := syncClient()
+		id := folderArg(client, args[0])
+
+		if err := client.ForceSync(id); err != nil {
+			color.Red("Error: %v", err)
+			os.Exit(1)
+		}
+
+		color.Green("✓ Syncing folder %d", id)
+	},
+}
+
+var syncStatsCmd = &cobra.Command{
+	Use:   "stats <folder>",
+	Short: "Show file and transfer counts for a folder",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		client := syncClient()
+		id := folderArg(client, args[0])
+
+		stats, err := client.GetFolderStats(id)
+		if err != nil {
+			color.Red("Error: %v", err)
+			os.Exit(1)
+		}
+
+		if viper.GetBool("json") {
+			printJSON(stats)
+			return
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintf(w, "Files:\t%d (%d synced, %d pending, %d errors, %d remote only)\n",
+			stats.Files, stats.SyncedFiles, stats.PendingFiles, stats.ErrorFiles, stats.DehydratedFiles)
+		fmt.Fprintf(w, "Local size:\t%s\n", formatBytes(stats.LocalBytes))
+		fmt.Fprintf(w, "Queue:\t%d waiting, %d failed\n", stats.QueuedOperations, stats.FailedOperations)
+		fmt.Fprintf(w, "Conflicts:\t%d\n", stats.Conflicts)
+		fmt.Fprintf(w, "Transferred:\t%d uploads, %d downloads, %d deletes, %s\n",
+			stats.Uploads, stats.Downloads, stats.Deletes, formatBytes(stats.BytesTransferred))
+		fmt.Fprintf(w, "Errors:\t%d\n", stats.Errors)
+		fmt.Fprintf(w, "Last sync:\t%s\n", formatTimeOr(stats.LastSync, "never"))
+		w.Flush()
+	},
+}
+
+func init() {
+	syncCmd.AddCommand(syncStatusCmd)
+	syncCmd.AddCommand(syncAddCmd)
+	syncCmd.AddCommand(syncRemoveCmd)
+	syncCmd.AddCommand(syncPauseCmd)
+	syncCmd.AddCommand(syncResumeCmd)
+	syncCmd.AddCommand(syncUpdateCmd)
+	syncCmd.AddCommand(syncNowCmd)
+	syncCmd.AddCommand(syncStatsCmd)
+
+	for _, c := range []*cobra.Command{syncAddCmd, syncUpdateCmd} {
+		c.Flags().String("direction", "bidirectional", "bidirectional, upload_only or download_only")
+		c.Flags().String("conflict", "keep_local", "conflict resolution: keep_local, keep_remote, keep_both or manual")
+		c.Flags().StringSlice("exclude", nil, "pattern of files not to sync (repeatable)")
+		c.Flags().StringSlice("include", nil, "only keep these paths locally, the rest remote only (repeatable)")
+		c.Flags().Int("bandwidth-limit", 0, "transfer limit in KB/s, 0 for none")
+		c.Flags().Bool("placeholders", false, "leave placeholders for files kept remote only")
+	}
+	syncUpdateCmd.Flags().String("remote", "", "remote path")
+	syncUpdateCmd.Flags().Int("interval", 0, "seconds between full scans, 0 to only follow changes")
+	syncUpdateCmd.Flags().Bool("enabled", true, "watch and sync the folder")
+	syncUpdateCmd.Flags().String("folder-profile", "", "account profile the folder syncs with")
+
+	syncPauseCmd.Flags().String("reason", "", "note shown in status while paused")
+	syncResumeCmd.Flags().Bool("discard-pending", false, "cancel the uploads and deletes queued while paused")
+}
+
+// formatTimeOr formats t for tables, or returns none if t is unset
+func formatTimeOr(t *time.Time, none string) string {
+	if t == nil {
+		return none
+	}
+	return t.Local().Format("2006-01-02 15:04")
+}
+
+// formatProgress shows how far a transfer has got
+func formatProgress(bytes, size int64) string {
+	if size <= 0 {
+		return formatBytes(bytes)
+	}
+	return fmt.Sprintf("%s / %s (%d%%)", formatBytes(bytes), formatBytes(size), bytes*100/size)
+}
@@ -0,0 +1,85 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var syncHooksCmd = &cobra.Command{
+	Use:   "hooks",
+	Short: "List the daemon's event hooks",
+	Long: `List the hooks configured under 'hooks' in config.yaml.
+
+A hook runs a command with the event as JSON on stdin, or posts the event to
+a webhook, when one of its events happens: transfer_failed, conflict,
+folder_paused or sync_complete. Webhooks with a secret are signed, and
+failed webhooks are retried.
+
+Examples:
+  darkstorage sync hooks
+  darkstorage sync hooks test alerts`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		hooks, err := syncClient().ListHooks()
+		if err != nil {
+			color.Red("Error: %v", err)
+			os.Exit(1)
+		}
+
+		if viper.GetBool("json") {
+			printJSON(hooks)
+			return
+		}
+
+		if len(hooks) == 0 {
+			fmt.Println("No hooks configured")
+			return
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "NAME\tEVENTS\tACTION")
+		for _, hook := range hooks {
+			action := "run " + strings.Join(hook.Command, " ")
+			if hook.URL != "" {
+				action = "post " + hook.URL
+				if hook.Signed {
+					action += " (signed)"
+				}
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\n", hook.Name, strings.Join(hook.Events, ","), action)
+		}
+		w.Flush()
+	},
+}
+
+var syncHooksTestCmd = &cobra.Command{
+	Use:   "test <name>",
+	Short: "Send a test event to a hook",
+	Long: `Send an event of type "test" to a hook and wait for it to be delivered,
+without retrying, to check the command or webhook works.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		client := syncClient()
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+		defer cancel()
+
+		if err := client.TestHook(ctx, args[0]); err != nil {
+			color.Red("Error: %v", err)
+			os.Exit(1)
+		}
+		color.Green("✓ Hook %s received the test event", args[0])
+	},
+}
+
+func init() {
+	syncCmd.AddCommand(syncHooksCmd)
+	syncHooksCmd.AddCommand(syncHooksTestCmd)
+}
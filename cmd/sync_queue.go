@@ -0,0 +1,230 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/darkstorage/cli/internal/ipc"
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var syncQueueCmd = &cobra.Command{
+	Use:   "queue",
+	Short: "List the daemon's queued operations",
+	Long: `List queued operations, newest first.
+
+Statuses are pending, processing, completed, failed and cancelled. Failed
+and cancelled operations can be queued again with 'sync queue retry'.
+
+Examples:
+  darkstorage sync queue
+  darkstorage sync queue --status failed
+  darkstorage sync queue --folder ~/Documents --limit 20`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		client := syncClient()
+		req := &ipc.ListQueueRequest{}
+		req.Status, _ = cmd.Flags().GetString("status")
+		req.Limit, _ = cmd.Flags().GetInt("limit")
+		if folder, _ := cmd.Flags().GetString("folder"); folder != "" {
+			req.FolderID = folderArg(client, folder)
+		}
+
+		ops, err := client.ListQueue(req)
+		if err != nil {
+			color.Red("Error: %v", err)
+			os.Exit(1)
+		}
+
+		if viper.GetBool("json") {
+			printJSON(ops)
+			return
+		}
+
+		if len(ops) == 0 {
+			fmt.Println("No queued operations")
+			return
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\tFOLDER\tOPERATION\tSTATUS\tATTEMPTS\tPATH")
+		for _, op := range ops {
+			status := op.Status
+			if op.Status == "processing" {
+				status += " " + formatProgress(op.Bytes, op.Size)
+			}
+			fmt.Fprintf(w, "%d\t%d\t%s\t%s\t%d/%d\t%s\n",
+				op.ID, op.FolderID, op.Operation, status, op.Attempts, op.MaxAttempts, op.Path)
+		}
+		w.Flush()
+	},
+}
+
+var syncQueueShowCmd = &cobra.Command{
+	Use:   "show <id>",
+	Short: "Show one queued operation",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		client := syncClient()
+		op, err := client.GetQueueItem(idArg(args[0]))
+		if err != nil {
+			color.Red("Error: %v", err)
+			os.Exit(1)
+		}
+
+		if viper.GetBool("json") {
+			printJSON(op)
+			return
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintf(w, "ID:\t%d\n", op.ID)
+		fmt.Fprintf(w, "Folder:\t%d\n", op.FolderID)
+		fmt.Fprintf(w, "Path:\t%s\n", op.Path)
+		fmt.Fprintf(w, "Operation:\t%s\n", op.Operation)
+		fmt.Fprintf(w, "Status:\t%s\n", op.Status)
+		if op.Status == "processing" {
+			fmt.Fprintf(w, "Progress:\t%s\n", formatProgress(op.Bytes, op.Size))
+		}
+		fmt.Fprintf(w, "Attempts:\t%d of %d\n", op.Attempts, op.MaxAttempts)
+		fmt.Fprintf(w, "Priority:\t%d\n", op.Priority)
+		fmt.Fprintf(w, "Queued:\t%s\n", op.CreatedAt.Local().Format("2006-01-02 15:04:05"))
+		if op.StartedAt != nil {
+			fmt.Fprintf(w, "Started:\t%s\n", op.StartedAt.Local().Format("2006-01-02 15:04:05"))
+		}
+		if op.CompletedAt != nil {
+			fmt.Fprintf(w, "Finished:\t%s\n", op.CompletedAt.Local().Format("2006-01-02 15:04:05"))
+		}
+		if op.Error != "" {
+			fmt.Fprintf(w, "Error:\t%s\n", op.Error)
+		}
+		w.Flush()
+	},
+}
+
+var syncQueueRetryCmd = &cobra.Command{
+	Use:   "retry <id>...",
+	Short: "Queue failed or cancelled operations again",
+	Args:  cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		client := syncClient()
+		for _, arg := range args {
+			id := idArg(arg)
+			if err := client.RetryQueueItem(id); err != nil {
+				color.Red("Error: %v", err)
+				os.Exit(1)
+			}
+			color.Green("✓ Queued operation %d again", id)
+		}
+	},
+}
+
+var syncQueueCancelCmd = &cobra.Command{
+	Use:   "cancel <id>...",
+	Short: "Cancel queued operations",
+	Long:  `Cancel queued operations, interrupting any that are running.`,
+	Args:  cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		client := syncClient()
+		for _, arg := range args {
+			id := idArg(arg)
+			if err := client.CancelQueueItem(id); err != nil {
+				color.Red("Error: %v", err)
+				os.Exit(1)
+			}
+			color.Green("✓ Cancelled operation %d", id)
+		}
+	},
+}
+
+var syncConflictsCmd = &cobra.Command{
+	Use:   "conflicts",
+	Short: "List unresolved sync conflicts",
+	Long: `List files changed both locally and remotely since they last synced.
+Settle each with 'darkstorage sync conflicts resolve'.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		client := syncClient()
+		folderID := 0
+		if folder, _ := cmd.Flags().GetString("folder"); folder != "" {
+			folderID = folderArg(client, folder)
+		}
+
+		conflicts, err := client.ListConflicts(folderID)
+		if err != nil {
+			color.Red("Error: %v", err)
+			os.Exit(1)
+		}
+
+		if viper.GetBool("json") {
+			printJSON(conflicts)
+			return
+		}
+
+		if len(conflicts) == 0 {
+			fmt.Println("No conflicts")
+			return
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\tFOLDER\tDETECTED\tLOCAL CHANGE\tREMOTE CHANGE\tPATH")
+		for _, c := range conflicts {
+			fmt.Fprintf(w, "%d\t%d\t%s\t%s\t%s\t%s\n",
+				c.ID, c.FolderID, c.CreatedAt.Local().Format("2006-01-02 15:04"),
+				formatTimeOr(c.LocalModifiedAt, "-"), formatTimeOr(c.RemoteModifiedAt, "-"), c.Path)
+		}
+		w.Flush()
+	},
+}
+
+var syncConflictsResolveCmd = &cobra.Command{
+	Use:   "resolve <id> <keep_local|keep_remote|keep_both>",
+	Short: "Settle a sync conflict",
+	Long: `Settle a conflict by keeping the local version (uploaded over the remote
+one), the remote version (downloaded over the local one), or both: the local
+file is copied to "<name> (conflict <date>)" and uploaded, then the remote
+version is downloaded.
+
+Examples:
+  darkstorage sync conflicts resolve 12 keep_remote`,
+	Args: cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		client := syncClient()
+		id := idArg(args[0])
+
+		if err := client.ResolveConflict(id, args[1]); err != nil {
+			color.Red("Error: %v", err)
+			os.Exit(1)
+		}
+
+		color.Green("✓ Resolved conflict %d (%s)", id, args[1])
+	},
+}
+
+func init() {
+	syncCmd.AddCommand(syncQueueCmd)
+	syncQueueCmd.AddCommand(syncQueueShowCmd)
+	syncQueueCmd.AddCommand(syncQueueRetryCmd)
+	syncQueueCmd.AddCommand(syncQueueCancelCmd)
+	syncCmd.AddCommand(syncConflictsCmd)
+	syncConflictsCmd.AddCommand(syncConflictsResolveCmd)
+
+	syncQueueCmd.Flags().String("folder", "", "only this folder (ID or local path)")
+	syncQueueCmd.Flags().String("status", "", "only operations with this status")
+	syncQueueCmd.Flags().Int("limit", 50, "maximum operations to list")
+	syncConflictsCmd.Flags().String("folder", "", "only this folder (ID or local path)")
+}
+
+// idArg parses a numeric ID argument, exiting if it is not one
+func idArg(arg string) int {
+	id, err := strconv.Atoi(arg)
+	if err != nil {
+		color.Red("Error: %q is not an ID", arg)
+		os.Exit(1)
+	}
+	return id
+}
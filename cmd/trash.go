@@ -3,11 +3,11 @@ package cmd
 import (
 	"encoding/json"
 	"fmt"
-	"net/http"
 	"os"
 	"text/tabwriter"
 	"time"
 
+	apiv1 "github.com/darkstorage/cli/internal/api/v1"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
@@ -23,42 +23,28 @@ var trashListCmd = &cobra.Command{
 	Short: "List recoverable deleted files",
 	Run: func(cmd *cobra.Command, args []string) {
 		client := newAPIClient()
-		resp, err := client.get("/sdms/recoverable")
+		files, err := apiv1.Collect(cmd.Context(), 0, client.RecoverableFiles())
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 			os.Exit(1)
 		}
 
-		var result struct {
-			Files []struct {
-				ID             string    `json:"id"`
-				OriginalName   string    `json:"original_name"`
-				OriginalPath   string    `json:"original_path"`
-				FileSize       int64     `json:"file_size"`
-				DeletedAt      time.Time `json:"deleted_at"`
-				ExpiresAt      time.Time `json:"expires_at"`
-				HoursRemaining float64   `json:"hours_remaining"`
-				Urgency        string    `json:"urgency"`
-			} `json:"files"`
-		}
-		json.Unmarshal(resp, &result)
-
 		if viper.GetBool("json") {
-			fmt.Println(string(resp))
+			printJSON(files)
 			return
 		}
 
-		if len(result.Files) == 0 {
+		if len(files) == 0 {
 			fmt.Println("No recoverable files found")
 			return
 		}
 
 		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
 		fmt.Fprintln(w, "ID\tNAME\tSIZE\tDELETED\tTIME LEFT\tURGENCY")
-		for _, f := range result.Files {
+		for _, f := range files {
 			timeLeft := formatDuration(f.HoursRemaining)
 			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n",
-				f.ID[:8], f.OriginalName, formatBytes(f.FileSize),
+				shortID(f.ID), f.OriginalName, formatBytes(f.FileSize),
 				f.DeletedAt.Format("2006-01-02 15:04"), timeLeft, f.Urgency)
 		}
 		w.Flush()
@@ -74,21 +60,12 @@ var trashRecoverCmd = &cobra.Command{
 		toPath, _ := cmd.Flags().GetString("to")
 
 		client := newAPIClient()
-		body := map[string]string{}
-		if toPath != "" {
-			body["recover_to_path"] = toPath
-		}
-
-		resp, err := client.post(fmt.Sprintf("/sdms/%s/recover", fileID), body)
+		result, err := client.RecoverFile(cmd.Context(), fileID, apiv1.RecoverRequest{RecoverToPath: toPath})
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 			os.Exit(1)
 		}
 
-		var result struct {
-			Message string `json:"message"`
-		}
-		json.Unmarshal(resp, &result)
 		fmt.Println(result.Message)
 	},
 }
@@ -112,8 +89,7 @@ var trashPurgeCmd = &cobra.Command{
 		}
 
 		client := newAPIClient()
-		err := client.delete(fmt.Sprintf("/sdms/%s", fileID))
-		if err != nil {
+		if err := client.PurgeFile(cmd.Context(), fileID); err != nil {
 			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 			os.Exit(1)
 		}
@@ -129,28 +105,17 @@ var trashInfoCmd = &cobra.Command{
 	Run: func(cmd *cobra.Command, args []string) {
 		fileID := args[0]
 		client := newAPIClient()
-		resp, err := client.get(fmt.Sprintf("/sdms/%s", fileID))
+		f, err := client.GetDeletedFile(cmd.Context(), fileID)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 			os.Exit(1)
 		}
 
 		if viper.GetBool("json") {
-			fmt.Println(string(resp))
+			printJSON(f)
 			return
 		}
 
-		var f struct {
-			OriginalName   string    `json:"original_name"`
-			OriginalPath   string    `json:"original_path"`
-			FileSize       int64     `json:"file_size"`
-			DeletedAt      time.Time `json:"deleted_at"`
-			ExpiresAt      time.Time `json:"expires_at"`
-			HoursRemaining float64   `json:"hours_remaining"`
-			Status         string    `json:"status"`
-		}
-		json.Unmarshal(resp, &f)
-
 		fmt.Printf("Name:           %s\n", f.OriginalName)
 		fmt.Printf("Original Path:  %s\n", f.OriginalPath)
 		fmt.Printf("Size:           %s\n", formatBytes(f.FileSize))
@@ -199,41 +164,29 @@ func formatBytes(bytes int64) string {
 	return fmt.Sprintf("%.1f %cB", float64(bytes)/float64(div), "KMGTPE"[exp])
 }
 
-type apiClient struct {
-	endpoint string
-	apiKey   string
-	http     *http.Client
-}
-
-func newAPIClient() *apiClient {
-	return &apiClient{
-		endpoint: viper.GetString("endpoint"),
-		apiKey:   viper.GetString("api_key"),
-		http:     &http.Client{Timeout: 30 * time.Second},
-	}
+// newAPIClient returns a v1 API client for the configured endpoint and
+// credentials
+func newAPIClient() *apiv1.Client {
+	client := apiv1.NewClient(getAPIEndpoint(), getAuthToken())
+	client.SetHTTPClient(newHTTPClient())
+	client.SetUserAgent("darkstorage-cli/" + version)
+	return client
 }
 
-func (c *apiClient) get(path string) ([]byte, error) {
-	req, _ := http.NewRequest("GET", c.endpoint+"/v1"+path, nil)
-	req.Header.Set("Authorization", "Bearer "+c.apiKey)
-	resp, err := c.http.Do(req)
+// printJSON writes v as indented JSON to stdout
+func printJSON(v interface{}) {
+	data, err := json.MarshalIndent(v, "", "  ")
 	if err != nil {
-		return nil, err
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
 	}
-	defer resp.Body.Close()
-	var body []byte
-	resp.Body.Read(body)
-	return body, nil
+	fmt.Println(string(data))
 }
 
-func (c *apiClient) post(path string, data interface{}) ([]byte, error) {
-	// Implementation would use json.Marshal and http.Post
-	return nil, nil
-}
-
-func (c *apiClient) delete(path string) error {
-	req, _ := http.NewRequest("DELETE", c.endpoint+"/v1"+path, nil)
-	req.Header.Set("Authorization", "Bearer "+c.apiKey)
-	_, err := c.http.Do(req)
-	return err
+// shortID abbreviates an ID for table output
+func shortID(id string) string {
+	if len(id) > 8 {
+		return id[:8]
+	}
+	return id
 }
@@ -6,6 +6,7 @@ import (
 	"io"
 	"net/http"
 	"os"
+	"time"
 
 	"github.com/fatih/color"
 	"github.com/spf13/cobra"
@@ -31,7 +32,11 @@ func showCurrentUser() {
 
 	if apiKey == "" {
 		color.Yellow("Not logged in")
-		fmt.Println("Run 'darkstorage login' to authenticate.")
+		if profile := activeProfile(); profile != "" {
+			fmt.Printf("Run 'darkstorage --profile %s login' to authenticate.\n", profile)
+		} else {
+			fmt.Println("Run 'darkstorage login' to authenticate.")
+		}
 		return
 	}
 
@@ -43,6 +48,7 @@ func showCurrentUser() {
 		fmt.Printf("  API Key: %s\n", apiKey)
 	}
 	fmt.Printf("  Endpoint: %s\n", endpoint)
+	fmt.Printf("  Profile: %s\n", profileName())
 
 	// Validate token by making a request to the API
 	verbose, _ := rootCmd.PersistentFlags().GetBool("verbose")
@@ -50,7 +56,7 @@ func showCurrentUser() {
 		fmt.Println("\nValidating credentials...")
 	}
 
-	client := &http.Client{}
+	client := newHTTPClient()
 	req, err := http.NewRequest("GET", endpoint+"/v1/user/profile", nil)
 	if err != nil {
 		color.Red("\nError creating request: %v", err)
@@ -93,4 +99,25 @@ func showCurrentUser() {
 	} else {
 		color.Yellow("\n⚠ Unable to validate credentials (HTTP %d)", resp.StatusCode)
 	}
+
+	showTokenLifetime()
+}
+
+// showTokenLifetime reports when the access token expires. It runs after
+// the validation request, which refreshes an expired OAuth token.
+func showTokenLifetime() {
+	token := storedToken()
+	if token == nil || rootCmd.PersistentFlags().Changed("api-key") {
+		fmt.Println("  Token: API key (does not expire)")
+		return
+	}
+
+	if token.Expiry.IsZero() {
+		fmt.Println("  Token: OAuth (does not expire)")
+	} else {
+		fmt.Printf("  Token expires: %s (in %s)\n",
+			token.Expiry.Local().Format("2006-01-02 15:04:05"),
+			formatLifetime(time.Until(token.Expiry)))
+	}
+	fmt.Println("  Refresh: automatic")
 }
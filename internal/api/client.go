@@ -1,9 +1,12 @@
 package api
 
 import (
-	"io"
 	"net/http"
 	"time"
+
+	"github.com/darkstorage/cli/internal/bandwidth"
+	"github.com/darkstorage/cli/internal/fsmeta"
+	"github.com/darkstorage/cli/internal/storage"
 )
 
 type Client struct {
@@ -11,6 +14,13 @@ type Client struct {
 	apiKey     string
 	httpClient *http.Client
 	timeout    time.Duration
+	metadata   fsmeta.Options
+	encryption string
+	backend    storage.StorageBackend
+
+	// Limiters shared by every upload and download, nil for unlimited
+	uploadLimiter   *bandwidth.Limiter
+	downloadLimiter *bandwidth.Limiter
 }
 
 func NewClient(endpoint, apiKey string) *Client {
@@ -20,7 +30,8 @@ func NewClient(endpoint, apiKey string) *Client {
 		httpClient: &http.Client{
 			Timeout: 30 * time.Second,
 		},
-		timeout: 30 * time.Second,
+		timeout:  30 * time.Second,
+		metadata: fsmeta.DefaultOptions(),
 	}
 }
 
@@ -29,20 +40,29 @@ func (c *Client) SetTimeout(duration time.Duration) {
 	c.httpClient.Timeout = duration
 }
 
-type UploadProgress func(bytesTransferred int64)
-type DownloadProgress func(bytesTransferred int64)
+// SetStorageBackend sets the backend used for file transfers
+func (c *Client) SetStorageBackend(backend storage.StorageBackend) {
+	c.backend = backend
+}
 
-type progressReader struct {
-	reader   io.Reader
-	progress UploadProgress
-	total    int64
+// SetMetadataOptions selects which POSIX metadata transfers preserve
+func (c *Client) SetMetadataOptions(opts fsmeta.Options) {
+	c.metadata = opts
 }
 
-func (pr *progressReader) Read(p []byte) (int, error) {
-	n, err := pr.reader.Read(p)
-	pr.total += int64(n)
-	if pr.progress != nil {
-		pr.progress(pr.total)
-	}
-	return n, err
+// SetBandwidthLimiters makes every upload and download share a limiter,
+// with each other and with any other client given the same ones. Nil
+// means unlimited.
+func (c *Client) SetBandwidthLimiters(upload, download *bandwidth.Limiter) {
+	c.uploadLimiter = upload
+	c.downloadLimiter = download
 }
+
+// SetEncryption selects server-side encryption for uploads, e.g.
+// storage.EncryptionSSES3
+func (c *Client) SetEncryption(mode string) {
+	c.encryption = mode
+}
+
+type UploadProgress func(bytesTransferred int64)
+type DownloadProgress func(bytesTransferred int64)
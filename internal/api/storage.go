@@ -1,50 +1,250 @@
 package api
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"io"
 	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/darkstorage/cli/internal/atomicfile"
+	"github.com/darkstorage/cli/internal/bandwidth"
+	"github.com/darkstorage/cli/internal/fsmeta"
+	"github.com/darkstorage/cli/internal/logging"
+	"github.com/darkstorage/cli/internal/storage"
 )
 
-func (c *Client) UploadFile(localPath, remotePath string, progress UploadProgress) error {
-	file, err := os.Open(localPath)
+var logger = logging.For(logging.Storage)
+
+// MetadataSHA256 is the object metadata key holding the SHA-256 of the
+// uploaded content, used to compare remote and local files
+const MetadataSHA256 = "darkstorage-sha256"
+
+var errNoBackend = fmt.Errorf("storage backend not configured")
+
+// RemoteFile describes an object under a sync folder's remote prefix
+type RemoteFile struct {
+	Path       string
+	Size       int64
+	Hash       string
+	ModifiedAt time.Time
+}
+
+// UploadFile uploads localPath within the client's upload limit and any
+// further limiters given. It returns the version ID of the object written,
+// "" if the bucket keeps no versions.
+func (c *Client) UploadFile(ctx context.Context, localPath, remotePath string, progress UploadProgress, limiters ...*bandwidth.Limiter) (string, error) {
+	if c.backend == nil {
+		return "", errNoBackend
+	}
+
+	metadata, err := fsmeta.Capture(localPath, c.metadata)
 	if err != nil {
-		return err
+		return "", err
 	}
-	defer file.Close()
 
-	stat, err := file.Stat()
+	// Symlinks are stored as empty objects carrying their target
+	var reader io.Reader = strings.NewReader("")
+	if _, isLink := fsmeta.SymlinkTarget(metadata); !isLink {
+		hash, err := hashFile(localPath)
+		if err != nil {
+			return "", err
+		}
+		metadata[MetadataSHA256] = hash
+
+		file, err := os.Open(localPath)
+		if err != nil {
+			return "", err
+		}
+		defer file.Close()
+		reader = file
+	}
+
+	opts := &storage.UploadOptions{
+		Metadata:             metadata,
+		ProgressFunc:         progress,
+		ServerSideEncryption: c.encryption,
+		Limiters:             append(limiters, c.uploadLimiter),
+	}
+
+	result, err := c.backend.Upload(ctx, reader, remotePath, opts)
+	if err != nil {
+		return "", err
+	}
+	return result.VersionID, nil
+}
+
+// DownloadFile downloads to localPath within the client's download limit
+// and any further limiters given. It returns the version ID of the object
+// read, "" if the bucket keeps no versions.
+func (c *Client) DownloadFile(ctx context.Context, remotePath, localPath string, progress DownloadProgress, limiters ...*bandwidth.Limiter) (string, error) {
+	if c.backend == nil {
+		return "", errNoBackend
+	}
+
+	info, err := c.backend.Stat(ctx, remotePath)
 	if err != nil {
-		return err
+		return "", err
 	}
+	metadata := info.Metadata
 
-	var reader io.Reader = file
-	if progress != nil {
-		reader = &progressReader{
-			reader:   file,
-			progress: progress,
+	if target, isLink := fsmeta.SymlinkTarget(metadata); isLink {
+		if err := fsmeta.CreateSymlink(localPath, target); err != nil {
+			return "", err
 		}
+		return info.VersionID, c.applyMetadata(localPath, metadata)
 	}
 
-	fmt.Printf("Uploading %s to %s (%d bytes)\n", localPath, remotePath, stat.Size())
-	_ = reader
-	return nil
+	if err := os.MkdirAll(filepath.Dir(localPath), 0755); err != nil {
+		return "", err
+	}
+
+	// Stream into a temp file beside localPath so an interrupted transfer
+	// never leaves a truncated file for the watcher to pick up
+	tmp, err := atomicfile.Create(localPath)
+	if err != nil {
+		return "", err
+	}
+
+	opts := &storage.DownloadOptions{
+		ProgressFunc: progress,
+		VersionID:    info.VersionID,
+		Limiters:     append(limiters, c.downloadLimiter),
+	}
+
+	result, err := c.backend.Download(ctx, remotePath, tmp, opts)
+	if err != nil {
+		tmp.Abort()
+		return "", err
+	}
+
+	expected := atomicfile.Expected{
+		Size: info.Size,
+		MD5:  atomicfile.MD5FromETag(result.ETag),
+	}
+	expected.SHA256, _ = fsmeta.Lookup(metadata, MetadataSHA256)
+
+	if err := tmp.Commit(expected); err != nil {
+		return "", err
+	}
+	return info.VersionID, c.applyMetadata(localPath, metadata)
 }
 
-func (c *Client) DownloadFile(remotePath, localPath string, progress DownloadProgress) error {
-	fmt.Printf("Downloading %s to %s\n", remotePath, localPath)
+// applyMetadata restores POSIX metadata after a download. Failures are
+// reported but do not fail the transfer, since the content is intact.
+func (c *Client) applyMetadata(localPath string, metadata map[string]string) error {
+	if len(metadata) == 0 {
+		return nil
+	}
+	if err := fsmeta.Apply(localPath, metadata, c.metadata); err != nil {
+		logger.Warn("could not restore metadata", "path", localPath, "error", err)
+	}
 	return nil
 }
 
-func (c *Client) DeleteFile(remotePath string) error {
-	fmt.Printf("Deleting %s\n", remotePath)
-	return nil
+func (c *Client) DeleteFile(ctx context.Context, remotePath string) error {
+	if c.backend == nil {
+		return errNoBackend
+	}
+
+	err := c.backend.Delete(ctx, remotePath)
+	if storage.IsNotFound(err) {
+		return nil
+	}
+	return err
+}
+
+func (c *Client) FileExists(ctx context.Context, remotePath string) (bool, error) {
+	if c.backend == nil {
+		return false, errNoBackend
+	}
+
+	_, err := c.backend.Stat(ctx, remotePath)
+	if storage.IsNotFound(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// GetFileHash returns the SHA-256 recorded when the object was uploaded, or
+// "" for objects written by other tools
+func (c *Client) GetFileHash(ctx context.Context, remotePath string) (string, error) {
+	metadata, err := c.GetFileMetadata(ctx, remotePath)
+	if err != nil {
+		return "", err
+	}
+	hash, _ := fsmeta.Lookup(metadata, MetadataSHA256)
+	return hash, nil
+}
+
+// GetFileMetadata returns the object metadata stored with remotePath
+func (c *Client) GetFileMetadata(ctx context.Context, remotePath string) (map[string]string, error) {
+	if c.backend == nil {
+		return nil, errNoBackend
+	}
+
+	info, err := c.backend.Stat(ctx, remotePath)
+	if err != nil {
+		return nil, err
+	}
+	return info.Metadata, nil
+}
+
+// ListFiles returns every object below remotePrefix. Hashes are only
+// filled in when the backend includes metadata in listings.
+func (c *Client) ListFiles(ctx context.Context, remotePrefix string) ([]RemoteFile, error) {
+	if c.backend == nil {
+		return nil, errNoBackend
+	}
+
+	prefix := strings.TrimSuffix(remotePrefix, "/") + "/"
+	files, err := c.backend.List(ctx, prefix, &storage.ListOptions{
+		Recursive:       true,
+		IncludeMetadata: true,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var remote []RemoteFile
+	for _, file := range files {
+		if file.IsDir {
+			continue
+		}
+		hash, _ := fsmeta.Lookup(file.Metadata, MetadataSHA256)
+		remote = append(remote, RemoteFile{
+			Path:       file.Path,
+			Size:       file.Size,
+			Hash:       hash,
+			ModifiedAt: file.ModifiedAt,
+		})
+	}
+	return remote, nil
 }
 
-func (c *Client) FileExists(remotePath string) (bool, error) {
-	return false, nil
+func hashFile(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	hasher := sha256.New()
+	if _, err := io.Copy(hasher, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hasher.Sum(nil)), nil
 }
 
-func (c *Client) GetFileHash(remotePath string) (string, error) {
-	return "", nil
+// Ping checks that the storage backend can be reached with the client's
+// credentials
+func (c *Client) Ping(ctx context.Context) error {
+	if c.backend == nil {
+		return errNoBackend
+	}
+	return c.backend.Ping(ctx)
 }
@@ -0,0 +1,85 @@
+package v1
+
+import (
+	"context"
+	"time"
+)
+
+type AuditEvent struct {
+	ID           string    `json:"id"`
+	EventType    string    `json:"event_type"`
+	ResourceType string    `json:"resource_type"`
+	ResourceName string    `json:"resource_name"`
+	ActorEmail   string    `json:"actor_email"`
+	IPAddress    string    `json:"ip_address"`
+	UserAgent    string    `json:"user_agent"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+type AuditEventList struct {
+	Events     []AuditEvent `json:"events"`
+	NextCursor string       `json:"next_cursor,omitempty"`
+}
+
+// AuditListOptions filters audit events. From and To are RFC 3339 times or
+// dates.
+type AuditListOptions struct {
+	PageOptions
+	EventType  string
+	ActorEmail string
+	Resource   string
+	From       string
+	To         string
+}
+
+type AuditSummary struct {
+	TotalEvents    int            `json:"total_events"`
+	EventsByType   map[string]int `json:"events_by_type"`
+	EventsToday    int            `json:"events_today"`
+	EventsThisWeek int            `json:"events_this_week"`
+}
+
+func (c *Client) ListAuditEvents(ctx context.Context, opts AuditListOptions) (*AuditEventList, error) {
+	q := opts.query()
+	if opts.EventType != "" {
+		q.Set("event_type", opts.EventType)
+	}
+	if opts.ActorEmail != "" {
+		q.Set("actor_email", opts.ActorEmail)
+	}
+	if opts.Resource != "" {
+		q.Set("resource", opts.Resource)
+	}
+	if opts.From != "" {
+		q.Set("from", opts.From)
+	}
+	if opts.To != "" {
+		q.Set("to", opts.To)
+	}
+
+	var list AuditEventList
+	if err := c.get(ctx, "/audit", q, &list); err != nil {
+		return nil, err
+	}
+	return &list, nil
+}
+
+// AuditEvents returns a PageFunc over ListAuditEvents for use with Collect
+func (c *Client) AuditEvents(opts AuditListOptions) PageFunc[AuditEvent] {
+	return func(ctx context.Context, cursor string) ([]AuditEvent, string, error) {
+		opts.Cursor = cursor
+		list, err := c.ListAuditEvents(ctx, opts)
+		if err != nil {
+			return nil, "", err
+		}
+		return list.Events, list.NextCursor, nil
+	}
+}
+
+func (c *Client) GetAuditSummary(ctx context.Context) (*AuditSummary, error) {
+	var summary AuditSummary
+	if err := c.get(ctx, "/audit/summary", nil, &summary); err != nil {
+		return nil, err
+	}
+	return &summary, nil
+}
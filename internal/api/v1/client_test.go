@@ -0,0 +1,336 @@
+package v1_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	apiv1 "github.com/darkstorage/cli/internal/api/v1"
+	"github.com/darkstorage/cli/internal/devapi"
+)
+
+const testToken = "dev-token"
+
+// newFakeAPI serves a fake API seeded with the default fixtures and
+// returns its endpoint
+func newFakeAPI(t *testing.T) (*devapi.Server, string) {
+	t.Helper()
+	fake := devapi.New(devapi.DefaultFixtures(time.Now()), testToken)
+	srv := httptest.NewServer(fake)
+	t.Cleanup(srv.Close)
+	return fake, srv.URL
+}
+
+func newTestClient(t *testing.T) (*apiv1.Client, *devapi.Server) {
+	t.Helper()
+	fake, endpoint := newFakeAPI(t)
+	return apiv1.NewClient(endpoint, testToken), fake
+}
+
+func TestClientLists(t *testing.T) {
+	client, _ := newTestClient(t)
+	ctx := context.Background()
+
+	// count returns how many items a paged list yields one at a time, so
+	// every list also exercises its cursors
+	count := func(fetch func(ctx context.Context, cursor string) (int, string, error)) (int, error) {
+		total, cursor := 0, ""
+		for {
+			n, next, err := fetch(ctx, cursor)
+			if err != nil {
+				return total, err
+			}
+			total += n
+			if next == "" {
+				return total, nil
+			}
+			cursor = next
+		}
+	}
+	page := func(cursor string) apiv1.PageOptions { return apiv1.PageOptions{Limit: 1, Cursor: cursor} }
+
+	tests := []struct {
+		name string
+		list func() (int, error)
+		want int
+	}{
+		{
+			name: "recoverable files",
+			list: func() (int, error) {
+				return count(func(ctx context.Context, cursor string) (int, string, error) {
+					r, err := client.ListRecoverable(ctx, page(cursor))
+					if err != nil {
+						return 0, "", err
+					}
+					return len(r.Files), r.NextCursor, nil
+				})
+			},
+			want: 2,
+		},
+		{
+			name: "audit events",
+			list: func() (int, error) {
+				events, err := apiv1.Collect(ctx, 0, client.AuditEvents(apiv1.AuditListOptions{PageOptions: apiv1.PageOptions{Limit: 1}}))
+				return len(events), err
+			},
+			want: 4,
+		},
+		{
+			name: "audit events of a type",
+			list: func() (int, error) {
+				r, err := client.ListAuditEvents(ctx, apiv1.AuditListOptions{EventType: "FILE_DOWNLOAD"})
+				if err != nil {
+					return 0, err
+				}
+				return len(r.Events), nil
+			},
+			want: 1,
+		},
+		{
+			name: "share links",
+			list: func() (int, error) {
+				links, err := apiv1.Collect(ctx, 0, client.ShareLinks())
+				return len(links), err
+			},
+			want: 2,
+		},
+		{
+			name: "groups",
+			list: func() (int, error) {
+				groups, err := apiv1.Collect(ctx, 0, client.Groups())
+				return len(groups), err
+			},
+			want: 1,
+		},
+		{
+			name: "group members",
+			list: func() (int, error) {
+				members, err := apiv1.Collect(ctx, 0, client.GroupMembers("g1a2b3c4"))
+				return len(members), err
+			},
+			want: 3,
+		},
+		{
+			name: "permissions",
+			list: func() (int, error) {
+				r, err := client.ListPermissions(ctx, "file-design")
+				if err != nil {
+					return 0, err
+				}
+				return len(r.Permissions), nil
+			},
+			want: 2,
+		},
+		{
+			name: "threats",
+			list: func() (int, error) {
+				threats, err := apiv1.Collect(ctx, 0, client.Threats())
+				return len(threats), err
+			},
+			want: 1,
+		},
+		{
+			name: "quarantine",
+			list: func() (int, error) {
+				files, err := apiv1.Collect(ctx, 0, client.Quarantine())
+				return len(files), err
+			},
+			want: 1,
+		},
+		{
+			name: "compartments",
+			list: func() (int, error) {
+				compartments, err := apiv1.Collect(ctx, 0, client.Compartments())
+				return len(compartments), err
+			},
+			want: 2,
+		},
+		{
+			name: "compartment files",
+			list: func() (int, error) {
+				return count(func(ctx context.Context, cursor string) (int, string, error) {
+					r, err := client.ListCompartmentFiles(ctx, "classified", page(cursor))
+					if err != nil {
+						return 0, "", err
+					}
+					return len(r.Files), r.NextCursor, nil
+				})
+			},
+			want: 2,
+		},
+		{
+			name: "collect stops at max",
+			list: func() (int, error) {
+				events, err := apiv1.Collect(ctx, 3, client.AuditEvents(apiv1.AuditListOptions{PageOptions: apiv1.PageOptions{Limit: 2}}))
+				return len(events), err
+			},
+			want: 3,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.list()
+			if err != nil {
+				t.Fatalf("list error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("listed %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClientErrors(t *testing.T) {
+	_, endpoint := newFakeAPI(t)
+	ctx := context.Background()
+
+	tests := []struct {
+		name       string
+		token      string
+		call       func(*apiv1.Client) error
+		wantStatus int
+		check      func(error) bool
+	}{
+		{
+			name:  "wrong token",
+			token: "wrong",
+			call: func(c *apiv1.Client) error {
+				_, err := c.ListGroups(ctx, apiv1.PageOptions{})
+				return err
+			},
+			wantStatus: http.StatusUnauthorized,
+			check:      apiv1.IsUnauthorized,
+		},
+		{
+			name: "unknown deleted file",
+			call: func(c *apiv1.Client) error {
+				_, err := c.GetDeletedFile(ctx, "nonexistent")
+				return err
+			},
+			wantStatus: http.StatusNotFound,
+			check:      apiv1.IsNotFound,
+		},
+		{
+			name: "duplicate compartment",
+			call: func(c *apiv1.Client) error {
+				_, err := c.CreateCompartment(ctx, apiv1.CreateCompartmentRequest{Name: "pii", Level: "CONFIDENTIAL"})
+				return err
+			},
+			wantStatus: http.StatusConflict,
+			check:      apiv1.IsConflict,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token := testToken
+			if tt.token != "" {
+				token = tt.token
+			}
+			err := tt.call(apiv1.NewClient(endpoint, token))
+			var apiErr *apiv1.Error
+			if !errors.As(err, &apiErr) {
+				t.Fatalf("error = %v, want an *Error", err)
+			}
+			if apiErr.StatusCode != tt.wantStatus {
+				t.Errorf("StatusCode = %d, want %d", apiErr.StatusCode, tt.wantStatus)
+			}
+			if apiErr.RequestID == "" {
+				t.Error("RequestID is empty")
+			}
+			if !tt.check(err) {
+				t.Errorf("error %v not classified as a %d", err, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestClientChanges(t *testing.T) {
+	client, fake := newTestClient(t)
+	ctx := context.Background()
+
+	steps := []struct {
+		name string
+		call func() error
+	}{
+		{"recover file", func() error {
+			_, err := client.RecoverFile(ctx, "5f1c2a9e", apiv1.RecoverRequest{RecoverToPath: "restored/q3.pdf"})
+			return err
+		}},
+		{"purge file", func() error { return client.PurgeFile(ctx, "9a8b7c6d5e4f") }},
+		{"create share link", func() error {
+			_, err := client.CreateShareLink(ctx, apiv1.CreateShareLinkRequest{FilePath: "bucket/notes.txt", MaxDownloads: 2})
+			return err
+		}},
+		{"revoke share link", func() error { return client.RevokeShareLink(ctx, "3c2b1a0f9e8d") }},
+		{"create group", func() error {
+			_, err := client.CreateGroup(ctx, apiv1.CreateGroupRequest{Name: "design"})
+			return err
+		}},
+		{"add group member", func() error {
+			return client.AddGroupMember(ctx, "g1a2b3c4", apiv1.AddGroupMemberRequest{Email: "carol@example.com", Role: "member"})
+		}},
+		{"remove group member", func() error { return client.RemoveGroupMember(ctx, "g1a2b3c4", "bob@example.com") }},
+		{"grant permission", func() error {
+			return client.GrantPermission(ctx, "file-design", apiv1.GrantPermissionRequest{GranteeType: "user", GranteeEmail: "carol@example.com", Level: "viewer"})
+		}},
+		{"revoke permission", func() error { return client.RevokePermission(ctx, "file-design", "p9e8d7c6b5a4f3e2") }},
+		{"scan file", func() error {
+			_, err := client.ScanFile(ctx, "bucket/dir/new.bin", apiv1.ScanRequest{ScanType: "antivirus"})
+			return err
+		}},
+		{"release quarantine", func() error { return client.ReleaseQuarantine(ctx, "q0a1b2c3") }},
+		{"create compartment", func() error {
+			_, err := client.CreateCompartment(ctx, apiv1.CreateCompartmentRequest{Name: "legal", Level: "CONFIDENTIAL"})
+			return err
+		}},
+		{"assign compartment", func() error {
+			_, err := client.AssignCompartment(ctx, "legal", apiv1.AssignCompartmentRequest{Path: "bucket/contracts/a.pdf"})
+			return err
+		}},
+		{"grant compartment access", func() error {
+			return client.GrantCompartmentAccess(ctx, "legal", apiv1.GrantCompartmentRequest{User: "alice@example.com", Access: "read"})
+		}},
+		{"revoke compartment access", func() error { return client.RevokeCompartmentAccess(ctx, "classified", "alice@example.com") }},
+		{"delete compartment", func() error { return client.DeleteCompartment(ctx, "pii") }},
+	}
+	for _, step := range steps {
+		if err := step.call(); err != nil {
+			t.Fatalf("%s: %v", step.name, err)
+		}
+	}
+
+	state, err := fake.Snapshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+	checks := []struct {
+		name      string
+		got, want int
+	}{
+		{"deleted files", len(state.DeletedFiles), 0},
+		{"share links", len(state.ShareLinks), 3},
+		{"groups", len(state.Groups), 2},
+		{"engineering members", len(state.Groups[0].Members), 3},
+		{"design permissions", len(state.Permissions["file-design"]), 2},
+		{"scans", len(state.Scans), 3},
+		{"quarantined files", len(state.Quarantine), 0},
+		{"compartments", len(state.Compartments), 2},
+		{"legal files", len(state.CompartmentFiles["legal"]), 1},
+		{"legal users", len(state.CompartmentUsers["legal"]), 2},
+		{"classified users", len(state.CompartmentUsers["classified"]), 1},
+		{"audit events", len(state.AuditEvents), 4 + len(steps)},
+	}
+	for _, tt := range checks {
+		if tt.got != tt.want {
+			t.Errorf("%s = %d, want %d", tt.name, tt.got, tt.want)
+		}
+	}
+
+	revoked := state.ShareLinks[0]
+	if revoked.IsActive {
+		t.Errorf("share link %s still active after revoking", revoked.ID)
+	}
+}
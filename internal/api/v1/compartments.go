@@ -0,0 +1,116 @@
+package v1
+
+import (
+	"context"
+	"time"
+)
+
+type CompartmentPolicies struct {
+	RequireMFA bool   `json:"require_mfa"`
+	Encryption string `json:"encryption"`
+}
+
+type Compartment struct {
+	Name        string              `json:"name"`
+	Level       string              `json:"level"`
+	Description string              `json:"description,omitempty"`
+	Compliance  []string            `json:"compliance"`
+	Policies    CompartmentPolicies `json:"policies"`
+	FileCount   int                 `json:"file_count"`
+	UserCount   int                 `json:"user_count"`
+	CreatedAt   time.Time           `json:"created_at"`
+}
+
+type CompartmentList struct {
+	Compartments []Compartment `json:"compartments"`
+	NextCursor   string        `json:"next_cursor,omitempty"`
+}
+
+type CreateCompartmentRequest struct {
+	Name        string              `json:"name"`
+	Level       string              `json:"level"`
+	Compliance  []string            `json:"compliance"`
+	Description string              `json:"description,omitempty"`
+	Policies    CompartmentPolicies `json:"policies"`
+}
+
+type AssignCompartmentRequest struct {
+	Path      string `json:"path"`
+	Recursive bool   `json:"recursive"`
+}
+
+type AssignCompartmentResponse struct {
+	FilesAssigned int `json:"files_assigned"`
+}
+
+type CompartmentFile struct {
+	Path       string    `json:"path"`
+	Size       int64     `json:"size"`
+	AssignedAt time.Time `json:"assigned_at"`
+}
+
+type CompartmentFileList struct {
+	Files      []CompartmentFile `json:"files"`
+	Total      int               `json:"total"`
+	NextCursor string            `json:"next_cursor,omitempty"`
+}
+
+type GrantCompartmentRequest struct {
+	User   string `json:"user"`
+	Access string `json:"access"`
+}
+
+func (c *Client) CreateCompartment(ctx context.Context, req CreateCompartmentRequest) (*Compartment, error) {
+	var compartment Compartment
+	if err := c.post(ctx, "/compartments", req, &compartment); err != nil {
+		return nil, err
+	}
+	return &compartment, nil
+}
+
+func (c *Client) ListCompartments(ctx context.Context, opts PageOptions) (*CompartmentList, error) {
+	var list CompartmentList
+	if err := c.get(ctx, "/compartments", opts.query(), &list); err != nil {
+		return nil, err
+	}
+	return &list, nil
+}
+
+// Compartments returns a PageFunc over ListCompartments for use with Collect
+func (c *Client) Compartments() PageFunc[Compartment] {
+	return func(ctx context.Context, cursor string) ([]Compartment, string, error) {
+		list, err := c.ListCompartments(ctx, PageOptions{Cursor: cursor})
+		if err != nil {
+			return nil, "", err
+		}
+		return list.Compartments, list.NextCursor, nil
+	}
+}
+
+func (c *Client) DeleteCompartment(ctx context.Context, name string) error {
+	return c.delete(ctx, pathf("/compartments/%s", name))
+}
+
+func (c *Client) AssignCompartment(ctx context.Context, name string, req AssignCompartmentRequest) (*AssignCompartmentResponse, error) {
+	var resp AssignCompartmentResponse
+	if err := c.post(ctx, pathf("/compartments/%s/assign", name), req, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+func (c *Client) ListCompartmentFiles(ctx context.Context, name string, opts PageOptions) (*CompartmentFileList, error) {
+	var list CompartmentFileList
+	if err := c.get(ctx, pathf("/compartments/%s/files", name), opts.query(), &list); err != nil {
+		return nil, err
+	}
+	return &list, nil
+}
+
+func (c *Client) GrantCompartmentAccess(ctx context.Context, name string, req GrantCompartmentRequest) error {
+	return c.post(ctx, pathf("/compartments/%s/grant", name), req, nil)
+}
+
+func (c *Client) RevokeCompartmentAccess(ctx context.Context, name, user string) error {
+	return c.delete(ctx, pathf("/compartments/%s/users/%s", name, user))
+}
@@ -0,0 +1,84 @@
+package v1
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Error is a non-2xx response from the API
+type Error struct {
+	StatusCode int                    `json:"-"`
+	Code       string                 `json:"code"`
+	Message    string                 `json:"message"`
+	RequestID  string                 `json:"request_id,omitempty"`
+	Details    map[string]interface{} `json:"details,omitempty"`
+}
+
+func (e *Error) Error() string {
+	msg := e.Message
+	if msg == "" {
+		msg = http.StatusText(e.StatusCode)
+	}
+	if e.Code != "" {
+		msg = fmt.Sprintf("%s (%s)", msg, e.Code)
+	}
+	if e.RequestID != "" {
+		msg = fmt.Sprintf("%s [request %s]", msg, e.RequestID)
+	}
+	return fmt.Sprintf("API error %d: %s", e.StatusCode, msg)
+}
+
+func newError(resp *http.Response, body []byte) *Error {
+	apiErr := &Error{StatusCode: resp.StatusCode}
+
+	// Accept both {"error": {...}} and a flat {"code": ..., "message": ...}
+	var envelope struct {
+		Error json.RawMessage `json:"error"`
+	}
+	if json.Unmarshal(body, &envelope) == nil && len(envelope.Error) > 0 {
+		if json.Unmarshal(envelope.Error, apiErr) != nil {
+			// "error" was a plain string
+			var msg string
+			json.Unmarshal(envelope.Error, &msg)
+			apiErr.Message = msg
+		}
+	} else {
+		json.Unmarshal(body, apiErr)
+	}
+
+	if apiErr.Message == "" {
+		apiErr.Message = strings.TrimSpace(string(body))
+		if len(apiErr.Message) > 200 {
+			apiErr.Message = apiErr.Message[:200] + "..."
+		}
+	}
+	if apiErr.RequestID == "" {
+		apiErr.RequestID = resp.Header.Get("X-Request-ID")
+	}
+	if apiErr.RequestID == "" && resp.Request != nil {
+		// Fall back to the ID we sent so the failure can still be traced
+		apiErr.RequestID = resp.Request.Header.Get("X-Request-ID")
+	}
+	apiErr.StatusCode = resp.StatusCode
+	return apiErr
+}
+
+func statusIs(err error, code int) bool {
+	var apiErr *Error
+	return errors.As(err, &apiErr) && apiErr.StatusCode == code
+}
+
+func IsNotFound(err error) bool {
+	return statusIs(err, http.StatusNotFound)
+}
+
+func IsUnauthorized(err error) bool {
+	return statusIs(err, http.StatusUnauthorized) || statusIs(err, http.StatusForbidden)
+}
+
+func IsConflict(err error) bool {
+	return statusIs(err, http.StatusConflict)
+}
@@ -0,0 +1,96 @@
+package v1
+
+import "context"
+
+type Group struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	MemberCount int    `json:"member_count"`
+	MyRole      string `json:"my_role"`
+}
+
+type GroupList struct {
+	Groups     []Group `json:"groups"`
+	NextCursor string  `json:"next_cursor,omitempty"`
+}
+
+type CreateGroupRequest struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+}
+
+type GroupMember struct {
+	Email  string `json:"email"`
+	Name   string `json:"name"`
+	Role   string `json:"role"`
+	Status string `json:"status"`
+}
+
+type GroupMemberList struct {
+	Members    []GroupMember `json:"members"`
+	NextCursor string        `json:"next_cursor,omitempty"`
+}
+
+type AddGroupMemberRequest struct {
+	Email string `json:"email"`
+	Role  string `json:"role"`
+}
+
+func (c *Client) ListGroups(ctx context.Context, opts PageOptions) (*GroupList, error) {
+	var list GroupList
+	if err := c.get(ctx, "/groups", opts.query(), &list); err != nil {
+		return nil, err
+	}
+	return &list, nil
+}
+
+// Groups returns a PageFunc over ListGroups for use with Collect
+func (c *Client) Groups() PageFunc[Group] {
+	return func(ctx context.Context, cursor string) ([]Group, string, error) {
+		list, err := c.ListGroups(ctx, PageOptions{Cursor: cursor})
+		if err != nil {
+			return nil, "", err
+		}
+		return list.Groups, list.NextCursor, nil
+	}
+}
+
+func (c *Client) CreateGroup(ctx context.Context, req CreateGroupRequest) (*Group, error) {
+	var group Group
+	if err := c.post(ctx, "/groups", req, &group); err != nil {
+		return nil, err
+	}
+	return &group, nil
+}
+
+func (c *Client) DeleteGroup(ctx context.Context, id string) error {
+	return c.delete(ctx, pathf("/groups/%s", id))
+}
+
+func (c *Client) ListGroupMembers(ctx context.Context, groupID string, opts PageOptions) (*GroupMemberList, error) {
+	var list GroupMemberList
+	if err := c.get(ctx, pathf("/groups/%s/members", groupID), opts.query(), &list); err != nil {
+		return nil, err
+	}
+	return &list, nil
+}
+
+// GroupMembers returns a PageFunc over ListGroupMembers for use with Collect
+func (c *Client) GroupMembers(groupID string) PageFunc[GroupMember] {
+	return func(ctx context.Context, cursor string) ([]GroupMember, string, error) {
+		list, err := c.ListGroupMembers(ctx, groupID, PageOptions{Cursor: cursor})
+		if err != nil {
+			return nil, "", err
+		}
+		return list.Members, list.NextCursor, nil
+	}
+}
+
+func (c *Client) AddGroupMember(ctx context.Context, groupID string, req AddGroupMemberRequest) error {
+	return c.post(ctx, pathf("/groups/%s/members", groupID), req, nil)
+}
+
+func (c *Client) RemoveGroupMember(ctx context.Context, groupID, email string) error {
+	return c.delete(ctx, pathf("/groups/%s/members/%s", groupID, email))
+}
@@ -0,0 +1,51 @@
+package v1
+
+import (
+	"context"
+	"net/url"
+	"strconv"
+)
+
+// PageOptions selects one page of a list endpoint. Responses carry a
+// NextCursor that is empty on the last page.
+type PageOptions struct {
+	Limit  int
+	Cursor string
+}
+
+func (p PageOptions) query() url.Values {
+	q := url.Values{}
+	if p.Limit > 0 {
+		q.Set("limit", strconv.Itoa(p.Limit))
+	}
+	if p.Cursor != "" {
+		q.Set("cursor", p.Cursor)
+	}
+	return q
+}
+
+// PageFunc fetches the page starting at cursor and returns its items and
+// the cursor of the next page
+type PageFunc[T any] func(ctx context.Context, cursor string) ([]T, string, error)
+
+// Collect follows cursors until the last page or until max items have been
+// gathered. A max of 0 or less means no limit.
+func Collect[T any](ctx context.Context, max int, fetch PageFunc[T]) ([]T, error) {
+	var all []T
+	cursor := ""
+	for {
+		items, next, err := fetch(ctx, cursor)
+		if err != nil {
+			return all, err
+		}
+		all = append(all, items...)
+
+		if max > 0 && len(all) >= max {
+			return all[:max], nil
+		}
+		if next == "" || next == cursor {
+			return all, nil
+		}
+		cursor = next
+	}
+}
@@ -0,0 +1,61 @@
+package v1
+
+import "context"
+
+type Permission struct {
+	ID           string `json:"id"`
+	GranteeType  string `json:"grantee_type"`
+	GranteeName  string `json:"grantee_name"`
+	GranteeEmail string `json:"grantee_email"`
+	Level        string `json:"level"`
+	CanRead      bool   `json:"can_read"`
+	CanWrite     bool   `json:"can_write"`
+	CanDelete    bool   `json:"can_delete"`
+	CanShare     bool   `json:"can_share"`
+}
+
+type PermissionList struct {
+	Permissions []Permission `json:"permissions"`
+}
+
+// GrantPermissionRequest grants Level to a user (by GranteeEmail) or a group
+// (by GranteeID)
+type GrantPermissionRequest struct {
+	GranteeType  string `json:"grantee_type"`
+	GranteeEmail string `json:"grantee_email,omitempty"`
+	GranteeID    string `json:"grantee_id,omitempty"`
+	Level        string `json:"level"`
+}
+
+type EffectivePermissions struct {
+	CanRead   bool `json:"can_read"`
+	CanWrite  bool `json:"can_write"`
+	CanDelete bool `json:"can_delete"`
+	CanShare  bool `json:"can_share"`
+	CanAdmin  bool `json:"can_admin"`
+	IsOwner   bool `json:"is_owner"`
+}
+
+func (c *Client) ListPermissions(ctx context.Context, fileID string) (*PermissionList, error) {
+	var list PermissionList
+	if err := c.get(ctx, pathf("/files/%s/permissions", fileID), nil, &list); err != nil {
+		return nil, err
+	}
+	return &list, nil
+}
+
+func (c *Client) GrantPermission(ctx context.Context, fileID string, req GrantPermissionRequest) error {
+	return c.post(ctx, pathf("/files/%s/permissions", fileID), req, nil)
+}
+
+func (c *Client) RevokePermission(ctx context.Context, fileID, permissionID string) error {
+	return c.delete(ctx, pathf("/files/%s/permissions/%s", fileID, permissionID))
+}
+
+func (c *Client) GetEffectivePermissions(ctx context.Context, fileID string) (*EffectivePermissions, error) {
+	var perms EffectivePermissions
+	if err := c.get(ctx, pathf("/files/%s/permissions/effective", fileID), nil, &perms); err != nil {
+		return nil, err
+	}
+	return &perms, nil
+}
@@ -0,0 +1,108 @@
+package v1
+
+import "context"
+
+type ScanRequest struct {
+	ScanType string `json:"scan_type"`
+	Priority int    `json:"priority"`
+}
+
+type ScanQueued struct {
+	Message string `json:"message"`
+	QueueID string `json:"queue_id"`
+}
+
+type Threat struct {
+	Name     string `json:"name"`
+	Category string `json:"category"`
+}
+
+type ScanResult struct {
+	Status     string   `json:"status"`
+	ScanEngine string   `json:"scan_engine"`
+	Severity   string   `json:"severity"`
+	Threats    []Threat `json:"threats"`
+}
+
+type ThreatDetection struct {
+	FileID   string `json:"file_id"`
+	FileName string `json:"file_name"`
+	Status   string `json:"status"`
+	Severity string `json:"severity"`
+}
+
+type ThreatList struct {
+	Threats    []ThreatDetection `json:"threats"`
+	NextCursor string            `json:"next_cursor,omitempty"`
+}
+
+type QuarantinedFile struct {
+	ID               string `json:"id"`
+	OriginalName     string `json:"original_name"`
+	QuarantineReason string `json:"quarantine_reason"`
+	FileSize         int64  `json:"file_size"`
+}
+
+type QuarantineList struct {
+	Files      []QuarantinedFile `json:"files"`
+	NextCursor string            `json:"next_cursor,omitempty"`
+}
+
+// ScanFile queues a scan of the object at filePath (bucket/key)
+func (c *Client) ScanFile(ctx context.Context, filePath string, req ScanRequest) (*ScanQueued, error) {
+	var queued ScanQueued
+	if err := c.post(ctx, "/files/"+objectPath(filePath)+"/scan", req, &queued); err != nil {
+		return nil, err
+	}
+	return &queued, nil
+}
+
+func (c *Client) GetScanResult(ctx context.Context, fileID string) (*ScanResult, error) {
+	var result ScanResult
+	if err := c.get(ctx, pathf("/files/%s/scan-result", fileID), nil, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+func (c *Client) ListThreats(ctx context.Context, opts PageOptions) (*ThreatList, error) {
+	var list ThreatList
+	if err := c.get(ctx, "/scanner/threats", opts.query(), &list); err != nil {
+		return nil, err
+	}
+	return &list, nil
+}
+
+// Threats returns a PageFunc over ListThreats for use with Collect
+func (c *Client) Threats() PageFunc[ThreatDetection] {
+	return func(ctx context.Context, cursor string) ([]ThreatDetection, string, error) {
+		list, err := c.ListThreats(ctx, PageOptions{Cursor: cursor})
+		if err != nil {
+			return nil, "", err
+		}
+		return list.Threats, list.NextCursor, nil
+	}
+}
+
+func (c *Client) ListQuarantine(ctx context.Context, opts PageOptions) (*QuarantineList, error) {
+	var list QuarantineList
+	if err := c.get(ctx, "/scanner/quarantine", opts.query(), &list); err != nil {
+		return nil, err
+	}
+	return &list, nil
+}
+
+// Quarantine returns a PageFunc over ListQuarantine for use with Collect
+func (c *Client) Quarantine() PageFunc[QuarantinedFile] {
+	return func(ctx context.Context, cursor string) ([]QuarantinedFile, string, error) {
+		list, err := c.ListQuarantine(ctx, PageOptions{Cursor: cursor})
+		if err != nil {
+			return nil, "", err
+		}
+		return list.Files, list.NextCursor, nil
+	}
+}
+
+func (c *Client) ReleaseQuarantine(ctx context.Context, id string) error {
+	return c.post(ctx, pathf("/scanner/quarantine/%s/release", id), nil, nil)
+}
@@ -0,0 +1,74 @@
+package v1
+
+import (
+	"context"
+	"time"
+)
+
+// DeletedFile is a soft-deleted file held by the Secure Deletion Management
+// System until it expires
+type DeletedFile struct {
+	ID             string    `json:"id"`
+	OriginalName   string    `json:"original_name"`
+	OriginalPath   string    `json:"original_path"`
+	FileSize       int64     `json:"file_size"`
+	Status         string    `json:"status,omitempty"`
+	DeletedAt      time.Time `json:"deleted_at"`
+	ExpiresAt      time.Time `json:"expires_at"`
+	HoursRemaining float64   `json:"hours_remaining"`
+	Urgency        string    `json:"urgency"`
+}
+
+type DeletedFileList struct {
+	Files      []DeletedFile `json:"files"`
+	NextCursor string        `json:"next_cursor,omitempty"`
+}
+
+type RecoverRequest struct {
+	RecoverToPath string `json:"recover_to_path,omitempty"`
+}
+
+type MessageResponse struct {
+	Message string `json:"message"`
+}
+
+func (c *Client) ListRecoverable(ctx context.Context, opts PageOptions) (*DeletedFileList, error) {
+	var list DeletedFileList
+	if err := c.get(ctx, "/sdms/recoverable", opts.query(), &list); err != nil {
+		return nil, err
+	}
+	return &list, nil
+}
+
+// RecoverableFiles returns a PageFunc over ListRecoverable for use with
+// Collect
+func (c *Client) RecoverableFiles() PageFunc[DeletedFile] {
+	return func(ctx context.Context, cursor string) ([]DeletedFile, string, error) {
+		list, err := c.ListRecoverable(ctx, PageOptions{Cursor: cursor})
+		if err != nil {
+			return nil, "", err
+		}
+		return list.Files, list.NextCursor, nil
+	}
+}
+
+func (c *Client) GetDeletedFile(ctx context.Context, id string) (*DeletedFile, error) {
+	var file DeletedFile
+	if err := c.get(ctx, pathf("/sdms/%s", id), nil, &file); err != nil {
+		return nil, err
+	}
+	return &file, nil
+}
+
+func (c *Client) RecoverFile(ctx context.Context, id string, req RecoverRequest) (*MessageResponse, error) {
+	var resp MessageResponse
+	if err := c.post(ctx, pathf("/sdms/%s/recover", id), req, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// PurgeFile permanently deletes a file before its recovery window ends
+func (c *Client) PurgeFile(ctx context.Context, id string) error {
+	return c.delete(ctx, pathf("/sdms/%s", id))
+}
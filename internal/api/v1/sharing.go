@@ -0,0 +1,76 @@
+package v1
+
+import (
+	"context"
+	"time"
+)
+
+type CreateShareLinkRequest struct {
+	FilePath     string     `json:"file_path"`
+	ExpiresAt    *time.Time `json:"expires_at,omitempty"`
+	Password     string     `json:"password,omitempty"`
+	MaxDownloads int        `json:"max_downloads,omitempty"`
+	OneTimeUse   bool       `json:"one_time_use"`
+}
+
+type ShareLink struct {
+	ID            string     `json:"id"`
+	FileName      string     `json:"file_name"`
+	Token         string     `json:"token"`
+	URL           string     `json:"url,omitempty"`
+	ExpiresAt     *time.Time `json:"expires_at"`
+	DownloadCount int        `json:"download_count"`
+	MaxDownloads  *int       `json:"max_downloads"`
+	IsActive      bool       `json:"is_active"`
+}
+
+type ShareLinkList struct {
+	Links      []ShareLink `json:"links"`
+	NextCursor string      `json:"next_cursor,omitempty"`
+}
+
+type ShareLinkStats struct {
+	ViewCount      int   `json:"view_count"`
+	DownloadCount  int   `json:"download_count"`
+	BandwidthUsed  int64 `json:"bandwidth_used"`
+	UniqueVisitors int   `json:"unique_visitors"`
+}
+
+func (c *Client) CreateShareLink(ctx context.Context, req CreateShareLinkRequest) (*ShareLink, error) {
+	var link ShareLink
+	if err := c.post(ctx, "/sharing/links", req, &link); err != nil {
+		return nil, err
+	}
+	return &link, nil
+}
+
+func (c *Client) ListShareLinks(ctx context.Context, opts PageOptions) (*ShareLinkList, error) {
+	var list ShareLinkList
+	if err := c.get(ctx, "/sharing/links", opts.query(), &list); err != nil {
+		return nil, err
+	}
+	return &list, nil
+}
+
+// ShareLinks returns a PageFunc over ListShareLinks for use with Collect
+func (c *Client) ShareLinks() PageFunc[ShareLink] {
+	return func(ctx context.Context, cursor string) ([]ShareLink, string, error) {
+		list, err := c.ListShareLinks(ctx, PageOptions{Cursor: cursor})
+		if err != nil {
+			return nil, "", err
+		}
+		return list.Links, list.NextCursor, nil
+	}
+}
+
+func (c *Client) RevokeShareLink(ctx context.Context, id string) error {
+	return c.delete(ctx, pathf("/sharing/links/%s", id))
+}
+
+func (c *Client) GetShareLinkStats(ctx context.Context, id string) (*ShareLinkStats, error) {
+	var stats ShareLinkStats
+	if err := c.get(ctx, pathf("/sharing/links/%s/stats", id), nil, &stats); err != nil {
+		return nil, err
+	}
+	return &stats, nil
+}
@@ -0,0 +1,174 @@
+// Package atomicfile writes downloads to a temporary file next to the
+// destination and only renames it into place once the content is verified,
+// so an interrupted transfer never leaves a truncated file behind.
+package atomicfile
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	tempPrefix = ".darkstorage-"
+	tempSuffix = ".partial"
+)
+
+// IsTemp reports whether path names an in-progress download. Watchers and
+// scanners should ignore these files.
+func IsTemp(path string) bool {
+	name := filepath.Base(path)
+	return strings.HasPrefix(name, tempPrefix) && strings.HasSuffix(name, tempSuffix)
+}
+
+// Expected describes what the finished file must match. A Size of -1 and
+// empty hashes are not checked.
+type Expected struct {
+	Size   int64
+	MD5    string
+	SHA256 string
+}
+
+// ExpectSize returns an Expected that only checks the size
+func ExpectSize(size int64) Expected {
+	return Expected{Size: size}
+}
+
+// File is a temporary file that becomes dest on Commit
+type File struct {
+	file    *os.File
+	dest    string
+	md5     hash.Hash
+	sha256  hash.Hash
+	written int64
+	done    bool
+}
+
+// Create opens a temporary file in the same directory as dest, so the final
+// rename stays on one filesystem
+func Create(dest string) (*File, error) {
+	dir := filepath.Dir(dest)
+	pattern := tempPrefix + filepath.Base(dest) + "-*" + tempSuffix
+
+	file, err := os.CreateTemp(dir, pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	return &File{
+		file:   file,
+		dest:   dest,
+		md5:    md5.New(),
+		sha256: sha256.New(),
+	}, nil
+}
+
+func (f *File) Write(p []byte) (int, error) {
+	n, err := f.file.Write(p)
+	f.md5.Write(p[:n])
+	f.sha256.Write(p[:n])
+	f.written += int64(n)
+	return n, err
+}
+
+// Name returns the temporary path
+func (f *File) Name() string {
+	return f.file.Name()
+}
+
+// Size returns the number of bytes written so far
+func (f *File) Size() int64 {
+	return f.written
+}
+
+// SHA256 returns the hex SHA-256 of the bytes written so far
+func (f *File) SHA256() string {
+	return hex.EncodeToString(f.sha256.Sum(nil))
+}
+
+// Commit verifies the content, flushes it to disk and renames it over dest.
+// On any failure the temporary file is removed and dest is left untouched.
+func (f *File) Commit(expected Expected) error {
+	if f.done {
+		return fmt.Errorf("%s already committed or aborted", f.dest)
+	}
+
+	if err := f.verify(expected); err != nil {
+		f.Abort()
+		return err
+	}
+
+	if err := f.file.Sync(); err != nil {
+		f.Abort()
+		return err
+	}
+	if err := f.file.Close(); err != nil {
+		f.Abort()
+		return err
+	}
+	f.done = true
+
+	if err := os.Rename(f.file.Name(), f.dest); err != nil {
+		os.Remove(f.file.Name())
+		return err
+	}
+
+	syncDir(filepath.Dir(f.dest))
+	return nil
+}
+
+// Abort discards the temporary file. It is safe to call after Commit.
+func (f *File) Abort() {
+	if f.done {
+		return
+	}
+	f.done = true
+	f.file.Close()
+	os.Remove(f.file.Name())
+}
+
+func (f *File) verify(expected Expected) error {
+	if expected.Size >= 0 && f.written != expected.Size {
+		return fmt.Errorf("size mismatch for %s: expected %d bytes, got %d", f.dest, expected.Size, f.written)
+	}
+	if expected.MD5 != "" {
+		if got := hex.EncodeToString(f.md5.Sum(nil)); !strings.EqualFold(got, expected.MD5) {
+			return fmt.Errorf("checksum mismatch for %s: expected md5 %s, got %s", f.dest, expected.MD5, got)
+		}
+	}
+	if expected.SHA256 != "" {
+		if got := f.SHA256(); !strings.EqualFold(got, expected.SHA256) {
+			return fmt.Errorf("checksum mismatch for %s: expected sha256 %s, got %s", f.dest, expected.SHA256, got)
+		}
+	}
+	return nil
+}
+
+// MD5FromETag returns the MD5 an S3 ETag represents, or "" for multipart
+// uploads whose ETag is not a plain content hash
+func MD5FromETag(etag string) string {
+	etag = strings.Trim(etag, `"`)
+	if len(etag) != 32 {
+		return ""
+	}
+	if _, err := hex.DecodeString(etag); err != nil {
+		return ""
+	}
+	return etag
+}
+
+// syncDir flushes the directory entry for the rename. Not every platform
+// supports fsync on directories, so errors are ignored.
+func syncDir(dir string) {
+	d, err := os.Open(dir)
+	if err != nil {
+		return
+	}
+	d.Sync()
+	d.Close()
+}
@@ -0,0 +1,116 @@
+package auth
+
+import (
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"html"
+	"net"
+	"net/http"
+	"time"
+)
+
+// CallbackServer receives the authorization redirect on a loopback port
+type CallbackServer struct {
+	listener net.Listener
+	path     string
+	state    string
+	result   chan callbackResult
+	server   *http.Server
+}
+
+type callbackResult struct {
+	code string
+	err  error
+}
+
+// ListenCallback listens on 127.0.0.1:port (0 picks a free port) for a
+// redirect to path carrying state
+func ListenCallback(port int, path, state string) (*CallbackServer, error) {
+	listener, err := net.Listen("tcp", fmt.Sprintf("127.0.0.1:%d", port))
+	if err != nil {
+		return nil, err
+	}
+
+	cs := &CallbackServer{
+		listener: listener,
+		path:     path,
+		state:    state,
+		result:   make(chan callbackResult, 1),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(path, cs.handle)
+	cs.server = &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	go cs.server.Serve(listener)
+
+	return cs, nil
+}
+
+// RedirectURL is the redirect_uri to register with the authorization request
+func (cs *CallbackServer) RedirectURL() string {
+	return fmt.Sprintf("http://127.0.0.1:%d%s", cs.listener.Addr().(*net.TCPAddr).Port, cs.path)
+}
+
+// Wait returns the authorization code or the error the server redirected with
+func (cs *CallbackServer) Wait(ctx context.Context) (string, error) {
+	select {
+	case r := <-cs.result:
+		return r.code, r.err
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
+
+func (cs *CallbackServer) Close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	return cs.server.Shutdown(ctx)
+}
+
+func (cs *CallbackServer) handle(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+
+	// A redirect that doesn't carry our state wasn't started by this login
+	// (or is a forged request); ignore it and keep waiting
+	state := q.Get("state")
+	if subtle.ConstantTimeCompare([]byte(state), []byte(cs.state)) != 1 {
+		writePage(w, http.StatusBadRequest, "Authentication Failed", "#dc2626", "The login request did not match. Please start again from the terminal.")
+		return
+	}
+
+	var result callbackResult
+	if errCode := q.Get("error"); errCode != "" {
+		result.err = &Error{Code: errCode, Description: q.Get("error_description")}
+		writePage(w, http.StatusBadRequest, "Authentication Failed", "#dc2626", q.Get("error_description"))
+	} else if code := q.Get("code"); code == "" {
+		result.err = fmt.Errorf("no authorization code in callback")
+		writePage(w, http.StatusBadRequest, "Authentication Failed", "#dc2626", "No authorization code was received.")
+	} else {
+		result.code = code
+		writePage(w, http.StatusOK, "✓ Authentication Successful!", "#16a34a", "The CLI received the authorization.")
+	}
+
+	select {
+	case cs.result <- result:
+	default:
+		// Already answered
+	}
+}
+
+// writePage answers with status and a page telling the user how the login
+// went. Headers must be set before WriteHeader or they are dropped.
+func writePage(w http.ResponseWriter, status int, title, color, message string) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	fmt.Fprintf(w, `
+<!DOCTYPE html>
+<html>
+<head><title>%s</title></head>
+<body style="font-family: system-ui; padding: 2rem; text-align: center;">
+	<h1 style="color: %s;">%s</h1>
+	<p>%s</p>
+	<p style="color: #666;">You can close this window and return to the terminal.</p>
+</body>
+</html>`, html.EscapeString(title), color, html.EscapeString(title), html.EscapeString(message))
+}
@@ -0,0 +1,135 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// DeviceGrantType is the grant_type for polling the token endpoint
+// (RFC 8628 section 3.4)
+const DeviceGrantType = "urn:ietf:params:oauth:grant-type:device_code"
+
+// Device flow errors from the token endpoint
+var (
+	ErrAccessDenied  = errors.New("authorization was denied")
+	ErrDeviceExpired = errors.New("the code expired before it was entered, run 'darkstorage login --device' again")
+)
+
+const (
+	defaultPollInterval = 5 * time.Second
+	slowDownIncrement   = 5 * time.Second
+)
+
+// DeviceCode is a pending device authorization. The user enters UserCode
+// at VerificationURI, or opens VerificationURIComplete directly.
+type DeviceCode struct {
+	DeviceCode              string
+	UserCode                string
+	VerificationURI         string
+	VerificationURIComplete string
+	Expiry                  time.Time
+	Interval                time.Duration
+}
+
+// DeviceAuth starts a device authorization (RFC 8628 section 3.1)
+func (c *Config) DeviceAuth(ctx context.Context, client *http.Client) (*DeviceCode, error) {
+	form := url.Values{}
+	if len(c.Scopes) > 0 {
+		form.Set("scope", strings.Join(c.Scopes, " "))
+	}
+
+	body, err := c.postForm(ctx, client, c.DeviceAuthURL, form)
+	if err != nil {
+		return nil, err
+	}
+
+	var result struct {
+		DeviceCode              string `json:"device_code"`
+		UserCode                string `json:"user_code"`
+		VerificationURI         string `json:"verification_uri"`
+		VerificationURIComplete string `json:"verification_uri_complete"`
+		ExpiresIn               int64  `json:"expires_in"`
+		Interval                int64  `json:"interval"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, fmt.Errorf("oauth: decoding device authorization response: %w", err)
+	}
+	if result.DeviceCode == "" || result.UserCode == "" || result.VerificationURI == "" {
+		return nil, fmt.Errorf("oauth: incomplete device authorization response")
+	}
+
+	dc := &DeviceCode{
+		DeviceCode:              result.DeviceCode,
+		UserCode:                result.UserCode,
+		VerificationURI:         result.VerificationURI,
+		VerificationURIComplete: result.VerificationURIComplete,
+		Interval:                time.Duration(result.Interval) * time.Second,
+	}
+	if result.ExpiresIn > 0 {
+		dc.Expiry = time.Now().Add(time.Duration(result.ExpiresIn) * time.Second)
+	}
+	if dc.Interval <= 0 {
+		dc.Interval = defaultPollInterval
+	}
+	return dc, nil
+}
+
+// PollDevice polls the token endpoint until the user approves or denies
+// the request, the code expires, or ctx is done. slow_down responses add
+// five seconds to the interval as the RFC requires; network errors and
+// server failures are retried at the current interval.
+func (c *Config) PollDevice(ctx context.Context, client *http.Client, dc *DeviceCode) (*Token, error) {
+	interval := dc.Interval
+
+	for {
+		if !dc.Expiry.IsZero() && time.Now().Add(interval).After(dc.Expiry) {
+			return nil, ErrDeviceExpired
+		}
+
+		timer := time.NewTimer(interval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+
+		tok, err := c.tokenRequest(ctx, client, url.Values{
+			"grant_type":  {DeviceGrantType},
+			"device_code": {dc.DeviceCode},
+		})
+		if err == nil {
+			return tok, nil
+		}
+
+		// Transient failures; try again while the code is valid
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) && ctx.Err() == nil {
+			continue
+		}
+		var oauthErr *Error
+		if !errors.As(err, &oauthErr) {
+			return nil, err
+		}
+		if oauthErr.StatusCode >= http.StatusInternalServerError {
+			continue
+		}
+		switch oauthErr.Code {
+		case "authorization_pending":
+		case "slow_down":
+			interval += slowDownIncrement
+		case "access_denied":
+			return nil, ErrAccessDenied
+		case "expired_token":
+			return nil, ErrDeviceExpired
+		default:
+			return nil, err
+		}
+	}
+}
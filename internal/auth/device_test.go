@@ -0,0 +1,119 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestDeviceAuthExpiry(t *testing.T) {
+	tests := []struct {
+		name     string
+		response string
+		wantZero bool
+	}{
+		{name: "expires_in given", response: `{"device_code": "d", "user_code": "u", "verification_uri": "https://v", "expires_in": 600}`},
+		{name: "expires_in omitted", response: `{"device_code": "d", "user_code": "u", "verification_uri": "https://v"}`, wantZero: true},
+		{name: "expires_in zero", response: `{"device_code": "d", "user_code": "u", "verification_uri": "https://v", "expires_in": 0}`, wantZero: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte(tt.response))
+			}))
+			defer srv.Close()
+
+			cfg := &Config{ClientID: "cli", DeviceAuthURL: srv.URL}
+			dc, err := cfg.DeviceAuth(context.Background(), srv.Client())
+			if err != nil {
+				t.Fatalf("DeviceAuth() error = %v", err)
+			}
+			if dc.Expiry.IsZero() != tt.wantZero {
+				t.Errorf("DeviceAuth() Expiry = %v, want zero %v", dc.Expiry, tt.wantZero)
+			}
+		})
+	}
+}
+
+// pollServer answers token requests with responses in turn; "drop" closes
+// the connection without a response
+func pollServer(t *testing.T, responses ...string) *httptest.Server {
+	t.Helper()
+	n := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if n >= len(responses) {
+			t.Errorf("unexpected request %d", n+1)
+			http.Error(w, "", http.StatusTeapot)
+			return
+		}
+		response := responses[n]
+		n++
+		switch response {
+		case "drop":
+			conn, _, err := w.(http.Hijacker).Hijack()
+			if err == nil {
+				conn.Close()
+			}
+		case "unavailable":
+			http.Error(w, "try later", http.StatusServiceUnavailable)
+		case "token":
+			w.Write([]byte(`{"access_token": "tok", "token_type": "Bearer"}`))
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error": "` + response + `"}`))
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestPollDevice(t *testing.T) {
+	tests := []struct {
+		name      string
+		responses []string
+		wantErr   error
+	}{
+		{name: "approved", responses: []string{"authorization_pending", "token"}},
+		{name: "network error", responses: []string{"drop", "authorization_pending", "token"}},
+		{name: "server unavailable", responses: []string{"unavailable", "token"}},
+		{name: "denied", responses: []string{"authorization_pending", "access_denied"}, wantErr: ErrAccessDenied},
+		{name: "expired", responses: []string{"expired_token"}, wantErr: ErrDeviceExpired},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := pollServer(t, tt.responses...)
+			cfg := &Config{ClientID: "cli", TokenURL: srv.URL}
+			dc := &DeviceCode{DeviceCode: "d", Interval: time.Millisecond}
+
+			tok, err := cfg.PollDevice(context.Background(), srv.Client(), dc)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("PollDevice() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("PollDevice() error = %v", err)
+			}
+			if tok.AccessToken != "tok" {
+				t.Errorf("PollDevice() AccessToken = %q, want %q", tok.AccessToken, "tok")
+			}
+		})
+	}
+}
+
+func TestPollDeviceRetriesUntilExpiry(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "down", http.StatusBadGateway)
+	}))
+	defer srv.Close()
+
+	cfg := &Config{ClientID: "cli", TokenURL: srv.URL}
+	dc := &DeviceCode{DeviceCode: "d", Interval: time.Millisecond, Expiry: time.Now().Add(50 * time.Millisecond)}
+	if _, err := cfg.PollDevice(context.Background(), srv.Client(), dc); !errors.Is(err, ErrDeviceExpired) {
+		t.Errorf("PollDevice() error = %v, want %v", err, ErrDeviceExpired)
+	}
+}
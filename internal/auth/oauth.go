@@ -0,0 +1,206 @@
+// Package auth implements the OAuth 2.0 authorization-code flow with PKCE
+// and the device authorization grant used by `darkstorage login`, and keeps
+// access tokens fresh for API calls.
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Config describes an OAuth client
+type Config struct {
+	ClientID      string
+	AuthURL       string
+	TokenURL      string
+	DeviceAuthURL string
+	RedirectURL   string
+	Scopes        []string
+}
+
+// Token is an access token with its optional refresh token. A zero Expiry
+// means the token does not expire.
+type Token struct {
+	AccessToken  string
+	RefreshToken string
+	TokenType    string
+	Expiry       time.Time
+}
+
+// expirySkew refreshes tokens slightly early so requests in flight don't
+// race the expiry
+const expirySkew = time.Minute
+
+// Expired reports whether the token is expired or about to be
+func (t *Token) Expired(now time.Time) bool {
+	return !t.Expiry.IsZero() && !now.Before(t.Expiry.Add(-expirySkew))
+}
+
+// Error is an OAuth error response (RFC 6749 section 5.2)
+type Error struct {
+	StatusCode  int
+	Code        string `json:"error"`
+	Description string `json:"error_description"`
+}
+
+func (e *Error) Error() string {
+	if e.Description != "" {
+		return fmt.Sprintf("oauth: %s: %s", e.Code, e.Description)
+	}
+	return fmt.Sprintf("oauth: %s (HTTP %d)", e.Code, e.StatusCode)
+}
+
+// IsInvalidGrant reports whether the refresh token or code was rejected, in
+// which case the user has to log in again
+func IsInvalidGrant(err error) bool {
+	oauthErr, ok := err.(*Error)
+	return ok && oauthErr.Code == "invalid_grant"
+}
+
+// NewVerifier returns a random PKCE code verifier (RFC 7636)
+func NewVerifier() string {
+	return randomString(32)
+}
+
+// Challenge derives the S256 code challenge for verifier
+func Challenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// NewState returns a random value to bind the callback to this login
+func NewState() string {
+	return randomString(16)
+}
+
+func randomString(n int) string {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		panic(fmt.Sprintf("crypto/rand: %v", err))
+	}
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// AuthCodeURL returns the URL the user visits to authorize the client
+func (c *Config) AuthCodeURL(state, verifier string, extra url.Values) string {
+	q := url.Values{}
+	for k, v := range extra {
+		q[k] = v
+	}
+	q.Set("response_type", "code")
+	q.Set("client_id", c.ClientID)
+	q.Set("redirect_uri", c.RedirectURL)
+	q.Set("state", state)
+	q.Set("code_challenge", Challenge(verifier))
+	q.Set("code_challenge_method", "S256")
+	if len(c.Scopes) > 0 {
+		q.Set("scope", strings.Join(c.Scopes, " "))
+	}
+
+	sep := "?"
+	if strings.Contains(c.AuthURL, "?") {
+		sep = "&"
+	}
+	return c.AuthURL + sep + q.Encode()
+}
+
+// Exchange trades an authorization code for tokens
+func (c *Config) Exchange(ctx context.Context, client *http.Client, code, verifier string) (*Token, error) {
+	return c.tokenRequest(ctx, client, url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {c.RedirectURL},
+		"code_verifier": {verifier},
+	})
+}
+
+// Refresh obtains a new access token. Servers that don't rotate refresh
+// tokens omit one from the response, so the old one is kept.
+func (c *Config) Refresh(ctx context.Context, client *http.Client, refreshToken string) (*Token, error) {
+	tok, err := c.tokenRequest(ctx, client, url.Values{
+		"grant_type":    {"refresh_token"},
+		"refresh_token": {refreshToken},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if tok.RefreshToken == "" {
+		tok.RefreshToken = refreshToken
+	}
+	return tok, nil
+}
+
+func (c *Config) tokenRequest(ctx context.Context, client *http.Client, form url.Values) (*Token, error) {
+	body, err := c.postForm(ctx, client, c.TokenURL, form)
+	if err != nil {
+		return nil, err
+	}
+
+	var result struct {
+		AccessToken  string `json:"access_token"`
+		RefreshToken string `json:"refresh_token"`
+		TokenType    string `json:"token_type"`
+		ExpiresIn    int64  `json:"expires_in"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, fmt.Errorf("oauth: decoding token response: %w", err)
+	}
+	if result.AccessToken == "" {
+		return nil, fmt.Errorf("oauth: token response has no access_token")
+	}
+
+	tok := &Token{
+		AccessToken:  result.AccessToken,
+		RefreshToken: result.RefreshToken,
+		TokenType:    result.TokenType,
+	}
+	if result.ExpiresIn > 0 {
+		tok.Expiry = time.Now().Add(time.Duration(result.ExpiresIn) * time.Second)
+	}
+	return tok, nil
+}
+
+// postForm posts form to endpoint as the client and returns the body of a
+// 200 response. Other responses are returned as *Error.
+func (c *Config) postForm(ctx context.Context, client *http.Client, endpoint string, form url.Values) ([]byte, error) {
+	form.Set("client_id", c.ClientID)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		oauthErr := &Error{StatusCode: resp.StatusCode}
+		if json.Unmarshal(body, oauthErr) != nil || oauthErr.Code == "" {
+			oauthErr.Code = "server_error"
+		}
+		return nil, oauthErr
+	}
+	return body, nil
+}
@@ -0,0 +1,126 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/darkstorage/cli/internal/transport"
+)
+
+// ErrLoginRequired means the stored credentials can no longer be refreshed
+var ErrLoginRequired = errors.New("session expired, run 'darkstorage login' again")
+
+// TokenSource hands out a valid access token, refreshing it when needed
+type TokenSource struct {
+	mu        sync.Mutex
+	config    *Config
+	client    *http.Client
+	token     *Token
+	onRefresh func(*Token) error
+}
+
+// NewTokenSource refreshes through config using client. onRefresh, if set,
+// is called with every new token so it can be persisted.
+func NewTokenSource(config *Config, client *http.Client, token *Token, onRefresh func(*Token) error) *TokenSource {
+	return &TokenSource{
+		config:    config,
+		client:    client,
+		token:     token,
+		onRefresh: onRefresh,
+	}
+}
+
+// Token returns the current token, refreshing it first if it has expired
+func (s *TokenSource) Token(ctx context.Context) (*Token, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.token.Expired(time.Now()) {
+		if err := s.refreshLocked(ctx); err != nil {
+			return nil, err
+		}
+	}
+	return s.token, nil
+}
+
+// ForceRefresh refreshes unless the token has changed since rejected was
+// handed out, e.g. because a concurrent request already refreshed it
+func (s *TokenSource) ForceRefresh(ctx context.Context, rejected string) (*Token, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.token.AccessToken != rejected {
+		return s.token, nil
+	}
+	if err := s.refreshLocked(ctx); err != nil {
+		return nil, err
+	}
+	return s.token, nil
+}
+
+func (s *TokenSource) refreshLocked(ctx context.Context) error {
+	if s.token.RefreshToken == "" {
+		return ErrLoginRequired
+	}
+
+	tok, err := s.config.Refresh(ctx, s.client, s.token.RefreshToken)
+	if err != nil {
+		if IsInvalidGrant(err) {
+			return ErrLoginRequired
+		}
+		return err
+	}
+
+	s.token = tok
+	if s.onRefresh != nil {
+		if err := s.onRefresh(tok); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Transport sets the bearer token on each request. A 401 triggers one
+// refresh and retry, for tokens revoked or expired early on the server.
+func Transport(source *TokenSource, next http.RoundTripper) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return transport.RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		tok, err := source.Token(req.Context())
+		if err != nil {
+			return nil, err
+		}
+
+		resp, err := next.RoundTrip(withToken(req, tok.AccessToken))
+		if err != nil || resp.StatusCode != http.StatusUnauthorized {
+			return resp, err
+		}
+		if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+			return resp, nil
+		}
+
+		fresh, refreshErr := source.ForceRefresh(req.Context(), tok.AccessToken)
+		if refreshErr != nil || fresh.AccessToken == tok.AccessToken {
+			return resp, nil
+		}
+		resp.Body.Close()
+
+		retry := withToken(req, fresh.AccessToken)
+		if req.GetBody != nil {
+			if retry.Body, err = req.GetBody(); err != nil {
+				return nil, err
+			}
+		}
+		return next.RoundTrip(retry)
+	})
+}
+
+func withToken(req *http.Request, accessToken string) *http.Request {
+	clone := req.Clone(req.Context())
+	clone.Header.Set("Authorization", "Bearer "+accessToken)
+	return clone
+}
@@ -0,0 +1,176 @@
+// Package bandwidth limits the bytes per second transfers use. A Limiter
+// is shared, so transfers running in parallel split its rate instead of
+// each getting all of it, and the rate can change while they run, e.g. as
+// a weekly Schedule says. NewBucket makes a Limiter for other units, such
+// as API requests.
+package bandwidth
+
+import (
+	"context"
+	"io"
+	"math"
+	"sync"
+	"time"
+)
+
+const (
+	// minBurst is the least a limiter lets through at once, so slow limits
+	// don't turn transfers into a trickle of tiny reads
+	minBurst = 16 * 1024
+	// maxChunk bounds what a Reader reads before waiting, keeping the rate
+	// smooth
+	maxChunk = 32 * 1024
+)
+
+// Limiter is a token bucket of bytes, holding a quarter of a second's
+// worth and at least minBurst. The zero rate is unlimited.
+type Limiter struct {
+	mu     sync.Mutex
+	rate   float64
+	tokens float64
+	last   time.Time
+	// size is the bucket size NewBucket was given, 0 for the byte default
+	size float64
+	// changed is closed and replaced when the rate changes, waking waiters
+	changed chan struct{}
+}
+
+// NewLimiter returns a limiter allowing rate bytes per second, 0 for
+// unlimited
+func NewLimiter(rate int64) *Limiter {
+	l := &Limiter{last: time.Now(), changed: make(chan struct{})}
+	l.SetRate(rate)
+	return l
+}
+
+// NewBucket returns a limiter allowing rate units per second, 0 for
+// unlimited, of which up to burst may be taken at once after a pause
+func NewBucket(rate float64, burst int) *Limiter {
+	l := &Limiter{last: time.Now(), changed: make(chan struct{}), size: math.Max(float64(burst), 1)}
+	l.setRate(rate)
+	return l
+}
+
+// SetRate changes the limit in bytes per second, 0 for unlimited.
+// Transfers waiting on the limiter carry on at the new rate.
+func (l *Limiter) SetRate(rate int64) {
+	l.setRate(float64(rate))
+}
+
+func (l *Limiter) setRate(rate float64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	next := math.Max(rate, 0)
+	if next == l.rate {
+		return
+	}
+	now := time.Now()
+	if l.rate == 0 {
+		// Unlimited until now, so nothing was counted
+		l.tokens, l.last = math.MaxFloat64, now
+	} else {
+		l.refill(now)
+	}
+	l.rate = next
+	l.tokens = math.Min(l.tokens, l.burst())
+
+	close(l.changed)
+	l.changed = make(chan struct{})
+}
+
+// Rate returns the limit in bytes per second, 0 for unlimited
+func (l *Limiter) Rate() int64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return int64(l.rate)
+}
+
+// WaitN blocks until n more bytes may pass or ctx is done
+func (l *Limiter) WaitN(ctx context.Context, n int) error {
+	for n > 0 {
+		took, delay, changed := l.reserve(n)
+		if took > 0 {
+			n -= took
+			continue
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-changed:
+			timer.Stop()
+		case <-timer.C:
+		}
+	}
+	return nil
+}
+
+// reserve takes tokens for up to n bytes, at most a burst, if they are
+// available, and otherwise returns how long until they are
+func (l *Limiter) reserve(n int) (took int, delay time.Duration, changed <-chan struct{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.rate == 0 {
+		return n, 0, nil
+	}
+	l.refill(time.Now())
+
+	want := math.Min(float64(n), l.burst())
+	if l.tokens >= want {
+		l.tokens -= want
+		return int(want), 0, nil
+	}
+	return 0, time.Duration((want - l.tokens) / l.rate * float64(time.Second)), l.changed
+}
+
+func (l *Limiter) refill(now time.Time) {
+	l.tokens = math.Min(l.tokens+now.Sub(l.last).Seconds()*l.rate, l.burst())
+	l.last = now
+}
+
+func (l *Limiter) burst() float64 {
+	if l.size > 0 {
+		return l.size
+	}
+	return math.Max(l.rate/4, minBurst)
+}
+
+// reader waits on its limiters after each read
+type reader struct {
+	ctx      context.Context
+	r        io.Reader
+	limiters []*Limiter
+}
+
+// NewReader limits reads from r to what every limiter allows; nil
+// limiters are ignored. A read waiting for its turn returns ctx's error
+// once ctx is done.
+func NewReader(ctx context.Context, r io.Reader, limiters ...*Limiter) io.Reader {
+	var active []*Limiter
+	for _, l := range limiters {
+		if l != nil {
+			active = append(active, l)
+		}
+	}
+	if len(active) == 0 {
+		return r
+	}
+	return &reader{ctx: ctx, r: r, limiters: active}
+}
+
+func (lr *reader) Read(p []byte) (int, error) {
+	if len(p) > maxChunk {
+		p = p[:maxChunk]
+	}
+	n, err := lr.r.Read(p)
+	for _, l := range lr.limiters {
+		if werr := l.WaitN(lr.ctx, n); werr != nil {
+			return n, werr
+		}
+	}
+	return n, err
+}
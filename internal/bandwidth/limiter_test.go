@@ -0,0 +1,166 @@
+package bandwidth
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestLimiterReserve(t *testing.T) {
+	tests := []struct {
+		name      string
+		rate      int64
+		tokens    float64
+		n         int
+		wantTook  int
+		wantDelay time.Duration
+	}{
+		{name: "unlimited", rate: 0, n: 1 << 20, wantTook: 1 << 20},
+		{name: "within tokens", rate: 64 * 1024, tokens: 16 * 1024, n: 1000, wantTook: 1000},
+		{name: "capped at min burst", rate: 64 * 1024, tokens: 16 * 1024, n: 100 * 1024, wantTook: minBurst},
+		{name: "capped at a quarter second", rate: 1 << 20, tokens: 1 << 20, n: 1 << 20, wantTook: 1 << 18},
+		{name: "empty bucket waits", rate: 64 * 1024, tokens: 0, n: 8 * 1024, wantDelay: 125 * time.Millisecond},
+		{name: "waits only for the shortfall", rate: 64 * 1024, tokens: 4 * 1024, n: 8 * 1024, wantDelay: 62500 * time.Microsecond},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := NewLimiter(tt.rate)
+			if tt.rate > 0 {
+				l.tokens, l.last = tt.tokens, time.Now()
+			}
+
+			took, delay, _ := l.reserve(tt.n)
+			if took != tt.wantTook {
+				t.Errorf("took %d, want %d", took, tt.wantTook)
+			}
+			// The bucket refills a little between setting it up and reserving
+			if diff := tt.wantDelay - delay; diff < 0 || diff > 5*time.Millisecond {
+				t.Errorf("delay %v, want about %v", delay, tt.wantDelay)
+			}
+		})
+	}
+}
+
+func TestLimiterSetRate(t *testing.T) {
+	tests := []struct {
+		name       string
+		from, to   int64
+		wantRate   int64
+		wantTokens float64
+	}{
+		{name: "limit an unlimited limiter", from: 0, to: 64 * 1024, wantRate: 64 * 1024, wantTokens: minBurst},
+		{name: "lower keeps tokens within the burst", from: 4 << 20, to: 64 * 1024, wantRate: 64 * 1024, wantTokens: minBurst},
+		{name: "negative is unlimited", from: 64 * 1024, to: -1, wantRate: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := NewLimiter(tt.from)
+			l.SetRate(tt.to)
+			if got := l.Rate(); got != tt.wantRate {
+				t.Errorf("Rate() = %d, want %d", got, tt.wantRate)
+			}
+			if tt.wantRate > 0 && l.tokens != tt.wantTokens {
+				t.Errorf("tokens = %g, want %g", l.tokens, tt.wantTokens)
+			}
+		})
+	}
+}
+
+func TestNewBucket(t *testing.T) {
+	tests := []struct {
+		name      string
+		rate      float64
+		burst     int
+		wantTaken int
+		wantDelay time.Duration
+	}{
+		{name: "burst then waits", rate: 2, burst: 3, wantTaken: 3, wantDelay: 500 * time.Millisecond},
+		{name: "fractional rate", rate: 0.5, burst: 1, wantTaken: 1, wantDelay: 2 * time.Second},
+		{name: "burst of at least one", rate: 10, burst: 0, wantTaken: 1, wantDelay: 100 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := NewBucket(tt.rate, tt.burst)
+			taken := 0
+			for {
+				took, delay, _ := l.reserve(1)
+				if took == 0 {
+					if diff := tt.wantDelay - delay; diff < 0 || diff > 5*time.Millisecond {
+						t.Errorf("delay %v, want about %v", delay, tt.wantDelay)
+					}
+					break
+				}
+				taken += took
+			}
+			if taken != tt.wantTaken {
+				t.Errorf("took %d before waiting, want %d", taken, tt.wantTaken)
+			}
+		})
+	}
+}
+
+func TestLimiterWaitN(t *testing.T) {
+	t.Run("cancelled while waiting", func(t *testing.T) {
+		l := NewLimiter(1024)
+		l.tokens = 0
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+		if err := l.WaitN(ctx, minBurst); !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("WaitN() = %v, want %v", err, context.DeadlineExceeded)
+		}
+	})
+
+	t.Run("woken by a rate change", func(t *testing.T) {
+		l := NewLimiter(1)
+		l.tokens = 0
+		done := make(chan error, 1)
+		go func() { done <- l.WaitN(context.Background(), minBurst) }()
+
+		time.Sleep(10 * time.Millisecond)
+		l.SetRate(0)
+		select {
+		case err := <-done:
+			if err != nil {
+				t.Fatalf("WaitN() = %v", err)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("WaitN still waiting after the limit was lifted")
+		}
+	})
+}
+
+func TestNewReader(t *testing.T) {
+	data := bytes.Repeat([]byte("x"), 3*maxChunk)
+	tests := []struct {
+		name      string
+		limiters  []*Limiter
+		wantFirst int
+	}{
+		{name: "no limiters", wantFirst: len(data)},
+		{name: "nil limiters", limiters: []*Limiter{nil, nil}, wantFirst: len(data)},
+		{name: "unlimited limiter", limiters: []*Limiter{NewLimiter(0)}, wantFirst: maxChunk},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewReader(context.Background(), bytes.NewReader(data), tt.limiters...)
+			buf := make([]byte, len(data))
+			n, err := r.Read(buf)
+			if err != nil {
+				t.Fatalf("Read() error = %v", err)
+			}
+			if n != tt.wantFirst {
+				t.Errorf("first Read() = %d bytes, want %d", n, tt.wantFirst)
+			}
+			rest, err := io.ReadAll(r)
+			if err != nil {
+				t.Fatalf("ReadAll() error = %v", err)
+			}
+			if n+len(rest) != len(data) {
+				t.Errorf("read %d bytes in all, want %d", n+len(rest), len(data))
+			}
+		})
+	}
+}
@@ -0,0 +1,114 @@
+package bandwidth
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Limits are bytes per second for uploads and downloads, 0 for unlimited
+type Limits struct {
+	Up   int64
+	Down int64
+}
+
+// Window applies Limits on some days of the week between two times of
+// day. A window that ends before it starts runs past midnight into the
+// next day.
+type Window struct {
+	// Days is indexed by time.Weekday
+	Days [7]bool
+	// Start and End are offsets from midnight
+	Start  time.Duration
+	End    time.Duration
+	Limits Limits
+}
+
+// Schedule gives the limits in force at any time: those of the first
+// window containing it, or Default outside them all
+type Schedule struct {
+	Default Limits
+	Windows []Window
+}
+
+// At returns the limits in force at t and the index of the window they
+// come from, -1 for Default
+func (s Schedule) At(t time.Time) (Limits, int) {
+	for i, w := range s.Windows {
+		if w.Contains(t) {
+			return w.Limits, i
+		}
+	}
+	return s.Default, -1
+}
+
+// Contains reports whether the window is in force at t, in t's location
+func (w Window) Contains(t time.Time) bool {
+	clock := time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute +
+		time.Duration(t.Second())*time.Second
+	day := t.Weekday()
+	if w.Start < w.End {
+		return w.Days[day] && clock >= w.Start && clock < w.End
+	}
+	yesterday := (day + 6) % 7
+	return (w.Days[day] && clock >= w.Start) || (w.Days[yesterday] && clock < w.End)
+}
+
+var dayNames = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+// ParseDays reads day names (sun to sat), ranges such as mon-fri, and
+// weekdays, weekends or daily. No days at all means every day.
+func ParseDays(names []string) ([7]bool, error) {
+	var days [7]bool
+	if len(names) == 0 {
+		return [7]bool{true, true, true, true, true, true, true}, nil
+	}
+	for _, name := range names {
+		name = strings.ToLower(strings.TrimSpace(name))
+		switch name {
+		case "daily":
+			return ParseDays(nil)
+		case "weekdays":
+			name = "mon-fri"
+		case "weekends":
+			days[time.Saturday], days[time.Sunday] = true, true
+			continue
+		}
+
+		first, last, isRange := strings.Cut(name, "-")
+		from, ok := dayNames[first]
+		if !ok {
+			return days, fmt.Errorf("%q is not a day: use sun to sat, a range such as mon-fri, weekdays, weekends or daily", name)
+		}
+		to := from
+		if isRange {
+			if to, ok = dayNames[last]; !ok {
+				return days, fmt.Errorf("%q is not a day: use sun to sat, a range such as mon-fri, weekdays, weekends or daily", name)
+			}
+		}
+		// Ranges may wrap, e.g. fri-mon
+		for d := from; ; d = (d + 1) % 7 {
+			days[d] = true
+			if d == to {
+				break
+			}
+		}
+	}
+	return days, nil
+}
+
+// ParseClock reads a time of day such as 09:00 or 17:30; 24:00 is the end
+// of the day
+func ParseClock(s string) (time.Duration, error) {
+	hours, minutes, ok := strings.Cut(strings.TrimSpace(s), ":")
+	h, herr := strconv.Atoi(hours)
+	m, merr := strconv.Atoi(minutes)
+	if !ok || herr != nil || merr != nil || h < 0 || m < 0 || m > 59 || h > 24 || (h == 24 && m > 0) {
+		return 0, fmt.Errorf("%q is not a time of day such as 09:00", s)
+	}
+	return time.Duration(h)*time.Hour + time.Duration(m)*time.Minute, nil
+}
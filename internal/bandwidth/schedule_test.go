@@ -0,0 +1,137 @@
+package bandwidth
+
+import (
+	"testing"
+	"time"
+)
+
+// at returns a time on the given weekday of a week starting Sunday 2024-01-07
+func at(day time.Weekday, hour, minute int) time.Time {
+	return time.Date(2024, time.January, 7+int(day), hour, minute, 0, 0, time.UTC)
+}
+
+func days(ds ...time.Weekday) [7]bool {
+	var out [7]bool
+	for _, d := range ds {
+		out[d] = true
+	}
+	return out
+}
+
+func TestWindowContains(t *testing.T) {
+	workHours := Window{Days: days(time.Monday, time.Tuesday), Start: 9 * time.Hour, End: 17 * time.Hour}
+	overnight := Window{Days: days(time.Friday), Start: 22 * time.Hour, End: 6 * time.Hour}
+
+	tests := []struct {
+		name   string
+		window Window
+		t      time.Time
+		want   bool
+	}{
+		{name: "inside", window: workHours, t: at(time.Monday, 12, 0), want: true},
+		{name: "at start", window: workHours, t: at(time.Monday, 9, 0), want: true},
+		{name: "at end", window: workHours, t: at(time.Monday, 17, 0), want: false},
+		{name: "before start", window: workHours, t: at(time.Tuesday, 8, 59), want: false},
+		{name: "other day", window: workHours, t: at(time.Wednesday, 12, 0), want: false},
+		{name: "overnight evening", window: overnight, t: at(time.Friday, 23, 0), want: true},
+		{name: "overnight past midnight", window: overnight, t: at(time.Saturday, 5, 59), want: true},
+		{name: "overnight after end", window: overnight, t: at(time.Saturday, 6, 0), want: false},
+		{name: "overnight morning of start day", window: overnight, t: at(time.Friday, 5, 0), want: false},
+		{name: "overnight evening of next day", window: overnight, t: at(time.Saturday, 23, 0), want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.window.Contains(tt.t); got != tt.want {
+				t.Errorf("Contains(%v) = %v, want %v", tt.t, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScheduleAt(t *testing.T) {
+	s := Schedule{
+		Default: Limits{Up: 1000},
+		Windows: []Window{
+			{Days: days(time.Monday), Start: 9 * time.Hour, End: 17 * time.Hour, Limits: Limits{Up: 100}},
+			{Days: days(time.Monday, time.Tuesday), Start: 0, End: 24 * time.Hour, Limits: Limits{Down: 200}},
+		},
+	}
+	tests := []struct {
+		name       string
+		t          time.Time
+		wantLimits Limits
+		wantIndex  int
+	}{
+		{name: "first window wins", t: at(time.Monday, 10, 0), wantLimits: Limits{Up: 100}, wantIndex: 0},
+		{name: "second window", t: at(time.Monday, 18, 0), wantLimits: Limits{Down: 200}, wantIndex: 1},
+		{name: "default", t: at(time.Wednesday, 10, 0), wantLimits: Limits{Up: 1000}, wantIndex: -1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			limits, index := s.At(tt.t)
+			if limits != tt.wantLimits || index != tt.wantIndex {
+				t.Errorf("At(%v) = %+v, %d, want %+v, %d", tt.t, limits, index, tt.wantLimits, tt.wantIndex)
+			}
+		})
+	}
+}
+
+func TestParseDays(t *testing.T) {
+	every := days(time.Sunday, time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday, time.Saturday)
+	tests := []struct {
+		name    string
+		names   []string
+		want    [7]bool
+		wantErr bool
+	}{
+		{name: "none is every day", want: every},
+		{name: "daily", names: []string{"daily"}, want: every},
+		{name: "single days", names: []string{"mon", " WED "}, want: days(time.Monday, time.Wednesday)},
+		{name: "range", names: []string{"tue-thu"}, want: days(time.Tuesday, time.Wednesday, time.Thursday)},
+		{name: "wrapping range", names: []string{"fri-mon"}, want: days(time.Friday, time.Saturday, time.Sunday, time.Monday)},
+		{name: "weekdays", names: []string{"weekdays"}, want: days(time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday)},
+		{name: "weekends and a day", names: []string{"weekends", "mon"}, want: days(time.Saturday, time.Sunday, time.Monday)},
+		{name: "unknown day", names: []string{"monday"}, wantErr: true},
+		{name: "bad range end", names: []string{"mon-xyz"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseDays(tt.names)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseDays(%q) error = %v, wantErr %v", tt.names, err, tt.wantErr)
+			}
+			if !tt.wantErr && got != tt.want {
+				t.Errorf("ParseDays(%q) = %v, want %v", tt.names, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseClock(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    time.Duration
+		wantErr bool
+	}{
+		{in: "00:00", want: 0},
+		{in: "09:00", want: 9 * time.Hour},
+		{in: " 17:30 ", want: 17*time.Hour + 30*time.Minute},
+		{in: "24:00", want: 24 * time.Hour},
+		{in: "24:01", wantErr: true},
+		{in: "12:60", wantErr: true},
+		{in: "-1:00", wantErr: true},
+		{in: "9", wantErr: true},
+		{in: "nine:00", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := ParseClock(tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseClock(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseClock(%q) = %v, want %v", tt.in, got, tt.want)
+			}
+		})
+	}
+}
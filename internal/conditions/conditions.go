@@ -0,0 +1,145 @@
+// Package conditions looks at the machine's network, power and load, so
+// the daemon can hold transfers back while they would cost money, battery
+// or responsiveness. Power and load are read from /sys and /proc; where
+// those don't exist the machine is never on battery and load is unknown.
+package conditions
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+const (
+	// powerSupplyDir lists Linux's batteries and chargers
+	powerSupplyDir = "/sys/class/power_supply"
+	loadAvgFile    = "/proc/loadavg"
+)
+
+// ErrUnsupported is returned where the system doesn't report a condition
+var ErrUnsupported = errors.New("not reported on this system")
+
+// MeteredInterface returns the name of the first interface that is up,
+// has an address and matches one of patterns, such as usb* or wwan0, or
+// "" if none does
+func MeteredInterface(patterns []string) (string, error) {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return "", err
+	}
+	for _, iface := range ifaces {
+		if iface.Flags&net.FlagUp == 0 || iface.Flags&net.FlagRunning == 0 || !matchAny(patterns, iface.Name) {
+			continue
+		}
+		addrs, err := iface.Addrs()
+		if err != nil {
+			continue
+		}
+		for _, addr := range addrs {
+			if ipnet, ok := addr.(*net.IPNet); ok && ipnet.IP.IsGlobalUnicast() {
+				return iface.Name, nil
+			}
+		}
+	}
+	return "", nil
+}
+
+func matchAny(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// ProbeMetered runs command, the program and its arguments, which exits 0
+// when the connection is metered and with any other status when it isn't.
+// A command that can't be run or is cut short by ctx is an error.
+func ProbeMetered(ctx context.Context, command []string) (bool, error) {
+	if len(command) == 0 {
+		return false, nil
+	}
+	err := exec.CommandContext(ctx, command[0], command[1:]...).Run()
+	if ctx.Err() != nil {
+		return false, fmt.Errorf("%s: %w", command[0], ctx.Err())
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// OnBattery reports whether the machine runs on battery: a battery is
+// discharging and no charger is online. Batteries of devices such as
+// mice don't count.
+func OnBattery() (bool, error) {
+	entries, err := os.ReadDir(powerSupplyDir)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	discharging := false
+	for _, entry := range entries {
+		dir := filepath.Join(powerSupplyDir, entry.Name())
+		if readAttr(dir, "scope") == "Device" {
+			continue
+		}
+		switch readAttr(dir, "type") {
+		case "Battery":
+			if readAttr(dir, "status") == "Discharging" {
+				discharging = true
+			}
+		default:
+			if readAttr(dir, "online") == "1" {
+				return false, nil
+			}
+		}
+	}
+	return discharging, nil
+}
+
+// readAttr returns a sysfs attribute, "" if it can't be read
+func readAttr(dir, name string) string {
+	data, err := os.ReadFile(filepath.Join(dir, name))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+// Load returns the one-minute load average divided by the number of CPUs,
+// so 1 means every CPU is busy
+func Load() (float64, error) {
+	data, err := os.ReadFile(loadAvgFile)
+	if os.IsNotExist(err) {
+		return 0, ErrUnsupported
+	}
+	if err != nil {
+		return 0, err
+	}
+	fields := strings.Fields(string(data))
+	if len(fields) == 0 {
+		return 0, fmt.Errorf("%s is empty", loadAvgFile)
+	}
+	load, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", loadAvgFile, err)
+	}
+	return load / float64(runtime.NumCPU()), nil
+}
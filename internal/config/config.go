@@ -0,0 +1,346 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/darkstorage/cli/internal/bandwidth"
+	"github.com/darkstorage/cli/internal/hooks"
+	"github.com/darkstorage/cli/internal/logging"
+	"github.com/darkstorage/cli/internal/storage"
+	"github.com/spf13/viper"
+)
+
+// Config is the whole of config.yaml, as the CLI and the daemon see it
+// after defaults, the selected profile and environment overrides
+type Config struct {
+	Profile      string `mapstructure:"profile" json:"profile,omitempty"`
+	Endpoint     string `mapstructure:"endpoint" json:"endpoint"`
+	APIKey       string `mapstructure:"api_key" json:"-"`
+	RefreshToken string `mapstructure:"refresh_token" json:"-"`
+	TokenExpiry  string `mapstructure:"token_expiry" json:"token_expiry,omitempty"`
+	Encryption   string `mapstructure:"encryption" json:"encryption,omitempty"`
+	Bucket       string `mapstructure:"bucket" json:"bucket,omitempty"`
+	JSON         bool   `mapstructure:"json" json:"json"`
+	Trace        bool   `mapstructure:"trace" json:"trace"`
+
+	Storage     StorageConfig       `mapstructure:"storage" json:"storage"`
+	HTTP        HTTPConfig          `mapstructure:"http" json:"http"`
+	Transfer    TransferConfig      `mapstructure:"transfer" json:"transfer"`
+	OAuth       OAuthConfig         `mapstructure:"oauth" json:"oauth"`
+	Credentials CredentialsConfig   `mapstructure:"credentials" json:"credentials"`
+	Profiles    map[string]*Profile `mapstructure:"profiles" json:"profiles,omitempty"`
+
+	DaemonConfig `mapstructure:",squash"`
+}
+
+// HTTPConfig tunes the API client
+type HTTPConfig struct {
+	MaxRetries int     `mapstructure:"max_retries" json:"max_retries"`
+	RateLimit  float64 `mapstructure:"rate_limit" json:"rate_limit"`
+	Burst      int     `mapstructure:"burst" json:"burst"`
+}
+
+// TransferConfig limits the bandwidth of the CLI's uploads and downloads.
+// Limits are in KB/s, 0 for unlimited.
+type TransferConfig struct {
+	LimitUp   int `mapstructure:"limit_up" json:"limit_up"`
+	LimitDown int `mapstructure:"limit_down" json:"limit_down"`
+}
+
+// OAuthConfig overrides the OAuth endpoints, e.g. for a development server
+type OAuthConfig struct {
+	AuthorizeURL string `mapstructure:"authorize_url" json:"authorize_url,omitempty"`
+	TokenURL     string `mapstructure:"token_url" json:"token_url,omitempty"`
+	DeviceURL    string `mapstructure:"device_url" json:"device_url,omitempty"`
+}
+
+// CredentialsConfig selects where secrets are stored
+type CredentialsConfig struct {
+	Helper string `mapstructure:"helper" json:"helper,omitempty"`
+}
+
+// FieldError is a problem with one setting
+type FieldError struct {
+	Key     string `json:"key"`
+	Message string `json:"message"`
+}
+
+func (e *FieldError) Error() string {
+	return e.Key + ": " + e.Message
+}
+
+// ValidationError lists every problem found in a configuration
+type ValidationError []*FieldError
+
+func (e ValidationError) Error() string {
+	msgs := make([]string, len(e))
+	for i, fe := range e {
+		msgs[i] = fe.Error()
+	}
+	return "invalid configuration: " + strings.Join(msgs, "; ")
+}
+
+// NewViper returns a viper instance for config.yaml in dir, set up with
+// Setup and with the file read if it exists
+func NewViper(dir string) (*viper.Viper, error) {
+	v := viper.New()
+	Setup(v, dir)
+	v.SetConfigFile(filepath.Join(dir, "config.yaml"))
+	if err := v.ReadInConfig(); err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("reading %s: %w", v.ConfigFileUsed(), err)
+	}
+	return v, nil
+}
+
+// Load decodes and validates the settings in v, which should have been
+// set up with Setup. A ValidationError names each offending key.
+func Load(v *viper.Viper) (*Config, error) {
+	if errs := checkTypes(v); len(errs) > 0 {
+		return nil, errs
+	}
+
+	cfg := &Config{}
+	if err := v.Unmarshal(cfg); err != nil {
+		return nil, err
+	}
+	cfg.expandPaths()
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// expandPaths resolves a leading ~ in file settings
+func (c *Config) expandPaths() {
+	c.Daemon.LogFile = expandHome(c.Daemon.LogFile)
+	c.Daemon.PIDFile = expandHome(c.Daemon.PIDFile)
+	c.Daemon.IPCSocket = expandHome(c.Daemon.IPCSocket)
+	for i := range c.SyncFolders {
+		c.SyncFolders[i].LocalPath = expandHome(c.SyncFolders[i].LocalPath)
+	}
+}
+
+func expandHome(p string) string {
+	if p != "~" && !strings.HasPrefix(p, "~/") {
+		return p
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return p
+	}
+	return filepath.Join(home, p[1:])
+}
+
+// Validate checks values beyond their types, e.g. ranges and enumerations
+func (c *Config) Validate() error {
+	var errs ValidationError
+	check := func(ok bool, key, format string, args ...interface{}) {
+		if !ok {
+			errs = append(errs, &FieldError{Key: key, Message: fmt.Sprintf(format, args...)})
+		}
+	}
+
+	if c.Profile != "" && c.Profile != DefaultProfile {
+		_, ok := c.Profiles[c.Profile]
+		check(ok, "profile", "no profile named %q", c.Profile)
+	}
+	checkURL(check, "endpoint", c.Endpoint, true)
+	check(storage.ValidateEncryption(c.Encryption) == nil, "encryption", "%q is not none or sse-s3", c.Encryption)
+	if c.TokenExpiry != "" {
+		_, err := time.Parse(time.RFC3339, c.TokenExpiry)
+		check(err == nil, "token_expiry", "%q is not an RFC 3339 time", c.TokenExpiry)
+	}
+
+	check(c.HTTP.MaxRetries >= 0, "http.max_retries", "must not be negative")
+	check(c.HTTP.RateLimit >= 0, "http.rate_limit", "must not be negative")
+	check(c.HTTP.Burst >= 1, "http.burst", "must be at least 1")
+	check(c.Transfer.LimitUp >= 0, "transfer.limit_up", "must not be negative")
+	check(c.Transfer.LimitDown >= 0, "transfer.limit_down", "must not be negative")
+	checkURL(check, "oauth.authorize_url", c.OAuth.AuthorizeURL, false)
+	checkURL(check, "oauth.token_url", c.OAuth.TokenURL, false)
+	checkURL(check, "oauth.device_url", c.OAuth.DeviceURL, false)
+
+	names := make([]string, 0, len(c.Profiles))
+	for name := range c.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		p := c.Profiles[name]
+		key := "profiles." + name
+		check(ValidateProfileName(name) == nil, key, "invalid profile name: use lowercase letters, digits, '-' and '_'")
+		if p == nil {
+			continue
+		}
+		checkURL(check, key+".endpoint", p.Endpoint, false)
+		check(storage.ValidateEncryption(p.Encryption) == nil, key+".encryption", "%q is not none or sse-s3", p.Encryption)
+	}
+
+	c.DaemonConfig.validate(check, c.Profiles)
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// isLoopbackAddress reports whether addr is a host:port that only accepts
+// connections from this machine
+func isLoopbackAddress(addr string) bool {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil || port == "" {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+type checkFunc func(ok bool, key, format string, args ...interface{})
+
+func checkURL(check checkFunc, key, value string, required bool) {
+	if value == "" {
+		check(!required, key, "must be set")
+		return
+	}
+	u, err := url.Parse(value)
+	check(err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "",
+		key, "%q is not an http or https URL", value)
+}
+
+func (c *DaemonConfig) validate(check checkFunc, profiles map[string]*Profile) {
+	d := c.Daemon
+	check(oneOf(d.LogLevel, "debug", "info", "warn", "error"), "daemon.log_level", "%q is not debug, info, warn or error", d.LogLevel)
+	components := make([]string, 0, len(d.LogLevels))
+	for component := range d.LogLevels {
+		components = append(components, component)
+	}
+	sort.Strings(components)
+	for _, component := range components {
+		key := "daemon.log_levels." + component
+		level := d.LogLevels[component]
+		check(oneOf(component, logging.Components...), key, "unknown component: use %s", strings.Join(logging.Components, ", "))
+		check(oneOf(level, "debug", "info", "warn", "error"), key, "%q is not debug, info, warn or error", level)
+	}
+	check(oneOf(d.LogFormat, logging.FormatText, logging.FormatJSON), "daemon.log_format", "%q is not text or json", d.LogFormat)
+	check(d.LogMaxSize >= 0, "daemon.log_max_size", "must not be negative")
+	check(d.LogRotateInterval >= 0, "daemon.log_rotate_interval", "must not be negative")
+	check(d.LogMaxFiles >= 0, "daemon.log_max_files", "must not be negative")
+	check(d.LogMaxAge >= 0, "daemon.log_max_age", "must not be negative")
+	check(d.WorkerThreads >= 1, "daemon.worker_threads", "must be at least 1")
+	check(d.MaxQueueSize >= 1, "daemon.max_queue_size", "must be at least 1")
+	check(d.DebounceDelay >= 0, "daemon.debounce_delay", "must not be negative")
+	check(d.Timeout > 0, "daemon.timeout", "must be positive")
+	check(d.RetryAttempts >= 0, "daemon.retry_attempts", "must not be negative")
+	check(d.RetryDelay >= 0, "daemon.retry_delay", "must not be negative")
+	check(d.ShutdownTimeout >= 0, "daemon.shutdown_timeout", "must not be negative")
+	check(d.BandwidthLimitUp >= 0, "daemon.bandwidth_limit_up", "must not be negative")
+	check(d.BandwidthLimitDown >= 0, "daemon.bandwidth_limit_down", "must not be negative")
+	for i, rule := range d.BandwidthSchedule {
+		key := fmt.Sprintf("daemon.bandwidth_schedule[%d]", i)
+		_, err := bandwidth.ParseDays(rule.Days)
+		check(err == nil, key+".days", "%v", err)
+		start, serr := bandwidth.ParseClock(rule.Start)
+		check(serr == nil, key+".start", "%v", serr)
+		end, eerr := bandwidth.ParseClock(rule.End)
+		check(eerr == nil, key+".end", "%v", eerr)
+		check(serr != nil || eerr != nil || start != end, key, "start and end must differ")
+		check(rule.Up >= 0, key+".up", "must not be negative")
+		check(rule.Down >= 0, key+".down", "must not be negative")
+	}
+	if d.MetricsAddress != "" {
+		check(isLoopbackAddress(d.MetricsAddress), "daemon.metrics_address",
+			"%q is not a loopback host:port such as 127.0.0.1:9464", d.MetricsAddress)
+	}
+
+	a := c.AnomalyDetection
+	check(a.Window > 0, "anomaly_detection.window", "must be positive")
+	check(a.MinFiles >= 1, "anomaly_detection.min_files", "must be at least 1")
+	check(a.Threshold > 0 && a.Threshold <= 1, "anomaly_detection.threshold", "must be between 0 and 1")
+
+	t := c.Throttling
+	for i, pattern := range t.MeteredInterfaces {
+		_, err := path.Match(pattern, "")
+		check(err == nil, fmt.Sprintf("throttling.metered_interfaces[%d]", i), "%q is not a valid pattern", pattern)
+	}
+	checkThrottleAction(check, "throttling.on_metered", t.OnMetered)
+	checkThrottleAction(check, "throttling.on_battery", t.OnBattery)
+	checkThrottleAction(check, "throttling.on_high_load", t.OnHighLoad)
+	check(t.MaxLoad >= 0, "throttling.max_load", "must not be negative")
+	check(t.LimitUp >= 0, "throttling.limit_up", "must not be negative")
+	check(t.LimitDown >= 0, "throttling.limit_down", "must not be negative")
+	check(t.CheckInterval > 0, "throttling.check_interval", "must be positive")
+
+	for i, pattern := range c.Metadata.Xattrs {
+		_, err := path.Match(pattern, "")
+		check(err == nil, fmt.Sprintf("metadata.xattrs[%d]", i), "%q is not a valid pattern", pattern)
+	}
+
+	seen := make(map[string]bool)
+	for i, f := range c.SyncFolders {
+		key := fmt.Sprintf("sync_folders[%d]", i)
+		check(f.LocalPath != "", key+".local_path", "must be set")
+		if f.LocalPath != "" {
+			check(filepath.IsAbs(f.LocalPath), key+".local_path", "%q is not an absolute path", f.LocalPath)
+			check(!seen[f.LocalPath], key+".local_path", "%s is already a sync folder", f.LocalPath)
+			seen[f.LocalPath] = true
+		}
+		check(f.RemotePath != "", key+".remote_path", "must be set")
+		check(f.Direction == "" || oneOf(f.Direction, "bidirectional", "upload_only", "download_only"),
+			key+".direction", "%q is not bidirectional, upload_only or download_only", f.Direction)
+		check(f.ConflictResolution == "" || oneOf(f.ConflictResolution, "keep_local", "keep_remote", "keep_both", "manual"),
+			key+".conflict_resolution", "%q is not keep_local, keep_remote, keep_both or manual", f.ConflictResolution)
+		check(f.SyncMode == "" || oneOf(f.SyncMode, "continuous", "interval", "scheduled"),
+			key+".sync_mode", "%q is not continuous, interval or scheduled", f.SyncMode)
+		check(f.SyncInterval >= 0, key+".sync_interval", "must not be negative")
+		check(f.BandwidthLimit >= 0, key+".bandwidth_limit", "must not be negative")
+		check(f.MaxFileSize >= 0, key+".max_file_size", "must not be negative")
+		if f.Profile != "" && f.Profile != DefaultProfile {
+			_, ok := profiles[f.Profile]
+			check(ok, key+".profile", "no profile named %q", f.Profile)
+		}
+	}
+
+	names := make(map[string]bool)
+	for i, h := range c.Hooks {
+		key := fmt.Sprintf("hooks[%d]", i)
+		check(h.Name != "", key+".name", "must be set")
+		if h.Name != "" {
+			check(!names[h.Name], key+".name", "another hook is called %q", h.Name)
+			names[h.Name] = true
+		}
+		check(len(h.Events) > 0, key+".events", "must list at least one event")
+		for _, event := range h.Events {
+			check(oneOf(event, hooks.Events...), key+".events", "unknown event %q: use %s", event, strings.Join(hooks.Events, ", "))
+		}
+		check((len(h.Command) > 0) != (h.URL != ""), key, "set either command or url")
+		checkURL(check, key+".url", h.URL, false)
+		check(h.Secret == "" || h.URL != "", key+".secret", "only webhooks are signed")
+		check(h.Timeout >= 0, key+".timeout", "must not be negative")
+		check(h.Retries == nil || *h.Retries >= 0, key+".retries", "must not be negative")
+	}
+}
+
+func checkThrottleAction(check checkFunc, key, action string) {
+	check(oneOf(action, ThrottleNone, ThrottleSlow, ThrottlePause), key, "%q is not none, throttle or pause", action)
+}
+
+func oneOf(value string, allowed ...string) bool {
+	for _, a := range allowed {
+		if value == a {
+			return true
+		}
+	}
+	return false
+}
@@ -1,128 +1,323 @@
 package config
 
 import (
+	"fmt"
 	"os"
 	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
 	"time"
 
-	"github.com/spf13/viper"
+	"github.com/spf13/cast"
 )
 
+// DaemonConfig holds the sync daemon's settings, part of Config
 type DaemonConfig struct {
-	Daemon        DaemonSettings       `yaml:"daemon"`
-	SyncFolders   []SyncFolderConfig   `yaml:"sync_folders"`
-	Notifications NotificationSettings `yaml:"notifications"`
-	API           APIConfig            `yaml:"api"`
+	Daemon           DaemonSettings       `mapstructure:"daemon" json:"daemon"`
+	SyncFolders      []SyncFolderConfig   `mapstructure:"sync_folders" json:"sync_folders"`
+	Notifications    NotificationSettings `mapstructure:"notifications" json:"notifications"`
+	AnomalyDetection AnomalySettings      `mapstructure:"anomaly_detection" json:"anomaly_detection"`
+	Metadata         MetadataSettings     `mapstructure:"metadata" json:"metadata"`
+	Hooks            []HookConfig         `mapstructure:"hooks" json:"hooks,omitempty"`
+	Throttling       ThrottleSettings     `mapstructure:"throttling" json:"throttling"`
 }
 
 type DaemonSettings struct {
-	Enabled            bool          `yaml:"enabled"`
-	LogLevel           string        `yaml:"log_level"`
-	LogFile            string        `yaml:"log_file"`
-	PIDFile            string        `yaml:"pid_file"`
-	IPCSocket          string        `yaml:"ipc_socket"`
-	WorkerThreads      int           `yaml:"worker_threads"`
-	MaxQueueSize       int           `yaml:"max_queue_size"`
-	DebounceDelay      time.Duration `yaml:"debounce_delay"`
-	Timeout            time.Duration `yaml:"timeout"`
-	RetryAttempts      int           `yaml:"retry_attempts"`
-	RetryDelay         time.Duration `yaml:"retry_delay"`
-	BandwidthLimitUp   int           `yaml:"bandwidth_limit_up"`
-	BandwidthLimitDown int           `yaml:"bandwidth_limit_down"`
+	Enabled   bool   `mapstructure:"enabled" json:"enabled"`
+	LogLevel  string `mapstructure:"log_level" json:"log_level"`
+	LogFile   string `mapstructure:"log_file" json:"log_file"`
+	LogFormat string `mapstructure:"log_format" json:"log_format"`
+	// LogLevels overrides LogLevel for components such as engine or ipc
+	LogLevels map[string]string `mapstructure:"log_levels" json:"log_levels,omitempty"`
+	// LogMaxSize is in MB
+	LogMaxSize         int           `mapstructure:"log_max_size" json:"log_max_size"`
+	LogRotateInterval  time.Duration `mapstructure:"log_rotate_interval" json:"log_rotate_interval"`
+	LogMaxFiles        int           `mapstructure:"log_max_files" json:"log_max_files"`
+	LogMaxAge          time.Duration `mapstructure:"log_max_age" json:"log_max_age"`
+	PIDFile            string        `mapstructure:"pid_file" json:"pid_file"`
+	IPCSocket          string        `mapstructure:"ipc_socket" json:"ipc_socket"`
+	WorkerThreads      int           `mapstructure:"worker_threads" json:"worker_threads"`
+	MaxQueueSize       int           `mapstructure:"max_queue_size" json:"max_queue_size"`
+	DebounceDelay      time.Duration `mapstructure:"debounce_delay" json:"debounce_delay"`
+	Timeout            time.Duration `mapstructure:"timeout" json:"timeout"`
+	RetryAttempts      int           `mapstructure:"retry_attempts" json:"retry_attempts"`
+	RetryDelay         time.Duration `mapstructure:"retry_delay" json:"retry_delay"`
+	ShutdownTimeout    time.Duration `mapstructure:"shutdown_timeout" json:"shutdown_timeout"`
+	BandwidthLimitUp   int           `mapstructure:"bandwidth_limit_up" json:"bandwidth_limit_up"`
+	BandwidthLimitDown int           `mapstructure:"bandwidth_limit_down" json:"bandwidth_limit_down"`
+	// BandwidthSchedule replaces the limits above at the times it lists
+	BandwidthSchedule []BandwidthRule `mapstructure:"bandwidth_schedule" json:"bandwidth_schedule,omitempty"`
+	// MetricsAddress is the loopback host:port serving /metrics, /healthz
+	// and /readyz, empty to serve none
+	MetricsAddress string `mapstructure:"metrics_address" json:"metrics_address"`
 }
 
+// SyncFolderConfig declares a sync folder in config.yaml. The daemon adds,
+// updates and removes these folders to match the file.
 type SyncFolderConfig struct {
-	ID                 int      `yaml:"id"`
-	Name               string   `yaml:"name"`
-	LocalPath          string   `yaml:"local_path"`
-	RemotePath         string   `yaml:"remote_path"`
-	Direction          string   `yaml:"direction"`
-	Enabled            bool     `yaml:"enabled"`
-	Excludes           []string `yaml:"excludes"`
-	ConflictResolution string   `yaml:"conflict_resolution"`
-	SyncMode           string   `yaml:"sync_mode"`
-	SyncInterval       int      `yaml:"sync_interval"`
-	SyncSchedule       string   `yaml:"sync_schedule"`
-	BandwidthLimit     int      `yaml:"bandwidth_limit"`
-	MaxFileSize        int64    `yaml:"max_file_size"`
+	ID                 int      `mapstructure:"id" json:"id"`
+	Name               string   `mapstructure:"name" json:"name"`
+	LocalPath          string   `mapstructure:"local_path" json:"local_path"`
+	RemotePath         string   `mapstructure:"remote_path" json:"remote_path"`
+	Direction          string   `mapstructure:"direction" json:"direction"`
+	Enabled            *bool    `mapstructure:"enabled" json:"enabled,omitempty"`
+	Excludes           []string `mapstructure:"excludes" json:"excludes"`
+	ConflictResolution string   `mapstructure:"conflict_resolution" json:"conflict_resolution"`
+	SyncMode           string   `mapstructure:"sync_mode" json:"sync_mode"`
+	SyncInterval       int      `mapstructure:"sync_interval" json:"sync_interval"`
+	SyncSchedule       string   `mapstructure:"sync_schedule" json:"sync_schedule"`
+	BandwidthLimit     int      `mapstructure:"bandwidth_limit" json:"bandwidth_limit"`
+	MaxFileSize        int64    `mapstructure:"max_file_size" json:"max_file_size"`
+	IncludePaths       []string `mapstructure:"include_paths" json:"include_paths"`
+	Placeholders       bool     `mapstructure:"placeholders" json:"placeholders"`
+	Profile            string   `mapstructure:"profile" json:"profile"`
+}
+
+// IsEnabled reports whether the folder syncs; it does unless disabled
+// explicitly
+func (f SyncFolderConfig) IsEnabled() bool {
+	return f.Enabled == nil || *f.Enabled
+}
+
+// BandwidthRule limits transfers on Days between Start and End, such as
+// 09:00 and 18:00. A rule ending before it starts runs past midnight.
+type BandwidthRule struct {
+	// Days are names such as mon, ranges such as mon-fri, weekdays,
+	// weekends or daily; every day if empty
+	Days  []string `mapstructure:"days" json:"days,omitempty"`
+	Start string   `mapstructure:"start" json:"start"`
+	End   string   `mapstructure:"end" json:"end"`
+	// Up and Down are in KB/s, 0 for unlimited
+	Up   int `mapstructure:"up" json:"up"`
+	Down int `mapstructure:"down" json:"down"`
+}
+
+// HookConfig runs a command or calls a webhook when one of Events happens.
+// Exactly one of Command and URL is set.
+type HookConfig struct {
+	Name   string   `mapstructure:"name" json:"name"`
+	Events []string `mapstructure:"events" json:"events"`
+	// Command is the program and its arguments, run without a shell with
+	// the event as JSON on stdin
+	Command []string `mapstructure:"command" json:"command,omitempty"`
+	URL     string   `mapstructure:"url" json:"url,omitempty"`
+	// Secret signs webhook bodies with HMAC-SHA256. It is moved to the
+	// credential store as hooks.<name>.secret when the file is read.
+	Secret  string        `mapstructure:"secret" json:"-"`
+	Timeout time.Duration `mapstructure:"timeout" json:"timeout,omitempty"`
+	// Retries defaults to hooks.DefaultRetries
+	Retries *int `mapstructure:"retries" json:"retries,omitempty"`
+}
+
+// Throttle actions, taken while a condition holds
+const (
+	ThrottleNone  = "none"
+	ThrottleSlow  = "throttle"
+	ThrottlePause = "pause"
+)
+
+// ThrottleSettings pause or slow transfers while the connection is
+// metered, the machine is on battery or its load is high
+type ThrottleSettings struct {
+	// MeteredInterfaces are patterns such as usb* for interfaces whose
+	// traffic is metered, e.g. a tethered phone
+	MeteredInterfaces []string `mapstructure:"metered_interfaces" json:"metered_interfaces"`
+	// MeteredCommand is run without a shell and exits 0 when the
+	// connection is metered
+	MeteredCommand []string `mapstructure:"metered_command" json:"metered_command"`
+	OnMetered      string   `mapstructure:"on_metered" json:"on_metered"`
+	OnBattery      string   `mapstructure:"on_battery" json:"on_battery"`
+	// MaxLoad is the one-minute load average per CPU above which load is
+	// high, 0 to ignore load
+	MaxLoad    float64 `mapstructure:"max_load" json:"max_load"`
+	OnHighLoad string  `mapstructure:"on_high_load" json:"on_high_load"`
+	// LimitUp and LimitDown are in KB/s, 0 for unlimited
+	LimitUp       int           `mapstructure:"limit_up" json:"limit_up"`
+	LimitDown     int           `mapstructure:"limit_down" json:"limit_down"`
+	CheckInterval time.Duration `mapstructure:"check_interval" json:"check_interval"`
 }
 
 type NotificationSettings struct {
-	Enabled       bool `yaml:"enabled"`
-	ShowSuccess   bool `yaml:"show_success"`
-	ShowErrors    bool `yaml:"show_errors"`
-	ShowConflicts bool `yaml:"show_conflicts"`
+	Enabled       bool `mapstructure:"enabled" json:"enabled"`
+	ShowSuccess   bool `mapstructure:"show_success" json:"show_success"`
+	ShowErrors    bool `mapstructure:"show_errors" json:"show_errors"`
+	ShowConflicts bool `mapstructure:"show_conflicts" json:"show_conflicts"`
+}
+
+type AnomalySettings struct {
+	Enabled   bool          `mapstructure:"enabled" json:"enabled"`
+	Window    time.Duration `mapstructure:"window" json:"window"`
+	MinFiles  int           `mapstructure:"min_files" json:"min_files"`
+	Threshold float64       `mapstructure:"threshold" json:"threshold"`
 }
 
-type APIConfig struct {
-	Endpoint string `yaml:"endpoint"`
+type MetadataSettings struct {
+	PreserveOwner bool     `mapstructure:"preserve_owner" json:"preserve_owner"`
+	Xattrs        []string `mapstructure:"xattrs" json:"xattrs"`
 }
 
+// LoadDaemonConfig reads the daemon settings from config.yaml in the data
+// directory
 func LoadDaemonConfig() (*DaemonConfig, error) {
-	home, err := os.UserHomeDir()
+	dir, err := GetDefaultDataDir()
 	if err != nil {
 		return nil, err
 	}
-
-	configPath := filepath.Join(home, ".darkstorage")
-	v := viper.New()
-	v.SetConfigName("daemon")
-	v.SetConfigType("yaml")
-	v.AddConfigPath(configPath)
-
-	v.SetDefault("daemon.enabled", true)
-	v.SetDefault("daemon.log_level", "info")
-	v.SetDefault("daemon.log_file", filepath.Join(configPath, "daemon.log"))
-	v.SetDefault("daemon.pid_file", filepath.Join(configPath, "daemon.pid"))
-	v.SetDefault("daemon.ipc_socket", filepath.Join(configPath, "daemon.sock"))
-	v.SetDefault("daemon.worker_threads", 4)
-	v.SetDefault("daemon.max_queue_size", 1000)
-	v.SetDefault("daemon.debounce_delay", "3s")
-	v.SetDefault("daemon.timeout", "30s")
-	v.SetDefault("daemon.retry_attempts", 3)
-	v.SetDefault("daemon.retry_delay", "5s")
-	v.SetDefault("notifications.enabled", true)
-	v.SetDefault("notifications.show_success", false)
-	v.SetDefault("notifications.show_errors", true)
-	v.SetDefault("notifications.show_conflicts", true)
-	v.SetDefault("api.endpoint", "https://api.darkstorage.io")
-
-	config := &DaemonConfig{}
-	if err := v.ReadInConfig(); err != nil {
-		if _, ok := err.(viper.ConfigFileNotFoundError); !ok {
-			return nil, err
-		}
+	v, err := NewViper(dir)
+	if err != nil {
+		return nil, err
 	}
-
-	if err := v.Unmarshal(config); err != nil {
+	cfg, err := Load(v)
+	if err != nil {
 		return nil, err
 	}
-
-	return config, nil
+	return &cfg.DaemonConfig, nil
 }
 
+// SaveDaemonConfig writes the daemon settings into config.yaml, leaving
+// the CLI's settings and the file's comments as they are
 func SaveDaemonConfig(config *DaemonConfig) error {
-	home, err := os.UserHomeDir()
+	dir, err := GetDefaultDataDir()
 	if err != nil {
 		return err
 	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
 
-	configPath := filepath.Join(home, ".darkstorage")
-	if err := os.MkdirAll(configPath, 0700); err != nil {
+	file, err := OpenYAML(filepath.Join(dir, "config.yaml"))
+	if err != nil {
 		return err
 	}
+	if err := setSettings(file, "", settingsValue(reflect.ValueOf(*config))); err != nil {
+		return err
+	}
+	return file.Save()
+}
 
-	v := viper.New()
-	v.SetConfigName("daemon")
-	v.SetConfigType("yaml")
-	v.AddConfigPath(configPath)
+// UpdateDaemonConfig validates settings on top of config.yaml in dir and
+// writes them to the file. Keys are dotted or nested, e.g.
+// {"daemon.worker_threads": 8} or {"daemon": {"worker_threads": 8}}; only
+// schema settings, sync_folders, daemon.log_levels and
+// daemon.bandwidth_schedule may be changed.
+// Nothing is written if the result would be invalid.
+func UpdateDaemonConfig(dir string, settings map[string]interface{}) error {
+	flat := make(map[string]interface{})
+	FlattenSettings("", settings, flat)
+	if len(flat) == 0 {
+		return fmt.Errorf("no settings given")
+	}
 
-	v.Set("daemon", config.Daemon)
-	v.Set("sync_folders", config.SyncFolders)
-	v.Set("notifications", config.Notifications)
-	v.Set("api", config.API)
+	var errs ValidationError
+	for key, value := range flat {
+		if key == "sync_folders" || key == "daemon.bandwidth_schedule" || strings.HasPrefix(key, "daemon.log_levels.") {
+			continue
+		}
+		s, ok := LookupSetting(key)
+		if !ok {
+			errs = append(errs, &FieldError{Key: key, Message: "unknown setting"})
+			continue
+		}
+		// Durations are written the way people type them, not as nanoseconds
+		if _, ok := s.Default.(time.Duration); ok {
+			if d, err := cast.ToDurationE(value); err == nil {
+				flat[key] = d.String()
+			}
+		}
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+
+	v, err := NewViper(dir)
+	if err != nil {
+		return err
+	}
+	for key, value := range flat {
+		v.Set(key, value)
+	}
+	if _, err := Load(v); err != nil {
+		return err
+	}
+
+	file, err := OpenYAML(filepath.Join(dir, "config.yaml"))
+	if err != nil {
+		return err
+	}
+	keys := make([]string, 0, len(flat))
+	for key := range flat {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if err := file.Set(key, flat[key]); err != nil {
+			return err
+		}
+	}
+	return file.Save()
+}
 
-	return v.WriteConfig()
+// setSettings stores each leaf of value under prefix, so comments on the
+// settings already in the file survive
+func setSettings(file *YAMLFile, prefix string, value interface{}) error {
+	m, ok := value.(map[string]interface{})
+	if !ok {
+		return file.Set(prefix, value)
+	}
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, name := range keys {
+		key := name
+		if prefix != "" {
+			key = prefix + "." + name
+		}
+		if err := setSettings(file, key, m[name]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// settingsValue converts v to plain maps and lists keyed like config.yaml,
+// with durations written as "30s" rather than nanoseconds
+func settingsValue(v reflect.Value) interface{} {
+	if d, ok := v.Interface().(time.Duration); ok {
+		return d.String()
+	}
+	switch v.Kind() {
+	case reflect.Struct:
+		m := make(map[string]interface{}, v.NumField())
+		for i := 0; i < v.NumField(); i++ {
+			key := v.Type().Field(i).Tag.Get("mapstructure")
+			if key == "" || key == "-" {
+				continue
+			}
+			m[key] = settingsValue(v.Field(i))
+		}
+		return m
+	case reflect.Map:
+		m := make(map[string]interface{}, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			m[iter.Key().String()] = settingsValue(iter.Value())
+		}
+		return m
+	case reflect.Slice:
+		if v.IsNil() {
+			return []interface{}{}
+		}
+		list := make([]interface{}, v.Len())
+		for i := range list {
+			list[i] = settingsValue(v.Index(i))
+		}
+		return list
+	default:
+		return v.Interface()
+	}
 }
 
 func GetDefaultDataDir() (string, error) {
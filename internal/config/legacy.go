@@ -0,0 +1,120 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Older releases kept settings in separate files next to config.yaml
+const (
+	legacyJSONFile   = "config.json" // read by `darkstorage api`
+	legacyDaemonFile = "daemon.yaml" // read by the sync daemon
+)
+
+// MigrateLegacy merges the legacy config.json and daemon.yaml in dir into
+// configFile, then renames them with a .migrated suffix. Settings already in
+// configFile win. Secrets land in configFile in plain text, so callers move
+// them into the credential store afterwards. It returns the files migrated.
+func MigrateLegacy(dir, configFile string) ([]string, error) {
+	settings := make(map[string]interface{})
+	var migrated []string
+
+	jsonPath := filepath.Join(dir, legacyJSONFile)
+	if data, err := os.ReadFile(jsonPath); err == nil {
+		var legacy struct {
+			AccessToken  string `json:"access_token"`
+			RefreshToken string `json:"refresh_token"`
+			Endpoint     string `json:"endpoint"`
+		}
+		if err := json.Unmarshal(data, &legacy); err != nil {
+			return nil, fmt.Errorf("parsing %s: %w", jsonPath, err)
+		}
+		for key, value := range map[string]string{
+			"api_key":       legacy.AccessToken,
+			"refresh_token": legacy.RefreshToken,
+			"endpoint":      legacy.Endpoint,
+		} {
+			if value != "" {
+				settings[key] = value
+			}
+		}
+		migrated = append(migrated, jsonPath)
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+
+	daemonPath := filepath.Join(dir, legacyDaemonFile)
+	if data, err := os.ReadFile(daemonPath); err == nil {
+		var legacy map[string]interface{}
+		if err := yaml.Unmarshal(data, &legacy); err != nil {
+			return nil, fmt.Errorf("parsing %s: %w", daemonPath, err)
+		}
+		// api.endpoint duplicated the CLI's endpoint
+		if api, ok := legacy["api"].(map[string]interface{}); ok {
+			if endpoint, ok := api["endpoint"]; ok {
+				if _, set := settings["endpoint"]; !set {
+					settings["endpoint"] = endpoint
+				}
+			}
+			delete(legacy, "api")
+		}
+		FlattenSettings("", legacy, settings)
+		migrated = append(migrated, daemonPath)
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+
+	if len(migrated) == 0 {
+		return nil, nil
+	}
+
+	file, err := OpenYAML(configFile)
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]string, 0, len(settings))
+	for key := range settings {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if file.find(key) != nil {
+			continue
+		}
+		if err := file.Set(key, settings[key]); err != nil {
+			return nil, err
+		}
+	}
+	if err := file.Save(); err != nil {
+		return nil, err
+	}
+
+	for _, path := range migrated {
+		if err := os.Rename(path, path+".migrated"); err != nil {
+			return nil, err
+		}
+	}
+	return migrated, nil
+}
+
+// FlattenSettings copies the leaves of a nested mapping into out under
+// dotted keys. Lists are leaves.
+func FlattenSettings(prefix string, in, out map[string]interface{}) {
+	for name, value := range in {
+		key := name
+		if prefix != "" {
+			key = prefix + "." + name
+		}
+		if child, ok := value.(map[string]interface{}); ok {
+			FlattenSettings(key, child, out)
+			continue
+		}
+		out[key] = value
+	}
+}
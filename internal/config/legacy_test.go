@@ -0,0 +1,152 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestMigrateLegacy(t *testing.T) {
+	tests := []struct {
+		name string
+		// files are written to the config directory first, "" for none
+		configJSON string
+		daemonYAML string
+		configYAML string
+		// want are values expected in config.yaml afterwards, "" for absent
+		want         map[string]string
+		wantMigrated []string
+		wantErr      string
+	}{
+		{
+			name: "nothing to migrate",
+			want: map[string]string{"api_key": ""},
+		},
+		{
+			name:         "config.json",
+			configJSON:   `{"access_token": "tok", "refresh_token": "ref", "endpoint": "https://json.example"}`,
+			want:         map[string]string{"api_key": "tok", "refresh_token": "ref", "endpoint": "https://json.example"},
+			wantMigrated: []string{legacyJSONFile},
+		},
+		{
+			name:         "empty values are skipped",
+			configJSON:   `{"access_token": "tok", "refresh_token": ""}`,
+			want:         map[string]string{"api_key": "tok", "refresh_token": ""},
+			wantMigrated: []string{legacyJSONFile},
+		},
+		{
+			name:         "daemon.yaml is flattened",
+			daemonYAML:   "sync:\n  interval: 30s\n  workers: 4\nlogging:\n  level: debug\n",
+			want:         map[string]string{"sync.interval": "30s", "sync.workers": "4", "logging.level": "debug"},
+			wantMigrated: []string{legacyDaemonFile},
+		},
+		{
+			name:         "daemon api.endpoint becomes endpoint",
+			daemonYAML:   "api:\n  endpoint: https://daemon.example\n",
+			want:         map[string]string{"endpoint": "https://daemon.example", "api.endpoint": ""},
+			wantMigrated: []string{legacyDaemonFile},
+		},
+		{
+			name:         "config.json endpoint wins over daemon.yaml",
+			configJSON:   `{"endpoint": "https://json.example"}`,
+			daemonYAML:   "api:\n  endpoint: https://daemon.example\nsync:\n  workers: 2\n",
+			want:         map[string]string{"endpoint": "https://json.example", "sync.workers": "2"},
+			wantMigrated: []string{legacyJSONFile, legacyDaemonFile},
+		},
+		{
+			name:         "config.yaml wins over both",
+			configJSON:   `{"access_token": "old", "endpoint": "https://json.example"}`,
+			daemonYAML:   "sync:\n  workers: 2\n  interval: 1m\n",
+			configYAML:   "# my settings\napi_key: current\nsync:\n  workers: 8\n",
+			want:         map[string]string{"api_key": "current", "endpoint": "https://json.example", "sync.workers": "8", "sync.interval": "1m"},
+			wantMigrated: []string{legacyJSONFile, legacyDaemonFile},
+		},
+		{
+			name:       "invalid config.json",
+			configJSON: `{"access_token": `,
+			daemonYAML: "sync:\n  workers: 2\n",
+			want:       map[string]string{"sync.workers": ""},
+			wantErr:    legacyJSONFile,
+		},
+		{
+			name:       "invalid daemon.yaml",
+			configJSON: `{"access_token": "tok"}`,
+			daemonYAML: "sync: [workers\n",
+			want:       map[string]string{"api_key": ""},
+			wantErr:    legacyDaemonFile,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			configFile := filepath.Join(dir, "config.yaml")
+			for name, content := range map[string]string{
+				legacyJSONFile:   tt.configJSON,
+				legacyDaemonFile: tt.daemonYAML,
+				"config.yaml":    tt.configYAML,
+			} {
+				if content == "" {
+					continue
+				}
+				if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0600); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			migrated, err := MigrateLegacy(dir, configFile)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("MigrateLegacy() error = %v, want one naming %s", err, tt.wantErr)
+				}
+			} else if err != nil {
+				t.Fatalf("MigrateLegacy() error = %v", err)
+			}
+
+			var wantMigrated []string
+			for _, name := range tt.wantMigrated {
+				wantMigrated = append(wantMigrated, filepath.Join(dir, name))
+			}
+			if !reflect.DeepEqual(migrated, wantMigrated) {
+				t.Errorf("MigrateLegacy() = %v, want %v", migrated, wantMigrated)
+			}
+
+			file, err := OpenYAML(configFile)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for key, want := range tt.want {
+				got, ok := file.Get(key)
+				if want == "" && ok {
+					t.Errorf("%s = %q, want it unset", key, got)
+				} else if want != "" && got != want {
+					t.Errorf("%s = %q, want %q", key, got, want)
+				}
+			}
+
+			for _, name := range []string{legacyJSONFile, legacyDaemonFile} {
+				_, err := os.Stat(filepath.Join(dir, name+".migrated"))
+				renamed := err == nil
+				wantRenamed := false
+				for _, m := range tt.wantMigrated {
+					wantRenamed = wantRenamed || m == name
+				}
+				if renamed != wantRenamed {
+					t.Errorf("%s renamed = %v, want %v", name, renamed, wantRenamed)
+				}
+			}
+
+			if tt.configYAML != "" {
+				data, err := os.ReadFile(configFile)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !strings.HasPrefix(string(data), "# my settings\n") {
+					t.Errorf("config.yaml lost its comment:\n%s", data)
+				}
+			}
+		})
+	}
+}
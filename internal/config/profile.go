@@ -0,0 +1,168 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultProfile names the top-level settings, used when no profile is
+// selected
+const DefaultProfile = "default"
+
+// ErrProfileNotFound means the selected profile isn't in the config file
+var ErrProfileNotFound = errors.New("profile not found")
+
+// ProfileKeys lists the account settings a profile holds. They never fall
+// back to the top-level values, so one account's keys can't leak into
+// another's profile.
+var ProfileKeys = []string{
+	"endpoint",
+	"api_key",
+	"refresh_token",
+	"token_expiry",
+	"storage.endpoint",
+	"storage.region",
+	"storage.use_ssl",
+	"storage.access_key",
+	"storage.secret_key",
+	"encryption",
+	"bucket",
+}
+
+// Profile is a named account: API endpoint, storage backend and defaults.
+// Credentials are kept in the credential store, not here.
+type Profile struct {
+	Name       string         `json:"name"`
+	Endpoint   string         `mapstructure:"endpoint" json:"endpoint,omitempty"`
+	Storage    ProfileStorage `mapstructure:"storage" json:"storage"`
+	Encryption string         `mapstructure:"encryption" json:"encryption,omitempty"`
+	Bucket     string         `mapstructure:"bucket" json:"bucket,omitempty"`
+}
+
+// ProfileStorage is a profile's storage backend
+type ProfileStorage struct {
+	Endpoint string `mapstructure:"endpoint" json:"endpoint,omitempty"`
+	Region   string `mapstructure:"region" json:"region,omitempty"`
+	UseSSL   *bool  `mapstructure:"use_ssl" json:"use_ssl,omitempty"`
+}
+
+// Viper lowercases keys, so mixed-case names wouldn't round-trip
+var profileNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+// ValidateProfileName rejects names that can't be used as a config key
+func ValidateProfileName(name string) error {
+	if name == DefaultProfile {
+		return fmt.Errorf("%q is reserved for the top-level settings", name)
+	}
+	if !profileNamePattern.MatchString(name) {
+		return fmt.Errorf("invalid profile name %q: use lowercase letters, digits, '-' and '_'", name)
+	}
+	return nil
+}
+
+// ActiveProfile returns the profile selected by --profile,
+// DARKSTORAGE_PROFILE or the profile config key, or "" for the top-level
+// settings
+func ActiveProfile(v *viper.Viper) string {
+	name := v.GetString("profile")
+	if name == DefaultProfile {
+		return ""
+	}
+	return name
+}
+
+// ProfileKey returns where key is stored for profile: under
+// profiles.<name>, or at the top level for the default profile
+func ProfileKey(profile, key string) string {
+	if profile == "" || profile == DefaultProfile {
+		return key
+	}
+	return "profiles." + profile + "." + key
+}
+
+// ProfileNames returns the configured profiles, sorted
+func ProfileNames(v *viper.Viper) []string {
+	var names []string
+	for name := range v.GetStringMap("profiles") {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// GetProfile returns the named profile's settings
+func GetProfile(v *viper.Viper, name string) (*Profile, error) {
+	if !v.IsSet("profiles." + name) {
+		return nil, fmt.Errorf("%w: %s", ErrProfileNotFound, name)
+	}
+	profile := &Profile{Name: name}
+	if err := v.UnmarshalKey("profiles."+name, profile); err != nil {
+		return nil, fmt.Errorf("profile %s: %w", name, err)
+	}
+	profile.Name = name
+	return profile, nil
+}
+
+// ApplyProfile makes the named profile's settings the config values of v,
+// which must have read its config file. Flags and environment variables
+// still take precedence. The profile's account settings replace the
+// top-level ones entirely; other settings are shared by all profiles.
+func ApplyProfile(v *viper.Viper, name string) error {
+	if name == "" || name == DefaultProfile {
+		return nil
+	}
+	if !v.IsSet("profiles." + name) {
+		return fmt.Errorf("%w: %s", ErrProfileNotFound, name)
+	}
+
+	// A fresh instance holds only the file's values, without flags,
+	// environment or defaults
+	file := viper.New()
+	file.SetConfigFile(v.ConfigFileUsed())
+	if err := file.ReadInConfig(); err != nil {
+		return err
+	}
+
+	settings := file.AllSettings()
+	for _, key := range ProfileKeys {
+		deleteKey(settings, strings.Split(key, "."))
+	}
+	profile := file.GetStringMap("profiles." + name)
+	mergeSettings(settings, profile)
+
+	data, err := yaml.Marshal(settings)
+	if err != nil {
+		return err
+	}
+	v.SetConfigType("yaml")
+	return v.ReadConfig(bytes.NewReader(data))
+}
+
+func deleteKey(settings map[string]interface{}, path []string) {
+	if len(path) == 1 {
+		delete(settings, path[0])
+		return
+	}
+	if child, ok := settings[path[0]].(map[string]interface{}); ok {
+		deleteKey(child, path[1:])
+	}
+}
+
+func mergeSettings(dst, src map[string]interface{}) {
+	for key, value := range src {
+		srcChild, srcIsMap := value.(map[string]interface{})
+		dstChild, dstIsMap := dst[key].(map[string]interface{})
+		if srcIsMap && dstIsMap {
+			mergeSettings(dstChild, srcChild)
+			continue
+		}
+		dst[key] = value
+	}
+}
@@ -0,0 +1,247 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/cast"
+	"github.com/spf13/viper"
+)
+
+// EnvPrefix is prepended to setting names to form their environment
+// variables, e.g. DARKSTORAGE_DAEMON_LOG_LEVEL for daemon.log_level
+const EnvPrefix = "DARKSTORAGE"
+
+// Setting describes one configuration key
+type Setting struct {
+	Key string
+	// Default also fixes the value's type
+	Default interface{}
+	// Env lists older environment variables still honoured besides
+	// DARKSTORAGE_<KEY>
+	Env         []string
+	Description string
+}
+
+// dataPath is a default for a file in the data directory
+type dataPath string
+
+// Schema lists every setting in config.yaml. sync_folders, profiles,
+// hooks, daemon.log_levels and daemon.bandwidth_schedule hold structured
+// values and are checked by Config.Validate instead.
+var Schema = []Setting{
+	{Key: "profile", Default: "", Description: "profile used when --profile isn't given"},
+	{Key: "endpoint", Default: "https://api.darkstorage.io", Description: "API endpoint"},
+	{Key: "api_key", Default: "", Description: "API key or OAuth access token (kept in the credential store)"},
+	{Key: "refresh_token", Default: "", Description: "OAuth refresh token (kept in the credential store)"},
+	{Key: "token_expiry", Default: "", Description: "expiry of the OAuth access token, RFC 3339"},
+	{Key: "encryption", Default: "", Description: "server-side encryption for uploads: none or sse-s3"},
+	{Key: "bucket", Default: "", Description: "default bucket for put"},
+	{Key: "json", Default: false, Description: "output in JSON format"},
+	{Key: "trace", Default: false, Description: "dump API requests and responses to stderr"},
+
+	{Key: "storage.endpoint", Default: "localhost:9000", Description: "storage (S3) endpoint"},
+	{Key: "storage.region", Default: "us-east-1", Description: "storage region"},
+	{Key: "storage.use_ssl", Default: false, Env: []string{"DARKSTORAGE_USE_SSL"}, Description: "use TLS for storage"},
+	{Key: "storage.access_key", Default: "", Env: []string{"DARKSTORAGE_ACCESS_KEY"}, Description: "storage access key (kept in the credential store)"},
+	{Key: "storage.secret_key", Default: "", Env: []string{"DARKSTORAGE_SECRET_KEY"}, Description: "storage secret key (kept in the credential store)"},
+
+	{Key: "http.max_retries", Default: 4, Description: "retries for failed API requests"},
+	{Key: "http.rate_limit", Default: 0.0, Description: "maximum API requests per second, 0 for unlimited"},
+	{Key: "http.burst", Default: 5, Description: "requests allowed at once above the rate limit"},
+
+	{Key: "transfer.limit_up", Default: 0, Description: "upload limit in KB/s shared by a command's transfers, 0 for unlimited"},
+	{Key: "transfer.limit_down", Default: 0, Description: "download limit in KB/s shared by a command's transfers, 0 for unlimited"},
+
+	{Key: "oauth.authorize_url", Default: "", Description: "OAuth authorization endpoint"},
+	{Key: "oauth.token_url", Default: "", Description: "OAuth token endpoint (default <endpoint>/v1/oauth/token)"},
+	{Key: "oauth.device_url", Default: "", Description: "OAuth device authorization endpoint (default <endpoint>/v1/oauth/device/code)"},
+
+	{Key: "credentials.helper", Default: "", Description: "external credential helper instead of the encrypted file"},
+
+	{Key: "daemon.enabled", Default: true, Description: "start the sync daemon"},
+	{Key: "daemon.log_level", Default: "info", Description: "debug, info, warn or error"},
+	{Key: "daemon.log_file", Default: dataPath("daemon.log"), Description: "daemon log file"},
+	{Key: "daemon.log_format", Default: "text", Description: "log format: text or json"},
+	{Key: "daemon.log_max_size", Default: 10, Description: "rotate the log file at this many MB, 0 for no limit"},
+	{Key: "daemon.log_rotate_interval", Default: 24 * time.Hour, Description: "also rotate the log file this often, 0 to rotate by size only"},
+	{Key: "daemon.log_max_files", Default: 7, Description: "rotated log files to keep, 0 for no limit"},
+	{Key: "daemon.log_max_age", Default: 30 * 24 * time.Hour, Description: "remove rotated log files older than this, 0 to keep them"},
+	{Key: "daemon.pid_file", Default: dataPath("daemon.pid"), Description: "daemon PID file"},
+	{Key: "daemon.ipc_socket", Default: dataPath("daemon.sock"), Description: "daemon control socket"},
+	{Key: "daemon.worker_threads", Default: 4, Description: "parallel transfers"},
+	{Key: "daemon.max_queue_size", Default: 1000, Description: "maximum queued operations"},
+	{Key: "daemon.debounce_delay", Default: 3 * time.Second, Description: "wait for changes to settle before syncing"},
+	{Key: "daemon.timeout", Default: 30 * time.Second, Description: "timeout for each transfer"},
+	{Key: "daemon.retry_attempts", Default: 3, Description: "retries for failed transfers"},
+	{Key: "daemon.retry_delay", Default: 5 * time.Second, Description: "delay between retries"},
+	{Key: "daemon.shutdown_timeout", Default: 30 * time.Second, Description: "wait for transfers to finish when stopping"},
+	{Key: "daemon.bandwidth_limit_up", Default: 0, Description: "upload limit in KB/s shared by all transfers, 0 for unlimited"},
+	{Key: "daemon.bandwidth_limit_down", Default: 0, Description: "download limit in KB/s shared by all transfers, 0 for unlimited"},
+	{Key: "daemon.metrics_address", Default: "", Description: "loopback address for metrics and health checks, e.g. 127.0.0.1:9464"},
+
+	{Key: "notifications.enabled", Default: true, Description: "show desktop notifications"},
+	{Key: "notifications.show_success", Default: false, Description: "notify about completed syncs"},
+	{Key: "notifications.show_errors", Default: true, Description: "notify about errors"},
+	{Key: "notifications.show_conflicts", Default: true, Description: "notify about conflicts"},
+
+	{Key: "anomaly_detection.enabled", Default: true, Description: "pause folders when many files change at once"},
+	{Key: "anomaly_detection.window", Default: time.Minute, Description: "period over which changes are counted"},
+	{Key: "anomaly_detection.min_files", Default: 20, Description: "changes needed before the threshold applies"},
+	{Key: "anomaly_detection.threshold", Default: 0.5, Description: "anomaly score (0-1) of the latest changes that pauses a folder"},
+
+	{Key: "throttling.metered_interfaces", Default: []string{}, Description: "patterns for metered network interfaces, e.g. usb* for a tethered phone"},
+	{Key: "throttling.metered_command", Default: []string{}, Description: "command that exits 0 when the connection is metered"},
+	{Key: "throttling.on_metered", Default: "pause", Description: "on a metered connection: none, throttle or pause"},
+	{Key: "throttling.on_battery", Default: "none", Description: "on battery: none, throttle or pause"},
+	{Key: "throttling.max_load", Default: 0.0, Description: "load average per CPU above which load is high, 0 to ignore load"},
+	{Key: "throttling.on_high_load", Default: "throttle", Description: "under high load: none, throttle or pause"},
+	{Key: "throttling.limit_up", Default: 128, Description: "upload limit in KB/s while throttled, 0 for unlimited"},
+	{Key: "throttling.limit_down", Default: 256, Description: "download limit in KB/s while throttled, 0 for unlimited"},
+	{Key: "throttling.check_interval", Default: 30 * time.Second, Description: "how often network, power and load are checked"},
+
+	{Key: "metadata.preserve_owner", Default: false, Description: "preserve file owner and group"},
+	{Key: "metadata.xattrs", Default: []string{"user.*"}, Description: "extended attributes to preserve"},
+}
+
+// structuredKeys hold lists or maps validated by Config.Validate
+var structuredKeys = []string{"sync_folders", "profiles", "hooks", "daemon.log_levels", "daemon.bandwidth_schedule"}
+
+// Setup configures v the way every Dark Storage program reads settings:
+// schema defaults, with files kept in dir, overridden by DARKSTORAGE_*
+// environment variables
+func Setup(v *viper.Viper, dir string) {
+	v.SetEnvPrefix(EnvPrefix)
+	// Nested keys such as oauth.token_url map to DARKSTORAGE_OAUTH_TOKEN_URL
+	v.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
+	v.AutomaticEnv()
+
+	for _, s := range Schema {
+		def := s.Default
+		if p, ok := def.(dataPath); ok {
+			def = filepath.Join(dir, string(p))
+		}
+		v.SetDefault(s.Key, def)
+		if len(s.Env) > 0 {
+			v.BindEnv(append([]string{s.Key, EnvName(s.Key)}, s.Env...)...)
+		}
+	}
+}
+
+// MoveLegacyEnv hands DARKSTORAGE_ENDPOINT to storage.endpoint when it
+// holds a host:port with no scheme, which older releases read as the
+// storage endpoint, so it isn't taken for the API endpoint. Call it before
+// Setup. It returns a warning to show, "" if nothing was moved.
+func MoveLegacyEnv() string {
+	name, storage := EnvName("endpoint"), EnvName("storage.endpoint")
+	value := os.Getenv(name)
+	if value == "" || strings.Contains(value, "://") {
+		return ""
+	}
+	os.Unsetenv(name)
+	if os.Getenv(storage) == "" {
+		os.Setenv(storage, value)
+	}
+	return fmt.Sprintf("%s=%s is used as the storage endpoint; set %s instead, %s is the API URL",
+		name, value, storage, name)
+}
+
+// EnvName returns the environment variable that overrides key
+func EnvName(key string) string {
+	return EnvPrefix + "_" + strings.ToUpper(strings.ReplaceAll(key, ".", "_"))
+}
+
+// LookupSetting returns the schema entry for key
+func LookupSetting(key string) (Setting, bool) {
+	key = strings.ToLower(key)
+	for _, s := range Schema {
+		if s.Key == key {
+			return s, true
+		}
+	}
+	return Setting{}, false
+}
+
+// checkTypes reports settings whose values can't be read as the type of
+// their default, which viper would otherwise silently turn into zero
+func checkTypes(v *viper.Viper) ValidationError {
+	var errs ValidationError
+	for _, s := range Schema {
+		value := v.Get(s.Key)
+		if value == nil {
+			continue
+		}
+		if msg := typeError(s.Default, value); msg != "" {
+			errs = append(errs, &FieldError{Key: s.Key, Message: msg})
+		}
+	}
+	return errs
+}
+
+func typeError(def, value interface{}) string {
+	var err error
+	var want string
+	switch def.(type) {
+	case bool:
+		want = "true or false"
+		_, err = cast.ToBoolE(value)
+	case int:
+		want = "an integer"
+		_, err = cast.ToIntE(value)
+	case float64:
+		want = "a number"
+		_, err = cast.ToFloat64E(value)
+	case time.Duration:
+		want = `a duration such as "30s" or "5m"`
+		_, err = cast.ToDurationE(value)
+	case []string:
+		want = "a list of strings"
+		_, err = cast.ToStringSliceE(value)
+	default:
+		want = "a string"
+		switch value.(type) {
+		case map[string]interface{}, []interface{}:
+			err = fmt.Errorf("not a scalar")
+		}
+	}
+	if err != nil {
+		return fmt.Sprintf("%v is not %s", value, want)
+	}
+	return ""
+}
+
+// UnknownKeys returns the keys set in v that aren't part of the schema,
+// usually typos
+func UnknownKeys(v *viper.Viper) []string {
+	var unknown []string
+	for _, key := range v.AllKeys() {
+		if _, ok := LookupSetting(key); ok || isStructuredKey(key) {
+			continue
+		}
+		unknown = append(unknown, key)
+	}
+	sort.Strings(unknown)
+	return unknown
+}
+
+func isStructuredKey(key string) bool {
+	// profiles.<name>.<setting>
+	if parts := strings.SplitN(key, ".", 3); parts[0] == "profiles" && len(parts) == 3 {
+		for _, k := range ProfileKeys {
+			if parts[2] == k {
+				return true
+			}
+		}
+		return false
+	}
+	for _, prefix := range structuredKeys {
+		if key == prefix || strings.HasPrefix(key, prefix+".") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,68 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+func TestMoveLegacyEnv(t *testing.T) {
+	tests := []struct {
+		name            string
+		endpoint        string
+		storageEndpoint string
+		wantAPI         string
+		wantStorage     string
+		wantWarning     bool
+	}{
+		{
+			name:        "unset",
+			wantAPI:     "https://api.darkstorage.io",
+			wantStorage: "localhost:9000",
+		},
+		{
+			name:        "API URL",
+			endpoint:    "https://api.example.com",
+			wantAPI:     "https://api.example.com",
+			wantStorage: "localhost:9000",
+		},
+		{
+			name:        "storage host:port",
+			endpoint:    "minio.local:9000",
+			wantAPI:     "https://api.darkstorage.io",
+			wantStorage: "minio.local:9000",
+			wantWarning: true,
+		},
+		{
+			name:            "storage endpoint set both ways",
+			endpoint:        "old.local:9000",
+			storageEndpoint: "new.local:9000",
+			wantAPI:         "https://api.darkstorage.io",
+			wantStorage:     "new.local:9000",
+			wantWarning:     true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(EnvName("endpoint"), tt.endpoint)
+			t.Setenv(EnvName("storage.endpoint"), tt.storageEndpoint)
+
+			warning := MoveLegacyEnv()
+			if (warning != "") != tt.wantWarning {
+				t.Errorf("MoveLegacyEnv() = %q, want a warning %v", warning, tt.wantWarning)
+			}
+
+			v := viper.New()
+			Setup(v, t.TempDir())
+			if got := v.GetString("endpoint"); got != tt.wantAPI {
+				t.Errorf("endpoint = %q, want %q", got, tt.wantAPI)
+			}
+			if got := v.GetString("storage.endpoint"); got != tt.wantStorage {
+				t.Errorf("storage.endpoint = %q, want %q", got, tt.wantStorage)
+			}
+			if _, err := Load(v); err != nil {
+				t.Errorf("Load() error = %v", err)
+			}
+		})
+	}
+}
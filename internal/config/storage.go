@@ -2,7 +2,6 @@ package config
 
 import (
 	"fmt"
-	"os"
 
 	"github.com/spf13/viper"
 )
@@ -16,52 +15,26 @@ type StorageConfig struct {
 	Region    string `mapstructure:"region"`
 }
 
-// LoadStorageConfig loads storage configuration
+// LoadStorageConfig loads storage configuration. There are no default
+// credentials: the keys come from the environment, or from the credential
+// store via `darkstorage config set --storage-access-key/--storage-secret-key`.
 func LoadStorageConfig() (*StorageConfig, error) {
-	// Default config
-	cfg := &StorageConfig{
-		Endpoint:  "localhost:9000", // Local MinIO for testing
-		AccessKey: "darkstorage",
-		SecretKey: "darkstorage123",
-		UseSSL:    false, // Local testing without SSL
-		Region:    "us-east-1",
-	}
-
-	// Override with environment variables
-	if endpoint := os.Getenv("DARKSTORAGE_ENDPOINT"); endpoint != "" {
-		cfg.Endpoint = endpoint
-	}
-	if accessKey := os.Getenv("DARKSTORAGE_ACCESS_KEY"); accessKey != "" {
-		cfg.AccessKey = accessKey
-	}
-	if secretKey := os.Getenv("DARKSTORAGE_SECRET_KEY"); secretKey != "" {
-		cfg.SecretKey = secretKey
-	}
-	if sslStr := os.Getenv("DARKSTORAGE_USE_SSL"); sslStr == "true" {
-		cfg.UseSSL = true
-	}
+	return LoadStorageConfigFrom(viper.GetViper())
+}
 
-	// Override with viper config (from ~/.darkstorage/config.yaml)
-	if viper.IsSet("storage.endpoint") {
-		cfg.Endpoint = viper.GetString("storage.endpoint")
-	}
-	if viper.IsSet("storage.access_key") {
-		cfg.AccessKey = viper.GetString("storage.access_key")
-	}
-	if viper.IsSet("storage.secret_key") {
-		cfg.SecretKey = viper.GetString("storage.secret_key")
-	}
-	if viper.IsSet("storage.use_ssl") {
-		cfg.UseSSL = viper.GetBool("storage.use_ssl")
-	}
-	if viper.IsSet("storage.region") {
-		cfg.Region = viper.GetString("storage.region")
+// LoadStorageConfigFrom loads storage configuration from v, e.g. one with a
+// profile applied. Defaults and environment overrides come from Setup.
+func LoadStorageConfigFrom(v *viper.Viper) (*StorageConfig, error) {
+	cfg := &StorageConfig{
+		Endpoint:  v.GetString("storage.endpoint"),
+		AccessKey: v.GetString("storage.access_key"),
+		SecretKey: v.GetString("storage.secret_key"),
+		UseSSL:    v.GetBool("storage.use_ssl"),
+		Region:    v.GetString("storage.region"),
 	}
 
-	// Validate
 	if cfg.AccessKey == "" || cfg.SecretKey == "" {
-		return nil, fmt.Errorf("storage credentials not configured")
+		return nil, fmt.Errorf("storage credentials not configured (run 'darkstorage config set --storage-access-key KEY --storage-secret-key SECRET')")
 	}
-
 	return cfg, nil
 }
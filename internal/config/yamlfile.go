@@ -0,0 +1,201 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/darkstorage/cli/internal/atomicfile"
+	"gopkg.in/yaml.v3"
+)
+
+// YAMLFile edits a YAML config file in place, keeping comments and the
+// order of keys. Keys are dotted paths such as storage.endpoint; Get and
+// Remove also take list indexes, as in hooks.0.secret.
+type YAMLFile struct {
+	path string
+	root *yaml.Node
+}
+
+// OpenYAML reads path. A missing or empty file gives an empty document.
+func OpenYAML(path string) (*YAMLFile, error) {
+	f := &YAMLFile{path: path, root: &yaml.Node{Kind: yaml.MappingNode}}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return f, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	if len(doc.Content) > 0 {
+		if doc.Content[0].Kind != yaml.MappingNode {
+			return nil, fmt.Errorf("parsing %s: top level is not a mapping", path)
+		}
+		f.root = doc.Content[0]
+	}
+	return f, nil
+}
+
+// Get returns the scalar at key
+func (f *YAMLFile) Get(key string) (string, bool) {
+	node := f.find(key)
+	if node == nil || node.Kind != yaml.ScalarNode {
+		return "", false
+	}
+	return node.Value, true
+}
+
+// Keys returns the names under the mapping at key, or the top-level names
+// when key is empty
+func (f *YAMLFile) Keys(key string) []string {
+	node := f.root
+	if key != "" {
+		node = f.find(key)
+	}
+	if node == nil || node.Kind != yaml.MappingNode {
+		return nil
+	}
+	var keys []string
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		keys = append(keys, node.Content[i].Value)
+	}
+	return keys
+}
+
+// Set stores value at key, creating parent mappings as needed
+func (f *YAMLFile) Set(key string, value interface{}) error {
+	var encoded yaml.Node
+	if err := encoded.Encode(value); err != nil {
+		return err
+	}
+
+	node := f.root
+	path := strings.Split(key, ".")
+	for i, name := range path {
+		child := lookupNode(node, name)
+		if i == len(path)-1 {
+			if child != nil {
+				// Keep comments attached to the old value
+				encoded.HeadComment, encoded.LineComment = child.HeadComment, child.LineComment
+				*child = encoded
+			} else {
+				node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: name}, &encoded)
+			}
+			return nil
+		}
+		if child == nil {
+			child = &yaml.Node{Kind: yaml.MappingNode}
+			node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: name}, child)
+		}
+		if child.Kind != yaml.MappingNode {
+			return fmt.Errorf("%s: %s is not a mapping", key, strings.Join(path[:i+1], "."))
+		}
+		node = child
+	}
+	return nil
+}
+
+// Remove deletes key, and parent mappings left empty by it. It reports
+// whether key was present.
+func (f *YAMLFile) Remove(key string) bool {
+	return removeNode(f.root, strings.Split(key, "."))
+}
+
+// Save writes the file atomically, readable only by the owner
+func (f *YAMLFile) Save() error {
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(4)
+	if err := enc.Encode(f.root); err != nil {
+		return err
+	}
+	if err := enc.Close(); err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(f.path), 0700); err != nil {
+		return err
+	}
+	out, err := atomicfile.Create(f.path)
+	if err != nil {
+		return err
+	}
+	if _, err := out.Write(buf.Bytes()); err != nil {
+		out.Abort()
+		return err
+	}
+	return out.Commit(atomicfile.ExpectSize(int64(buf.Len())))
+}
+
+// find returns the node at key. Names index mappings and numbers
+// sequences, e.g. hooks.0.secret.
+func (f *YAMLFile) find(key string) *yaml.Node {
+	node := f.root
+	for _, name := range strings.Split(key, ".") {
+		switch node.Kind {
+		case yaml.MappingNode:
+			node = lookupNode(node, name)
+		case yaml.SequenceNode:
+			node = sequenceItem(node, name)
+		default:
+			return nil
+		}
+		if node == nil {
+			return nil
+		}
+	}
+	return node
+}
+
+func lookupNode(mapping *yaml.Node, name string) *yaml.Node {
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == name {
+			return mapping.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// sequenceItem returns the item at index, a decimal string
+func sequenceItem(sequence *yaml.Node, index string) *yaml.Node {
+	i, err := strconv.Atoi(index)
+	if err != nil || i < 0 || i >= len(sequence.Content) {
+		return nil
+	}
+	return sequence.Content[i]
+}
+
+func removeNode(mapping *yaml.Node, path []string) bool {
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value != path[0] {
+			continue
+		}
+		if len(path) > 1 {
+			child := mapping.Content[i+1]
+			if child.Kind == yaml.SequenceNode {
+				// Keys are removed from items, but items are never removed
+				item := sequenceItem(child, path[1])
+				return len(path) > 2 && item != nil && item.Kind == yaml.MappingNode && removeNode(item, path[2:])
+			}
+			if child.Kind != yaml.MappingNode || !removeNode(child, path[1:]) {
+				return false
+			}
+			if len(child.Content) > 0 {
+				return true
+			}
+		}
+		mapping.Content = append(mapping.Content[:i], mapping.Content[i+2:]...)
+		return true
+	}
+	return false
+}
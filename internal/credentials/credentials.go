@@ -0,0 +1,132 @@
+// Package credentials keeps API keys, OAuth tokens and storage keys out of
+// config.yaml. Secrets are kept in an encrypted file, or handed to an
+// external helper program that speaks a git-credential style protocol.
+package credentials
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// PassphraseEnv names the environment variable holding the passphrase for
+// the encrypted file store
+const PassphraseEnv = "DARKSTORAGE_PASSPHRASE"
+
+// ErrNotFound means the store has no value for a key
+var ErrNotFound = errors.New("credential not found")
+
+// Keys lists the config keys that hold secrets. They are never written to
+// config.yaml. A profile's secrets are stored under profiles.<name>.<key>.
+var Keys = []string{
+	"api_key",
+	"refresh_token",
+	"storage.access_key",
+	"storage.secret_key",
+}
+
+// HookSecretKey returns the store key for the webhook secret of the hook
+// called name
+func HookSecretKey(name string) string {
+	return "hooks." + name + ".secret"
+}
+
+// IsSecret reports whether key, which may be a profiles.<name>.<key> or
+// hooks.<name>.secret path, belongs in the credential store
+func IsSecret(key string) bool {
+	if rest, ok := strings.CutPrefix(key, "hooks."); ok {
+		return strings.HasSuffix(rest, ".secret")
+	}
+	if rest, ok := strings.CutPrefix(key, "profiles."); ok {
+		if _, k, ok := strings.Cut(rest, "."); ok {
+			key = k
+		}
+	}
+	for _, k := range Keys {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
+
+// Store holds secrets by config key
+type Store interface {
+	// Get returns ErrNotFound when key has no value
+	Get(key string) (string, error)
+	Store(key, value string) error
+	// Erase succeeds when key has no value
+	Erase(key string) error
+	String() string
+}
+
+// Options selects and configures a store
+type Options struct {
+	// Dir holds the encrypted file and its master key
+	Dir string
+	// Helper, when set, names an external helper to use instead of the file
+	Helper string
+	// Passphrase, when set, derives the file's key instead of the master
+	// key file
+	Passphrase string
+}
+
+// Open returns the store described by opts
+func Open(opts Options) Store {
+	if opts.Helper != "" {
+		return NewHelperStore(opts.Helper)
+	}
+	return NewFileStore(
+		filepath.Join(opts.Dir, "credentials.enc"),
+		filepath.Join(opts.Dir, "master.key"),
+		opts.Passphrase,
+	)
+}
+
+// Set stores value, or erases key when value is empty
+func Set(store Store, key, value string) error {
+	if value == "" {
+		return store.Erase(key)
+	}
+	return store.Store(key, value)
+}
+
+// Load sets each secret v doesn't already have from flags, environment or
+// config to its value in store, reading the named profile's secrets when
+// profile isn't empty
+func Load(v *viper.Viper, store Store, profile string) error {
+	for _, key := range Keys {
+		if v.GetString(key) != "" {
+			continue
+		}
+		value, err := store.Get(StoreKey(profile, key))
+		if errors.Is(err, ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		v.Set(key, value)
+	}
+	return nil
+}
+
+// HookSecret returns the webhook secret stored for the hook called name,
+// "" if there is none
+func HookSecret(store Store, name string) (string, error) {
+	value, err := store.Get(HookSecretKey(name))
+	if errors.Is(err, ErrNotFound) {
+		return "", nil
+	}
+	return value, err
+}
+
+// StoreKey returns the store key for a profile's secret
+func StoreKey(profile, key string) string {
+	if profile == "" {
+		return key
+	}
+	return "profiles." + profile + "." + key
+}
@@ -0,0 +1,275 @@
+package credentials
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/darkstorage/cli/internal/atomicfile"
+	"golang.org/x/crypto/scrypt"
+)
+
+// ErrPassphraseRequired means the file was sealed with a passphrase that
+// hasn't been provided
+var ErrPassphraseRequired = fmt.Errorf("credentials are passphrase protected, set %s", PassphraseEnv)
+
+const (
+	fileVersion = 1
+	kdfKeyFile  = "keyfile"
+	kdfScrypt   = "scrypt"
+	keySize     = 32
+)
+
+// sealedFile is the on-disk format. Data is the AES-256-GCM sealed JSON
+// object of secrets, with the version and KDF as additional data.
+type sealedFile struct {
+	Version int    `json:"version"`
+	KDF     string `json:"kdf"`
+	Salt    []byte `json:"salt,omitempty"`
+	Nonce   []byte `json:"nonce"`
+	Data    []byte `json:"data"`
+}
+
+// FileStore keeps secrets in a file encrypted with AES-256-GCM. The key is
+// derived from a passphrase with scrypt when one is given, otherwise it is
+// a random master key kept next to the file. The master key only guards
+// against the file being shared or backed up on its own; use a passphrase
+// (or a helper) to protect against someone who can read the whole
+// directory.
+type FileStore struct {
+	mu         sync.Mutex
+	path       string
+	keyPath    string
+	passphrase string
+
+	// scrypt is slow on purpose, so keep the last derived key
+	salt       []byte
+	derivedKey []byte
+}
+
+// NewFileStore returns a store in path. keyPath is the master key file,
+// created on first write when no passphrase is set.
+func NewFileStore(path, keyPath, passphrase string) *FileStore {
+	return &FileStore{path: path, keyPath: keyPath, passphrase: passphrase}
+}
+
+func (s *FileStore) String() string {
+	if s.passphrase != "" {
+		return "encrypted file " + s.path + " (passphrase)"
+	}
+	return "encrypted file " + s.path
+}
+
+// Get returns the secret for key
+func (s *FileStore) Get(key string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	secrets, _, err := s.read()
+	if err != nil {
+		return "", err
+	}
+	value, ok := secrets[key]
+	if !ok {
+		return "", ErrNotFound
+	}
+	return value, nil
+}
+
+// Store saves the secret for key
+func (s *FileStore) Store(key, value string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	secrets, sealed, err := s.read()
+	if err != nil {
+		return err
+	}
+	secrets[key] = value
+	return s.write(secrets, sealed)
+}
+
+// Erase removes the secret for key
+func (s *FileStore) Erase(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	secrets, sealed, err := s.read()
+	if err != nil {
+		return err
+	}
+	if _, ok := secrets[key]; !ok {
+		return nil
+	}
+	delete(secrets, key)
+	return s.write(secrets, sealed)
+}
+
+// read returns the decrypted secrets and the file they came from, which is
+// nil when the file doesn't exist yet
+func (s *FileStore) read() (map[string]string, *sealedFile, error) {
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return make(map[string]string), nil, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var sealed sealedFile
+	if err := json.Unmarshal(data, &sealed); err != nil {
+		return nil, nil, fmt.Errorf("reading %s: %w", s.path, err)
+	}
+	if sealed.Version != fileVersion {
+		return nil, nil, fmt.Errorf("reading %s: unsupported version %d", s.path, sealed.Version)
+	}
+
+	key, err := s.key(sealed.KDF, sealed.Salt, false)
+	if err != nil {
+		return nil, nil, err
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	plaintext, err := gcm.Open(nil, sealed.Nonce, sealed.Data, additionalData(&sealed))
+	if err != nil {
+		if sealed.KDF == kdfScrypt {
+			return nil, nil, fmt.Errorf("decrypting %s: wrong passphrase", s.path)
+		}
+		return nil, nil, fmt.Errorf("decrypting %s: %w", s.path, err)
+	}
+
+	secrets := make(map[string]string)
+	if err := json.Unmarshal(plaintext, &secrets); err != nil {
+		return nil, nil, fmt.Errorf("reading %s: %w", s.path, err)
+	}
+	return secrets, &sealed, nil
+}
+
+// write seals secrets and replaces the file. A passphrase, when set, is
+// always used, so setting one upgrades a master-key file on the next write.
+func (s *FileStore) write(secrets map[string]string, previous *sealedFile) error {
+	sealed := &sealedFile{Version: fileVersion, KDF: kdfKeyFile}
+	if s.passphrase != "" {
+		sealed.KDF = kdfScrypt
+		if previous != nil && previous.KDF == kdfScrypt {
+			sealed.Salt = previous.Salt
+		} else {
+			sealed.Salt = make([]byte, 16)
+			if _, err := rand.Read(sealed.Salt); err != nil {
+				return err
+			}
+		}
+	}
+
+	key, err := s.key(sealed.KDF, sealed.Salt, true)
+	if err != nil {
+		return err
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return err
+	}
+
+	plaintext, err := json.Marshal(secrets)
+	if err != nil {
+		return err
+	}
+	sealed.Nonce = make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, sealed.Nonce); err != nil {
+		return err
+	}
+	sealed.Data = gcm.Seal(nil, sealed.Nonce, plaintext, additionalData(sealed))
+
+	data, err := json.MarshalIndent(sealed, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFile(s.path, append(data, '\n'))
+}
+
+// key returns the AES key for a file sealed with kdf. create allows
+// generating a missing master key.
+func (s *FileStore) key(kdf string, salt []byte, create bool) ([]byte, error) {
+	switch kdf {
+	case kdfScrypt:
+		if s.passphrase == "" {
+			return nil, ErrPassphraseRequired
+		}
+		if s.derivedKey != nil && string(s.salt) == string(salt) {
+			return s.derivedKey, nil
+		}
+		key, err := scrypt.Key([]byte(s.passphrase), salt, 1<<15, 8, 1, keySize)
+		if err != nil {
+			return nil, err
+		}
+		s.salt, s.derivedKey = salt, key
+		return key, nil
+
+	case kdfKeyFile:
+		key, err := os.ReadFile(s.keyPath)
+		if errors.Is(err, os.ErrNotExist) && create {
+			return s.createMasterKey()
+		}
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("master key %s is missing, credentials cannot be decrypted", s.keyPath)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(key) != keySize {
+			return nil, fmt.Errorf("master key %s is corrupt", s.keyPath)
+		}
+		return key, nil
+
+	default:
+		return nil, fmt.Errorf("reading %s: unknown key derivation %q", s.path, kdf)
+	}
+}
+
+func (s *FileStore) createMasterKey() ([]byte, error) {
+	key := make([]byte, keySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	if err := writeFile(s.keyPath, key); err != nil {
+		return nil, fmt.Errorf("creating master key: %w", err)
+	}
+	return key, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func additionalData(sealed *sealedFile) []byte {
+	return []byte(fmt.Sprintf("darkstorage-credentials/%d/%s", sealed.Version, sealed.KDF))
+}
+
+// writeFile replaces path with data, readable only by the owner
+func writeFile(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	f, err := atomicfile.Create(path)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Abort()
+		return err
+	}
+	return f.Commit(atomicfile.ExpectSize(int64(len(data))))
+}
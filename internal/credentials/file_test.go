@@ -0,0 +1,197 @@
+package credentials
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testSecret = "s3cr3t-value"
+
+// editSealed rewrites the sealed file at path through edit
+func editSealed(t *testing.T, path string, edit func(*sealedFile)) {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sealed sealedFile
+	if err := json.Unmarshal(data, &sealed); err != nil {
+		t.Fatal(err)
+	}
+	edit(&sealed)
+	if data, err = json.Marshal(&sealed); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFileStoreRoundTrip(t *testing.T) {
+	tests := []struct {
+		name string
+		// writePassphrase seals the file, readPassphrase opens it again
+		writePassphrase string
+		readPassphrase  string
+		// tamper changes the files between writing and reading
+		tamper     func(t *testing.T, path, keyPath string)
+		wantErr    error
+		wantErrMsg string
+	}{
+		{name: "master key"},
+		{name: "passphrase", writePassphrase: "hunter2", readPassphrase: "hunter2"},
+		{name: "wrong passphrase", writePassphrase: "hunter2", readPassphrase: "hunter3", wantErrMsg: "wrong passphrase"},
+		{name: "passphrase not given", writePassphrase: "hunter2", wantErr: ErrPassphraseRequired},
+		{
+			name: "master key missing",
+			tamper: func(t *testing.T, path, keyPath string) {
+				os.Remove(keyPath)
+			},
+			wantErrMsg: "is missing",
+		},
+		{
+			name: "master key corrupt",
+			tamper: func(t *testing.T, path, keyPath string) {
+				os.WriteFile(keyPath, []byte("short"), 0600)
+			},
+			wantErrMsg: "is corrupt",
+		},
+		{
+			name: "ciphertext modified",
+			tamper: func(t *testing.T, path, keyPath string) {
+				editSealed(t, path, func(s *sealedFile) { s.Data[0] ^= 1 })
+			},
+			wantErrMsg: "decrypting",
+		},
+		{
+			name: "key derivation swapped",
+			tamper: func(t *testing.T, path, keyPath string) {
+				editSealed(t, path, func(s *sealedFile) { s.KDF = "rot13" })
+			},
+			wantErrMsg: "unknown key derivation",
+		},
+		{
+			name: "unsupported version",
+			tamper: func(t *testing.T, path, keyPath string) {
+				editSealed(t, path, func(s *sealedFile) { s.Version = 2 })
+			},
+			wantErrMsg: "unsupported version",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			path, keyPath := filepath.Join(dir, "credentials"), filepath.Join(dir, "credentials.key")
+
+			writer := NewFileStore(path, keyPath, tt.writePassphrase)
+			if err := writer.Store("default.token", testSecret); err != nil {
+				t.Fatalf("Store() error = %v", err)
+			}
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if bytes.Contains(data, []byte(testSecret)) {
+				t.Fatal("secret written in plain text")
+			}
+			if tt.tamper != nil {
+				tt.tamper(t, path, keyPath)
+			}
+
+			got, err := NewFileStore(path, keyPath, tt.readPassphrase).Get("default.token")
+			switch {
+			case tt.wantErr != nil:
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("Get() error = %v, want %v", err, tt.wantErr)
+				}
+			case tt.wantErrMsg != "":
+				if err == nil || !strings.Contains(err.Error(), tt.wantErrMsg) {
+					t.Fatalf("Get() error = %v, want one containing %q", err, tt.wantErrMsg)
+				}
+			case err != nil:
+				t.Fatalf("Get() error = %v", err)
+			case got != testSecret:
+				t.Errorf("Get() = %q, want %q", got, testSecret)
+			}
+		})
+	}
+}
+
+func TestFileStoreKeys(t *testing.T) {
+	dir := t.TempDir()
+	store := NewFileStore(filepath.Join(dir, "credentials"), filepath.Join(dir, "credentials.key"), "")
+
+	if _, err := store.Get("default.token"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Get() on a new store error = %v, want %v", err, ErrNotFound)
+	}
+	for _, key := range []string{"default.token", "default.refresh_token", "work.token"} {
+		if err := store.Store(key, key+"-value"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := store.Erase("default.refresh_token"); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Erase("never.stored"); err != nil {
+		t.Fatalf("Erase() of a missing key error = %v", err)
+	}
+
+	tests := []struct {
+		key     string
+		want    string
+		wantErr error
+	}{
+		{key: "default.token", want: "default.token-value"},
+		{key: "work.token", want: "work.token-value"},
+		{key: "default.refresh_token", wantErr: ErrNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			got, err := store.Get(tt.key)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Get() error = %v, want %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Get() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFileStoreAddPassphrase(t *testing.T) {
+	dir := t.TempDir()
+	path, keyPath := filepath.Join(dir, "credentials"), filepath.Join(dir, "credentials.key")
+
+	if err := NewFileStore(path, keyPath, "").Store("default.token", testSecret); err != nil {
+		t.Fatal(err)
+	}
+	// The next write with a passphrase seals the file with it
+	if err := NewFileStore(path, keyPath, "hunter2").Store("work.token", "other"); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		passphrase string
+		wantErr    bool
+	}{
+		{name: "master key alone no longer opens it", wantErr: true},
+		{name: "passphrase opens it", passphrase: "hunter2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NewFileStore(path, keyPath, tt.passphrase).Get("default.token")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Get() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && got != testSecret {
+				t.Errorf("Get() = %q, want %q", got, testSecret)
+			}
+		})
+	}
+}
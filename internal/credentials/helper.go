@@ -0,0 +1,101 @@
+package credentials
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// helperPrefix is prepended to helper names that aren't paths, so
+// credentials.helper: pass runs darkstorage-credential-pass
+const helperPrefix = "darkstorage-credential-"
+
+// HelperStore delegates to an external program, in the style of git
+// credential helpers. The program is run with one of get, store or erase
+// as its last argument and reads attributes from stdin, one key=value per
+// line, ending with a blank line:
+//
+//	key=api_key
+//	value=secret    (store only)
+//
+// For get it prints value=<secret>, or nothing if it has no value. A
+// non-zero exit is an error.
+type HelperStore struct {
+	args []string
+}
+
+// NewHelperStore returns a store using helper, a program name with
+// optional arguments. Names without a path separator get the
+// darkstorage-credential- prefix.
+func NewHelperStore(helper string) *HelperStore {
+	args := strings.Fields(helper)
+	if len(args) > 0 && !strings.ContainsRune(args[0], '/') && !strings.ContainsRune(args[0], filepath.Separator) {
+		args[0] = helperPrefix + args[0]
+	}
+	return &HelperStore{args: args}
+}
+
+func (h *HelperStore) String() string {
+	return "helper " + strings.Join(h.args, " ")
+}
+
+// Get asks the helper for key
+func (h *HelperStore) Get(key string) (string, error) {
+	out, err := h.run("get", key, "")
+	if err != nil {
+		return "", err
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		if value, ok := strings.CutPrefix(scanner.Text(), "value="); ok && value != "" {
+			return value, nil
+		}
+	}
+	return "", ErrNotFound
+}
+
+// Store hands value to the helper
+func (h *HelperStore) Store(key, value string) error {
+	_, err := h.run("store", key, value)
+	return err
+}
+
+// Erase asks the helper to forget key
+func (h *HelperStore) Erase(key string) error {
+	_, err := h.run("erase", key, "")
+	return err
+}
+
+func (h *HelperStore) run(operation, key, value string) ([]byte, error) {
+	if len(h.args) == 0 {
+		return nil, fmt.Errorf("credential helper is not configured")
+	}
+	if strings.ContainsAny(key+value, "\n\x00") {
+		return nil, fmt.Errorf("credential %s contains a newline", key)
+	}
+
+	var input bytes.Buffer
+	fmt.Fprintf(&input, "key=%s\n", key)
+	if operation == "store" {
+		fmt.Fprintf(&input, "value=%s\n", value)
+	}
+	input.WriteString("\n")
+
+	cmd := exec.Command(h.args[0], append(h.args[1:], operation)...)
+	cmd.Stdin = &input
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("credential helper %s %s: %s", h.args[0], operation, msg)
+		}
+		return nil, fmt.Errorf("credential helper %s %s: %w", h.args[0], operation, err)
+	}
+	return stdout.Bytes(), nil
+}
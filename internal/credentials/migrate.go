@@ -0,0 +1,78 @@
+package credentials
+
+import (
+	"fmt"
+
+	"github.com/darkstorage/cli/internal/config"
+)
+
+// MigrateConfig moves plaintext secrets out of the YAML config file at path
+// into store, then rewrites the file without them. Comments and the order
+// of other settings are kept. It returns the keys that were moved; a
+// missing file is not an error.
+func MigrateConfig(store Store, path string) ([]string, error) {
+	file, err := config.OpenYAML(path)
+	if err != nil {
+		return nil, err
+	}
+
+	// Store everything before touching the file, so a failure leaves the
+	// secrets where they were
+	var found, moved []string
+	for _, secret := range secretPaths(file) {
+		value, ok := file.Get(secret.path)
+		if !ok {
+			continue
+		}
+		found = append(found, secret.path)
+		if value == "" {
+			continue
+		}
+		if err := store.Store(secret.key, value); err != nil {
+			return nil, fmt.Errorf("storing %s: %w", secret.key, err)
+		}
+		moved = append(moved, secret.key)
+	}
+	if len(found) == 0 {
+		return nil, nil
+	}
+
+	// Empty values left by older versions are dropped too
+	for _, key := range found {
+		file.Remove(key)
+	}
+	if err := file.Save(); err != nil {
+		return nil, err
+	}
+	return moved, nil
+}
+
+// secretPath is where a secret may appear in the config, and its key in
+// the store
+type secretPath struct {
+	path string
+	key  string
+}
+
+// secretPaths returns the secrets that may appear in the config, those of
+// each profile and hook included
+func secretPaths(file *config.YAMLFile) []secretPath {
+	var paths []secretPath
+	for _, key := range Keys {
+		paths = append(paths, secretPath{key, key})
+	}
+	for _, profile := range file.Keys("profiles") {
+		for _, key := range Keys {
+			paths = append(paths, secretPath{StoreKey(profile, key), StoreKey(profile, key)})
+		}
+	}
+	// Hooks are a list, stored by name
+	for i := 0; ; i++ {
+		name, ok := file.Get(fmt.Sprintf("hooks.%d.name", i))
+		if !ok {
+			break
+		}
+		paths = append(paths, secretPath{fmt.Sprintf("hooks.%d.secret", i), HookSecretKey(name)})
+	}
+	return paths
+}
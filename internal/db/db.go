@@ -1,6 +1,7 @@
 package db
 
 import (
+	"context"
 	"database/sql"
 	"os"
 	"path/filepath"
@@ -19,7 +20,8 @@ func New(dataDir string) (*DB, error) {
 	}
 
 	dbPath := filepath.Join(dataDir, "darkstorage.db")
-	conn, err := sql.Open("sqlite3", dbPath)
+	// Foreign keys make deleting a folder remove its queue and file state
+	conn, err := sql.Open("sqlite3", dbPath+"?_foreign_keys=on")
 	if err != nil {
 		return nil, err
 	}
@@ -43,6 +45,12 @@ func (db *DB) Close() error {
 	return db.conn.Close()
 }
 
+// Ping checks that the database can be read
+func (db *DB) Ping(ctx context.Context) error {
+	var count int
+	return db.conn.QueryRowContext(ctx, "SELECT COUNT(*) FROM sync_folders").Scan(&count)
+}
+
 func (db *DB) Conn() *sql.DB {
 	return db.conn
 }
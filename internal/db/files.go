@@ -11,8 +11,8 @@ func (db *DB) UpsertFileState(state *FileState) error {
 		INSERT INTO file_states (
 			sync_folder_id, relative_path, local_hash, remote_hash,
 			local_modified_at, remote_modified_at, local_size, remote_size,
-			sync_status, last_synced_at, updated_at
-		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+			sync_status, dehydrated, last_synced_at, updated_at
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 		ON CONFLICT(sync_folder_id, relative_path) DO UPDATE SET
 			local_hash = excluded.local_hash,
 			remote_hash = excluded.remote_hash,
@@ -21,11 +21,12 @@ func (db *DB) UpsertFileState(state *FileState) error {
 			local_size = excluded.local_size,
 			remote_size = excluded.remote_size,
 			sync_status = excluded.sync_status,
+			dehydrated = excluded.dehydrated,
 			last_synced_at = excluded.last_synced_at,
 			updated_at = excluded.updated_at
 	`, state.SyncFolderID, state.RelativePath, state.LocalHash, state.RemoteHash,
 		state.LocalModifiedAt, state.RemoteModifiedAt, state.LocalSize, state.RemoteSize,
-		state.SyncStatus, state.LastSyncedAt, state.UpdatedAt)
+		state.SyncStatus, state.Dehydrated, state.LastSyncedAt, state.UpdatedAt)
 	return err
 }
 
@@ -34,12 +35,12 @@ func (db *DB) GetFileState(folderID int, path string) (*FileState, error) {
 	err := db.conn.QueryRow(`
 		SELECT id, sync_folder_id, relative_path, local_hash, remote_hash,
 			local_modified_at, remote_modified_at, local_size, remote_size,
-			sync_status, last_synced_at, created_at, updated_at
+			sync_status, dehydrated, last_synced_at, created_at, updated_at
 		FROM file_states WHERE sync_folder_id = ? AND relative_path = ?
 	`, folderID, path).Scan(
 		&state.ID, &state.SyncFolderID, &state.RelativePath, &state.LocalHash, &state.RemoteHash,
 		&state.LocalModifiedAt, &state.RemoteModifiedAt, &state.LocalSize, &state.RemoteSize,
-		&state.SyncStatus, &state.LastSyncedAt, &state.CreatedAt, &state.UpdatedAt,
+		&state.SyncStatus, &state.Dehydrated, &state.LastSyncedAt, &state.CreatedAt, &state.UpdatedAt,
 	)
 	if err == sql.ErrNoRows {
 		return nil, nil
@@ -51,7 +52,7 @@ func (db *DB) ListFileStates(folderID int, status string) ([]*FileState, error)
 	query := `
 		SELECT id, sync_folder_id, relative_path, local_hash, remote_hash,
 			local_modified_at, remote_modified_at, local_size, remote_size,
-			sync_status, last_synced_at, created_at, updated_at
+			sync_status, dehydrated, last_synced_at, created_at, updated_at
 		FROM file_states WHERE sync_folder_id = ?
 	`
 	args := []interface{}{folderID}
@@ -73,7 +74,7 @@ func (db *DB) ListFileStates(folderID int, status string) ([]*FileState, error)
 		err := rows.Scan(
 			&state.ID, &state.SyncFolderID, &state.RelativePath, &state.LocalHash, &state.RemoteHash,
 			&state.LocalModifiedAt, &state.RemoteModifiedAt, &state.LocalSize, &state.RemoteSize,
-			&state.SyncStatus, &state.LastSyncedAt, &state.CreatedAt, &state.UpdatedAt,
+			&state.SyncStatus, &state.Dehydrated, &state.LastSyncedAt, &state.CreatedAt, &state.UpdatedAt,
 		)
 		if err != nil {
 			return nil, err
@@ -91,3 +92,18 @@ func (db *DB) UpdateSyncStatus(id int, status string) error {
 	`, status, now, now, id)
 	return err
 }
+
+func (db *DB) SetFileDehydrated(folderID int, path string, dehydrated bool) error {
+	_, err := db.conn.Exec(`
+		UPDATE file_states SET dehydrated = ?, updated_at = ?
+		WHERE sync_folder_id = ? AND relative_path = ?
+	`, dehydrated, time.Now(), folderID, path)
+	return err
+}
+
+func (db *DB) DeleteFileState(folderID int, path string) error {
+	_, err := db.conn.Exec(`
+		DELETE FROM file_states WHERE sync_folder_id = ? AND relative_path = ?
+	`, folderID, path)
+	return err
+}
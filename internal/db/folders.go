@@ -9,10 +9,12 @@ func (db *DB) CreateSyncFolder(folder *SyncFolder) error {
 	result, err := db.conn.Exec(`
 		INSERT INTO sync_folders (
 			local_path, remote_path, direction, enabled,
-			conflict_resolution, exclude_patterns, bandwidth_limit, sync_interval
-		) VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+			conflict_resolution, exclude_patterns, bandwidth_limit, sync_interval,
+			include_paths, placeholders, profile, config_managed
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 	`, folder.LocalPath, folder.RemotePath, folder.Direction, folder.Enabled,
-		folder.ConflictResolution, folder.ExcludePatterns, folder.BandwidthLimit, folder.SyncInterval)
+		folder.ConflictResolution, folder.ExcludePatterns, folder.BandwidthLimit, folder.SyncInterval,
+		folder.IncludePaths, folder.Placeholders, folder.Profile, folder.ConfigManaged)
 	if err != nil {
 		return err
 	}
@@ -30,12 +32,30 @@ func (db *DB) GetSyncFolder(id int) (*SyncFolder, error) {
 	err := db.conn.QueryRow(`
 		SELECT id, local_path, remote_path, direction, enabled,
 			conflict_resolution, exclude_patterns, bandwidth_limit, sync_interval,
-			created_at, updated_at
+			paused, pause_reason, pause_all, include_paths, placeholders, profile, config_managed, created_at, updated_at
 		FROM sync_folders WHERE id = ?
 	`, id).Scan(
 		&folder.ID, &folder.LocalPath, &folder.RemotePath, &folder.Direction, &folder.Enabled,
 		&folder.ConflictResolution, &folder.ExcludePatterns, &folder.BandwidthLimit, &folder.SyncInterval,
-		&folder.CreatedAt, &folder.UpdatedAt,
+		&folder.Paused, &folder.PauseReason, &folder.PauseAll, &folder.IncludePaths, &folder.Placeholders, &folder.Profile, &folder.ConfigManaged, &folder.CreatedAt, &folder.UpdatedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return folder, err
+}
+
+func (db *DB) GetSyncFolderByPath(localPath string) (*SyncFolder, error) {
+	folder := &SyncFolder{}
+	err := db.conn.QueryRow(`
+		SELECT id, local_path, remote_path, direction, enabled,
+			conflict_resolution, exclude_patterns, bandwidth_limit, sync_interval,
+			paused, pause_reason, pause_all, include_paths, placeholders, profile, config_managed, created_at, updated_at
+		FROM sync_folders WHERE local_path = ?
+	`, localPath).Scan(
+		&folder.ID, &folder.LocalPath, &folder.RemotePath, &folder.Direction, &folder.Enabled,
+		&folder.ConflictResolution, &folder.ExcludePatterns, &folder.BandwidthLimit, &folder.SyncInterval,
+		&folder.Paused, &folder.PauseReason, &folder.PauseAll, &folder.IncludePaths, &folder.Placeholders, &folder.Profile, &folder.ConfigManaged, &folder.CreatedAt, &folder.UpdatedAt,
 	)
 	if err == sql.ErrNoRows {
 		return nil, nil
@@ -47,7 +67,7 @@ func (db *DB) ListSyncFolders() ([]*SyncFolder, error) {
 	rows, err := db.conn.Query(`
 		SELECT id, local_path, remote_path, direction, enabled,
 			conflict_resolution, exclude_patterns, bandwidth_limit, sync_interval,
-			created_at, updated_at
+			paused, pause_reason, pause_all, include_paths, placeholders, profile, config_managed, created_at, updated_at
 		FROM sync_folders ORDER BY id
 	`)
 	if err != nil {
@@ -61,7 +81,7 @@ func (db *DB) ListSyncFolders() ([]*SyncFolder, error) {
 		err := rows.Scan(
 			&folder.ID, &folder.LocalPath, &folder.RemotePath, &folder.Direction, &folder.Enabled,
 			&folder.ConflictResolution, &folder.ExcludePatterns, &folder.BandwidthLimit, &folder.SyncInterval,
-			&folder.CreatedAt, &folder.UpdatedAt,
+			&folder.Paused, &folder.PauseReason, &folder.PauseAll, &folder.IncludePaths, &folder.Placeholders, &folder.Profile, &folder.ConfigManaged, &folder.CreatedAt, &folder.UpdatedAt,
 		)
 		if err != nil {
 			return nil, err
@@ -76,11 +96,23 @@ func (db *DB) UpdateSyncFolder(folder *SyncFolder) error {
 		UPDATE sync_folders SET
 			local_path = ?, remote_path = ?, direction = ?, enabled = ?,
 			conflict_resolution = ?, exclude_patterns = ?, bandwidth_limit = ?,
-			sync_interval = ?, updated_at = ?
+			sync_interval = ?, include_paths = ?, placeholders = ?, profile = ?,
+			config_managed = ?, updated_at = ?
 		WHERE id = ?
 	`, folder.LocalPath, folder.RemotePath, folder.Direction, folder.Enabled,
 		folder.ConflictResolution, folder.ExcludePatterns, folder.BandwidthLimit,
-		folder.SyncInterval, time.Now(), folder.ID)
+		folder.SyncInterval, folder.IncludePaths, folder.Placeholders, folder.Profile,
+		folder.ConfigManaged, time.Now(), folder.ID)
+	return err
+}
+
+// SetSyncFolderPaused pauses or resumes a folder. A pause holds uploads and
+// deletes; with all set it holds downloads too.
+func (db *DB) SetSyncFolderPaused(id int, paused, all bool, reason *string) error {
+	_, err := db.conn.Exec(`
+		UPDATE sync_folders SET paused = ?, pause_all = ?, pause_reason = ?, updated_at = ?
+		WHERE id = ?
+	`, paused, paused && all, reason, time.Now(), id)
 	return err
 }
 
@@ -0,0 +1,63 @@
+package db
+
+import "time"
+
+func (db *DB) RecordFileVersion(version *FileVersion) error {
+	if version.RecordedAt.IsZero() {
+		version.RecordedAt = time.Now()
+	}
+	result, err := db.conn.Exec(`
+		INSERT INTO file_history (
+			sync_folder_id, relative_path, operation, hash, size,
+			version_id, deleted, recorded_at
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	`, version.SyncFolderID, version.RelativePath, version.Operation, version.Hash, version.Size,
+		version.VersionID, version.Deleted, version.RecordedAt.UTC())
+	if err != nil {
+		return err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	version.ID = int(id)
+	return nil
+}
+
+// GetFolderTreeAt returns the most recent recorded version of every path in
+// the folder as of the given time, omitting paths whose latest entry is a
+// deletion.
+func (db *DB) GetFolderTreeAt(folderID int, at time.Time) ([]*FileVersion, error) {
+	cutoff := at.UTC()
+	rows, err := db.conn.Query(`
+		SELECT h.id, h.sync_folder_id, h.relative_path, h.operation, h.hash, h.size,
+			h.version_id, h.deleted, h.recorded_at
+		FROM file_history h
+		WHERE h.sync_folder_id = ? AND h.id = (
+			SELECT MAX(id) FROM file_history
+			WHERE sync_folder_id = h.sync_folder_id
+				AND relative_path = h.relative_path
+				AND recorded_at <= ?
+		) AND h.deleted = 0
+		ORDER BY h.relative_path
+	`, folderID, cutoff)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var versions []*FileVersion
+	for rows.Next() {
+		version := &FileVersion{}
+		err := rows.Scan(
+			&version.ID, &version.SyncFolderID, &version.RelativePath, &version.Operation,
+			&version.Hash, &version.Size, &version.VersionID, &version.Deleted, &version.RecordedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		versions = append(versions, version)
+	}
+	return versions, rows.Err()
+}
@@ -100,6 +100,38 @@ func (db *DB) migrate() error {
 		`CREATE INDEX idx_file_states_status ON file_states(sync_status)`,
 		`CREATE INDEX idx_sync_queue_status ON sync_queue(status)`,
 		`CREATE INDEX idx_activity_log_created ON activity_log(created_at DESC)`,
+		// Version 10-11: file_history table
+		`CREATE TABLE file_history (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			sync_folder_id INTEGER NOT NULL,
+			relative_path TEXT NOT NULL,
+			operation TEXT NOT NULL,
+			hash TEXT,
+			size INTEGER,
+			version_id TEXT,
+			deleted INTEGER DEFAULT 0,
+			recorded_at DATETIME NOT NULL,
+			FOREIGN KEY (sync_folder_id) REFERENCES sync_folders(id) ON DELETE CASCADE
+		)`,
+		`CREATE INDEX idx_file_history_path ON file_history(sync_folder_id, relative_path, recorded_at)`,
+		// Version 12-13: folder pause state
+		`ALTER TABLE sync_folders ADD COLUMN paused INTEGER DEFAULT 0`,
+		`ALTER TABLE sync_folders ADD COLUMN pause_reason TEXT`,
+		// Version 14-16: selective sync
+		`ALTER TABLE sync_folders ADD COLUMN include_paths TEXT DEFAULT ''`,
+		`ALTER TABLE sync_folders ADD COLUMN placeholders INTEGER DEFAULT 0`,
+		`ALTER TABLE file_states ADD COLUMN dehydrated INTEGER DEFAULT 0`,
+		// Version 17: per-folder account profile
+		`ALTER TABLE sync_folders ADD COLUMN profile TEXT DEFAULT ''`,
+		// Version 18: folders declared under sync_folders in config.yaml
+		`ALTER TABLE sync_folders ADD COLUMN config_managed INTEGER DEFAULT 0`,
+		// Version 19: pauses that hold downloads as well as uploads
+		`ALTER TABLE sync_folders ADD COLUMN pause_all INTEGER DEFAULT 0`,
+		// Version 20: rows of folders removed before foreign keys were enforced
+		`DELETE FROM sync_queue WHERE sync_folder_id NOT IN (SELECT id FROM sync_folders);
+		DELETE FROM file_states WHERE sync_folder_id NOT IN (SELECT id FROM sync_folders);
+		DELETE FROM conflicts WHERE sync_folder_id NOT IN (SELECT id FROM sync_folders);
+		DELETE FROM file_history WHERE sync_folder_id NOT IN (SELECT id FROM sync_folders)`,
 	}
 
 	for i := version; i < len(migrations); i++ {
@@ -12,6 +12,13 @@ type SyncFolder struct {
 	ExcludePatterns    string    `db:"exclude_patterns"`
 	BandwidthLimit     *int      `db:"bandwidth_limit"`
 	SyncInterval       *int      `db:"sync_interval"`
+	Paused             bool      `db:"paused"`
+	PauseReason        *string   `db:"pause_reason"`
+	PauseAll           bool      `db:"pause_all"`
+	IncludePaths       string    `db:"include_paths"`
+	Placeholders       bool      `db:"placeholders"`
+	Profile            string    `db:"profile"`
+	ConfigManaged      bool      `db:"config_managed"`
 	CreatedAt          time.Time `db:"created_at"`
 	UpdatedAt          time.Time `db:"updated_at"`
 }
@@ -27,6 +34,7 @@ type FileState struct {
 	LocalSize        *int64     `db:"local_size"`
 	RemoteSize       *int64     `db:"remote_size"`
 	SyncStatus       string     `db:"sync_status"`
+	Dehydrated       bool       `db:"dehydrated"`
 	LastSyncedAt     *time.Time `db:"last_synced_at"`
 	CreatedAt        time.Time  `db:"created_at"`
 	UpdatedAt        time.Time  `db:"updated_at"`
@@ -73,3 +81,15 @@ type Conflict struct {
 	CreatedAt        time.Time  `db:"created_at"`
 	ResolvedAt       *time.Time `db:"resolved_at"`
 }
+
+type FileVersion struct {
+	ID           int       `db:"id"`
+	SyncFolderID int       `db:"sync_folder_id"`
+	RelativePath string    `db:"relative_path"`
+	Operation    string    `db:"operation"`
+	Hash         *string   `db:"hash"`
+	Size         *int64    `db:"size"`
+	VersionID    *string   `db:"version_id"`
+	Deleted      bool      `db:"deleted"`
+	RecordedAt   time.Time `db:"recorded_at"`
+}
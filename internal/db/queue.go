@@ -23,6 +23,20 @@ func (db *DB) EnqueueOperation(op *QueueOperation) error {
 	return nil
 }
 
+// IsQueued reports whether operation on a folder's relPath is waiting or
+// running
+func (db *DB) IsQueued(folderID int, relPath, operation string) (bool, error) {
+	var queued bool
+	err := db.conn.QueryRow(`
+		SELECT EXISTS (
+			SELECT 1 FROM sync_queue
+			WHERE sync_folder_id = ? AND relative_path = ? AND operation = ?
+				AND status IN ('pending', 'processing')
+		)
+	`, folderID, relPath, operation).Scan(&queued)
+	return queued, err
+}
+
 func (db *DB) DequeueOperation() (*QueueOperation, error) {
 	tx, err := db.conn.Begin()
 	if err != nil {
@@ -36,6 +50,11 @@ func (db *DB) DequeueOperation() (*QueueOperation, error) {
 			attempts, max_attempts, status, error_message, created_at, started_at, completed_at
 		FROM sync_queue
 		WHERE status = 'pending' AND attempts < max_attempts
+			AND NOT EXISTS (
+				SELECT 1 FROM sync_folders f
+				WHERE f.id = sync_queue.sync_folder_id AND f.paused = 1
+					AND (f.pause_all = 1 OR sync_queue.operation IN ('upload', 'delete'))
+			)
 		ORDER BY priority DESC, created_at ASC
 		LIMIT 1
 	`).Scan(
@@ -92,3 +111,158 @@ func (db *DB) ClearCompletedOperations(olderThan time.Duration) error {
 	`, cutoff)
 	return err
 }
+
+const queueColumns = `id, sync_folder_id, relative_path, operation, priority,
+	attempts, max_attempts, status, error_message, created_at, started_at, completed_at`
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanQueueOperation(row rowScanner) (*QueueOperation, error) {
+	op := &QueueOperation{}
+	err := row.Scan(
+		&op.ID, &op.SyncFolderID, &op.RelativePath, &op.Operation, &op.Priority,
+		&op.Attempts, &op.MaxAttempts, &op.Status, &op.ErrorMessage,
+		&op.CreatedAt, &op.StartedAt, &op.CompletedAt,
+	)
+	return op, err
+}
+
+// ListQueueOperations returns queued operations, newest first. A folderID
+// of 0 or an empty status matches all; a limit of 0 returns everything.
+func (db *DB) ListQueueOperations(folderID int, status string, limit int) ([]*QueueOperation, error) {
+	query := "SELECT " + queueColumns + " FROM sync_queue WHERE 1 = 1"
+	var args []interface{}
+	if folderID != 0 {
+		query += " AND sync_folder_id = ?"
+		args = append(args, folderID)
+	}
+	if status != "" {
+		query += " AND status = ?"
+		args = append(args, status)
+	}
+	query += " ORDER BY created_at DESC, id DESC"
+	if limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, limit)
+	}
+
+	rows, err := db.conn.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ops []*QueueOperation
+	for rows.Next() {
+		op, err := scanQueueOperation(rows)
+		if err != nil {
+			return nil, err
+		}
+		ops = append(ops, op)
+	}
+	return ops, rows.Err()
+}
+
+func (db *DB) GetQueueOperation(id int) (*QueueOperation, error) {
+	op, err := scanQueueOperation(db.conn.QueryRow(
+		"SELECT "+queueColumns+" FROM sync_queue WHERE id = ?", id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return op, err
+}
+
+// RequeueOperation puts a failed or cancelled operation back in the queue
+// with its attempts reset. It reports false if the operation was not in
+// either state.
+func (db *DB) RequeueOperation(id int) (bool, error) {
+	result, err := db.conn.Exec(`
+		UPDATE sync_queue SET status = 'pending', attempts = 0, error_message = NULL,
+			started_at = NULL, completed_at = NULL
+		WHERE id = ? AND status IN ('failed', 'cancelled')
+	`, id)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
+
+// CancelQueuedOperation marks a pending or failed operation cancelled. It
+// reports false if the operation was not in either state, e.g. because a
+// worker has already taken it.
+func (db *DB) CancelQueuedOperation(id int) (bool, error) {
+	result, err := db.conn.Exec(`
+		UPDATE sync_queue SET status = 'cancelled', completed_at = ?
+		WHERE id = ? AND status IN ('pending', 'failed')
+	`, time.Now(), id)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
+
+// CancelPendingChanges marks a folder's pending uploads and deletes
+// cancelled, returning how many there were
+func (db *DB) CancelPendingChanges(folderID int) (int, error) {
+	result, err := db.conn.Exec(`
+		UPDATE sync_queue SET status = 'cancelled', completed_at = ?
+		WHERE sync_folder_id = ? AND status = 'pending' AND operation IN ('upload', 'delete')
+	`, time.Now(), folderID)
+	if err != nil {
+		return 0, err
+	}
+	n, err := result.RowsAffected()
+	return int(n), err
+}
+
+// FolderQueueCounts returns how many of a folder's operations are waiting
+// (pending or processing) and how many failed
+func (db *DB) FolderQueueCounts(folderID int) (waiting, failed int, err error) {
+	err = db.conn.QueryRow(`
+		SELECT
+			COALESCE(SUM(CASE WHEN status IN ('pending', 'processing') THEN 1 ELSE 0 END), 0),
+			COALESCE(SUM(CASE WHEN status = 'failed' THEN 1 ELSE 0 END), 0)
+		FROM sync_queue WHERE sync_folder_id = ?
+	`, folderID).Scan(&waiting, &failed)
+	return waiting, failed, err
+}
+
+// QueueCountsByStatus returns the number of queued operations in each
+// status present
+func (db *DB) QueueCountsByStatus() (map[string]int, error) {
+	rows, err := db.conn.Query("SELECT status, COUNT(*) FROM sync_queue GROUP BY status")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := make(map[string]int)
+	for rows.Next() {
+		var status string
+		var count int
+		if err := rows.Scan(&status, &count); err != nil {
+			return nil, err
+		}
+		counts[status] = count
+	}
+	return counts, rows.Err()
+}
+
+// GetLastQueueError returns the error of the folder's most recent failed
+// operation, or "" if none failed
+func (db *DB) GetLastQueueError(folderID int) (string, error) {
+	var msg sql.NullString
+	err := db.conn.QueryRow(`
+		SELECT error_message FROM sync_queue
+		WHERE sync_folder_id = ? AND status = 'failed'
+		ORDER BY completed_at DESC LIMIT 1
+	`, folderID).Scan(&msg)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return msg.String, err
+}
@@ -0,0 +1,97 @@
+package db
+
+import (
+	"database/sql"
+	"time"
+)
+
+// FolderStats summarises a sync folder's files, queue and transfer history
+type FolderStats struct {
+	Files           int
+	SyncedFiles     int
+	PendingFiles    int
+	ErrorFiles      int
+	DehydratedFiles int
+	LocalBytes      int64
+
+	QueuedOperations int
+	FailedOperations int
+	Conflicts        int
+
+	Uploads          int
+	Downloads        int
+	Deletes          int
+	Errors           int
+	BytesTransferred int64
+	LastSync         *time.Time
+}
+
+func (db *DB) GetFolderStats(folderID int) (*FolderStats, error) {
+	stats := &FolderStats{}
+
+	err := db.conn.QueryRow(`
+		SELECT COUNT(*),
+			COALESCE(SUM(CASE WHEN sync_status = 'synced' THEN 1 ELSE 0 END), 0),
+			COALESCE(SUM(CASE WHEN sync_status = 'pending' THEN 1 ELSE 0 END), 0),
+			COALESCE(SUM(CASE WHEN sync_status = 'error' THEN 1 ELSE 0 END), 0),
+			COALESCE(SUM(dehydrated), 0),
+			COALESCE(SUM(CASE WHEN dehydrated = 0 THEN local_size ELSE 0 END), 0)
+		FROM file_states WHERE sync_folder_id = ?
+	`, folderID).Scan(
+		&stats.Files, &stats.SyncedFiles, &stats.PendingFiles, &stats.ErrorFiles,
+		&stats.DehydratedFiles, &stats.LocalBytes,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	stats.QueuedOperations, stats.FailedOperations, err = db.FolderQueueCounts(folderID)
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.conn.QueryRow(`
+		SELECT COUNT(*) FROM conflicts WHERE sync_folder_id = ? AND resolved = 0
+	`, folderID).Scan(&stats.Conflicts)
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.conn.QueryRow(`
+		SELECT
+			COALESCE(SUM(CASE WHEN operation = 'upload' AND status = 'success' THEN 1 ELSE 0 END), 0),
+			COALESCE(SUM(CASE WHEN operation = 'download' AND status = 'success' THEN 1 ELSE 0 END), 0),
+			COALESCE(SUM(CASE WHEN operation = 'delete' AND status = 'success' THEN 1 ELSE 0 END), 0),
+			COALESCE(SUM(CASE WHEN status = 'error' THEN 1 ELSE 0 END), 0),
+			COALESCE(SUM(CASE WHEN status = 'success' THEN bytes_transferred ELSE 0 END), 0)
+		FROM activity_log WHERE sync_folder_id = ?
+	`, folderID).Scan(&stats.Uploads, &stats.Downloads, &stats.Deletes, &stats.Errors, &stats.BytesTransferred)
+	if err != nil {
+		return nil, err
+	}
+
+	stats.LastSync, err = db.GetLastSyncTime(folderID)
+	if err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
+
+// GetLastSyncTime returns when an operation for the folder last succeeded,
+// or nil if none has
+func (db *DB) GetLastSyncTime(folderID int) (*time.Time, error) {
+	var at time.Time
+	err := db.conn.QueryRow(`
+		SELECT created_at FROM activity_log
+		WHERE sync_folder_id = ? AND status = 'success'
+			AND operation IN ('upload', 'download', 'delete')
+		ORDER BY created_at DESC LIMIT 1
+	`, folderID).Scan(&at)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &at, nil
+}
@@ -0,0 +1,183 @@
+package devapi
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"time"
+
+	apiv1 "github.com/darkstorage/cli/internal/api/v1"
+)
+
+// Fixtures is the complete state of the fake API. It is also the format of
+// fixture files, so a dump of one run can seed the next.
+type Fixtures struct {
+	Profile          Profile                            `json:"profile"`
+	DeletedFiles     []apiv1.DeletedFile                `json:"deleted_files"`
+	AuditEvents      []apiv1.AuditEvent                 `json:"audit_events"`
+	ShareLinks       []apiv1.ShareLink                  `json:"share_links"`
+	ShareStats       map[string]apiv1.ShareLinkStats    `json:"share_stats"`
+	Groups           []Group                            `json:"groups"`
+	Permissions      map[string][]apiv1.Permission      `json:"permissions"`
+	Scans            []Scan                             `json:"scans"`
+	Quarantine       []apiv1.QuarantinedFile            `json:"quarantine"`
+	Compartments     []apiv1.Compartment                `json:"compartments"`
+	CompartmentFiles map[string][]apiv1.CompartmentFile `json:"compartment_files"`
+	CompartmentUsers map[string]map[string]string       `json:"compartment_users"`
+}
+
+// Profile is the account the fake API authenticates every token as
+type Profile struct {
+	Email string `json:"email"`
+	Name  string `json:"name"`
+	Tier  string `json:"tier"`
+}
+
+type Group struct {
+	apiv1.Group
+	Members []apiv1.GroupMember `json:"members"`
+}
+
+// Scan is a scan result for a file
+type Scan struct {
+	FileID   string `json:"file_id"`
+	FileName string `json:"file_name"`
+	apiv1.ScanResult
+}
+
+// LoadFixtures reads fixtures from JSON. Missing sections start empty.
+func LoadFixtures(r io.Reader) (*Fixtures, error) {
+	var f Fixtures
+	if err := json.NewDecoder(r).Decode(&f); err != nil {
+		return nil, err
+	}
+	f.init()
+	return &f, nil
+}
+
+func LoadFixturesFile(path string) (*Fixtures, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return LoadFixtures(file)
+}
+
+// EmptyFixtures returns a state with only a profile
+func EmptyFixtures() *Fixtures {
+	f := &Fixtures{
+		Profile: Profile{Email: "dev@example.com", Name: "Dev User", Tier: "pro"},
+	}
+	f.init()
+	return f
+}
+
+func (f *Fixtures) init() {
+	if f.Profile.Email == "" {
+		f.Profile = EmptyFixtures().Profile
+	}
+	if f.ShareStats == nil {
+		f.ShareStats = make(map[string]apiv1.ShareLinkStats)
+	}
+	if f.Permissions == nil {
+		f.Permissions = make(map[string][]apiv1.Permission)
+	}
+	if f.CompartmentFiles == nil {
+		f.CompartmentFiles = make(map[string][]apiv1.CompartmentFile)
+	}
+	if f.CompartmentUsers == nil {
+		f.CompartmentUsers = make(map[string]map[string]string)
+	}
+}
+
+// DefaultFixtures returns a small data set covering every endpoint, with
+// timestamps relative to now
+func DefaultFixtures(now time.Time) *Fixtures {
+	f := EmptyFixtures()
+	maxDownloads := 10
+	weekOut := now.Add(7 * 24 * time.Hour)
+
+	f.DeletedFiles = []apiv1.DeletedFile{
+		{
+			ID:           "5f1c2a9e7b3d4c6a8e0f1a2b3c4d5e6f",
+			OriginalName: "q3-report.pdf",
+			OriginalPath: "documents/reports/q3-report.pdf",
+			FileSize:     2457600,
+			Status:       "deleted",
+			DeletedAt:    now.Add(-26 * time.Hour),
+			ExpiresAt:    now.Add(4 * 24 * time.Hour),
+		},
+		{
+			ID:           "9a8b7c6d5e4f40312a1b2c3d4e5f6a7b",
+			OriginalName: "old-notes.txt",
+			OriginalPath: "scratch/old-notes.txt",
+			FileSize:     4096,
+			Status:       "deleted",
+			DeletedAt:    now.Add(-6*24*time.Hour - 20*time.Hour),
+			ExpiresAt:    now.Add(4 * time.Hour),
+		},
+	}
+
+	f.AuditEvents = []apiv1.AuditEvent{
+		{ID: "ae000001", EventType: "FILE_UPLOAD", ResourceType: "file", ResourceName: "documents/reports/q3-report.pdf", ActorEmail: f.Profile.Email, IPAddress: "203.0.113.10", UserAgent: "darkstorage-cli", CreatedAt: now.Add(-3 * 24 * time.Hour)},
+		{ID: "ae000002", EventType: "FILE_DOWNLOAD", ResourceType: "file", ResourceName: "documents/reports/q3-report.pdf", ActorEmail: "alice@example.com", IPAddress: "198.51.100.7", UserAgent: "Mozilla/5.0", CreatedAt: now.Add(-2 * 24 * time.Hour)},
+		{ID: "ae000003", EventType: "FILE_DELETE", ResourceType: "file", ResourceName: "documents/reports/q3-report.pdf", ActorEmail: f.Profile.Email, IPAddress: "203.0.113.10", UserAgent: "darkstorage-cli", CreatedAt: now.Add(-26 * time.Hour)},
+		{ID: "ae000004", EventType: "PERMISSION_GRANT", ResourceType: "file", ResourceName: "shared/design.fig", ActorEmail: f.Profile.Email, IPAddress: "203.0.113.10", UserAgent: "darkstorage-cli", CreatedAt: now.Add(-2 * time.Hour)},
+	}
+
+	f.ShareLinks = []apiv1.ShareLink{
+		{ID: "3c2b1a0f9e8d4c7b6a5f4e3d2c1b0a99", FileName: "design.fig", Token: "tok_design", ExpiresAt: &weekOut, DownloadCount: 3, MaxDownloads: &maxDownloads, IsActive: true},
+		{ID: "7d6c5b4a3f2e4d1c0b9a8f7e6d5c4b33", FileName: "invoice.pdf", Token: "tok_invoice", DownloadCount: 1, IsActive: false},
+	}
+	f.ShareStats["3c2b1a0f9e8d4c7b6a5f4e3d2c1b0a99"] = apiv1.ShareLinkStats{ViewCount: 12, DownloadCount: 3, BandwidthUsed: 15728640, UniqueVisitors: 4}
+
+	f.Groups = []Group{
+		{
+			Group: apiv1.Group{ID: "g1a2b3c4d5e6f7a8b9c0d1e2f3a4b5c6", Name: "engineering", Description: "Engineering team", MyRole: "owner"},
+			Members: []apiv1.GroupMember{
+				{Email: f.Profile.Email, Name: f.Profile.Name, Role: "owner", Status: "active"},
+				{Email: "alice@example.com", Name: "Alice", Role: "admin", Status: "active"},
+				{Email: "bob@example.com", Name: "Bob", Role: "member", Status: "invited"},
+			},
+		},
+	}
+
+	f.Permissions["file-design"] = []apiv1.Permission{
+		levelPermission(apiv1.Permission{ID: "p1b2c3d4e5f6a7b8", GranteeType: "user", GranteeName: "Alice", GranteeEmail: "alice@example.com"}, "editor"),
+		levelPermission(apiv1.Permission{ID: "p9e8d7c6b5a4f3e2", GranteeType: "group", GranteeName: "engineering"}, "viewer"),
+	}
+
+	f.Scans = []Scan{
+		{FileID: "file-design", FileName: "shared/design.fig", ScanResult: apiv1.ScanResult{Status: "clean", ScanEngine: "clamav", Severity: "none"}},
+		{FileID: "file-installer", FileName: "downloads/setup.exe", ScanResult: apiv1.ScanResult{
+			Status: "infected", ScanEngine: "clamav", Severity: "high",
+			Threats: []apiv1.Threat{{Name: "Win.Trojan.Agent-1234", Category: "trojan"}},
+		}},
+	}
+	f.Quarantine = []apiv1.QuarantinedFile{
+		{ID: "q0a1b2c3d4e5f6a7b8c9d0e1f2a3b4c5", OriginalName: "setup.exe", QuarantineReason: "Win.Trojan.Agent-1234", FileSize: 5242880},
+	}
+
+	f.Compartments = []apiv1.Compartment{
+		{Name: "classified", Level: "SECRET", Compliance: []string{"ITAR"}, Policies: apiv1.CompartmentPolicies{RequireMFA: true, Encryption: "AES256-GCM"}, CreatedAt: now.Add(-30 * 24 * time.Hour)},
+		{Name: "pii", Level: "CONFIDENTIAL", Compliance: []string{"HIPAA", "GDPR"}, Policies: apiv1.CompartmentPolicies{RequireMFA: true, Encryption: "AES256-GCM"}, CreatedAt: now.Add(-60 * 24 * time.Hour)},
+	}
+	f.CompartmentFiles["classified"] = []apiv1.CompartmentFile{
+		{Path: "my-bucket/classified/report-2026.pdf", Size: 2516582, AssignedAt: now.Add(-20 * 24 * time.Hour)},
+		{Path: "my-bucket/classified/analysis.docx", Size: 1153434, AssignedAt: now.Add(-19 * 24 * time.Hour)},
+	}
+	f.CompartmentUsers["classified"] = map[string]string{f.Profile.Email: "admin", "alice@example.com": "read"}
+
+	return f
+}
+
+// levelPermission fills in the capability flags for a permission level
+func levelPermission(p apiv1.Permission, level string) apiv1.Permission {
+	p.Level = level
+	p.CanRead = true
+	p.CanWrite = level == "editor" || level == "admin"
+	p.CanDelete = level == "admin"
+	p.CanShare = level == "admin"
+	return p
+}
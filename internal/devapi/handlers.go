@@ -0,0 +1,725 @@
+package devapi
+
+import (
+	"net/http"
+	"path"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	apiv1 "github.com/darkstorage/cli/internal/api/v1"
+)
+
+// SDMS
+
+func (s *Server) deletedFile(f apiv1.DeletedFile) apiv1.DeletedFile {
+	f.HoursRemaining = f.ExpiresAt.Sub(s.now()).Hours()
+	switch {
+	case f.HoursRemaining <= 0:
+		f.HoursRemaining = 0
+		f.Urgency = "expired"
+	case f.HoursRemaining < 24:
+		f.Urgency = "critical"
+	case f.HoursRemaining < 72:
+		f.Urgency = "warning"
+	default:
+		f.Urgency = "normal"
+	}
+	return f
+}
+
+func (s *Server) findDeletedFile(w http.ResponseWriter, r *http.Request) int {
+	id := r.PathValue("id")
+	i := matchID(s.data.DeletedFiles, id, func(f apiv1.DeletedFile) string { return f.ID })
+	if i < 0 {
+		notFound(w, "deleted file", id)
+	}
+	return i
+}
+
+func (s *Server) listRecoverable(w http.ResponseWriter, r *http.Request) {
+	files := []apiv1.DeletedFile{}
+	for _, f := range s.data.DeletedFiles {
+		if f = s.deletedFile(f); f.Urgency != "expired" {
+			files = append(files, f)
+		}
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].DeletedAt.After(files[j].DeletedAt) })
+
+	page, next, ok := paginate(w, r, files)
+	if !ok {
+		return
+	}
+	writeJSON(w, http.StatusOK, apiv1.DeletedFileList{Files: page, NextCursor: next})
+}
+
+func (s *Server) getDeletedFile(w http.ResponseWriter, r *http.Request) {
+	if i := s.findDeletedFile(w, r); i >= 0 {
+		writeJSON(w, http.StatusOK, s.deletedFile(s.data.DeletedFiles[i]))
+	}
+}
+
+func (s *Server) recoverFile(w http.ResponseWriter, r *http.Request) {
+	var req apiv1.RecoverRequest
+	if r.ContentLength != 0 && !decode(w, r, &req) {
+		return
+	}
+	i := s.findDeletedFile(w, r)
+	if i < 0 {
+		return
+	}
+
+	f := s.deletedFile(s.data.DeletedFiles[i])
+	if f.Urgency == "expired" {
+		writeError(w, http.StatusGone, "expired", "recovery window has expired")
+		return
+	}
+	dest := f.OriginalPath
+	if req.RecoverToPath != "" {
+		dest = req.RecoverToPath
+	}
+
+	s.data.DeletedFiles = slices.Delete(s.data.DeletedFiles, i, i+1)
+	s.record(r, "FILE_RECOVER", "file", dest)
+	message(w, "Recovered "+f.OriginalName+" to "+dest)
+}
+
+func (s *Server) purgeFile(w http.ResponseWriter, r *http.Request) {
+	i := s.findDeletedFile(w, r)
+	if i < 0 {
+		return
+	}
+	f := s.data.DeletedFiles[i]
+	s.data.DeletedFiles = slices.Delete(s.data.DeletedFiles, i, i+1)
+	s.record(r, "FILE_PURGE", "file", f.OriginalPath)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Audit
+
+// parseTime accepts RFC 3339 or a plain date
+func parseTime(value string) (time.Time, bool) {
+	for _, layout := range []string{time.RFC3339, "2006-01-02T15:04:05", "2006-01-02"} {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+func (s *Server) listAuditEvents(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+
+	var from, to time.Time
+	if value := q.Get("from"); value != "" {
+		t, ok := parseTime(value)
+		if !ok {
+			badRequest(w, "invalid from")
+			return
+		}
+		from = t
+	}
+	if value := q.Get("to"); value != "" {
+		t, ok := parseTime(value)
+		if !ok {
+			badRequest(w, "invalid to")
+			return
+		}
+		to = t
+	}
+
+	events := []apiv1.AuditEvent{}
+	for _, e := range s.data.AuditEvents {
+		if t := q.Get("event_type"); t != "" && !strings.EqualFold(e.EventType, t) {
+			continue
+		}
+		if a := q.Get("actor_email"); a != "" && !strings.EqualFold(e.ActorEmail, a) {
+			continue
+		}
+		if res := q.Get("resource"); res != "" && !strings.HasPrefix(e.ResourceName, res) {
+			continue
+		}
+		if !from.IsZero() && e.CreatedAt.Before(from) {
+			continue
+		}
+		if !to.IsZero() && e.CreatedAt.After(to) {
+			continue
+		}
+		events = append(events, e)
+	}
+	sort.SliceStable(events, func(i, j int) bool { return events[i].CreatedAt.After(events[j].CreatedAt) })
+
+	page, next, ok := paginate(w, r, events)
+	if !ok {
+		return
+	}
+	writeJSON(w, http.StatusOK, apiv1.AuditEventList{Events: page, NextCursor: next})
+}
+
+func (s *Server) auditSummary(w http.ResponseWriter, r *http.Request) {
+	now := s.now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	weekAgo := now.Add(-7 * 24 * time.Hour)
+
+	summary := apiv1.AuditSummary{EventsByType: make(map[string]int)}
+	for _, e := range s.data.AuditEvents {
+		summary.TotalEvents++
+		summary.EventsByType[e.EventType]++
+		if !e.CreatedAt.Before(today) {
+			summary.EventsToday++
+		}
+		if e.CreatedAt.After(weekAgo) {
+			summary.EventsThisWeek++
+		}
+	}
+	writeJSON(w, http.StatusOK, summary)
+}
+
+// Sharing
+
+func (s *Server) findShareLink(w http.ResponseWriter, r *http.Request) int {
+	id := r.PathValue("id")
+	i := matchID(s.data.ShareLinks, id, func(l apiv1.ShareLink) string { return l.ID })
+	if i < 0 {
+		notFound(w, "share link", id)
+	}
+	return i
+}
+
+func (s *Server) listShareLinks(w http.ResponseWriter, r *http.Request) {
+	page, next, ok := paginate(w, r, s.data.ShareLinks)
+	if !ok {
+		return
+	}
+	writeJSON(w, http.StatusOK, apiv1.ShareLinkList{Links: page, NextCursor: next})
+}
+
+func (s *Server) createShareLink(w http.ResponseWriter, r *http.Request) {
+	var req apiv1.CreateShareLinkRequest
+	if !decode(w, r, &req) {
+		return
+	}
+	if req.FilePath == "" {
+		badRequest(w, "file_path is required")
+		return
+	}
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(s.now()) {
+		badRequest(w, "expires_at must be in the future")
+		return
+	}
+
+	link := apiv1.ShareLink{
+		ID:        newID(),
+		FileName:  path.Base(req.FilePath),
+		Token:     newID(),
+		ExpiresAt: req.ExpiresAt,
+		IsActive:  true,
+	}
+	if req.OneTimeUse {
+		one := 1
+		link.MaxDownloads = &one
+	} else if req.MaxDownloads > 0 {
+		max := req.MaxDownloads
+		link.MaxDownloads = &max
+	}
+	link.URL = "http://" + r.Host + "/s/" + link.Token
+
+	s.data.ShareLinks = append(s.data.ShareLinks, link)
+	s.record(r, "SHARE_CREATE", "file", req.FilePath)
+	writeJSON(w, http.StatusCreated, link)
+}
+
+func (s *Server) revokeShareLink(w http.ResponseWriter, r *http.Request) {
+	i := s.findShareLink(w, r)
+	if i < 0 {
+		return
+	}
+	s.data.ShareLinks[i].IsActive = false
+	s.record(r, "SHARE_REVOKE", "share_link", s.data.ShareLinks[i].FileName)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) shareLinkStats(w http.ResponseWriter, r *http.Request) {
+	i := s.findShareLink(w, r)
+	if i < 0 {
+		return
+	}
+	link := s.data.ShareLinks[i]
+	stats, ok := s.data.ShareStats[link.ID]
+	if !ok {
+		stats = apiv1.ShareLinkStats{DownloadCount: link.DownloadCount}
+	}
+	writeJSON(w, http.StatusOK, stats)
+}
+
+// Groups
+
+func (s *Server) findGroup(w http.ResponseWriter, r *http.Request) int {
+	id := r.PathValue("id")
+	i := matchID(s.data.Groups, id, func(g Group) string { return g.ID })
+	if i < 0 {
+		notFound(w, "group", id)
+	}
+	return i
+}
+
+func (s *Server) listGroups(w http.ResponseWriter, r *http.Request) {
+	groups := make([]apiv1.Group, 0, len(s.data.Groups))
+	for _, g := range s.data.Groups {
+		group := g.Group
+		group.MemberCount = len(g.Members)
+		groups = append(groups, group)
+	}
+
+	page, next, ok := paginate(w, r, groups)
+	if !ok {
+		return
+	}
+	writeJSON(w, http.StatusOK, apiv1.GroupList{Groups: page, NextCursor: next})
+}
+
+func (s *Server) createGroup(w http.ResponseWriter, r *http.Request) {
+	var req apiv1.CreateGroupRequest
+	if !decode(w, r, &req) {
+		return
+	}
+	if req.Name == "" {
+		badRequest(w, "name is required")
+		return
+	}
+	for _, g := range s.data.Groups {
+		if strings.EqualFold(g.Name, req.Name) {
+			writeError(w, http.StatusConflict, "conflict", "group "+req.Name+" already exists")
+			return
+		}
+	}
+
+	group := Group{
+		Group: apiv1.Group{ID: newID(), Name: req.Name, Description: req.Description, MyRole: "owner"},
+		Members: []apiv1.GroupMember{
+			{Email: s.data.Profile.Email, Name: s.data.Profile.Name, Role: "owner", Status: "active"},
+		},
+	}
+	s.data.Groups = append(s.data.Groups, group)
+	s.record(r, "GROUP_CREATE", "group", req.Name)
+
+	created := group.Group
+	created.MemberCount = len(group.Members)
+	writeJSON(w, http.StatusCreated, created)
+}
+
+func (s *Server) deleteGroup(w http.ResponseWriter, r *http.Request) {
+	i := s.findGroup(w, r)
+	if i < 0 {
+		return
+	}
+	name := s.data.Groups[i].Name
+	s.data.Groups = slices.Delete(s.data.Groups, i, i+1)
+	s.record(r, "GROUP_DELETE", "group", name)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) listGroupMembers(w http.ResponseWriter, r *http.Request) {
+	i := s.findGroup(w, r)
+	if i < 0 {
+		return
+	}
+	page, next, ok := paginate(w, r, s.data.Groups[i].Members)
+	if !ok {
+		return
+	}
+	writeJSON(w, http.StatusOK, apiv1.GroupMemberList{Members: page, NextCursor: next})
+}
+
+func (s *Server) addGroupMember(w http.ResponseWriter, r *http.Request) {
+	var req apiv1.AddGroupMemberRequest
+	if !decode(w, r, &req) {
+		return
+	}
+	i := s.findGroup(w, r)
+	if i < 0 {
+		return
+	}
+	if !strings.Contains(req.Email, "@") {
+		badRequest(w, "a valid email is required")
+		return
+	}
+	if req.Role == "" {
+		req.Role = "member"
+	}
+	if req.Role != "owner" && req.Role != "admin" && req.Role != "member" {
+		badRequest(w, "role must be owner, admin or member")
+		return
+	}
+
+	group := &s.data.Groups[i]
+	for _, m := range group.Members {
+		if strings.EqualFold(m.Email, req.Email) {
+			writeError(w, http.StatusConflict, "conflict", req.Email+" is already a member")
+			return
+		}
+	}
+	group.Members = append(group.Members, apiv1.GroupMember{
+		Email:  req.Email,
+		Name:   strings.Split(req.Email, "@")[0],
+		Role:   req.Role,
+		Status: "invited",
+	})
+	s.record(r, "GROUP_MEMBER_ADD", "group", group.Name)
+	writeJSON(w, http.StatusCreated, map[string]string{"status": "invited"})
+}
+
+func (s *Server) removeGroupMember(w http.ResponseWriter, r *http.Request) {
+	i := s.findGroup(w, r)
+	if i < 0 {
+		return
+	}
+	email := r.PathValue("email")
+	group := &s.data.Groups[i]
+	j := slices.IndexFunc(group.Members, func(m apiv1.GroupMember) bool { return strings.EqualFold(m.Email, email) })
+	if j < 0 {
+		notFound(w, "member", email)
+		return
+	}
+	group.Members = slices.Delete(group.Members, j, j+1)
+	s.record(r, "GROUP_MEMBER_REMOVE", "group", group.Name)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Permissions
+
+func (s *Server) listPermissions(w http.ResponseWriter, r *http.Request) {
+	perms := s.data.Permissions[r.PathValue("id")]
+	if perms == nil {
+		perms = []apiv1.Permission{}
+	}
+	writeJSON(w, http.StatusOK, apiv1.PermissionList{Permissions: perms})
+}
+
+func (s *Server) grantPermission(w http.ResponseWriter, r *http.Request) {
+	var req apiv1.GrantPermissionRequest
+	if !decode(w, r, &req) {
+		return
+	}
+	if req.Level != "viewer" && req.Level != "editor" && req.Level != "admin" {
+		badRequest(w, "level must be viewer, editor or admin")
+		return
+	}
+
+	perm := apiv1.Permission{ID: newID(), GranteeType: req.GranteeType}
+	switch req.GranteeType {
+	case "user":
+		if req.GranteeEmail == "" {
+			badRequest(w, "grantee_email is required for users")
+			return
+		}
+		perm.GranteeEmail = req.GranteeEmail
+		perm.GranteeName = strings.Split(req.GranteeEmail, "@")[0]
+	case "group":
+		i := matchID(s.data.Groups, req.GranteeID, func(g Group) string { return g.ID })
+		if i < 0 {
+			notFound(w, "group", req.GranteeID)
+			return
+		}
+		perm.GranteeName = s.data.Groups[i].Name
+	default:
+		badRequest(w, "grantee_type must be user or group")
+		return
+	}
+
+	fileID := r.PathValue("id")
+	perm = levelPermission(perm, req.Level)
+	s.data.Permissions[fileID] = append(s.data.Permissions[fileID], perm)
+	s.record(r, "PERMISSION_GRANT", "file", fileID)
+	writeJSON(w, http.StatusCreated, perm)
+}
+
+func (s *Server) revokePermission(w http.ResponseWriter, r *http.Request) {
+	fileID := r.PathValue("id")
+	pid := r.PathValue("pid")
+	perms := s.data.Permissions[fileID]
+	i := matchID(perms, pid, func(p apiv1.Permission) string { return p.ID })
+	if i < 0 {
+		notFound(w, "permission", pid)
+		return
+	}
+	s.data.Permissions[fileID] = slices.Delete(perms, i, i+1)
+	s.record(r, "PERMISSION_REVOKE", "file", fileID)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// effectivePermissions treats the caller as the owner unless a permission
+// was granted to them directly
+func (s *Server) effectivePermissions(w http.ResponseWriter, r *http.Request) {
+	for _, p := range s.data.Permissions[r.PathValue("id")] {
+		if p.GranteeType == "user" && strings.EqualFold(p.GranteeEmail, s.data.Profile.Email) {
+			writeJSON(w, http.StatusOK, apiv1.EffectivePermissions{
+				CanRead:   p.CanRead,
+				CanWrite:  p.CanWrite,
+				CanDelete: p.CanDelete,
+				CanShare:  p.CanShare,
+				CanAdmin:  p.Level == "admin",
+			})
+			return
+		}
+	}
+	writeJSON(w, http.StatusOK, apiv1.EffectivePermissions{
+		CanRead: true, CanWrite: true, CanDelete: true, CanShare: true, CanAdmin: true, IsOwner: true,
+	})
+}
+
+// Scanner
+
+func (s *Server) scanFile(w http.ResponseWriter, r *http.Request) {
+	filePath, ok := strings.CutSuffix(r.PathValue("path"), "/scan")
+	if !ok || filePath == "" {
+		writeError(w, http.StatusNotFound, "not_found", "no route for "+r.URL.Path)
+		return
+	}
+
+	var req apiv1.ScanRequest
+	if !decode(w, r, &req) {
+		return
+	}
+	switch req.ScanType {
+	case "", "antivirus", "magic", "dlp":
+	default:
+		badRequest(w, "scan_type must be antivirus, magic or dlp")
+		return
+	}
+
+	// Scans finish immediately; files are clean unless a fixture says
+	// otherwise
+	if slices.IndexFunc(s.data.Scans, func(sc Scan) bool { return sc.FileID == filePath }) < 0 {
+		s.data.Scans = append(s.data.Scans, Scan{
+			FileID:     filePath,
+			FileName:   filePath,
+			ScanResult: apiv1.ScanResult{Status: "clean", ScanEngine: "clamav", Severity: "none"},
+		})
+	}
+	s.record(r, "FILE_SCAN", "file", filePath)
+	writeJSON(w, http.StatusAccepted, apiv1.ScanQueued{
+		Message: "Scan queued for " + filePath,
+		QueueID: newID(),
+	})
+}
+
+func (s *Server) scanResult(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	i := slices.IndexFunc(s.data.Scans, func(sc Scan) bool { return sc.FileID == id })
+	if i < 0 {
+		notFound(w, "scan result for", id)
+		return
+	}
+	result := s.data.Scans[i].ScanResult
+	if result.Threats == nil {
+		result.Threats = []apiv1.Threat{}
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+func (s *Server) listThreats(w http.ResponseWriter, r *http.Request) {
+	threats := []apiv1.ThreatDetection{}
+	for _, sc := range s.data.Scans {
+		if len(sc.Threats) == 0 {
+			continue
+		}
+		threats = append(threats, apiv1.ThreatDetection{
+			FileID:   sc.FileID,
+			FileName: sc.FileName,
+			Status:   sc.Status,
+			Severity: sc.Severity,
+		})
+	}
+
+	page, next, ok := paginate(w, r, threats)
+	if !ok {
+		return
+	}
+	writeJSON(w, http.StatusOK, apiv1.ThreatList{Threats: page, NextCursor: next})
+}
+
+func (s *Server) listQuarantine(w http.ResponseWriter, r *http.Request) {
+	page, next, ok := paginate(w, r, s.data.Quarantine)
+	if !ok {
+		return
+	}
+	writeJSON(w, http.StatusOK, apiv1.QuarantineList{Files: page, NextCursor: next})
+}
+
+func (s *Server) releaseQuarantine(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	i := matchID(s.data.Quarantine, id, func(f apiv1.QuarantinedFile) string { return f.ID })
+	if i < 0 {
+		notFound(w, "quarantined file", id)
+		return
+	}
+	name := s.data.Quarantine[i].OriginalName
+	s.data.Quarantine = slices.Delete(s.data.Quarantine, i, i+1)
+	s.record(r, "QUARANTINE_RELEASE", "file", name)
+	message(w, "Released "+name)
+}
+
+// Compartments
+
+func (s *Server) findCompartment(w http.ResponseWriter, r *http.Request) int {
+	name := r.PathValue("name")
+	i := slices.IndexFunc(s.data.Compartments, func(c apiv1.Compartment) bool { return c.Name == name })
+	if i < 0 {
+		notFound(w, "compartment", name)
+	}
+	return i
+}
+
+func (s *Server) compartment(c apiv1.Compartment) apiv1.Compartment {
+	c.FileCount = len(s.data.CompartmentFiles[c.Name])
+	c.UserCount = len(s.data.CompartmentUsers[c.Name])
+	if c.Compliance == nil {
+		c.Compliance = []string{}
+	}
+	return c
+}
+
+func (s *Server) listCompartments(w http.ResponseWriter, r *http.Request) {
+	compartments := make([]apiv1.Compartment, 0, len(s.data.Compartments))
+	for _, c := range s.data.Compartments {
+		compartments = append(compartments, s.compartment(c))
+	}
+
+	page, next, ok := paginate(w, r, compartments)
+	if !ok {
+		return
+	}
+	writeJSON(w, http.StatusOK, apiv1.CompartmentList{Compartments: page, NextCursor: next})
+}
+
+func (s *Server) createCompartment(w http.ResponseWriter, r *http.Request) {
+	var req apiv1.CreateCompartmentRequest
+	if !decode(w, r, &req) {
+		return
+	}
+	if req.Name == "" {
+		badRequest(w, "name is required")
+		return
+	}
+	switch req.Level {
+	case "", "PUBLIC", "CONFIDENTIAL", "SECRET", "TOP_SECRET":
+	default:
+		badRequest(w, "level must be PUBLIC, CONFIDENTIAL, SECRET or TOP_SECRET")
+		return
+	}
+	if slices.ContainsFunc(s.data.Compartments, func(c apiv1.Compartment) bool { return c.Name == req.Name }) {
+		writeError(w, http.StatusConflict, "conflict", "compartment "+req.Name+" already exists")
+		return
+	}
+
+	c := apiv1.Compartment{
+		Name:        req.Name,
+		Level:       req.Level,
+		Description: req.Description,
+		Compliance:  req.Compliance,
+		Policies:    req.Policies,
+		CreatedAt:   s.now(),
+	}
+	s.data.Compartments = append(s.data.Compartments, c)
+	s.data.CompartmentUsers[c.Name] = map[string]string{s.data.Profile.Email: "admin"}
+	s.record(r, "COMPARTMENT_CREATE", "compartment", c.Name)
+	writeJSON(w, http.StatusCreated, s.compartment(c))
+}
+
+func (s *Server) deleteCompartment(w http.ResponseWriter, r *http.Request) {
+	i := s.findCompartment(w, r)
+	if i < 0 {
+		return
+	}
+	name := s.data.Compartments[i].Name
+	s.data.Compartments = slices.Delete(s.data.Compartments, i, i+1)
+	delete(s.data.CompartmentFiles, name)
+	delete(s.data.CompartmentUsers, name)
+	s.record(r, "COMPARTMENT_DELETE", "compartment", name)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) assignCompartment(w http.ResponseWriter, r *http.Request) {
+	var req apiv1.AssignCompartmentRequest
+	if !decode(w, r, &req) {
+		return
+	}
+	i := s.findCompartment(w, r)
+	if i < 0 {
+		return
+	}
+	if req.Path == "" {
+		badRequest(w, "path is required")
+		return
+	}
+
+	name := s.data.Compartments[i].Name
+	files := s.data.CompartmentFiles[name]
+	if !slices.ContainsFunc(files, func(f apiv1.CompartmentFile) bool { return f.Path == req.Path }) {
+		s.data.CompartmentFiles[name] = append(files, apiv1.CompartmentFile{
+			Path:       req.Path,
+			AssignedAt: s.now(),
+		})
+	}
+	s.record(r, "COMPARTMENT_ASSIGN", "file", req.Path)
+	writeJSON(w, http.StatusOK, apiv1.AssignCompartmentResponse{FilesAssigned: 1})
+}
+
+func (s *Server) listCompartmentFiles(w http.ResponseWriter, r *http.Request) {
+	i := s.findCompartment(w, r)
+	if i < 0 {
+		return
+	}
+	files := s.data.CompartmentFiles[s.data.Compartments[i].Name]
+
+	page, next, ok := paginate(w, r, files)
+	if !ok {
+		return
+	}
+	writeJSON(w, http.StatusOK, apiv1.CompartmentFileList{Files: page, Total: len(files), NextCursor: next})
+}
+
+func (s *Server) grantCompartment(w http.ResponseWriter, r *http.Request) {
+	var req apiv1.GrantCompartmentRequest
+	if !decode(w, r, &req) {
+		return
+	}
+	i := s.findCompartment(w, r)
+	if i < 0 {
+		return
+	}
+	if req.User == "" {
+		badRequest(w, "user is required")
+		return
+	}
+	if req.Access != "read" && req.Access != "write" && req.Access != "admin" {
+		badRequest(w, "access must be read, write or admin")
+		return
+	}
+
+	name := s.data.Compartments[i].Name
+	if s.data.CompartmentUsers[name] == nil {
+		s.data.CompartmentUsers[name] = make(map[string]string)
+	}
+	s.data.CompartmentUsers[name][req.User] = req.Access
+	s.record(r, "COMPARTMENT_GRANT", "compartment", name)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) revokeCompartment(w http.ResponseWriter, r *http.Request) {
+	i := s.findCompartment(w, r)
+	if i < 0 {
+		return
+	}
+	name := s.data.Compartments[i].Name
+	user := r.PathValue("user")
+	if _, ok := s.data.CompartmentUsers[name][user]; !ok {
+		notFound(w, "compartment user", user)
+		return
+	}
+	delete(s.data.CompartmentUsers[name], user)
+	s.record(r, "COMPARTMENT_REVOKE", "compartment", name)
+	w.WriteHeader(http.StatusNoContent)
+}
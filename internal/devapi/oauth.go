@@ -0,0 +1,266 @@
+package devapi
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"html"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/darkstorage/cli/internal/auth"
+)
+
+const defaultTokenTTL = time.Hour
+
+type authCode struct {
+	challenge   string
+	redirectURI string
+	expires     time.Time
+}
+
+// Device authorization grant (RFC 8628) settings
+const (
+	deviceCodeTTL      = 10 * time.Minute
+	devicePollInterval = 5 * time.Second
+)
+
+type deviceGrant struct {
+	userCode string
+	expires  time.Time
+	interval time.Duration
+	lastPoll time.Time
+	approved bool
+	denied   bool
+}
+
+// SetTokenTTL sets the lifetime of issued access tokens, e.g. to exercise
+// refresh with a short value
+func (s *Server) SetTokenTTL(ttl time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tokenTTL = ttl
+}
+
+// authorize approves every request immediately and redirects back with a
+// code, standing in for the console's login page
+func (s *Server) authorize(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+
+	redirectURI, err := url.Parse(q.Get("redirect_uri"))
+	if err != nil || redirectURI.Scheme != "http" || !isLoopback(redirectURI.Hostname()) {
+		badRequest(w, "redirect_uri must be a loopback http URL")
+		return
+	}
+	if q.Get("response_type") != "code" {
+		badRequest(w, "response_type must be code")
+		return
+	}
+	if q.Get("code_challenge") == "" || q.Get("code_challenge_method") != "S256" {
+		badRequest(w, "PKCE with S256 is required")
+		return
+	}
+
+	code := newID()
+	s.codes[code] = authCode{
+		challenge:   q.Get("code_challenge"),
+		redirectURI: redirectURI.String(),
+		expires:     s.now().Add(5 * time.Minute),
+	}
+
+	callback := url.Values{"code": {code}, "state": {q.Get("state")}}
+	redirectURI.RawQuery = callback.Encode()
+	http.Redirect(w, r, redirectURI.String(), http.StatusFound)
+}
+
+func (s *Server) issueToken(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		oauthError(w, "invalid_request", err.Error())
+		return
+	}
+
+	switch r.PostForm.Get("grant_type") {
+	case "authorization_code":
+		code, ok := s.codes[r.PostForm.Get("code")]
+		delete(s.codes, r.PostForm.Get("code"))
+		if !ok || s.now().After(code.expires) {
+			oauthError(w, "invalid_grant", "authorization code is invalid or expired")
+			return
+		}
+		if code.redirectURI != r.PostForm.Get("redirect_uri") {
+			oauthError(w, "invalid_grant", "redirect_uri does not match")
+			return
+		}
+		sum := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+		if base64.RawURLEncoding.EncodeToString(sum[:]) != code.challenge {
+			oauthError(w, "invalid_grant", "code_verifier does not match")
+			return
+		}
+
+	case "refresh_token":
+		refresh := r.PostForm.Get("refresh_token")
+		if !s.refreshTokens[refresh] {
+			oauthError(w, "invalid_grant", "refresh token is invalid")
+			return
+		}
+		// Rotate: each refresh token is single use
+		delete(s.refreshTokens, refresh)
+
+	case auth.DeviceGrantType:
+		if !s.pollDevice(w, r.PostForm.Get("device_code")) {
+			return
+		}
+
+	default:
+		oauthError(w, "unsupported_grant_type", "")
+		return
+	}
+
+	access := "dev_at_" + newID()
+	refresh := "dev_rt_" + newID()
+	s.accessTokens[access] = s.now().Add(s.tokenTTL)
+	s.refreshTokens[refresh] = true
+
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"access_token":  access,
+		"refresh_token": refresh,
+		"token_type":    "Bearer",
+		"expires_in":    int(s.tokenTTL.Seconds()),
+	})
+}
+
+// deviceAuthorization starts a device grant. The user code is entered at
+// /oauth/device, which stands in for the console's activation page.
+func (s *Server) deviceAuthorization(w http.ResponseWriter, r *http.Request) {
+	deviceCode := newID()
+	userCode := newUserCode()
+	s.devices[deviceCode] = &deviceGrant{
+		userCode: userCode,
+		expires:  s.now().Add(deviceCodeTTL),
+		interval: devicePollInterval,
+	}
+
+	verificationURI := "http://" + r.Host + "/oauth/device"
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"device_code":               deviceCode,
+		"user_code":                 userCode,
+		"verification_uri":          verificationURI,
+		"verification_uri_complete": verificationURI + "?user_code=" + url.QueryEscape(userCode),
+		"expires_in":                int(deviceCodeTTL.Seconds()),
+		"interval":                  int(devicePollInterval.Seconds()),
+	})
+}
+
+// verifyDevice shows a form for the user code, and approves the matching
+// grant once one is submitted. action=deny rejects it instead.
+func (s *Server) verifyDevice(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+
+	userCode := strings.ToUpper(strings.TrimSpace(r.URL.Query().Get("user_code")))
+	if userCode == "" {
+		fmt.Fprint(w, devicePage("Activate a device", `<form method="get">
+<input name="user_code" placeholder="XXXX-XXXX" autofocus>
+<button name="action" value="approve">Approve</button>
+<button name="action" value="deny">Deny</button>
+</form>`))
+		return
+	}
+
+	for _, grant := range s.devices {
+		if grant.userCode != userCode || s.now().After(grant.expires) {
+			continue
+		}
+		if r.URL.Query().Get("action") == "deny" {
+			grant.denied = true
+			fmt.Fprint(w, devicePage("Request denied", "The device was not signed in."))
+		} else {
+			grant.approved = true
+			fmt.Fprint(w, devicePage("Device approved", "You can return to your terminal."))
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNotFound)
+	fmt.Fprint(w, devicePage("Unknown code", "No pending request matches "+html.EscapeString(userCode)+"."))
+}
+
+// pollDevice answers a device_code token request, writing the error
+// response and returning false until the grant is approved
+func (s *Server) pollDevice(w http.ResponseWriter, deviceCode string) bool {
+	grant, ok := s.devices[deviceCode]
+	if !ok {
+		oauthError(w, "invalid_grant", "device code is invalid")
+		return false
+	}
+
+	now := s.now()
+	switch {
+	case now.After(grant.expires):
+		delete(s.devices, deviceCode)
+		oauthError(w, "expired_token", "device code has expired")
+		return false
+	case grant.denied:
+		delete(s.devices, deviceCode)
+		oauthError(w, "access_denied", "the user denied the request")
+		return false
+	case grant.approved:
+		delete(s.devices, deviceCode)
+		return true
+	}
+
+	tooSoon := !grant.lastPoll.IsZero() && now.Sub(grant.lastPoll) < grant.interval
+	grant.lastPoll = now
+	if tooSoon {
+		grant.interval += 5 * time.Second
+		oauthError(w, "slow_down", "polling too frequently")
+		return false
+	}
+	oauthError(w, "authorization_pending", "")
+	return false
+}
+
+// newUserCode returns a code like WDJB-MJHT, avoiding vowels and look-alike
+// letters as RFC 8628 section 6.1 suggests
+func newUserCode() string {
+	const alphabet = "BCDFGHJKLMNPQRSTVWXZ"
+	b := make([]byte, 8)
+	rand.Read(b)
+	for i := range b {
+		b[i] = alphabet[int(b[i])%len(alphabet)]
+	}
+	return string(b[:4]) + "-" + string(b[4:])
+}
+
+func devicePage(title, body string) string {
+	return `<!DOCTYPE html>
+<html>
+<head><title>` + title + `</title></head>
+<body style="font-family: system-ui; padding: 2rem; text-align: center;">
+<h1>` + title + `</h1>
+` + body + `
+</body>
+</html>`
+}
+
+// tokenExpired reports whether token was issued here and has expired.
+// Tokens from elsewhere are not tracked.
+func (s *Server) tokenExpired(token string) bool {
+	expiry, ok := s.accessTokens[token]
+	return ok && !s.now().Before(expiry)
+}
+
+func oauthError(w http.ResponseWriter, code, description string) {
+	writeJSON(w, http.StatusBadRequest, map[string]string{
+		"error":             code,
+		"error_description": description,
+	})
+}
+
+func isLoopback(host string) bool {
+	return host == "localhost" || host == "::1" || strings.HasPrefix(host, "127.")
+}
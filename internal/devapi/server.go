@@ -0,0 +1,278 @@
+// Package devapi is an in-memory fake of the Dark Storage /v1 REST API for
+// offline demos and integration tests. It speaks the same types as the
+// internal/api/v1 client.
+package devapi
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	apiv1 "github.com/darkstorage/cli/internal/api/v1"
+)
+
+const (
+	defaultPageSize = 100
+	maxPageSize     = 1000
+)
+
+type Server struct {
+	mu    sync.Mutex
+	data  *Fixtures
+	token string
+	now   func() time.Time
+	mux   *http.ServeMux
+
+	tokenTTL      time.Duration
+	codes         map[string]authCode
+	devices       map[string]*deviceGrant
+	accessTokens  map[string]time.Time
+	refreshTokens map[string]bool
+}
+
+// New returns a server over fixtures. When token is non-empty requests must
+// present it or a token issued through /v1/oauth/token; otherwise any
+// unexpired bearer token is accepted.
+func New(fixtures *Fixtures, token string) *Server {
+	if fixtures == nil {
+		fixtures = EmptyFixtures()
+	}
+	fixtures.init()
+
+	s := &Server{
+		data:  fixtures,
+		token: token,
+		now:   time.Now,
+		mux:   http.NewServeMux(),
+
+		tokenTTL:      defaultTokenTTL,
+		codes:         make(map[string]authCode),
+		devices:       make(map[string]*deviceGrant),
+		accessTokens:  make(map[string]time.Time),
+		refreshTokens: make(map[string]bool),
+	}
+	s.routes()
+	return s
+}
+
+// Snapshot returns a copy of the current state in fixture form
+func (s *Server) Snapshot() (*Fixtures, error) {
+	s.mu.Lock()
+	data, err := json.Marshal(s.data)
+	s.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	var f Fixtures
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, err
+	}
+	f.init()
+	return &f, nil
+}
+
+func (s *Server) routes() {
+	s.mux.HandleFunc("GET /oauth/authorize", s.authorize)
+	s.mux.HandleFunc("POST /v1/oauth/token", s.issueToken)
+	s.mux.HandleFunc("POST /v1/oauth/device/code", s.deviceAuthorization)
+	s.mux.HandleFunc("GET /oauth/device", s.verifyDevice)
+
+	s.mux.HandleFunc("GET /v1/user/profile", s.getProfile)
+
+	s.mux.HandleFunc("GET /v1/sdms/recoverable", s.listRecoverable)
+	s.mux.HandleFunc("GET /v1/sdms/{id}", s.getDeletedFile)
+	s.mux.HandleFunc("POST /v1/sdms/{id}/recover", s.recoverFile)
+	s.mux.HandleFunc("DELETE /v1/sdms/{id}", s.purgeFile)
+
+	s.mux.HandleFunc("GET /v1/audit", s.listAuditEvents)
+	s.mux.HandleFunc("GET /v1/audit/summary", s.auditSummary)
+
+	s.mux.HandleFunc("GET /v1/sharing/links", s.listShareLinks)
+	s.mux.HandleFunc("POST /v1/sharing/links", s.createShareLink)
+	s.mux.HandleFunc("DELETE /v1/sharing/links/{id}", s.revokeShareLink)
+	s.mux.HandleFunc("GET /v1/sharing/links/{id}/stats", s.shareLinkStats)
+
+	s.mux.HandleFunc("GET /v1/groups", s.listGroups)
+	s.mux.HandleFunc("POST /v1/groups", s.createGroup)
+	s.mux.HandleFunc("DELETE /v1/groups/{id}", s.deleteGroup)
+	s.mux.HandleFunc("GET /v1/groups/{id}/members", s.listGroupMembers)
+	s.mux.HandleFunc("POST /v1/groups/{id}/members", s.addGroupMember)
+	s.mux.HandleFunc("DELETE /v1/groups/{id}/members/{email}", s.removeGroupMember)
+
+	s.mux.HandleFunc("GET /v1/files/{id}/permissions", s.listPermissions)
+	s.mux.HandleFunc("POST /v1/files/{id}/permissions", s.grantPermission)
+	s.mux.HandleFunc("DELETE /v1/files/{id}/permissions/{pid}", s.revokePermission)
+	s.mux.HandleFunc("GET /v1/files/{id}/permissions/effective", s.effectivePermissions)
+
+	// Scans address objects by bucket/key, so the path has several segments
+	s.mux.HandleFunc("POST /v1/files/{path...}", s.scanFile)
+	s.mux.HandleFunc("GET /v1/files/{id}/scan-result", s.scanResult)
+	s.mux.HandleFunc("GET /v1/scanner/threats", s.listThreats)
+	s.mux.HandleFunc("GET /v1/scanner/quarantine", s.listQuarantine)
+	s.mux.HandleFunc("POST /v1/scanner/quarantine/{id}/release", s.releaseQuarantine)
+
+	s.mux.HandleFunc("GET /v1/compartments", s.listCompartments)
+	s.mux.HandleFunc("POST /v1/compartments", s.createCompartment)
+	s.mux.HandleFunc("DELETE /v1/compartments/{name}", s.deleteCompartment)
+	s.mux.HandleFunc("POST /v1/compartments/{name}/assign", s.assignCompartment)
+	s.mux.HandleFunc("GET /v1/compartments/{name}/files", s.listCompartmentFiles)
+	s.mux.HandleFunc("POST /v1/compartments/{name}/grant", s.grantCompartment)
+	s.mux.HandleFunc("DELETE /v1/compartments/{name}/users/{user}", s.revokeCompartment)
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	requestID := r.Header.Get("X-Request-ID")
+	if requestID == "" {
+		requestID = newID()
+	}
+	w.Header().Set("X-Request-ID", requestID)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !strings.HasPrefix(r.URL.Path, "/oauth/") && !strings.HasPrefix(r.URL.Path, "/v1/oauth/") {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		_, issued := s.accessTokens[token]
+		if !ok || token == "" || (s.token != "" && token != s.token && !issued) {
+			writeError(w, http.StatusUnauthorized, "unauthorized", "missing or invalid bearer token")
+			return
+		}
+		if s.tokenExpired(token) {
+			writeError(w, http.StatusUnauthorized, "token_expired", "access token has expired")
+			return
+		}
+	}
+
+	s.mux.ServeHTTP(w, r)
+}
+
+func (s *Server) getProfile(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.data.Profile)
+}
+
+// record appends an audit event for a change made through the API
+func (s *Server) record(r *http.Request, eventType, resourceType, resourceName string) {
+	s.data.AuditEvents = append(s.data.AuditEvents, apiv1.AuditEvent{
+		ID:           newID(),
+		EventType:    eventType,
+		ResourceType: resourceType,
+		ResourceName: resourceName,
+		ActorEmail:   s.data.Profile.Email,
+		IPAddress:    remoteIP(r),
+		UserAgent:    r.UserAgent(),
+		CreatedAt:    s.now(),
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, code, message string) {
+	writeJSON(w, status, map[string]interface{}{
+		"error": map[string]string{
+			"code":       code,
+			"message":    message,
+			"request_id": w.Header().Get("X-Request-ID"),
+		},
+	})
+}
+
+func notFound(w http.ResponseWriter, what, id string) {
+	writeError(w, http.StatusNotFound, "not_found", what+" "+id+" not found")
+}
+
+func badRequest(w http.ResponseWriter, message string) {
+	writeError(w, http.StatusBadRequest, "invalid_request", message)
+}
+
+func message(w http.ResponseWriter, text string) {
+	writeJSON(w, http.StatusOK, apiv1.MessageResponse{Message: text})
+}
+
+// decode reads a JSON body, answering 400 on failure
+func decode(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		badRequest(w, "invalid JSON body: "+err.Error())
+		return false
+	}
+	return true
+}
+
+// paginate applies limit and cursor (an offset) to items
+func paginate[T any](w http.ResponseWriter, r *http.Request, items []T) ([]T, string, bool) {
+	q := r.URL.Query()
+	if items == nil {
+		// Encode as [] rather than null
+		items = []T{}
+	}
+
+	limit := defaultPageSize
+	if value := q.Get("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 {
+			badRequest(w, "invalid limit")
+			return nil, "", false
+		}
+		limit = min(n, maxPageSize)
+	}
+
+	offset := 0
+	if value := q.Get("cursor"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			badRequest(w, "invalid cursor")
+			return nil, "", false
+		}
+		offset = min(n, len(items))
+	}
+
+	end := min(offset+limit, len(items))
+	next := ""
+	if end < len(items) {
+		next = strconv.Itoa(end)
+	}
+	return items[offset:end], next, true
+}
+
+// matchID reports whether id selects want. Unique prefixes are accepted
+// so the shortened IDs that list commands print can be pasted back.
+func matchID[T any](items []T, id string, key func(T) string) int {
+	found := -1
+	for i, item := range items {
+		k := key(item)
+		if k == id {
+			return i
+		}
+		if len(id) >= 6 && strings.HasPrefix(k, id) {
+			if found >= 0 {
+				return -1
+			}
+			found = i
+		}
+	}
+	return found
+}
+
+func remoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+func newID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,105 @@
+package filetype
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+)
+
+// signature describes the magic bytes of a well-known file format
+type signature struct {
+	Name       string
+	Offset     int
+	Magic      []byte
+	Extensions []string
+	Compressed bool // content is already compressed or encrypted (high entropy is normal)
+}
+
+var signatures = []signature{
+	{Name: "ZIP/JAR/APK/DOCX/XLSX", Magic: []byte{0x50, 0x4B, 0x03, 0x04},
+		Extensions: []string{".zip", ".jar", ".apk", ".docx", ".xlsx", ".pptx", ".odt", ".ods", ".odp", ".epub"}, Compressed: true},
+	{Name: "GZIP", Magic: []byte{0x1F, 0x8B}, Extensions: []string{".gz", ".tgz"}, Compressed: true},
+	{Name: "BZIP2", Magic: []byte("BZh"), Extensions: []string{".bz2", ".tbz2"}, Compressed: true},
+	{Name: "XZ", Magic: []byte{0xFD, '7', 'z', 'X', 'Z', 0x00}, Extensions: []string{".xz", ".txz"}, Compressed: true},
+	{Name: "7-Zip", Magic: []byte{'7', 'z', 0xBC, 0xAF, 0x27, 0x1C}, Extensions: []string{".7z"}, Compressed: true},
+	{Name: "RAR", Magic: []byte("Rar!"), Extensions: []string{".rar"}, Compressed: true},
+	{Name: "TAR archive", Offset: 257, Magic: []byte("ustar"), Extensions: []string{".tar"}},
+	{Name: "PDF", Magic: []byte("%PDF"), Extensions: []string{".pdf"}},
+	{Name: "PNG", Magic: []byte{0x89, 'P', 'N', 'G'}, Extensions: []string{".png"}, Compressed: true},
+	{Name: "JPEG", Magic: []byte{0xFF, 0xD8, 0xFF}, Extensions: []string{".jpg", ".jpeg"}, Compressed: true},
+	{Name: "GIF", Magic: []byte("GIF8"), Extensions: []string{".gif"}, Compressed: true},
+	{Name: "SQLite", Magic: []byte("SQLite format 3\x00"), Extensions: []string{".sqlite", ".sqlite3"}},
+	{Name: "OLE2 (legacy Office)", Magic: []byte{0xD0, 0xCF, 0x11, 0xE0}, Extensions: []string{".doc", ".xls", ".ppt", ".msi"}},
+	{Name: "ELF executable", Magic: []byte{0x7F, 'E', 'L', 'F'}},
+	{Name: "PE executable", Magic: []byte("MZ"), Extensions: []string{".exe", ".dll"}},
+}
+
+// compressedExtensions lists formats without a reliable signature that are
+// nonetheless expected to have high entropy
+var compressedExtensions = map[string]bool{
+	".mp3": true, ".mp4": true, ".mkv": true, ".webm": true, ".mov": true,
+	".webp": true, ".heic": true, ".zst": true, ".lz4": true, ".gpg": true,
+	".age": true, ".enc": true,
+}
+
+// Detect returns the name of the format identified by the file's leading
+// bytes, or an empty string if none of the known signatures match
+func Detect(header []byte) string {
+	for _, sig := range signatures {
+		if matches(sig, header) {
+			return sig.Name
+		}
+	}
+	return ""
+}
+
+// MatchesExtension reports whether the leading bytes are consistent with
+// the file's extension. known is false when the extension has no signature
+// to check against, in which case ok is always true.
+func MatchesExtension(name string, header []byte) (ok bool, known bool) {
+	ext := strings.ToLower(filepath.Ext(name))
+	if ext == "" {
+		return true, false
+	}
+
+	for _, sig := range signatures {
+		for _, e := range sig.Extensions {
+			if e != ext {
+				continue
+			}
+			known = true
+			if matches(sig, header) {
+				return true, true
+			}
+		}
+	}
+	return !known, known
+}
+
+// IsCompressed reports whether a file with this name is expected to contain
+// compressed or encrypted (high entropy) data
+func IsCompressed(name string) bool {
+	ext := strings.ToLower(filepath.Ext(name))
+	if compressedExtensions[ext] {
+		return true
+	}
+	for _, sig := range signatures {
+		if !sig.Compressed {
+			continue
+		}
+		for _, e := range sig.Extensions {
+			if e == ext {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func matches(sig signature, header []byte) bool {
+	end := sig.Offset + len(sig.Magic)
+	if len(header) < end {
+		return false
+	}
+	return bytes.Equal(header[sig.Offset:end], sig.Magic)
+}
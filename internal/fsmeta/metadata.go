@@ -0,0 +1,289 @@
+// Package fsmeta captures POSIX file metadata as object metadata and
+// restores it onto local files, so a round trip through storage keeps
+// permissions, timestamps, symlinks and extended attributes intact.
+package fsmeta
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Object metadata keys. Backends may change their case, so use Lookup to
+// read them back.
+const (
+	KeyMode    = "posix-mode"
+	KeyUID     = "posix-uid"
+	KeyGID     = "posix-gid"
+	KeyMtime   = "posix-mtime"
+	KeySymlink = "posix-symlink"
+	KeyXattrs  = "posix-xattrs"
+)
+
+// Object metadata is limited to a few KB in total, so xattrs beyond this
+// encoded size are dropped rather than failing the upload
+const maxXattrBytes = 1024
+
+// Attributes under this prefix are internal (e.g. placeholder markers) and
+// are never copied
+const internalXattrPrefix = "user.darkstorage."
+
+var ErrXattrUnsupported = errors.New("extended attributes not supported")
+
+// Options selects which optional metadata is captured and restored
+type Options struct {
+	Owner  bool     // uid/gid; restoring usually requires root
+	Xattrs []string // glob patterns of attribute names, e.g. "user.*"
+}
+
+// DefaultOptions preserves user xattrs but not ownership
+func DefaultOptions() Options {
+	return Options{Xattrs: []string{"user.*"}}
+}
+
+// Capture describes the file at p (without following symlinks) as object
+// metadata
+func Capture(p string, opts Options) (map[string]string, error) {
+	info, err := os.Lstat(p)
+	if err != nil {
+		return nil, err
+	}
+
+	meta := map[string]string{
+		KeyMtime: strconv.FormatInt(info.ModTime().UnixNano(), 10),
+	}
+
+	if info.Mode()&os.ModeSymlink != 0 {
+		target, err := os.Readlink(p)
+		if err != nil {
+			return nil, err
+		}
+		meta[KeySymlink] = target
+	} else {
+		meta[KeyMode] = formatMode(info.Mode())
+	}
+
+	if opts.Owner {
+		if uid, gid, ok := owner(info); ok {
+			meta[KeyUID] = strconv.Itoa(uid)
+			meta[KeyGID] = strconv.Itoa(gid)
+		}
+	}
+
+	if len(opts.Xattrs) > 0 {
+		if encoded := captureXattrs(p, opts.Xattrs); encoded != "" {
+			meta[KeyXattrs] = encoded
+		}
+	}
+
+	return meta, nil
+}
+
+// Apply restores captured metadata onto p. Every field is attempted; the
+// returned error joins whatever could not be applied.
+func Apply(p string, meta map[string]string, opts Options) error {
+	var errs []error
+
+	_, isLink := SymlinkTarget(meta)
+
+	if value, ok := Lookup(meta, KeyXattrs); ok && len(opts.Xattrs) > 0 {
+		if err := applyXattrs(p, value, opts.Xattrs); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if value, ok := Lookup(meta, KeyMode); ok && !isLink {
+		mode, err := parseMode(value)
+		if err == nil {
+			err = os.Chmod(p, mode)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("mode: %w", err))
+		}
+	}
+
+	if opts.Owner {
+		uidValue, hasUID := Lookup(meta, KeyUID)
+		gidValue, hasGID := Lookup(meta, KeyGID)
+		if hasUID && hasGID {
+			uid, err1 := strconv.Atoi(uidValue)
+			gid, err2 := strconv.Atoi(gidValue)
+			if err := errors.Join(err1, err2); err != nil {
+				errs = append(errs, fmt.Errorf("owner: %w", err))
+			} else if err := lchown(p, uid, gid); err != nil {
+				errs = append(errs, fmt.Errorf("owner: %w", err))
+			}
+		}
+	}
+
+	// Last, since the other changes can touch timestamps
+	if mtime, ok := ModTime(meta); ok {
+		if err := lchtimes(p, mtime); err != nil {
+			errs = append(errs, fmt.Errorf("mtime: %w", err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// Lookup reads a key case-insensitively
+func Lookup(meta map[string]string, key string) (string, bool) {
+	if value, ok := meta[key]; ok {
+		return value, true
+	}
+	for k, value := range meta {
+		if strings.EqualFold(k, key) {
+			return value, true
+		}
+	}
+	return "", false
+}
+
+// SymlinkTarget returns the link target when the object represents a symlink
+func SymlinkTarget(meta map[string]string) (string, bool) {
+	target, ok := Lookup(meta, KeySymlink)
+	return target, ok && target != ""
+}
+
+// ModTime returns the stored modification time with nanosecond precision
+func ModTime(meta map[string]string) (time.Time, bool) {
+	value, ok := Lookup(meta, KeyMtime)
+	if !ok {
+		return time.Time{}, false
+	}
+	nanos, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return time.Unix(0, nanos), true
+}
+
+// CreateSymlink replaces whatever is at p with a symlink to target
+func CreateSymlink(p, target string) error {
+	if info, err := os.Lstat(p); err == nil {
+		if info.IsDir() {
+			return fmt.Errorf("%s is a directory", p)
+		}
+		if err := os.Remove(p); err != nil {
+			return err
+		}
+	}
+	return os.Symlink(target, p)
+}
+
+// formatMode encodes permission and special bits in the usual octal form
+func formatMode(mode os.FileMode) string {
+	bits := uint32(mode.Perm())
+	if mode&os.ModeSetuid != 0 {
+		bits |= 0o4000
+	}
+	if mode&os.ModeSetgid != 0 {
+		bits |= 0o2000
+	}
+	if mode&os.ModeSticky != 0 {
+		bits |= 0o1000
+	}
+	return fmt.Sprintf("%04o", bits)
+}
+
+func parseMode(value string) (os.FileMode, error) {
+	bits, err := strconv.ParseUint(value, 8, 32)
+	if err != nil {
+		return 0, err
+	}
+
+	mode := os.FileMode(bits).Perm()
+	if bits&0o4000 != 0 {
+		mode |= os.ModeSetuid
+	}
+	if bits&0o2000 != 0 {
+		mode |= os.ModeSetgid
+	}
+	if bits&0o1000 != 0 {
+		mode |= os.ModeSticky
+	}
+	return mode, nil
+}
+
+func matchesAny(name string, patterns []string) bool {
+	if strings.HasPrefix(name, internalXattrPrefix) {
+		return false
+	}
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// captureXattrs encodes selected attributes as base64 JSON, keeping as many
+// as fit within maxXattrBytes
+func captureXattrs(p string, patterns []string) string {
+	names, err := ListXattrs(p)
+	if err != nil {
+		return ""
+	}
+	sort.Strings(names)
+
+	values := make(map[string][]byte)
+	encoded := ""
+	for _, name := range names {
+		if !matchesAny(name, patterns) {
+			continue
+		}
+		value, err := GetXattr(p, name)
+		if err != nil {
+			continue
+		}
+
+		values[name] = value
+		candidate, err := encodeXattrs(values)
+		if err != nil || len(candidate) > maxXattrBytes {
+			delete(values, name)
+			continue
+		}
+		encoded = candidate
+	}
+	return encoded
+}
+
+func encodeXattrs(values map[string][]byte) (string, error) {
+	data, err := json.Marshal(values)
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(data), nil
+}
+
+func applyXattrs(p, encoded string, patterns []string) error {
+	data, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return fmt.Errorf("xattrs: %w", err)
+	}
+
+	var values map[string][]byte
+	if err := json.Unmarshal(data, &values); err != nil {
+		return fmt.Errorf("xattrs: %w", err)
+	}
+
+	for name, value := range values {
+		if !matchesAny(name, patterns) {
+			continue
+		}
+		if err := SetXattr(p, name, value); err != nil {
+			if err == ErrXattrUnsupported {
+				return nil
+			}
+			return fmt.Errorf("xattr %s: %w", name, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,27 @@
+//go:build !linux && !darwin
+
+package fsmeta
+
+import (
+	"os"
+	"time"
+)
+
+func owner(info os.FileInfo) (uid, gid int, ok bool) {
+	return 0, 0, false
+}
+
+func lchown(path string, uid, gid int) error {
+	return nil
+}
+
+func lchtimes(path string, mtime time.Time) error {
+	info, err := os.Lstat(path)
+	if err != nil {
+		return err
+	}
+	if info.Mode()&os.ModeSymlink != 0 {
+		return nil
+	}
+	return os.Chtimes(path, mtime, mtime)
+}
@@ -0,0 +1,19 @@
+//go:build !linux && !darwin
+
+package fsmeta
+
+func SetXattr(path, name string, value []byte) error {
+	return ErrXattrUnsupported
+}
+
+func GetXattr(path, name string) ([]byte, error) {
+	return nil, ErrXattrUnsupported
+}
+
+func RemoveXattr(path, name string) error {
+	return ErrXattrUnsupported
+}
+
+func ListXattrs(path string) ([]string, error) {
+	return nil, ErrXattrUnsupported
+}
@@ -0,0 +1,74 @@
+//go:build linux || darwin
+
+package fsmeta
+
+import (
+	"errors"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+// SetXattr sets an extended attribute without following symlinks
+func SetXattr(path, name string, value []byte) error {
+	err := unix.Lsetxattr(path, name, value, 0)
+	if errors.Is(err, unix.ENOTSUP) {
+		return ErrXattrUnsupported
+	}
+	return err
+}
+
+// GetXattr reads an extended attribute without following symlinks
+func GetXattr(path, name string) ([]byte, error) {
+	size, err := unix.Lgetxattr(path, name, nil)
+	if err != nil {
+		if errors.Is(err, unix.ENOTSUP) {
+			return nil, ErrXattrUnsupported
+		}
+		return nil, err
+	}
+
+	buf := make([]byte, size)
+	size, err = unix.Lgetxattr(path, name, buf)
+	if err != nil {
+		return nil, err
+	}
+	return buf[:size], nil
+}
+
+// RemoveXattr removes an extended attribute without following symlinks
+func RemoveXattr(path, name string) error {
+	err := unix.Lremovexattr(path, name)
+	if errors.Is(err, unix.ENOTSUP) {
+		return ErrXattrUnsupported
+	}
+	return err
+}
+
+// ListXattrs returns the names of all extended attributes on path
+func ListXattrs(path string) ([]string, error) {
+	size, err := unix.Llistxattr(path, nil)
+	if err != nil {
+		if errors.Is(err, unix.ENOTSUP) {
+			return nil, ErrXattrUnsupported
+		}
+		return nil, err
+	}
+	if size == 0 {
+		return nil, nil
+	}
+
+	buf := make([]byte, size)
+	size, err = unix.Llistxattr(path, buf)
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, name := range strings.Split(string(buf[:size]), "\x00") {
+		if name != "" {
+			names = append(names, name)
+		}
+	}
+	return names, nil
+}
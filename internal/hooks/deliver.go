@@ -0,0 +1,169 @@
+package hooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Headers sent with each webhook. The signature is "sha256=" and the hex
+// HMAC-SHA256, keyed by the hook's secret, of the timestamp, a '.' and
+// the body.
+const (
+	HeaderEvent     = "X-Darkstorage-Event"
+	HeaderDelivery  = "X-Darkstorage-Delivery"
+	HeaderTimestamp = "X-Darkstorage-Timestamp"
+	HeaderSignature = "X-Darkstorage-Signature"
+)
+
+const (
+	// maxBackoff caps the wait between webhook attempts
+	maxBackoff = time.Minute
+	// maxOutput is how much of a failed command's output is logged
+	maxOutput = 1024
+)
+
+// runCommand runs the hook's command with the event on stdin
+func runCommand(ctx context.Context, hook *Hook, eventType string, body []byte) error {
+	ctx, cancel := context.WithTimeout(ctx, hook.timeout())
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, hook.Command[0], hook.Command[1:]...)
+	cmd.Stdin = bytes.NewReader(body)
+	cmd.Env = append(os.Environ(), "DARKSTORAGE_EVENT="+eventType, "DARKSTORAGE_HOOK="+hook.Name)
+	// Don't wait for children that keep the output open after it exits
+	cmd.WaitDelay = time.Second
+
+	out, err := cmd.CombinedOutput()
+	if err == nil {
+		return nil
+	}
+	if ctx.Err() == context.DeadlineExceeded {
+		err = fmt.Errorf("timed out after %s", hook.timeout())
+	}
+	if output := strings.TrimSpace(string(out)); output != "" {
+		if len(output) > maxOutput {
+			output = output[:maxOutput] + "..."
+		}
+		return fmt.Errorf("%w: %s", err, output)
+	}
+	return err
+}
+
+// post sends the event to the hook's URL, retrying network errors and
+// responses that say to try again
+func (d *Dispatcher) post(ctx context.Context, hook *Hook, eventType string, body []byte, retries int) error {
+	delivery, err := newDeliveryID()
+	if err != nil {
+		return err
+	}
+
+	for attempt := 0; ; attempt++ {
+		retryAfter, err := d.postOnce(ctx, hook, eventType, delivery, body)
+		if err == nil {
+			return nil
+		}
+		if retryAfter < 0 || attempt >= retries {
+			return err
+		}
+
+		wait := backoff(attempt)
+		if retryAfter > 0 {
+			wait = retryAfter
+		}
+		logger.Warn("webhook failed, retrying", "hook", hook.Name, "event", eventType,
+			"attempt", attempt+1, "wait", wait, "error", err)
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return err
+		}
+	}
+}
+
+// postOnce makes one attempt. retryAfter is negative if the error won't
+// go away by retrying, and positive if the server said when to retry.
+func (d *Dispatcher) postOnce(ctx context.Context, hook *Hook, eventType, delivery string, body []byte) (retryAfter time.Duration, err error) {
+	ctx, cancel := context.WithTimeout(ctx, hook.timeout())
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, hook.URL, bytes.NewReader(body))
+	if err != nil {
+		return -1, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "darkstorage-daemon")
+	req.Header.Set(HeaderEvent, eventType)
+	req.Header.Set(HeaderDelivery, delivery)
+	if hook.Secret != "" {
+		timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+		req.Header.Set(HeaderTimestamp, timestamp)
+		req.Header.Set(HeaderSignature, Sign(hook.Secret, timestamp, body))
+	}
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return 0, nil
+	}
+	err = fmt.Errorf("webhook returned %s", resp.Status)
+	switch {
+	case resp.StatusCode == http.StatusTooManyRequests, resp.StatusCode == http.StatusRequestTimeout, resp.StatusCode >= 500:
+		return parseRetryAfter(resp.Header.Get("Retry-After")), err
+	default:
+		return -1, err
+	}
+}
+
+// Sign returns the signature header value of body sent at timestamp
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// backoff is the wait before retry attempt+1: 1s, 2s, 4s and so on
+func backoff(attempt int) time.Duration {
+	if attempt >= 6 {
+		return maxBackoff
+	}
+	return min(time.Second<<attempt, maxBackoff)
+}
+
+// parseRetryAfter reads a Retry-After header given in seconds, capped at
+// maxBackoff; 0 if absent
+func parseRetryAfter(value string) time.Duration {
+	seconds, err := strconv.Atoi(value)
+	if err != nil || seconds <= 0 {
+		return 0
+	}
+	return min(time.Duration(seconds)*time.Second, maxBackoff)
+}
+
+// newDeliveryID identifies a delivery across its retries, so receivers
+// can ignore duplicates
+func newDeliveryID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,246 @@
+// Package hooks tells other programs about sync events, by running a
+// local command with the event on stdin or by posting it to a webhook.
+package hooks
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/darkstorage/cli/internal/logging"
+)
+
+var logger = logging.For(logging.Hooks)
+
+// Event types hooks can subscribe to
+const (
+	EventTransferFailed = "transfer_failed"
+	EventConflict       = "conflict"
+	EventFolderPaused   = "folder_paused"
+	EventSyncComplete   = "sync_complete"
+	// EventTest is sent by Test, whatever events the hook subscribes to
+	EventTest = "test"
+)
+
+// Events lists the event types hooks can subscribe to
+var Events = []string{EventTransferFailed, EventConflict, EventFolderPaused, EventSyncComplete}
+
+const (
+	// DefaultTimeout bounds a command, or one webhook attempt, when the
+	// hook sets no timeout
+	DefaultTimeout = 30 * time.Second
+	// DefaultRetries is how often a failed webhook is retried when the
+	// hook doesn't say
+	DefaultRetries = 3
+
+	// queueSize is how many events may wait for delivery; more are dropped
+	queueSize = 256
+	// maxDeliveries limits the hooks running at once
+	maxDeliveries = 4
+)
+
+// Event is what a hook receives, as JSON
+type Event struct {
+	Type     string    `json:"type"`
+	Time     time.Time `json:"time"`
+	FolderID int       `json:"folder_id,omitempty"`
+	// Folder is the folder's local path
+	Folder     string       `json:"folder,omitempty"`
+	Path       string       `json:"path,omitempty"`
+	Operation  string       `json:"operation,omitempty"`
+	Error      string       `json:"error,omitempty"`
+	Reason     string       `json:"reason,omitempty"`
+	ConflictID int          `json:"conflict_id,omitempty"`
+	Summary    *SyncSummary `json:"summary,omitempty"`
+}
+
+// SyncSummary counts the operations that ran before a folder's queue
+// emptied
+type SyncSummary struct {
+	Completed int `json:"completed"`
+	Failed    int `json:"failed"`
+	Cancelled int `json:"cancelled"`
+}
+
+// Hook is one configured action. It runs Command if set, and posts to URL
+// otherwise.
+type Hook struct {
+	Name   string
+	Events []string
+	// Command is the program and its arguments, run without a shell
+	Command []string
+	URL     string
+	// Secret signs webhook bodies when set
+	Secret  string
+	Timeout time.Duration
+	// Retries applies to webhooks only; commands run once
+	Retries int
+}
+
+func (h *Hook) handles(eventType string) bool {
+	for _, e := range h.Events {
+		if e == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+func (h *Hook) timeout() time.Duration {
+	if h.Timeout > 0 {
+		return h.Timeout
+	}
+	return DefaultTimeout
+}
+
+// Dispatcher delivers events to the hooks subscribed to them, in the
+// background. Deliveries may finish out of order.
+type Dispatcher struct {
+	client *http.Client
+
+	mu     sync.RWMutex
+	hooks  []Hook
+	closed bool
+
+	queue chan *Event
+	slots chan struct{}
+	wg    sync.WaitGroup
+	// ctx is cancelled when Stop gives up waiting, ending retries
+	ctx    context.Context
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+func NewDispatcher() *Dispatcher {
+	ctx, cancel := context.WithCancel(context.Background())
+	d := &Dispatcher{
+		client: &http.Client{},
+		queue:  make(chan *Event, queueSize),
+		slots:  make(chan struct{}, maxDeliveries),
+		ctx:    ctx,
+		cancel: cancel,
+		done:   make(chan struct{}),
+	}
+	go d.run()
+	return d
+}
+
+// SetHooks replaces the configured hooks. Deliveries already under way
+// finish with the old ones.
+func (d *Dispatcher) SetHooks(hooks []Hook) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.hooks = append([]Hook(nil), hooks...)
+}
+
+// Hooks returns the configured hooks
+func (d *Dispatcher) Hooks() []Hook {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return append([]Hook(nil), d.hooks...)
+}
+
+// Fire queues event for the hooks subscribed to its type. It never
+// blocks; events are dropped if delivery has fallen far behind.
+func (d *Dispatcher) Fire(event *Event) {
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	if d.closed {
+		return
+	}
+	subscribed := false
+	for i := range d.hooks {
+		if d.hooks[i].handles(event.Type) {
+			subscribed = true
+			break
+		}
+	}
+	if !subscribed {
+		return
+	}
+
+	select {
+	case d.queue <- event:
+	default:
+		logger.Warn("dropped event, hooks are too slow", "event", event.Type)
+	}
+}
+
+func (d *Dispatcher) run() {
+	defer close(d.done)
+	for event := range d.queue {
+		body, err := json.Marshal(event)
+		if err != nil {
+			logger.Error("failed to encode event", "event", event.Type, "error", err)
+			continue
+		}
+		for _, hook := range d.Hooks() {
+			if !hook.handles(event.Type) {
+				continue
+			}
+			d.slots <- struct{}{}
+			d.wg.Add(1)
+			go func(hook Hook) {
+				defer func() {
+					<-d.slots
+					d.wg.Done()
+				}()
+				d.deliver(d.ctx, &hook, event.Type, body, hook.Retries)
+			}(hook)
+		}
+	}
+	d.wg.Wait()
+}
+
+// Test sends a test event to hook and waits for the result, without
+// retrying
+func (d *Dispatcher) Test(ctx context.Context, hook Hook) error {
+	body, err := json.Marshal(&Event{Type: EventTest, Time: time.Now()})
+	if err != nil {
+		return err
+	}
+	return d.deliver(ctx, &hook, EventTest, body, 0)
+}
+
+func (d *Dispatcher) deliver(ctx context.Context, hook *Hook, eventType string, body []byte, retries int) error {
+	start := time.Now()
+	var err error
+	if len(hook.Command) > 0 {
+		err = runCommand(ctx, hook, eventType, body)
+	} else {
+		err = d.post(ctx, hook, eventType, body, retries)
+	}
+
+	if err != nil {
+		logger.Error("hook failed", "hook", hook.Name, "event", eventType, "error", err)
+		return err
+	}
+	logger.Debug("hook delivered", "hook", hook.Name, "event", eventType, "duration", time.Since(start))
+	return nil
+}
+
+// Stop delivers the events already fired, waiting up to timeout before
+// abandoning the rest
+func (d *Dispatcher) Stop(timeout time.Duration) {
+	d.mu.Lock()
+	if !d.closed {
+		d.closed = true
+		close(d.queue)
+	}
+	d.mu.Unlock()
+
+	select {
+	case <-d.done:
+	case <-time.After(timeout):
+		logger.Warn("abandoning hook deliveries still running", "timeout", timeout)
+		d.cancel()
+		<-d.done
+	}
+	d.cancel()
+}
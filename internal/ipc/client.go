@@ -1,15 +1,39 @@
 package ipc
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net"
+	"strconv"
+	"sync"
 	"time"
 )
 
+// Reconnect delays for Subscribe, doubling from the first to the second
+const (
+	subscribeMinBackoff = time.Second
+	subscribeMaxBackoff = 30 * time.Second
+)
+
+// errClosed is returned by calls on a closed Client
+var errClosed = errors.New("client closed")
+
+// errOldDaemon is returned when the daemon doesn't speak JSON-RPC, i.e. it
+// predates protocol versioning
+var errOldDaemon = errors.New("daemon uses an older IPC protocol, restart it")
+
+// Client calls the daemon over one connection, opened on first use and
+// again after it fails. It is safe for concurrent use; calls run
+// concurrently on the daemon.
 type Client struct {
 	socketPath string
 	timeout    time.Duration
+
+	mu     sync.Mutex
+	conn   *clientConn
+	closed bool
 }
 
 func NewClient(socketPath string) *Client {
@@ -19,114 +43,460 @@ func NewClient(socketPath string) *Client {
 	}
 }
 
-func (c *Client) SendCommand(cmd *Command) (*Response, error) {
-	conn, err := net.DialTimeout("unix", c.socketPath, c.timeout)
+// Call invokes method with params, which may be nil, and decodes the
+// result into result, if not nil. Without a deadline on ctx it gives up
+// after the client's timeout. Errors the daemon reports are *Error.
+func (c *Client) Call(ctx context.Context, method string, params, result interface{}) error {
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
+
+	cc, err := c.connection(ctx)
+	if err != nil {
+		return err
+	}
+	return cc.call(ctx, method, params, result)
+}
+
+// Close closes the connection, failing calls in flight
+func (c *Client) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.closed = true
+	if c.conn != nil {
+		c.conn.fail(errClosed)
+		c.conn = nil
+	}
+	return nil
+}
+
+// connection returns the open connection, dialling a new one if there is
+// none or it has failed
+func (c *Client) connection(ctx context.Context) (*clientConn, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return nil, errClosed
+	}
+	if c.conn != nil && c.conn.alive() {
+		return c.conn, nil
+	}
+
+	cc, err := c.dial(ctx, false)
+	if err != nil {
+		return nil, err
+	}
+	c.conn = cc
+	return cc, nil
+}
+
+// dial connects and checks the daemon speaks our protocol version. With
+// notifications set the connection delivers notifications from the daemon
+// on cc.notifications.
+func (c *Client) dial(ctx context.Context, notifications bool) (*clientConn, error) {
+	dialer := net.Dialer{Timeout: c.timeout}
+	conn, err := dialer.DialContext(ctx, "unix", c.socketPath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to daemon: %w", err)
 	}
-	defer conn.Close()
 
-	conn.SetDeadline(time.Now().Add(c.timeout))
+	cc := &clientConn{
+		conn:    conn,
+		encoder: json.NewEncoder(conn),
+		pending: make(map[uint64]chan *message),
+		done:    make(chan struct{}),
+	}
+	if notifications {
+		cc.notifications = make(chan *message, subscriberBuffer)
+	}
+	go cc.read()
+
+	var hello HelloResponse
+	if err := cc.call(ctx, MethodHello, &HelloRequest{Versions: []int{ProtocolVersion}}, &hello); err != nil {
+		cc.fail(err)
+		return nil, err
+	}
+	return cc, nil
+}
+
+// clientConn is one connection to the daemon
+type clientConn struct {
+	conn    net.Conn
+	writeMu sync.Mutex
+	encoder *json.Encoder
+
+	mu      sync.Mutex
+	nextID  uint64
+	pending map[uint64]chan *message
+	// err is why the connection failed; done is closed when it is set
+	err  error
+	done chan struct{}
+	// notifications receives requests from the daemon, if wanted
+	notifications chan *message
+}
 
-	encoder := json.NewEncoder(conn)
-	if err := encoder.Encode(cmd); err != nil {
-		return nil, fmt.Errorf("failed to send command: %w", err)
+func (cc *clientConn) alive() bool {
+	select {
+	case <-cc.done:
+		return false
+	default:
+		return true
 	}
+}
 
-	var response Response
-	decoder := json.NewDecoder(conn)
-	if err := decoder.Decode(&response); err != nil {
-		return nil, fmt.Errorf("failed to receive response: %w", err)
+// fail closes the connection, recording err as the reason if it is the
+// first
+func (cc *clientConn) fail(err error) {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	if cc.err != nil {
+		return
 	}
+	cc.err = err
+	close(cc.done)
+	cc.conn.Close()
+}
+
+// read hands each response to its call until the connection fails
+func (cc *clientConn) read() {
+	decoder := json.NewDecoder(cc.conn)
+	for {
+		var msg message
+		if err := decoder.Decode(&msg); err != nil {
+			cc.fail(fmt.Errorf("connection to daemon lost: %w", err))
+			return
+		}
+		if msg.JSONRPC != jsonrpcVersion {
+			cc.fail(errOldDaemon)
+			return
+		}
+
+		if msg.Method != "" {
+			if cc.notifications != nil {
+				select {
+				case cc.notifications <- &msg:
+				case <-cc.done:
+					return
+				}
+			}
+			continue
+		}
 
-	return &response, nil
+		id, err := strconv.ParseUint(string(msg.ID), 10, 64)
+		if err != nil {
+			// An error without an ID is about the connection, e.g. the
+			// daemon refusing it
+			if msg.Error != nil {
+				cc.fail(msg.Error)
+				return
+			}
+			continue
+		}
+		cc.mu.Lock()
+		ch := cc.pending[id]
+		delete(cc.pending, id)
+		cc.mu.Unlock()
+		if ch != nil {
+			ch <- &msg
+		}
+	}
 }
 
-func (c *Client) GetStatus() (*StatusResponse, error) {
-	cmd := &Command{Type: "status"}
-	resp, err := c.SendCommand(cmd)
+func (cc *clientConn) call(ctx context.Context, method string, params, result interface{}) error {
+	req := &message{JSONRPC: jsonrpcVersion, Method: method}
+	if params != nil {
+		data, err := json.Marshal(params)
+		if err != nil {
+			return err
+		}
+		req.Params = data
+	}
+
+	// Buffered so read never waits for a call that gave up
+	ch := make(chan *message, 1)
+	cc.mu.Lock()
+	if cc.err != nil {
+		cc.mu.Unlock()
+		return cc.err
+	}
+	cc.nextID++
+	id := cc.nextID
+	cc.pending[id] = ch
+	cc.mu.Unlock()
+	defer func() {
+		cc.mu.Lock()
+		delete(cc.pending, id)
+		cc.mu.Unlock()
+	}()
+	req.ID = json.RawMessage(strconv.FormatUint(id, 10))
+
+	cc.writeMu.Lock()
+	deadline, _ := ctx.Deadline()
+	cc.conn.SetWriteDeadline(deadline)
+	err := cc.encoder.Encode(req)
+	cc.writeMu.Unlock()
 	if err != nil {
-		return nil, err
+		err = fmt.Errorf("failed to send request: %w", err)
+		cc.fail(err)
+		return err
 	}
 
-	if !resp.Success {
-		return nil, fmt.Errorf("command failed: %s", resp.Error)
+	select {
+	case resp := <-ch:
+		if resp.Error != nil {
+			return resp.Error
+		}
+		if result == nil {
+			return nil
+		}
+		return json.Unmarshal(resp.Result, result)
+	case <-cc.done:
+		return cc.err
+	case <-ctx.Done():
+		return fmt.Errorf("%s: %w", method, ctx.Err())
 	}
+}
+
+// call invokes method on the daemon with the client's timeout
+func (c *Client) call(method string, req, result interface{}) error {
+	return c.Call(context.Background(), method, req, result)
+}
 
+func (c *Client) GetStatus() (*StatusResponse, error) {
 	var status StatusResponse
-	if err := json.Unmarshal(resp.Data, &status); err != nil {
+	if err := c.call("status", nil, &status); err != nil {
 		return nil, err
 	}
-
 	return &status, nil
 }
 
 func (c *Client) AddSyncFolder(req *AddSyncFolderRequest) (*AddSyncFolderResponse, error) {
-	data, err := json.Marshal(req)
-	if err != nil {
+	var result AddSyncFolderResponse
+	if err := c.call("add_sync_folder", req, &result); err != nil {
 		return nil, err
 	}
+	return &result, nil
+}
 
-	cmd := &Command{
-		Type: "add_sync_folder",
-		Data: data,
+func (c *Client) GetConfig() (*GetConfigResponse, error) {
+	var config GetConfigResponse
+	if err := c.call("get_config", nil, &config); err != nil {
+		return nil, err
 	}
+	return &config, nil
+}
 
-	resp, err := c.SendCommand(cmd)
-	if err != nil {
-		return nil, err
+func (c *Client) SetConfig(config map[string]interface{}) error {
+	return c.call("set_config", &SetConfigRequest{Config: config}, nil)
+}
+
+// ResumeFolder clears a folder's pause. With discardPending, the uploads
+// and deletes queued while it was paused are cancelled; it returns how
+// many were.
+func (c *Client) ResumeFolder(folderID int, discardPending bool) (int, error) {
+	var resp ResumeFolderResponse
+	err := c.call("resume_folder", &ResumeFolderRequest{FolderID: folderID, DiscardPending: discardPending}, &resp)
+	return resp.Discarded, err
+}
+
+// Hydrate queues downloads for dehydrated files under paths and returns the number of files affected
+func (c *Client) Hydrate(paths []string) (int, error) {
+	var result HydrateResponse
+	if err := c.call("hydrate", &HydrateRequest{Paths: paths}, &result); err != nil {
+		return 0, err
 	}
+	return result.Files, nil
+}
 
-	if !resp.Success {
-		return nil, fmt.Errorf("command failed: %s", resp.Error)
+// Dehydrate frees local copies of synced files under paths and returns the number of files affected
+func (c *Client) Dehydrate(paths []string) (int, error) {
+	var result HydrateResponse
+	if err := c.call("dehydrate", &HydrateRequest{Paths: paths}, &result); err != nil {
+		return 0, err
 	}
+	return result.Files, nil
+}
 
-	var result AddSyncFolderResponse
-	if err := json.Unmarshal(resp.Data, &result); err != nil {
+// Shutdown asks the daemon to stop. It returns once the request is
+// accepted; the daemon then finishes transfers in flight before exiting.
+func (c *Client) Shutdown() error {
+	return c.call("shutdown", nil, nil)
+}
+
+// RemoveSyncFolder stops syncing a folder. Local and remote files are kept.
+func (c *Client) RemoveSyncFolder(id int) error {
+	return c.call("remove_sync_folder", &RemoveSyncFolderRequest{ID: id}, nil)
+}
+
+// UpdateSyncFolder changes the settings set in req and resyncs the folder
+func (c *Client) UpdateSyncFolder(req *UpdateSyncFolderRequest) error {
+	return c.call("update_sync_folder", req, nil)
+}
+
+// PauseFolder holds all of a folder's transfers until ResumeFolder
+func (c *Client) PauseFolder(folderID int, reason string) error {
+	return c.call("pause_folder", &PauseFolderRequest{FolderID: folderID, Reason: reason}, nil)
+}
+
+// ForceSync starts a full scan of a folder
+func (c *Client) ForceSync(folderID int) error {
+	return c.call("force_sync", &ForceSyncRequest{FolderID: folderID}, nil)
+}
+
+func (c *Client) GetActivity(req *GetActivityRequest) ([]ActivityEntry, error) {
+	var result GetActivityResponse
+	if err := c.call("get_activity", req, &result); err != nil {
 		return nil, err
 	}
+	return result.Activities, nil
+}
 
+func (c *Client) GetFolderStats(folderID int) (*FolderStatsResponse, error) {
+	var result FolderStatsResponse
+	if err := c.call("folder_stats", &FolderStatsRequest{FolderID: folderID}, &result); err != nil {
+		return nil, err
+	}
 	return &result, nil
 }
 
-func (c *Client) GetConfig() (*GetConfigResponse, error) {
-	cmd := &Command{Type: "get_config"}
-	resp, err := c.SendCommand(cmd)
-	if err != nil {
+// ListConflicts returns unresolved conflicts, for one folder or all if
+// folderID is 0
+func (c *Client) ListConflicts(folderID int) ([]ConflictEntry, error) {
+	var result ListConflictsResponse
+	if err := c.call("list_conflicts", &ListConflictsRequest{FolderID: folderID}, &result); err != nil {
 		return nil, err
 	}
+	return result.Conflicts, nil
+}
+
+// ResolveConflict settles a conflict with keep_local, keep_remote or
+// keep_both, queueing the transfers that takes
+func (c *Client) ResolveConflict(id int, resolution string) error {
+	return c.call("resolve_conflict", &ResolveConflictRequest{ID: id, Resolution: resolution}, nil)
+}
 
-	if !resp.Success {
-		return nil, fmt.Errorf("command failed: %s", resp.Error)
+func (c *Client) ListQueue(req *ListQueueRequest) ([]QueueEntry, error) {
+	var result ListQueueResponse
+	if err := c.call("list_queue", req, &result); err != nil {
+		return nil, err
 	}
+	return result.Operations, nil
+}
 
-	var config GetConfigResponse
-	if err := json.Unmarshal(resp.Data, &config); err != nil {
+func (c *Client) GetQueueItem(id int) (*QueueEntry, error) {
+	var result QueueEntry
+	if err := c.call("get_queue_item", &QueueItemRequest{ID: id}, &result); err != nil {
 		return nil, err
 	}
+	return &result, nil
+}
 
-	return &config, nil
+// RetryQueueItem queues a failed or cancelled operation again
+func (c *Client) RetryQueueItem(id int) error {
+	return c.call("retry_queue_item", &QueueItemRequest{ID: id}, nil)
 }
 
-func (c *Client) SetConfig(config map[string]interface{}) error {
-	data, err := json.Marshal(&SetConfigRequest{Config: config})
-	if err != nil {
-		return err
+// CancelQueueItem cancels a queued operation, interrupting it if running
+func (c *Client) CancelQueueItem(id int) error {
+	return c.call("cancel_queue_item", &QueueItemRequest{ID: id}, nil)
+}
+
+// GetBandwidth returns the bandwidth limits in force and the schedule.
+// They are changed with SetConfig.
+func (c *Client) GetBandwidth() (*GetBandwidthResponse, error) {
+	var result GetBandwidthResponse
+	if err := c.call("get_bandwidth", nil, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+func (c *Client) ListHooks() ([]HookEntry, error) {
+	var result ListHooksResponse
+	if err := c.call("list_hooks", nil, &result); err != nil {
+		return nil, err
 	}
+	return result.Hooks, nil
+}
+
+// TestHook sends a test event to the named hook and waits for it to be
+// delivered, which may take longer than the client's usual timeout
+func (c *Client) TestHook(ctx context.Context, name string) error {
+	return c.Call(ctx, "test_hook", &TestHookRequest{Name: name}, nil)
+}
+
+// errRejected marks a subscription the daemon refused, which retrying
+// won't fix
+var errRejected = errors.New("subscription rejected")
 
-	cmd := &Command{
-		Type: "set_config",
-		Data: data,
+// Subscribe calls handle with each event on topics (all if empty) until
+// ctx is done, then returns ctx.Err(). Whenever the connection drops, e.g.
+// while the daemon restarts, it reconnects with backoff. Each connection
+// starts with a TopicConnected event. handle runs on Subscribe's goroutine
+// and should return quickly.
+func (c *Client) Subscribe(ctx context.Context, topics []string, handle func(*Event)) error {
+	backoff := subscribeMinBackoff
+	for {
+		connected, err := c.subscribeOnce(ctx, topics, handle)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if errors.Is(err, errRejected) {
+			return err
+		}
+		if connected {
+			backoff = subscribeMinBackoff
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, subscribeMaxBackoff)
 	}
+}
 
-	resp, err := c.SendCommand(cmd)
+// subscribeOnce runs one subscription, on a connection of its own, until
+// the connection fails. It reports whether the daemon accepted it.
+func (c *Client) subscribeOnce(ctx context.Context, topics []string, handle func(*Event)) (bool, error) {
+	setupCtx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	cc, err := c.dial(setupCtx, true)
+	if err == nil {
+		err = cc.call(setupCtx, MethodSubscribe, &SubscribeRequest{Topics: topics}, nil)
+	}
 	if err != nil {
-		return err
+		if cc != nil {
+			cc.fail(err)
+		}
+		if ErrorCode(err) != 0 || errors.Is(err, errOldDaemon) {
+			return false, fmt.Errorf("%w: %v", errRejected, err)
+		}
+		return false, err
 	}
+	defer cc.fail(errClosed)
+	// Unblock the wait below when ctx is done
+	stop := context.AfterFunc(ctx, func() { cc.fail(ctx.Err()) })
+	defer stop()
 
-	if !resp.Success {
-		return fmt.Errorf("command failed: %s", resp.Error)
+	handle(&Event{Topic: TopicConnected, Time: time.Now()})
+	for {
+		select {
+		case msg := <-cc.notifications:
+			if msg.Method != MethodEvent {
+				continue
+			}
+			var event Event
+			if err := json.Unmarshal(msg.Params, &event); err != nil {
+				continue
+			}
+			handle(&event)
+		case <-cc.done:
+			return true, cc.err
+		}
 	}
-
-	return nil
 }
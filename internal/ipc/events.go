@@ -0,0 +1,157 @@
+package ipc
+
+import (
+	"encoding/json"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Event topics. Subscribers filter on a full topic or on the part before
+// the dot, e.g. "transfer" for every transfer event.
+const (
+	TopicTransferStarted   = "transfer.started"
+	TopicTransferProgress  = "transfer.progress"
+	TopicTransferCompleted = "transfer.completed"
+	TopicConflictCreated   = "conflict.created"
+	TopicFolderPaused      = "folder.paused"
+	TopicQueueSize         = "queue.size"
+
+	// TopicConnected is delivered by Client.Subscribe, not the daemon,
+	// each time the subscription is (re)established. Events may have been
+	// missed before it, so state kept from them should be fetched again.
+	TopicConnected = "connected"
+)
+
+// subscriberBuffer is how many events may wait for a slow subscriber. One
+// that falls further behind is disconnected and has to resubscribe.
+const subscriberBuffer = 256
+
+// Event is the params of each event notification on a subscribed
+// connection
+type Event struct {
+	Topic string          `json:"topic"`
+	Time  time.Time       `json:"time"`
+	Data  json.RawMessage `json:"data,omitempty"`
+}
+
+// Decode unmarshals the event's data into v, the payload type for its
+// topic
+func (e *Event) Decode(v interface{}) error {
+	return json.Unmarshal(e.Data, v)
+}
+
+type SubscribeRequest struct {
+	// Topics limits the events sent; empty means all
+	Topics []string `json:"topics,omitempty"`
+}
+
+// TransferEvent is the payload of the transfer topics. Bytes is set for
+// progress, Error for a failed transfer.
+type TransferEvent struct {
+	FolderID  int    `json:"folder_id"`
+	Path      string `json:"path"`
+	Operation string `json:"operation"`
+	Bytes     int64  `json:"bytes,omitempty"`
+	Size      int64  `json:"size,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+type ConflictEvent struct {
+	ID       int    `json:"id"`
+	FolderID int    `json:"folder_id"`
+	Path     string `json:"path"`
+}
+
+type FolderPausedEvent struct {
+	FolderID int    `json:"folder_id"`
+	Reason   string `json:"reason"`
+}
+
+type QueueSizeEvent struct {
+	Size int `json:"size"`
+}
+
+// MatchTopic reports whether topic passes filters
+func MatchTopic(filters []string, topic string) bool {
+	if len(filters) == 0 {
+		return true
+	}
+	for _, f := range filters {
+		if f == topic || strings.HasPrefix(topic, f+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// broker fans events out to subscribers
+type broker struct {
+	mu     sync.Mutex
+	subs   map[*subscriber]struct{}
+	closed bool
+}
+
+type subscriber struct {
+	topics []string
+	// events is closed when the subscriber falls behind or the server stops
+	events chan *Event
+}
+
+func newBroker() *broker {
+	return &broker{subs: make(map[*subscriber]struct{})}
+}
+
+func (b *broker) subscribe(topics []string) *subscriber {
+	sub := &subscriber{topics: topics, events: make(chan *Event, subscriberBuffer)}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		close(sub.events)
+		return sub
+	}
+	b.subs[sub] = struct{}{}
+	return sub
+}
+
+func (b *broker) unsubscribe(sub *subscriber) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, ok := b.subs[sub]; ok {
+		delete(b.subs, sub)
+		close(sub.events)
+	}
+}
+
+func (b *broker) active() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.subs) > 0
+}
+
+func (b *broker) publish(event *Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for sub := range b.subs {
+		if !MatchTopic(sub.topics, event.Topic) {
+			continue
+		}
+		select {
+		case sub.events <- event:
+		default:
+			delete(b.subs, sub)
+			close(sub.events)
+		}
+	}
+}
+
+// close ends every subscription
+func (b *broker) close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for sub := range b.subs {
+		close(sub.events)
+	}
+	b.subs = make(map[*subscriber]struct{})
+	b.closed = true
+}
@@ -0,0 +1,132 @@
+package ipc
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// The daemon's socket carries JSON-RPC 2.0 messages, one JSON value per
+// line. A connection may have many calls in flight; responses are matched
+// to calls by ID and may arrive in any order.
+
+// ProtocolVersion is the IPC protocol version this package speaks. The
+// hello call only checks that the client speaks it too; connections that
+// skip hello are served it all the same.
+const ProtocolVersion = 1
+
+const jsonrpcVersion = "2.0"
+
+// Methods the server handles itself
+const (
+	MethodHello     = "hello"
+	MethodSubscribe = "subscribe"
+	// MethodEvent is the notification that carries each subscribed Event
+	MethodEvent = "event"
+)
+
+// Error codes. Those from -32768 to -32000 are defined by JSON-RPC; the
+// daemon's own start at -32000.
+const (
+	CodeParseError     = -32700
+	CodeInvalidRequest = -32600
+	CodeMethodNotFound = -32601
+	CodeInvalidParams  = -32602
+	CodeInternalError  = -32603
+
+	// CodeFailed is used for handler errors that carry no code of their own
+	CodeFailed             = -32000
+	CodeUnsupportedVersion = -32001
+	CodePermissionDenied   = -32002
+	CodeNotFound           = -32003
+)
+
+// Error is a JSON-RPC error object. Handlers return one to choose the code
+// the caller sees; Client methods return one for any error the daemon
+// reports.
+type Error struct {
+	Code    int             `json:"code"`
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data,omitempty"`
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// Errorf returns an Error with code and a formatted message
+func Errorf(code int, format string, args ...interface{}) *Error {
+	return &Error{Code: code, Message: fmt.Sprintf(format, args...)}
+}
+
+// ErrorCode returns the code of the Error in err's chain, or 0 if there is
+// none, e.g. because the daemon could not be reached
+func ErrorCode(err error) int {
+	var rpcErr *Error
+	if errors.As(err, &rpcErr) {
+		return rpcErr.Code
+	}
+	return 0
+}
+
+// toError converts a handler error to the Error sent to the caller
+func toError(err error) *Error {
+	var rpcErr *Error
+	if errors.As(err, &rpcErr) {
+		return rpcErr
+	}
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &syntaxErr) || errors.As(err, &typeErr) {
+		return Errorf(CodeInvalidParams, "invalid params: %v", err)
+	}
+	return &Error{Code: CodeFailed, Message: err.Error()}
+}
+
+// message is any JSON-RPC message: a request or notification when Method
+// is set, otherwise a response. ID is absent for notifications.
+type message struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method,omitempty"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *Error          `json:"error,omitempty"`
+}
+
+// validID reports whether id is a string, number or null, as JSON-RPC
+// requires
+func validID(id json.RawMessage) bool {
+	if len(id) == 0 {
+		return false
+	}
+	switch c := id[0]; {
+	case c == '"', c == '-', c >= '0' && c <= '9':
+		return true
+	default:
+		return string(id) == "null"
+	}
+}
+
+// nullID is the ID of a response to a request whose ID could not be read
+var nullID = json.RawMessage("null")
+
+type HelloRequest struct {
+	// Versions are the protocol versions the client speaks
+	Versions []int `json:"versions"`
+}
+
+type HelloResponse struct {
+	// Version is the protocol version used for the rest of the connection
+	Version int `json:"version"`
+}
+
+// speaks reports whether offered includes ProtocolVersion
+func speaks(offered []int) bool {
+	for _, v := range offered {
+		if v == ProtocolVersion {
+			return true
+		}
+	}
+	return false
+}
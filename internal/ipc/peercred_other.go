@@ -0,0 +1,11 @@
+//go:build !linux && !darwin
+
+package ipc
+
+import "net"
+
+// peerUID is not available here; access is left to the socket file's
+// permissions
+func peerUID(conn net.Conn) (int, error) {
+	return 0, errPeerCredUnsupported
+}
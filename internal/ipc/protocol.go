@@ -1,21 +1,9 @@
 package ipc
 
 import (
-	"encoding/json"
 	"time"
 )
 
-type Command struct {
-	Type string          `json:"command"`
-	Data json.RawMessage `json:"data,omitempty"`
-}
-
-type Response struct {
-	Success bool            `json:"success"`
-	Data    json.RawMessage `json:"data,omitempty"`
-	Error   string          `json:"error,omitempty"`
-}
-
 type StatusRequest struct{}
 
 type StatusResponse struct {
@@ -23,17 +11,50 @@ type StatusResponse struct {
 	SyncFolders   []SyncFolderStatus `json:"sync_folders"`
 	QueueSize     int                `json:"queue_size"`
 	Uptime        string             `json:"uptime"`
+	// Transfers are the operations running now, oldest first
+	Transfers []TransferStatus `json:"transfers,omitempty"`
 }
 
+// Folder states reported in SyncFolderStatus.Status
+const (
+	FolderIdle     = "idle"
+	FolderSyncing  = "syncing"
+	FolderPending  = "pending"
+	FolderError    = "error"
+	FolderPaused   = "paused"
+	FolderDisabled = "disabled"
+)
+
 type SyncFolderStatus struct {
-	ID           int       `json:"id"`
-	Name         string    `json:"name"`
-	LocalPath    string    `json:"local_path"`
-	RemotePath   string    `json:"remote_path"`
-	Status       string    `json:"status"`
-	FilesPending int       `json:"files_pending"`
-	LastSync     time.Time `json:"last_sync"`
-	ErrorMessage string    `json:"error_message,omitempty"`
+	ID         int    `json:"id"`
+	Name       string `json:"name"`
+	LocalPath  string `json:"local_path"`
+	RemotePath string `json:"remote_path"`
+	Direction  string `json:"direction"`
+	Status     string `json:"status"`
+	// FilesPending counts queued and running operations
+	FilesPending int `json:"files_pending"`
+	FilesFailed  int `json:"files_failed"`
+	// LastSync is when an operation last succeeded, unset if none has
+	LastSync *time.Time `json:"last_sync,omitempty"`
+	// ErrorMessage is the pause reason for a paused folder, otherwise the
+	// most recent failure
+	ErrorMessage string `json:"error_message,omitempty"`
+	// Throttled is why the folder's transfers are slowed, e.g. on battery
+	Throttled string `json:"throttled,omitempty"`
+	Profile   string `json:"profile,omitempty"`
+	// ConfigManaged folders are declared in config.yaml and changed there
+	ConfigManaged bool `json:"config_managed,omitempty"`
+}
+
+type TransferStatus struct {
+	ID        int       `json:"id"`
+	FolderID  int       `json:"folder_id"`
+	Path      string    `json:"path"`
+	Operation string    `json:"operation"`
+	Bytes     int64     `json:"bytes"`
+	Size      int64     `json:"size,omitempty"`
+	StartedAt time.Time `json:"started_at"`
 }
 
 type AddSyncFolderRequest struct {
@@ -44,6 +65,11 @@ type AddSyncFolderRequest struct {
 	Excludes           []string `json:"excludes"`
 	ConflictResolution string   `json:"conflict_resolution"`
 	BandwidthLimit     int      `json:"bandwidth_limit,omitempty"`
+	IncludePaths       []string `json:"include_paths,omitempty"`
+	Placeholders       bool     `json:"placeholders,omitempty"`
+	// Profile selects the account the folder syncs with; empty for the
+	// top-level settings
+	Profile string `json:"profile,omitempty"`
 }
 
 type AddSyncFolderResponse struct {
@@ -54,6 +80,22 @@ type RemoveSyncFolderRequest struct {
 	ID int `json:"id"`
 }
 
+// UpdateSyncFolderRequest changes the settings that are set; the rest are
+// kept
+type UpdateSyncFolderRequest struct {
+	ID                 int       `json:"id"`
+	RemotePath         *string   `json:"remote_path,omitempty"`
+	Direction          *string   `json:"direction,omitempty"`
+	Excludes           *[]string `json:"excludes,omitempty"`
+	ConflictResolution *string   `json:"conflict_resolution,omitempty"`
+	BandwidthLimit     *int      `json:"bandwidth_limit,omitempty"`
+	SyncInterval       *int      `json:"sync_interval,omitempty"`
+	IncludePaths       *[]string `json:"include_paths,omitempty"`
+	Placeholders       *bool     `json:"placeholders,omitempty"`
+	Enabled            *bool     `json:"enabled,omitempty"`
+	Profile            *string   `json:"profile,omitempty"`
+}
+
 type GetConfigRequest struct{}
 
 type GetConfigResponse struct {
@@ -69,7 +111,7 @@ type SetConfigResponse struct {
 }
 
 type GetActivityRequest struct {
-	Limit    int `json:"limit"`
+	Limit    int  `json:"limit"`
 	FolderID *int `json:"folder_id,omitempty"`
 }
 
@@ -90,3 +132,171 @@ type GetActivityResponse struct {
 type ForceSyncRequest struct {
 	FolderID int `json:"folder_id"`
 }
+
+type ResumeFolderRequest struct {
+	FolderID int `json:"folder_id"`
+	// DiscardPending cancels the uploads and deletes queued while paused
+	DiscardPending bool `json:"discard_pending,omitempty"`
+}
+
+type ResumeFolderResponse struct {
+	// Discarded counts the operations cancelled by DiscardPending
+	Discarded int `json:"discarded"`
+}
+
+type PauseFolderRequest struct {
+	FolderID int    `json:"folder_id"`
+	Reason   string `json:"reason,omitempty"`
+}
+
+type FolderStatsRequest struct {
+	FolderID int `json:"folder_id"`
+}
+
+type FolderStatsResponse struct {
+	FolderID        int   `json:"folder_id"`
+	Files           int   `json:"files"`
+	SyncedFiles     int   `json:"synced_files"`
+	PendingFiles    int   `json:"pending_files"`
+	ErrorFiles      int   `json:"error_files"`
+	DehydratedFiles int   `json:"dehydrated_files"`
+	LocalBytes      int64 `json:"local_bytes"`
+
+	QueuedOperations int `json:"queued_operations"`
+	FailedOperations int `json:"failed_operations"`
+	Conflicts        int `json:"conflicts"`
+
+	Uploads          int        `json:"uploads"`
+	Downloads        int        `json:"downloads"`
+	Deletes          int        `json:"deletes"`
+	Errors           int        `json:"errors"`
+	BytesTransferred int64      `json:"bytes_transferred"`
+	LastSync         *time.Time `json:"last_sync,omitempty"`
+}
+
+type ListConflictsRequest struct {
+	// FolderID limits the list to one folder; 0 for all
+	FolderID int `json:"folder_id,omitempty"`
+}
+
+type ConflictEntry struct {
+	ID               int        `json:"id"`
+	FolderID         int        `json:"folder_id"`
+	Path             string     `json:"path"`
+	LocalHash        string     `json:"local_hash,omitempty"`
+	RemoteHash       string     `json:"remote_hash,omitempty"`
+	LocalModifiedAt  *time.Time `json:"local_modified_at,omitempty"`
+	RemoteModifiedAt *time.Time `json:"remote_modified_at,omitempty"`
+	CreatedAt        time.Time  `json:"created_at"`
+}
+
+type ListConflictsResponse struct {
+	Conflicts []ConflictEntry `json:"conflicts"`
+}
+
+type ResolveConflictRequest struct {
+	ID int `json:"id"`
+	// Resolution is keep_local, keep_remote or keep_both
+	Resolution string `json:"resolution"`
+}
+
+type ListQueueRequest struct {
+	// FolderID and Status filter the list when set
+	FolderID int    `json:"folder_id,omitempty"`
+	Status   string `json:"status,omitempty"`
+	Limit    int    `json:"limit,omitempty"`
+}
+
+// QueueEntry is a queued operation. Bytes and Size are set while it runs.
+type QueueEntry struct {
+	ID          int        `json:"id"`
+	FolderID    int        `json:"folder_id"`
+	Path        string     `json:"path"`
+	Operation   string     `json:"operation"`
+	Priority    int        `json:"priority"`
+	Attempts    int        `json:"attempts"`
+	MaxAttempts int        `json:"max_attempts"`
+	Status      string     `json:"status"`
+	Error       string     `json:"error,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	StartedAt   *time.Time `json:"started_at,omitempty"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+	Bytes       int64      `json:"bytes,omitempty"`
+	Size        int64      `json:"size,omitempty"`
+}
+
+type ListQueueResponse struct {
+	Operations []QueueEntry `json:"operations"`
+}
+
+// QueueItemRequest names one queued operation, for get_queue_item,
+// retry_queue_item and cancel_queue_item
+type QueueItemRequest struct {
+	ID int `json:"id"`
+}
+
+// HydrateRequest is shared by the hydrate and dehydrate commands. Paths are
+// absolute local paths inside a sync folder; directories apply recursively.
+type HydrateRequest struct {
+	Paths []string `json:"paths"`
+}
+
+type HydrateResponse struct {
+	Files int `json:"files"`
+}
+
+// HookEntry is a configured hook. Webhook secrets aren't sent.
+type HookEntry struct {
+	Name    string   `json:"name"`
+	Events  []string `json:"events"`
+	Command []string `json:"command,omitempty"`
+	URL     string   `json:"url,omitempty"`
+	Signed  bool     `json:"signed,omitempty"`
+	Timeout string   `json:"timeout"`
+	Retries int      `json:"retries"`
+}
+
+type ListHooksResponse struct {
+	Hooks []HookEntry `json:"hooks"`
+}
+
+type TestHookRequest struct {
+	Name string `json:"name"`
+}
+
+// BandwidthRule is a rule of the bandwidth schedule, as in config.yaml.
+// Limits are in KB/s, 0 for unlimited.
+type BandwidthRule struct {
+	Days  []string `json:"days,omitempty"`
+	Start string   `json:"start"`
+	End   string   `json:"end"`
+	Up    int      `json:"up"`
+	Down  int      `json:"down"`
+}
+
+// FolderBandwidth is a folder's own limit in KB/s, applied to uploads and
+// downloads separately within the daemon-wide limits
+type FolderBandwidth struct {
+	FolderID  int    `json:"folder_id"`
+	LocalPath string `json:"local_path"`
+	Limit     int    `json:"limit"`
+}
+
+// GetBandwidthResponse describes the daemon's bandwidth limits, in KB/s
+// with 0 for unlimited
+type GetBandwidthResponse struct {
+	// Up and Down are the limits in force now
+	Up   int `json:"up"`
+	Down int `json:"down"`
+	// Rule is the index in Schedule of the rule in force, -1 when the
+	// defaults are
+	Rule int `json:"rule"`
+	// Throttled is why Up and Down are held below the schedule's, if
+	// they are
+	Throttled   string          `json:"throttled,omitempty"`
+	DefaultUp   int             `json:"default_up"`
+	DefaultDown int             `json:"default_down"`
+	Schedule    []BandwidthRule `json:"schedule"`
+	// Folders lists the folders with limits of their own
+	Folders []FolderBandwidth `json:"folders"`
+}
@@ -2,30 +2,64 @@ package ipc
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
 	"net"
 	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/darkstorage/cli/internal/logging"
 )
 
-type HandlerFunc func(data json.RawMessage) (*Response, error)
+var logger = logging.For(logging.IPC)
+
+// writeTimeout bounds how long a client may take to accept a message
+const writeTimeout = 10 * time.Second
+
+// maxInFlight limits the calls one connection may have running; reading
+// further requests waits until one finishes
+const maxInFlight = 16
+
+// errPeerCredUnsupported means the platform can't report who connected
+var errPeerCredUnsupported = errors.New("peer credentials not supported")
+
+// HandlerFunc serves one method. params are the request's params, {} if it
+// had none, and the result is sent back as the response's result. Return
+// an *Error to choose the error code the caller sees.
+type HandlerFunc func(params json.RawMessage) (interface{}, error)
 
 type Server struct {
 	socketPath string
 	listener   net.Listener
+	handlersMu sync.RWMutex
 	handlers   map[string]HandlerFunc
-	running    bool
+	events     *broker
+	// stopped is closed by Stop
+	stopped  chan struct{}
+	stopOnce sync.Once
+	connsMu  sync.Mutex
+	conns    map[net.Conn]struct{}
+	// inherited is set when the socket was created by someone else, who
+	// then owns the socket file
+	inherited bool
 }
 
 func NewServer(socketPath string) *Server {
 	return &Server{
 		socketPath: socketPath,
 		handlers:   make(map[string]HandlerFunc),
+		events:     newBroker(),
+		stopped:    make(chan struct{}),
+		conns:      make(map[net.Conn]struct{}),
 	}
 }
 
-func (s *Server) RegisterHandler(command string, handler HandlerFunc) {
-	s.handlers[command] = handler
+func (s *Server) RegisterHandler(method string, handler HandlerFunc) {
+	s.handlersMu.Lock()
+	defer s.handlersMu.Unlock()
+	s.handlers[method] = handler
 }
 
 func (s *Server) Start() error {
@@ -42,68 +76,331 @@ func (s *Server) Start() error {
 	}
 
 	s.listener = listener
-	s.running = true
 
 	go s.acceptLoop()
 	return nil
 }
 
+// StartListener serves on a listener created elsewhere, such as a socket
+// passed in by systemd socket activation. The socket file is left in place
+// on Stop.
+func (s *Server) StartListener(listener net.Listener) {
+	s.listener = listener
+	s.inherited = true
+
+	go s.acceptLoop()
+}
+
+// Stop closes the listener and every connection, ending subscriptions
 func (s *Server) Stop() error {
-	s.running = false
-	if s.listener != nil {
-		s.listener.Close()
-		os.Remove(s.socketPath)
-	}
+	s.stopOnce.Do(func() {
+		close(s.stopped)
+		s.events.close()
+		if s.listener != nil {
+			s.listener.Close()
+			if !s.inherited {
+				os.Remove(s.socketPath)
+			}
+		}
+
+		s.connsMu.Lock()
+		for conn := range s.conns {
+			conn.Close()
+		}
+		s.connsMu.Unlock()
+	})
 	return nil
 }
 
+func (s *Server) stopping() bool {
+	select {
+	case <-s.stopped:
+		return true
+	default:
+		return false
+	}
+}
+
 func (s *Server) acceptLoop() {
-	for s.running {
+	for {
 		conn, err := s.listener.Accept()
 		if err != nil {
-			if s.running {
-				fmt.Printf("Accept error: %v\n", err)
+			if s.stopping() || errors.Is(err, net.ErrClosed) {
+				return
 			}
+			logger.Error("accept failed", "error", err)
+			time.Sleep(100 * time.Millisecond)
 			continue
 		}
 		go s.handleConnection(conn)
 	}
 }
 
+// track records an open connection so Stop can close it. It reports false
+// once the server is stopping.
+func (s *Server) track(conn net.Conn) bool {
+	s.connsMu.Lock()
+	defer s.connsMu.Unlock()
+	if s.stopping() {
+		return false
+	}
+	s.conns[conn] = struct{}{}
+	return true
+}
+
+func (s *Server) untrack(conn net.Conn) {
+	s.connsMu.Lock()
+	defer s.connsMu.Unlock()
+	delete(s.conns, conn)
+}
+
 func (s *Server) handleConnection(conn net.Conn) {
 	defer conn.Close()
+	if !s.track(conn) {
+		return
+	}
+	defer s.untrack(conn)
 
-	decoder := json.NewDecoder(conn)
-	encoder := json.NewEncoder(conn)
+	c := &serverConn{server: s, conn: conn, encoder: json.NewEncoder(conn)}
 
-	var cmd Command
-	if err := decoder.Decode(&cmd); err != nil {
-		if err != io.EOF {
-			encoder.Encode(&Response{
-				Success: false,
-				Error:   fmt.Sprintf("decode error: %v", err),
-			})
-		}
+	if err := checkPeer(conn); err != nil {
+		logger.Warn("rejected connection", "error", err)
+		c.write(errorMessage(nullID, Errorf(CodePermissionDenied, "permission denied: %v", err)))
 		return
 	}
 
-	handler, ok := s.handlers[cmd.Type]
+	c.serve()
+}
+
+// checkPeer refuses connections from other users. Where the platform
+// can't tell who connected, the socket file's 0600 mode is relied on.
+func checkPeer(conn net.Conn) error {
+	uid, err := peerUID(conn)
+	if errors.Is(err, errPeerCredUnsupported) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("can't identify peer: %w", err)
+	}
+	if uid != os.Getuid() {
+		return fmt.Errorf("uid %d is not the daemon's user", uid)
+	}
+	return nil
+}
+
+// serverConn is one client connection
+type serverConn struct {
+	server  *Server
+	conn    net.Conn
+	writeMu sync.Mutex
+	encoder *json.Encoder
+	// sub is set once the connection subscribes to events
+	sub         atomic.Pointer[subscriber]
+	pumpStarted atomic.Bool
+	inFlight    sync.WaitGroup
+}
+
+// serve reads requests until the client hangs up, running each in its own
+// goroutine so slow calls don't hold up others
+func (c *serverConn) serve() {
+	defer func() {
+		if sub := c.sub.Load(); sub != nil {
+			c.server.events.unsubscribe(sub)
+		}
+	}()
+	defer c.inFlight.Wait()
+
+	decoder := json.NewDecoder(c.conn)
+	slots := make(chan struct{}, maxInFlight)
+	for {
+		var raw json.RawMessage
+		if err := decoder.Decode(&raw); err != nil {
+			var syntaxErr *json.SyntaxError
+			if errors.As(err, &syntaxErr) {
+				// The stream can't be resynchronised after bad JSON
+				c.write(errorMessage(nullID, Errorf(CodeParseError, "parse error: %v", err)))
+			}
+			return
+		}
+
+		slots <- struct{}{}
+		c.inFlight.Add(1)
+		go func() {
+			defer func() {
+				<-slots
+				c.inFlight.Done()
+			}()
+			if reply := c.dispatch(raw); reply != nil {
+				c.write(reply)
+			}
+			c.startEvents()
+		}()
+	}
+}
+
+// dispatch runs a request or a batch of them and returns the reply, or
+// nil if nothing needs one
+func (c *serverConn) dispatch(raw json.RawMessage) interface{} {
+	if len(raw) == 0 || raw[0] != '[' {
+		if reply := c.call(raw); reply != nil {
+			return reply
+		}
+		return nil
+	}
+
+	var batch []json.RawMessage
+	if err := json.Unmarshal(raw, &batch); err != nil || len(batch) == 0 {
+		return errorMessage(nullID, Errorf(CodeInvalidRequest, "invalid request: empty batch"))
+	}
+	var replies []*message
+	for _, item := range batch {
+		if reply := c.call(item); reply != nil {
+			replies = append(replies, reply)
+		}
+	}
+	if len(replies) == 0 {
+		return nil
+	}
+	return replies
+}
+
+// call runs one request and returns its response, or nil for a
+// notification
+func (c *serverConn) call(raw json.RawMessage) *message {
+	var req message
+	if err := json.Unmarshal(raw, &req); err != nil {
+		return errorMessage(nullID, Errorf(CodeInvalidRequest, "invalid request: %v", err))
+	}
+
+	notification := req.ID == nil
+	if !notification && !validID(req.ID) {
+		return errorMessage(nullID, Errorf(CodeInvalidRequest, "invalid request: id must be a string or number"))
+	}
+	if req.JSONRPC != jsonrpcVersion || req.Method == "" {
+		id := req.ID
+		if notification {
+			id = nullID
+		}
+		return errorMessage(id, Errorf(CodeInvalidRequest, "invalid request: not a JSON-RPC 2.0 call"))
+	}
+
+	result, err := c.invoke(req.Method, req.Params)
+	if notification {
+		return nil
+	}
+	if err != nil {
+		return errorMessage(req.ID, toError(err))
+	}
+
+	data, err := json.Marshal(result)
+	if err != nil {
+		return errorMessage(req.ID, Errorf(CodeInternalError, "failed to encode result: %v", err))
+	}
+	return &message{JSONRPC: jsonrpcVersion, ID: req.ID, Result: data}
+}
+
+func (c *serverConn) invoke(method string, params json.RawMessage) (interface{}, error) {
+	if len(params) == 0 || string(params) == "null" {
+		params = json.RawMessage("{}")
+	}
+
+	switch method {
+	case MethodHello:
+		return c.hello(params)
+	case MethodSubscribe:
+		return c.subscribe(params)
+	}
+
+	c.server.handlersMu.RLock()
+	handler, ok := c.server.handlers[method]
+	c.server.handlersMu.RUnlock()
 	if !ok {
-		encoder.Encode(&Response{
-			Success: false,
-			Error:   fmt.Sprintf("unknown command: %s", cmd.Type),
-		})
+		return nil, Errorf(CodeMethodNotFound, "unknown method: %s", method)
+	}
+	return handler(params)
+}
+
+func (c *serverConn) hello(params json.RawMessage) (interface{}, error) {
+	var req HelloRequest
+	if err := json.Unmarshal(params, &req); err != nil {
+		return nil, err
+	}
+
+	if !speaks(req.Versions) {
+		data, _ := json.Marshal(map[string][]int{"versions": {ProtocolVersion}})
+		return nil, &Error{
+			Code:    CodeUnsupportedVersion,
+			Message: fmt.Sprintf("no common protocol version (daemon speaks %d, client %v)", ProtocolVersion, req.Versions),
+			Data:    data,
+		}
+	}
+	return &HelloResponse{Version: ProtocolVersion}, nil
+}
+
+// subscribe starts event notifications on this connection, once the
+// response has been written
+func (c *serverConn) subscribe(params json.RawMessage) (interface{}, error) {
+	var req SubscribeRequest
+	if err := json.Unmarshal(params, &req); err != nil {
+		return nil, err
+	}
+
+	sub := c.server.events.subscribe(req.Topics)
+	if !c.sub.CompareAndSwap(nil, sub) {
+		c.server.events.unsubscribe(sub)
+		return nil, Errorf(CodeFailed, "already subscribed")
+	}
+	return struct{}{}, nil
+}
+
+// startEvents starts sending events once the connection has subscribed
+func (c *serverConn) startEvents() {
+	sub := c.sub.Load()
+	if sub == nil || !c.pumpStarted.CompareAndSwap(false, true) {
 		return
 	}
+	go func() {
+		for event := range sub.events {
+			params, err := json.Marshal(event)
+			if err != nil {
+				continue
+			}
+			if err := c.write(&message{JSONRPC: jsonrpcVersion, Method: MethodEvent, Params: params}); err != nil {
+				return
+			}
+		}
+		// Fell behind or the server is stopping; the client resubscribes
+		c.conn.Close()
+	}()
+}
+
+// write sends one message, closing the connection if it can't
+func (c *serverConn) write(v interface{}) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	c.conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+	if err := c.encoder.Encode(v); err != nil {
+		c.conn.Close()
+		return err
+	}
+	return nil
+}
+
+func errorMessage(id json.RawMessage, err *Error) *message {
+	return &message{JSONRPC: jsonrpcVersion, ID: id, Error: err}
+}
 
-	response, err := handler(cmd.Data)
+// Publish sends an event with data as its payload to the subscribers of
+// topic. It never blocks.
+func (s *Server) Publish(topic string, data interface{}) {
+	if !s.events.active() {
+		return
+	}
+	payload, err := json.Marshal(data)
 	if err != nil {
-		encoder.Encode(&Response{
-			Success: false,
-			Error:   err.Error(),
-		})
+		logger.Error("failed to encode event", "topic", topic, "error", err)
 		return
 	}
-
-	encoder.Encode(response)
+	s.events.publish(&Event{Topic: topic, Time: time.Now(), Data: payload})
 }
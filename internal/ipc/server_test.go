@@ -0,0 +1,234 @@
+package ipc
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// startServer serves a few test methods on a socket in a fresh directory
+func startServer(t *testing.T) *Server {
+	t.Helper()
+	// Socket paths are limited to about 100 bytes, too few for t.TempDir
+	dir, err := os.MkdirTemp("", "ipc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	s := NewServer(filepath.Join(dir, "daemon.sock"))
+	s.RegisterHandler("echo", func(params json.RawMessage) (interface{}, error) {
+		return params, nil
+	})
+	s.RegisterHandler("fail", func(params json.RawMessage) (interface{}, error) {
+		return nil, errors.New("it broke")
+	})
+	s.RegisterHandler("missing", func(params json.RawMessage) (interface{}, error) {
+		return nil, Errorf(CodeNotFound, "no such folder")
+	})
+	s.RegisterHandler("count", func(params json.RawMessage) (interface{}, error) {
+		var req struct{ N int }
+		if err := json.Unmarshal(params, &req); err != nil {
+			return nil, err
+		}
+		return req.N, nil
+	})
+	if err := s.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Stop() })
+	return s
+}
+
+// normalize decodes a message and drops error messages and data, so
+// replies compare on their codes
+func normalize(t *testing.T, raw string) interface{} {
+	t.Helper()
+	var v interface{}
+	if err := json.Unmarshal([]byte(raw), &v); err != nil {
+		t.Fatalf("invalid JSON %q: %v", raw, err)
+	}
+	var strip func(v interface{})
+	strip = func(v interface{}) {
+		switch v := v.(type) {
+		case []interface{}:
+			for _, item := range v {
+				strip(item)
+			}
+		case map[string]interface{}:
+			if e, ok := v["error"].(map[string]interface{}); ok {
+				delete(e, "message")
+				delete(e, "data")
+			}
+		}
+	}
+	strip(v)
+	return v
+}
+
+func TestServerFraming(t *testing.T) {
+	const probe = `{"jsonrpc":"2.0","id":"probe","method":"echo","params":[]}`
+	const probeReply = `{"jsonrpc":"2.0","id":"probe","result":[]}`
+
+	tests := []struct {
+		name string
+		send string
+		// want is the first reply; a request that gets none is followed by
+		// probe, whose reply comes first instead
+		want string
+	}{
+		{
+			name: "call",
+			send: `{"jsonrpc":"2.0","id":1,"method":"echo","params":{"a":1}}`,
+			want: `{"jsonrpc":"2.0","id":1,"result":{"a":1}}`,
+		},
+		{
+			name: "string id and no params",
+			send: `{"jsonrpc":"2.0","id":"x","method":"echo"}`,
+			want: `{"jsonrpc":"2.0","id":"x","result":{}}`,
+		},
+		{
+			name: "notification",
+			send: `{"jsonrpc":"2.0","method":"echo","params":{"a":1}}`,
+			want: probeReply,
+		},
+		{
+			name: "parse error",
+			send: `{"jsonrpc" 1}`,
+			want: `{"jsonrpc":"2.0","id":null,"error":{"code":-32700}}`,
+		},
+		{
+			name: "not JSON-RPC 2.0",
+			send: `{"jsonrpc":"1.0","id":2,"method":"echo"}`,
+			want: `{"jsonrpc":"2.0","id":2,"error":{"code":-32600}}`,
+		},
+		{
+			name: "object id",
+			send: `{"jsonrpc":"2.0","id":{},"method":"echo"}`,
+			want: `{"jsonrpc":"2.0","id":null,"error":{"code":-32600}}`,
+		},
+		{
+			name: "unknown method",
+			send: `{"jsonrpc":"2.0","id":3,"method":"nope"}`,
+			want: `{"jsonrpc":"2.0","id":3,"error":{"code":-32601}}`,
+		},
+		{
+			name: "handler error",
+			send: `{"jsonrpc":"2.0","id":4,"method":"fail"}`,
+			want: `{"jsonrpc":"2.0","id":4,"error":{"code":-32000}}`,
+		},
+		{
+			name: "handler error with a code",
+			send: `{"jsonrpc":"2.0","id":5,"method":"missing"}`,
+			want: `{"jsonrpc":"2.0","id":5,"error":{"code":-32003}}`,
+		},
+		{
+			name: "params of the wrong type",
+			send: `{"jsonrpc":"2.0","id":6,"method":"count","params":{"N":"many"}}`,
+			want: `{"jsonrpc":"2.0","id":6,"error":{"code":-32602}}`,
+		},
+		{
+			name: "unsupported protocol version",
+			send: `{"jsonrpc":"2.0","id":7,"method":"hello","params":{"versions":[99]}}`,
+			want: `{"jsonrpc":"2.0","id":7,"error":{"code":-32001}}`,
+		},
+		{
+			name: "hello",
+			send: `{"jsonrpc":"2.0","id":8,"method":"hello","params":{"versions":[1,99]}}`,
+			want: `{"jsonrpc":"2.0","id":8,"result":{"version":1}}`,
+		},
+		{
+			name: "batch",
+			send: `[{"jsonrpc":"2.0","id":1,"method":"count","params":{"N":1}},{"jsonrpc":"2.0","method":"echo"},{"jsonrpc":"2.0","id":2,"method":"nope"}]`,
+			want: `[{"jsonrpc":"2.0","id":1,"result":1},{"jsonrpc":"2.0","id":2,"error":{"code":-32601}}]`,
+		},
+		{
+			name: "empty batch",
+			send: `[]`,
+			want: `{"jsonrpc":"2.0","id":null,"error":{"code":-32600}}`,
+		},
+		{
+			name: "batch of notifications",
+			send: `[{"jsonrpc":"2.0","method":"echo"},{"jsonrpc":"2.0","method":"echo"}]`,
+			want: probeReply,
+		},
+	}
+
+	s := startServer(t)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn, err := net.Dial("unix", s.socketPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer conn.Close()
+			conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+			send := tt.send + "\n"
+			if tt.want == probeReply {
+				send += probe + "\n"
+			}
+			if _, err := conn.Write([]byte(send)); err != nil {
+				t.Fatal(err)
+			}
+
+			reply, err := bufio.NewReader(conn).ReadString('\n')
+			if err != nil {
+				t.Fatalf("no reply: %v", err)
+			}
+			if got, want := normalize(t, reply), normalize(t, tt.want); !reflect.DeepEqual(got, want) {
+				t.Errorf("reply %s, want %s", reply, tt.want)
+			}
+		})
+	}
+}
+
+func TestClientCall(t *testing.T) {
+	s := startServer(t)
+	client := NewClient(s.socketPath)
+	defer client.Close()
+
+	tests := []struct {
+		name     string
+		method   string
+		params   interface{}
+		want     int
+		wantCode int
+	}{
+		{name: "result", method: "count", params: map[string]int{"N": 42}, want: 42},
+		{name: "handler error", method: "fail", wantCode: CodeFailed},
+		{name: "handler error with a code", method: "missing", wantCode: CodeNotFound},
+		{name: "unknown method", method: "nope", wantCode: CodeMethodNotFound},
+		{name: "invalid params", method: "count", params: map[string]string{"N": "many"}, wantCode: CodeInvalidParams},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got int
+			err := client.Call(context.Background(), tt.method, tt.params, &got)
+			if code := ErrorCode(err); code != tt.wantCode {
+				t.Fatalf("Call() error = %v with code %d, want code %d", err, code, tt.wantCode)
+			}
+			if got != tt.want {
+				t.Errorf("result = %d, want %d", got, tt.want)
+			}
+		})
+	}
+
+	t.Run("daemon gone", func(t *testing.T) {
+		s.Stop()
+		err := client.Call(context.Background(), "count", nil, nil)
+		if err == nil {
+			t.Fatal("Call() succeeded after the server stopped")
+		}
+		if code := ErrorCode(err); code != 0 {
+			t.Errorf("ErrorCode() = %d, want 0 for an unreachable daemon", code)
+		}
+	})
+}
@@ -0,0 +1,185 @@
+package logging
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// rotatedSuffix is the layout of the timestamp added to rotated files
+const rotatedSuffix = "20060102-150405"
+
+// Rotation says when a log file is rotated and which old files are kept.
+// Zero values disable that rule.
+type Rotation struct {
+	// MaxSize rotates the file once it reaches this many bytes
+	MaxSize int64
+	// Interval rotates the file when a new interval starts, e.g. daily
+	Interval time.Duration
+	// MaxFiles is how many rotated files are kept
+	MaxFiles int
+	// MaxAge removes rotated files older than this
+	MaxAge time.Duration
+}
+
+// File is a log file that rotates itself. Rotated files are renamed to
+// path.YYYYMMDD-HHMMSS next to it.
+type File struct {
+	path string
+
+	mu       sync.Mutex
+	rotation Rotation
+	f        *os.File
+	size     int64
+	// period is the start of the interval the file was opened in
+	period time.Time
+}
+
+// OpenFile opens path for appending, creating it and its directory
+func OpenFile(path string, rotation Rotation) (*File, error) {
+	file := &File{path: path, rotation: rotation}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	if err := file.open(); err != nil {
+		return nil, err
+	}
+	return file, nil
+}
+
+func (l *File) Path() string {
+	return l.path
+}
+
+// SetRotation changes the rules for the next write
+func (l *File) SetRotation(rotation Rotation) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.rotation = rotation
+	l.period = l.periodOf(time.Now())
+}
+
+func (l *File) open() error {
+	f, err := os.OpenFile(l.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	l.f = f
+	l.size = info.Size()
+	l.period = l.periodOf(info.ModTime())
+	return nil
+}
+
+// periodOf is the start of the rotation interval t falls in
+func (l *File) periodOf(t time.Time) time.Time {
+	if l.rotation.Interval <= 0 {
+		return time.Time{}
+	}
+	return t.UTC().Truncate(l.rotation.Interval)
+}
+
+func (l *File) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.f == nil {
+		return 0, os.ErrClosed
+	}
+	if l.due(int64(len(p))) {
+		// Failing to rotate must not lose the record, so keep writing to
+		// the current file
+		l.rotate()
+	}
+	n, err := l.f.Write(p)
+	l.size += int64(n)
+	return n, err
+}
+
+// due reports whether writing n more bytes should go to a new file
+func (l *File) due(n int64) bool {
+	if l.size == 0 {
+		return false
+	}
+	if l.rotation.MaxSize > 0 && l.size+n > l.rotation.MaxSize {
+		return true
+	}
+	return l.rotation.Interval > 0 && !l.periodOf(time.Now()).Equal(l.period)
+}
+
+func (l *File) rotate() error {
+	rotated := l.path + "." + time.Now().UTC().Format(rotatedSuffix)
+	// Two rotations in the same second keep the first file
+	if _, err := os.Stat(rotated); err == nil {
+		return nil
+	}
+	if err := os.Rename(l.path, rotated); err != nil {
+		return err
+	}
+	l.f.Close()
+	if err := l.open(); err != nil {
+		// Carry on in the rotated file rather than drop records
+		f, reopenErr := os.OpenFile(rotated, os.O_WRONLY|os.O_APPEND, 0644)
+		if reopenErr == nil {
+			l.f = f
+		} else {
+			l.f = nil
+		}
+		return err
+	}
+	l.prune()
+	return nil
+}
+
+// prune removes rotated files beyond MaxFiles or older than MaxAge
+func (l *File) prune() {
+	rotated := RotatedFiles(l.path)
+	cutoff := time.Time{}
+	if l.rotation.MaxAge > 0 {
+		cutoff = time.Now().Add(-l.rotation.MaxAge)
+	}
+	// Newest first
+	for i := len(rotated) - 1; i >= 0; i-- {
+		keep := len(rotated) - i
+		info, err := os.Stat(rotated[i])
+		if err != nil {
+			continue
+		}
+		if (l.rotation.MaxFiles > 0 && keep > l.rotation.MaxFiles) ||
+			(!cutoff.IsZero() && info.ModTime().Before(cutoff)) {
+			os.Remove(rotated[i])
+		}
+	}
+}
+
+func (l *File) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.f == nil {
+		return nil
+	}
+	err := l.f.Close()
+	l.f = nil
+	return err
+}
+
+// RotatedFiles lists the rotated files of the log at path, oldest first
+func RotatedFiles(path string) []string {
+	matches, _ := filepath.Glob(path + ".*")
+	var rotated []string
+	for _, match := range matches {
+		suffix := strings.TrimPrefix(match, path+".")
+		if _, err := time.Parse(rotatedSuffix, suffix); err == nil {
+			rotated = append(rotated, match)
+		}
+	}
+	sort.Strings(rotated)
+	return rotated
+}
@@ -0,0 +1,247 @@
+// Package logging gives each part of the daemon a structured logger with a
+// level of its own, writing text or JSON to stderr and a rotating file.
+// Loggers can be created before Configure; they follow whatever output and
+// levels are configured when they log.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// Components with their own level. Each record carries its component
+// under the "component" key.
+const (
+	Daemon  = "daemon"
+	Engine  = "engine"
+	Watcher = "watcher"
+	IPC     = "ipc"
+	Hooks   = "hooks"
+	Storage = "storage"
+)
+
+// Components lists every component, for validating per-component levels
+var Components = []string{Daemon, Engine, Watcher, IPC, Hooks, Storage}
+
+// ComponentKey is the attribute naming a record's component
+const ComponentKey = "component"
+
+// Output formats
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+// Options configure where logs go and what is kept
+type Options struct {
+	// Level applies to components without a level in Levels
+	Level  string
+	Levels map[string]string
+	// Format is FormatText or FormatJSON
+	Format string
+	// File is written unless empty, rotating as Rotation says
+	File     string
+	Rotation Rotation
+	// Stderr also writes every record to stderr
+	Stderr bool
+}
+
+// ParseLevel reads debug, info, warn or error, case-insensitively
+func ParseLevel(s string) (slog.Level, error) {
+	switch strings.ToLower(s) {
+	case "debug":
+		return slog.LevelDebug, nil
+	case "info", "":
+		return slog.LevelInfo, nil
+	case "warn", "warning":
+		return slog.LevelWarn, nil
+	case "error":
+		return slog.LevelError, nil
+	}
+	return 0, fmt.Errorf("%q is not debug, info, warn or error", s)
+}
+
+// levelSet is the level of each component
+type levelSet struct {
+	def         slog.Level
+	byComponent map[string]slog.Level
+}
+
+func (l *levelSet) level(component string) slog.Level {
+	if level, ok := l.byComponent[component]; ok {
+		return level
+	}
+	return l.def
+}
+
+// output is where records are written. It is replaced as a whole when the
+// configuration changes, so loggers never see half of one.
+type output struct {
+	handler slog.Handler
+}
+
+var (
+	levels  atomic.Pointer[levelSet]
+	current atomic.Pointer[output]
+
+	// configMu serialises Configure; file is the open log file, if any
+	configMu   sync.Mutex
+	file       *File
+	lastFormat string
+	lastStderr bool
+)
+
+func init() {
+	levels.Store(&levelSet{def: slog.LevelInfo})
+	current.Store(&output{handler: newHandler(os.Stderr, FormatText)})
+}
+
+// newHandler writes every record to w; levels are checked by the
+// component loggers
+func newHandler(w io.Writer, format string) slog.Handler {
+	opts := &slog.HandlerOptions{Level: slog.Level(-1 << 20)}
+	if format == FormatJSON {
+		return slog.NewJSONHandler(w, opts)
+	}
+	return slog.NewTextHandler(w, opts)
+}
+
+// Configure applies opts to every logger. It may be called again, e.g. on
+// reload: levels and rotation change in place, and a new file or format
+// takes over from the next record.
+func Configure(opts Options) error {
+	set, err := parseLevels(opts.Level, opts.Levels)
+	if err != nil {
+		return err
+	}
+
+	configMu.Lock()
+	defer configMu.Unlock()
+
+	reopened := false
+	if file == nil || file.Path() != opts.File {
+		var next *File
+		if opts.File != "" {
+			if next, err = OpenFile(opts.File, opts.Rotation); err != nil {
+				return err
+			}
+		}
+		if file != nil {
+			defer file.Close()
+		}
+		file = next
+		reopened = true
+	} else {
+		file.SetRotation(opts.Rotation)
+	}
+
+	levels.Store(set)
+	if reopened || opts.Format != lastFormat || opts.Stderr != lastStderr {
+		var writers []io.Writer
+		if opts.Stderr {
+			writers = append(writers, os.Stderr)
+		}
+		if file != nil {
+			writers = append(writers, file)
+		}
+		current.Store(&output{handler: newHandler(io.MultiWriter(writers...), opts.Format)})
+		lastFormat, lastStderr = opts.Format, opts.Stderr
+	}
+	return nil
+}
+
+// Close closes the log file. Later records go to stderr only.
+func Close() error {
+	configMu.Lock()
+	defer configMu.Unlock()
+
+	current.Store(&output{handler: newHandler(os.Stderr, lastFormat)})
+	if file == nil {
+		return nil
+	}
+	err := file.Close()
+	file = nil
+	return err
+}
+
+func parseLevels(def string, byComponent map[string]string) (*levelSet, error) {
+	level, err := ParseLevel(def)
+	if err != nil {
+		return nil, err
+	}
+	set := &levelSet{def: level, byComponent: make(map[string]slog.Level)}
+	for component, s := range byComponent {
+		level, err := ParseLevel(s)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", component, err)
+		}
+		set.byComponent[strings.ToLower(component)] = level
+	}
+	return set, nil
+}
+
+// For returns the logger of component
+func For(component string) *slog.Logger {
+	return slog.New(&handler{component: component})
+}
+
+// handler checks the component's level and passes records on to the
+// current output, replaying the attributes and groups added with With
+type handler struct {
+	component string
+	ops       []func(slog.Handler) slog.Handler
+	cache     atomic.Pointer[resolved]
+}
+
+// resolved is the output handler with the logger's attributes applied
+type resolved struct {
+	out     *output
+	handler slog.Handler
+}
+
+func (h *handler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= levels.Load().level(h.component)
+}
+
+func (h *handler) Handle(ctx context.Context, r slog.Record) error {
+	return h.resolve().Handle(ctx, r)
+}
+
+func (h *handler) resolve() slog.Handler {
+	out := current.Load()
+	if r := h.cache.Load(); r != nil && r.out == out {
+		return r.handler
+	}
+	inner := out.handler.WithAttrs([]slog.Attr{slog.String(ComponentKey, h.component)})
+	for _, op := range h.ops {
+		inner = op(inner)
+	}
+	h.cache.Store(&resolved{out: out, handler: inner})
+	return inner
+}
+
+func (h *handler) with(op func(slog.Handler) slog.Handler) *handler {
+	ops := make([]func(slog.Handler) slog.Handler, len(h.ops), len(h.ops)+1)
+	copy(ops, h.ops)
+	return &handler{component: h.component, ops: append(ops, op)}
+}
+
+func (h *handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return h
+	}
+	return h.with(func(inner slog.Handler) slog.Handler { return inner.WithAttrs(attrs) })
+}
+
+func (h *handler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	return h.with(func(inner slog.Handler) slog.Handler { return inner.WithGroup(name) })
+}
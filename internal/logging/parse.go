@@ -0,0 +1,78 @@
+package logging
+
+import (
+	"encoding/json"
+	"log/slog"
+	"strconv"
+	"strings"
+)
+
+// Line is what Parse could read from one line of a log file
+type Line struct {
+	// Level is valid only if HasLevel is set
+	Level     slog.Level
+	HasLevel  bool
+	Component string
+}
+
+// Parse reads the level and component of a line written in either format.
+// Lines from elsewhere, such as a panic's stack trace, have neither.
+func Parse(line string) Line {
+	if strings.HasPrefix(line, "{") {
+		var record struct {
+			Level     string `json:"level"`
+			Component string `json:"component"`
+		}
+		if json.Unmarshal([]byte(line), &record) != nil {
+			return Line{}
+		}
+		parsed := Line{Component: record.Component}
+		parsed.Level, parsed.HasLevel = recordLevel(record.Level)
+		return parsed
+	}
+
+	var parsed Line
+	if value, ok := textField(line, "level"); ok {
+		parsed.Level, parsed.HasLevel = recordLevel(value)
+	}
+	parsed.Component, _ = textField(line, ComponentKey)
+	return parsed
+}
+
+// recordLevel reads a level as slog writes it, e.g. INFO or WARN+2
+func recordLevel(s string) (slog.Level, bool) {
+	var level slog.Level
+	if level.UnmarshalText([]byte(s)) != nil {
+		return 0, false
+	}
+	return level, true
+}
+
+// textField finds key=value in a line of the text format
+func textField(line, key string) (string, bool) {
+	prefix := key + "="
+	for start := 0; start < len(line); {
+		i := strings.Index(line[start:], prefix)
+		if i < 0 {
+			return "", false
+		}
+		i += start
+		start = i + len(prefix)
+		if i > 0 && line[i-1] != ' ' {
+			continue
+		}
+
+		rest := line[start:]
+		if strings.HasPrefix(rest, `"`) {
+			if quoted, err := strconv.QuotedPrefix(rest); err == nil {
+				value, _ := strconv.Unquote(quoted)
+				return value, true
+			}
+		}
+		if end := strings.IndexByte(rest, ' '); end >= 0 {
+			rest = rest[:end]
+		}
+		return rest, true
+	}
+	return "", false
+}
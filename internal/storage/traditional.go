@@ -2,14 +2,17 @@ package storage
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"path/filepath"
 	"strings"
 	"time"
 
+	"github.com/darkstorage/cli/internal/bandwidth"
 	"github.com/minio/minio-go/v7"
 	"github.com/minio/minio-go/v7/pkg/credentials"
+	"github.com/minio/minio-go/v7/pkg/encrypt"
 )
 
 // TraditionalBackend implements StorageBackend for MinIO/S3
@@ -76,9 +79,7 @@ func (t *TraditionalBackend) Upload(ctx context.Context, src io.Reader, dest str
 	if opts.ProgressFunc != nil {
 		reader = NewProgressReader(reader, opts.ProgressFunc)
 	}
-	if opts.BandwidthLimit > 0 {
-		reader = NewBandwidthLimitedReader(reader, opts.BandwidthLimit)
-	}
+	reader = bandwidth.NewReader(ctx, reader, opts.Limiters...)
 
 	// Prepare put options
 	putOpts := minio.PutObjectOptions{
@@ -91,6 +92,10 @@ func (t *TraditionalBackend) Upload(ctx context.Context, src io.Reader, dest str
 		putOpts.StorageClass = string(opts.StorageClass)
 	}
 
+	if opts.ServerSideEncryption == EncryptionSSES3 {
+		putOpts.ServerSideEncryption = encrypt.NewSSE()
+	}
+
 	// Set part size for multipart upload
 	if opts.PartSize > 0 {
 		putOpts.PartSize = uint64(opts.PartSize)
@@ -152,9 +157,7 @@ func (t *TraditionalBackend) Download(ctx context.Context, src string, dest io.W
 	if opts.ProgressFunc != nil {
 		reader = NewProgressReader(reader, opts.ProgressFunc)
 	}
-	if opts.BandwidthLimit > 0 {
-		reader = NewBandwidthLimitedReader(reader, opts.BandwidthLimit)
-	}
+	reader = bandwidth.NewReader(ctx, reader, opts.Limiters...)
 
 	// Copy to destination
 	bytesWritten, err := io.Copy(dest, reader)
@@ -243,8 +246,10 @@ func (t *TraditionalBackend) List(ctx context.Context, prefix string, opts *List
 
 	// List objects
 	listOpts := minio.ListObjectsOptions{
-		Prefix:    objectPrefix,
-		Recursive: opts.Recursive,
+		Prefix:       objectPrefix,
+		Recursive:    opts.Recursive,
+		WithVersions: opts.IncludeVersions,
+		WithMetadata: opts.IncludeMetadata,
 	}
 
 	var files []FileInfo
@@ -254,17 +259,19 @@ func (t *TraditionalBackend) List(ctx context.Context, prefix string, opts *List
 		}
 
 		files = append(files, FileInfo{
-			Name:         filepath.Base(obj.Key),
-			Path:         bucket + "/" + obj.Key,
-			Size:         obj.Size,
-			IsDir:        strings.HasSuffix(obj.Key, "/"),
-			ModifiedAt:   obj.LastModified,
-			ContentType:  obj.ContentType,
-			ETag:         obj.ETag,
-			VersionID:    obj.VersionID,
-			StorageClass: StorageClass(obj.StorageClass),
-			Metadata:     obj.UserMetadata,
-			BackendType:  BackendTraditional,
+			Name:           filepath.Base(obj.Key),
+			Path:           bucket + "/" + obj.Key,
+			Size:           obj.Size,
+			IsDir:          strings.HasSuffix(obj.Key, "/"),
+			ModifiedAt:     obj.LastModified,
+			ContentType:    obj.ContentType,
+			ETag:           obj.ETag,
+			VersionID:      obj.VersionID,
+			StorageClass:   StorageClass(obj.StorageClass),
+			Metadata:       obj.UserMetadata,
+			IsLatest:       obj.IsLatest,
+			IsDeleteMarker: obj.IsDeleteMarker,
+			BackendType:    BackendTraditional,
 		})
 
 		// Respect MaxKeys limit
@@ -278,12 +285,18 @@ func (t *TraditionalBackend) List(ctx context.Context, prefix string, opts *List
 
 // Stat gets metadata for a file
 func (t *TraditionalBackend) Stat(ctx context.Context, path string) (*FileInfo, error) {
+	return t.StatVersion(ctx, path, "")
+}
+
+// StatVersion gets metadata for a version of a file, the latest one if
+// versionID is empty
+func (t *TraditionalBackend) StatVersion(ctx context.Context, path, versionID string) (*FileInfo, error) {
 	bucket, object := parsePath(path)
 	if object == "" {
 		return nil, fmt.Errorf("invalid path: %s (must be bucket/object)", path)
 	}
 
-	info, err := t.client.StatObject(ctx, bucket, object, minio.StatObjectOptions{})
+	info, err := t.client.StatObject(ctx, bucket, object, minio.StatObjectOptions{VersionID: versionID})
 	if err != nil {
 		return nil, fmt.Errorf("stat failed: %w", err)
 	}
@@ -365,3 +378,30 @@ func (t *TraditionalBackend) Ping(ctx context.Context) error {
 	}
 	return nil
 }
+
+// IsNotFound reports whether err means the object or bucket does not exist
+func IsNotFound(err error) bool {
+	var resp minio.ErrorResponse
+	if !errors.As(err, &resp) {
+		return false
+	}
+	switch resp.Code {
+	case "NoSuchKey", "NoSuchBucket", "NotFound":
+		return true
+	}
+	return false
+}
+
+// IsAccessDenied reports whether err means the credentials were refused
+// or lack permission
+func IsAccessDenied(err error) bool {
+	var resp minio.ErrorResponse
+	if !errors.As(err, &resp) {
+		return false
+	}
+	switch resp.Code {
+	case "AccessDenied", "InvalidAccessKeyId", "SignatureDoesNotMatch", "ExpiredToken", "InvalidToken":
+		return true
+	}
+	return false
+}
@@ -2,8 +2,11 @@ package storage
 
 import (
 	"context"
+	"fmt"
 	"io"
 	"time"
+
+	"github.com/darkstorage/cli/internal/bandwidth"
 )
 
 // StorageBackend defines the interface that all storage backends must implement
@@ -19,6 +22,7 @@ type StorageBackend interface {
 	// Listing and metadata
 	List(ctx context.Context, prefix string, opts *ListOptions) ([]FileInfo, error)
 	Stat(ctx context.Context, path string) (*FileInfo, error)
+	StatVersion(ctx context.Context, path, versionID string) (*FileInfo, error)
 
 	// Bucket operations
 	CreateBucket(ctx context.Context, name string) error
@@ -146,6 +150,21 @@ func AllStorageClasses() []StorageClass {
 	}
 }
 
+// Server-side encryption modes for UploadOptions
+const (
+	EncryptionNone  = "none"
+	EncryptionSSES3 = "sse-s3"
+)
+
+// ValidateEncryption checks a server-side encryption mode
+func ValidateEncryption(mode string) error {
+	switch mode {
+	case "", EncryptionNone, EncryptionSSES3:
+		return nil
+	}
+	return fmt.Errorf("unknown encryption %q (use %s or %s)", mode, EncryptionNone, EncryptionSSES3)
+}
+
 // UploadOptions configures upload behavior
 type UploadOptions struct {
 	// Storage class
@@ -160,11 +179,15 @@ type UploadOptions struct {
 	// Encryption (handled by encryption layer, not backend)
 	// Encryption will be done before upload, so backend sees encrypted data
 
+	// Server-side encryption at rest: "" or EncryptionNone, or EncryptionSSES3
+	ServerSideEncryption string
+
 	// Progress callback
 	ProgressFunc func(bytesTransferred int64)
 
-	// Bandwidth limit (bytes per second, 0 = unlimited)
-	BandwidthLimit int64
+	// Limiters the upload shares with other transfers; nil ones are
+	// ignored
+	Limiters []*bandwidth.Limiter
 
 	// Part size for multipart upload (0 = auto-detect)
 	PartSize int64
@@ -178,8 +201,9 @@ type DownloadOptions struct {
 	// Progress callback
 	ProgressFunc func(bytesTransferred int64)
 
-	// Bandwidth limit (bytes per second, 0 = unlimited)
-	BandwidthLimit int64
+	// Limiters the download shares with other transfers; nil ones are
+	// ignored
+	Limiters []*bandwidth.Limiter
 
 	// Resume from byte offset (for resumable downloads)
 	ResumeFrom int64
@@ -204,6 +228,9 @@ type ListOptions struct {
 
 	// Include metadata
 	IncludeMetadata bool
+
+	// Include every stored version and delete marker, not just the latest
+	IncludeVersions bool
 }
 
 // UploadResult contains information about an upload
@@ -243,6 +270,10 @@ type FileInfo struct {
 	StorageClass StorageClass
 	Metadata     map[string]string
 
+	// Versioning info (populated when listing with IncludeVersions)
+	IsLatest       bool
+	IsDeleteMarker bool
+
 	// Backend-specific info
 	BackendType BackendType
 	BackendData map[string]interface{}
@@ -279,39 +310,3 @@ func (pr *ProgressReader) Read(p []byte) (int, error) {
 	}
 	return n, err
 }
-
-// BandwidthLimitedReader wraps io.Reader and limits bandwidth
-type BandwidthLimitedReader struct {
-	reader         io.Reader
-	limit          int64 // bytes per second
-	lastReadTime   time.Time
-	bytesRead      int64
-}
-
-func NewBandwidthLimitedReader(r io.Reader, limit int64) *BandwidthLimitedReader {
-	return &BandwidthLimitedReader{
-		reader:       r,
-		limit:        limit,
-		lastReadTime: time.Now(),
-	}
-}
-
-func (br *BandwidthLimitedReader) Read(p []byte) (int, error) {
-	if br.limit <= 0 {
-		return br.reader.Read(p)
-	}
-
-	// Calculate how long we should wait to maintain the limit
-	elapsed := time.Since(br.lastReadTime)
-	expectedDuration := time.Duration(float64(br.bytesRead) / float64(br.limit) * float64(time.Second))
-
-	if expectedDuration > elapsed {
-		time.Sleep(expectedDuration - elapsed)
-	}
-
-	n, err := br.reader.Read(p)
-	br.bytesRead += int64(n)
-	br.lastReadTime = time.Now()
-
-	return n, err
-}
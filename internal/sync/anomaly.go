@@ -0,0 +1,333 @@
+package sync
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	gosync "sync"
+	"time"
+
+	"github.com/darkstorage/cli/internal/filetype"
+)
+
+const (
+	// Bytes read from the start of each changed file for scoring
+	anomalySampleSize = 64 * 1024
+
+	// Shannon entropy (bits per byte) above which content looks encrypted
+	highEntropyThreshold = 7.5
+
+	// Minimum entropy increase over a file's previous sample that counts as a jump
+	entropyJumpThreshold = 1.5
+
+	// Signal weights used to score a batch of changes
+	weightEntropy   = 0.45
+	weightMagic     = 0.30
+	weightExtension = 0.25
+)
+
+// AnomalyConfig controls when a batch of changes is considered suspicious
+type AnomalyConfig struct {
+	Enabled   bool
+	Window    time.Duration // changes older than this fall out of the batch
+	MinFiles  int           // number of most recent changes scored as one batch
+	Threshold float64       // score (0-1) at which the folder is paused
+}
+
+// DefaultAnomalyConfig returns conservative defaults that tolerate bulk
+// edits such as a git checkout while catching mass encryption
+func DefaultAnomalyConfig() AnomalyConfig {
+	return AnomalyConfig{
+		Enabled:   true,
+		Window:    time.Minute,
+		MinFiles:  20,
+		Threshold: 0.5,
+	}
+}
+
+// AnomalyAlert describes a batch of changes that looked like mass encryption
+type AnomalyAlert struct {
+	FolderID         int
+	Score            float64
+	FilesChanged     int
+	EntropyJumps     int
+	MagicMismatches  int
+	ExtensionChanges int
+	Samples          []string
+	DetectedAt       time.Time
+}
+
+func (a *AnomalyAlert) String() string {
+	return fmt.Sprintf("score %.2f over %d changes: %d entropy jumps, %d magic mismatches, %d extension changes (e.g. %s)",
+		a.Score, a.FilesChanged, a.EntropyJumps, a.MagicMismatches, a.ExtensionChanges,
+		strings.Join(a.Samples, ", "))
+}
+
+// changeSignals records which indicators fired for a single changed file
+type changeSignals struct {
+	path            string
+	at              time.Time
+	entropyJump     bool
+	magicMismatch   bool
+	extensionChange bool
+}
+
+func (c *changeSignals) suspicious() bool {
+	return c.entropyJump || c.magicMismatch || c.extensionChange
+}
+
+// baselineEntry is a file's entropy when it last had this size and
+// modification time
+type baselineEntry struct {
+	entropy float64
+	size    int64
+	modTime time.Time
+}
+
+// folderWindow is the sliding window of recent activity for one folder
+type folderWindow struct {
+	changes  []*changeSignals
+	removed  map[string]time.Time     // recently deleted/renamed paths
+	baseline map[string]baselineEntry // last observed entropy per path
+}
+
+// AnomalyDetector scores batches of file changes for signs of ransomware:
+// entropy jumping to near-random, content no longer matching the magic
+// bytes its extension implies, and files being renamed to new extensions
+type AnomalyDetector struct {
+	config  AnomalyConfig
+	folders map[int]*folderWindow
+	mu      gosync.Mutex
+}
+
+func NewAnomalyDetector(config AnomalyConfig) *AnomalyDetector {
+	return &AnomalyDetector{
+		config:  config,
+		folders: make(map[int]*folderWindow),
+	}
+}
+
+func (d *AnomalyDetector) SetConfig(config AnomalyConfig) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.config = config
+}
+
+func (d *AnomalyDetector) window(folderID int) *folderWindow {
+	w, ok := d.folders[folderID]
+	if !ok {
+		w = &folderWindow{
+			removed:  make(map[string]time.Time),
+			baseline: make(map[string]baselineEntry),
+		}
+		d.folders[folderID] = w
+	}
+	return w
+}
+
+// Baseline records the current entropy of a file without scoring it, so
+// later modifications can be compared against it. A file with the size and
+// modification time already recorded isn't read again.
+func (d *AnomalyDetector) Baseline(folderID int, relPath, fullPath string, info os.FileInfo) {
+	d.mu.Lock()
+	entry, ok := d.window(folderID).baseline[relPath]
+	d.mu.Unlock()
+	if ok && entry.size == info.Size() && entry.modTime.Equal(info.ModTime()) {
+		return
+	}
+
+	sample, err := readSample(fullPath)
+	if err != nil {
+		return
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.window(folderID).baseline[relPath] = baselineEntry{
+		entropy: Entropy(sample),
+		size:    info.Size(),
+		modTime: info.ModTime(),
+	}
+}
+
+// Observe scores a single file event and returns an alert when the batch it
+// belongs to crosses the configured threshold. The window is reset after an
+// alert so one burst produces one alert.
+func (d *AnomalyDetector) Observe(folderID int, relPath, fullPath string, eventType EventType) *AnomalyAlert {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if !d.config.Enabled {
+		return nil
+	}
+
+	now := time.Now()
+	w := d.window(folderID)
+	d.prune(w, now)
+
+	if eventType == EventDelete || eventType == EventRename {
+		w.removed[relPath] = now
+		delete(w.baseline, relPath)
+		return nil
+	}
+
+	sample, err := readSample(fullPath)
+	if err != nil {
+		return nil
+	}
+
+	change := &changeSignals{path: relPath, at: now}
+
+	entropy := Entropy(sample)
+	previous, seen := w.baseline[relPath]
+	if entropy >= highEntropyThreshold {
+		if seen {
+			change.entropyJump = entropy-previous.entropy >= entropyJumpThreshold
+		} else {
+			change.entropyJump = !filetype.IsCompressed(relPath)
+		}
+	}
+	entry := baselineEntry{entropy: entropy}
+	if info, err := os.Lstat(fullPath); err == nil {
+		entry.size, entry.modTime = info.Size(), info.ModTime()
+	}
+	w.baseline[relPath] = entry
+
+	if ok, known := filetype.MatchesExtension(relPath, sample); known && !ok {
+		change.magicMismatch = true
+	}
+
+	change.extensionChange = d.isExtensionChange(w, relPath)
+
+	w.changes = append(w.changes, change)
+	return d.evaluate(folderID, w, now)
+}
+
+// isExtensionChange detects "report.docx" becoming "report.docx.locked" or
+// "report.enc" within the window
+func (d *AnomalyDetector) isExtensionChange(w *folderWindow, relPath string) bool {
+	ext := filepath.Ext(relPath)
+	if ext == "" {
+		return false
+	}
+
+	// Appended extension: the original path is still known or was just removed
+	original := strings.TrimSuffix(relPath, ext)
+	if filepath.Ext(original) != "" {
+		if _, ok := w.removed[original]; ok {
+			return true
+		}
+		if _, ok := w.baseline[original]; ok {
+			return true
+		}
+	}
+
+	// Replaced extension: same stem, different extension, removed recently
+	for removed := range w.removed {
+		removedExt := filepath.Ext(removed)
+		if removedExt != "" && removedExt != ext && strings.TrimSuffix(removed, removedExt) == original {
+			return true
+		}
+	}
+	return false
+}
+
+func (d *AnomalyDetector) prune(w *folderWindow, now time.Time) {
+	cutoff := now.Add(-d.config.Window)
+
+	kept := w.changes[:0]
+	for _, c := range w.changes {
+		if c.at.After(cutoff) {
+			kept = append(kept, c)
+		}
+	}
+	w.changes = kept
+
+	for path, at := range w.removed {
+		if !at.After(cutoff) {
+			delete(w.removed, path)
+		}
+	}
+}
+
+// evaluate scores the most recent MinFiles changes in the window, so a burst
+// of encryption is not diluted by unrelated edits made earlier in the window
+func (d *AnomalyDetector) evaluate(folderID int, w *folderWindow, now time.Time) *AnomalyAlert {
+	if d.config.MinFiles <= 0 || len(w.changes) < d.config.MinFiles {
+		return nil
+	}
+	batch := w.changes[len(w.changes)-d.config.MinFiles:]
+
+	alert := &AnomalyAlert{
+		FolderID:     folderID,
+		FilesChanged: len(batch),
+		DetectedAt:   now,
+	}
+	for _, c := range batch {
+		if c.entropyJump {
+			alert.EntropyJumps++
+		}
+		if c.magicMismatch {
+			alert.MagicMismatches++
+		}
+		if c.extensionChange {
+			alert.ExtensionChanges++
+		}
+		if c.suspicious() && len(alert.Samples) < 5 {
+			alert.Samples = append(alert.Samples, c.path)
+		}
+	}
+
+	n := float64(len(batch))
+	alert.Score = weightEntropy*float64(alert.EntropyJumps)/n +
+		weightMagic*float64(alert.MagicMismatches)/n +
+		weightExtension*float64(alert.ExtensionChanges)/n
+
+	if alert.Score < d.config.Threshold {
+		return nil
+	}
+
+	w.changes = nil
+	return alert
+}
+
+// Entropy returns the Shannon entropy of data in bits per byte (0-8)
+func Entropy(data []byte) float64 {
+	if len(data) == 0 {
+		return 0
+	}
+
+	var counts [256]int
+	for _, b := range data {
+		counts[b]++
+	}
+
+	var entropy float64
+	size := float64(len(data))
+	for _, c := range counts {
+		if c == 0 {
+			continue
+		}
+		p := float64(c) / size
+		entropy -= p * math.Log2(p)
+	}
+	return entropy
+}
+
+func readSample(path string) ([]byte, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	buf := make([]byte, anomalySampleSize)
+	n, err := io.ReadFull(file, buf)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return nil, err
+	}
+	return buf[:n], nil
+}
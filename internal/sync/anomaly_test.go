@@ -0,0 +1,223 @@
+package sync
+
+import (
+	"bytes"
+	"crypto/rand"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestEntropy(t *testing.T) {
+	every := make([]byte, 256)
+	for i := range every {
+		every[i] = byte(i)
+	}
+	tests := []struct {
+		name string
+		data []byte
+		want float64
+	}{
+		{name: "empty", data: nil, want: 0},
+		{name: "one value", data: bytes.Repeat([]byte("a"), 100), want: 0},
+		{name: "two values", data: []byte("abababab"), want: 1},
+		{name: "four values", data: []byte("abcdabcd"), want: 2},
+		{name: "every value once", data: every, want: 8},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Entropy(tt.data); math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("Entropy() = %g, want %g", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAnomalyEvaluate(t *testing.T) {
+	clean := changeSignals{}
+	entropy := changeSignals{entropyJump: true}
+	encrypted := changeSignals{entropyJump: true, magicMismatch: true}
+	renamed := changeSignals{entropyJump: true, magicMismatch: true, extensionChange: true}
+
+	tests := []struct {
+		name      string
+		changes   []changeSignals
+		wantAlert bool
+		wantScore float64
+	}{
+		{name: "too few changes", changes: []changeSignals{renamed, renamed, renamed}},
+		{name: "clean edits", changes: []changeSignals{clean, clean, clean, clean}},
+		{name: "entropy alone stays under", changes: []changeSignals{entropy, entropy, entropy, entropy}},
+		{name: "encrypted content", changes: []changeSignals{encrypted, encrypted, encrypted, encrypted}, wantAlert: true, wantScore: 0.75},
+		{name: "half renamed", changes: []changeSignals{clean, renamed, clean, renamed}, wantAlert: true, wantScore: 0.5},
+		{name: "one renamed", changes: []changeSignals{clean, clean, clean, renamed}},
+		{name: "only the latest batch is scored", changes: []changeSignals{clean, clean, renamed, renamed, renamed, renamed}, wantAlert: true, wantScore: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := NewAnomalyDetector(AnomalyConfig{Enabled: true, Window: time.Minute, MinFiles: 4, Threshold: 0.5})
+			w := d.window(1)
+			for i, c := range tt.changes {
+				c := c
+				c.path = fmt.Sprintf("file%d", i)
+				w.changes = append(w.changes, &c)
+			}
+
+			alert := d.evaluate(1, w, time.Now())
+			if (alert != nil) != tt.wantAlert {
+				t.Fatalf("evaluate() = %v, want alert %v", alert, tt.wantAlert)
+			}
+			if alert == nil {
+				return
+			}
+			if math.Abs(alert.Score-tt.wantScore) > 1e-9 {
+				t.Errorf("score = %g, want %g", alert.Score, tt.wantScore)
+			}
+			if alert.FilesChanged != 4 {
+				t.Errorf("FilesChanged = %d, want 4", alert.FilesChanged)
+			}
+			if len(w.changes) != 0 {
+				t.Errorf("window kept %d changes after the alert", len(w.changes))
+			}
+		})
+	}
+}
+
+func randomBytes(t *testing.T, n int) []byte {
+	t.Helper()
+	data := make([]byte, n)
+	if _, err := rand.Read(data); err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func writeFile(t *testing.T, path string, data []byte) {
+	t.Helper()
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAnomalyObserve(t *testing.T) {
+	const files = 5
+	text := bytes.Repeat([]byte("the quick brown fox jumps over the lazy dog\n"), 200)
+	pdf := append([]byte("%PDF-1.7\n"), text...)
+
+	tests := []struct {
+		name string
+		// file is baselined with before, unless that is nil, then after
+		// is written to file plus suffix and observed
+		file      string
+		before    []byte
+		suffix    string
+		after     func(t *testing.T) []byte
+		disabled  bool
+		wantAlert bool
+	}{
+		{
+			name: "text edited", file: "notes.txt", before: text,
+			after: func(t *testing.T) []byte { return append(text, "more\n"...) },
+		},
+		{
+			name: "new compressed media", file: "clip.mp4",
+			after: func(t *testing.T) []byte { return randomBytes(t, 8192) },
+		},
+		{
+			name: "documents encrypted in place", file: "report.pdf", before: pdf,
+			after:     func(t *testing.T) []byte { return randomBytes(t, 8192) },
+			wantAlert: true,
+		},
+		{
+			name: "documents encrypted to a new extension", file: "notes.txt", before: text, suffix: ".locked",
+			after:     func(t *testing.T) []byte { return randomBytes(t, 8192) },
+			wantAlert: true,
+		},
+		{
+			name: "detection disabled", file: "report.pdf", before: pdf, disabled: true,
+			after: func(t *testing.T) []byte { return randomBytes(t, 8192) },
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			d := NewAnomalyDetector(AnomalyConfig{Enabled: !tt.disabled, Window: time.Minute, MinFiles: files, Threshold: 0.5})
+
+			var alert *AnomalyAlert
+			for i := 0; i < files; i++ {
+				rel := fmt.Sprintf("%d-%s", i, tt.file)
+				full := filepath.Join(dir, rel)
+				if tt.before != nil {
+					writeFile(t, full, tt.before)
+					info, err := os.Stat(full)
+					if err != nil {
+						t.Fatal(err)
+					}
+					d.Baseline(1, rel, full, info)
+				}
+
+				writeFile(t, full+tt.suffix, tt.after(t))
+				if a := d.Observe(1, rel+tt.suffix, full+tt.suffix, EventModify); a != nil {
+					alert = a
+				}
+			}
+
+			if (alert != nil) != tt.wantAlert {
+				t.Fatalf("Observe() alert = %v, want alert %v", alert, tt.wantAlert)
+			}
+			if alert != nil && alert.EntropyJumps != files {
+				t.Errorf("EntropyJumps = %d, want %d", alert.EntropyJumps, files)
+			}
+		})
+	}
+}
+
+func TestAnomalyBaseline(t *testing.T) {
+	text := bytes.Repeat([]byte("abcd"), 2048)
+
+	tests := []struct {
+		name string
+		// touch moves the modification time after the content is replaced
+		touch bool
+		// wantRead is whether the replaced content is sampled
+		wantRead bool
+	}{
+		{name: "same size and mtime isn't read again"},
+		{name: "new mtime is read again", touch: true, wantRead: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			full := filepath.Join(t.TempDir(), "file.txt")
+			writeFile(t, full, text)
+			info, err := os.Stat(full)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			d := NewAnomalyDetector(DefaultAnomalyConfig())
+			d.Baseline(1, "file.txt", full, info)
+
+			writeFile(t, full, randomBytes(t, len(text)))
+			modTime := info.ModTime()
+			if tt.touch {
+				modTime = modTime.Add(time.Second)
+			}
+			if err := os.Chtimes(full, modTime, modTime); err != nil {
+				t.Fatal(err)
+			}
+			info, err = os.Stat(full)
+			if err != nil {
+				t.Fatal(err)
+			}
+			d.Baseline(1, "file.txt", full, info)
+
+			entropy := d.window(1).baseline["file.txt"].entropy
+			if read := entropy >= highEntropyThreshold; read != tt.wantRead {
+				t.Errorf("baseline entropy = %g, want the new content read %v", entropy, tt.wantRead)
+			}
+		})
+	}
+}
@@ -0,0 +1,47 @@
+package sync
+
+import (
+	"github.com/darkstorage/cli/internal/bandwidth"
+	"github.com/darkstorage/cli/internal/db"
+)
+
+// folderLimiters hold a folder's transfers to its own bandwidth limit,
+// within the daemon-wide ones. The limit applies to each direction.
+type folderLimiters struct {
+	up   *bandwidth.Limiter
+	down *bandwidth.Limiter
+}
+
+// folderLimiter returns the limiter shared by the folder's transfers in
+// one direction, nil if the folder has no limit. The rate follows the
+// folder's current limit, so transfers already running pick up a change
+// when the next one starts.
+func (e *Engine) folderLimiter(folder *db.SyncFolder, operation string) *bandwidth.Limiter {
+	e.limitersMu.Lock()
+	defer e.limitersMu.Unlock()
+
+	if folder.BandwidthLimit == nil || *folder.BandwidthLimit <= 0 {
+		if l, ok := e.limiters[folder.ID]; ok {
+			// Let transfers still holding them run freely
+			l.up.SetRate(0)
+			l.down.SetRate(0)
+			delete(e.limiters, folder.ID)
+		}
+		return nil
+	}
+
+	// Limits are configured in KB/s
+	rate := int64(*folder.BandwidthLimit) * 1024
+	l, ok := e.limiters[folder.ID]
+	if !ok {
+		l = &folderLimiters{up: bandwidth.NewLimiter(rate), down: bandwidth.NewLimiter(rate)}
+		e.limiters[folder.ID] = l
+	}
+	l.up.SetRate(rate)
+	l.down.SetRate(rate)
+
+	if operation == "download" {
+		return l.down
+	}
+	return l.up
+}
@@ -0,0 +1,145 @@
+package sync
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/darkstorage/cli/internal/atomicfile"
+	"github.com/darkstorage/cli/internal/db"
+)
+
+// Conflict resolutions
+const (
+	ResolveKeepLocal  = "keep_local"
+	ResolveKeepRemote = "keep_remote"
+	ResolveKeepBoth   = "keep_both"
+)
+
+// resolvePriority queues the operations that settle a conflict ahead of
+// routine syncing
+const resolvePriority = 10
+
+// ResolveConflict settles a conflict by queueing the transfers that make
+// both sides agree: keep_local uploads the local file, keep_remote
+// downloads the remote one, and keep_both first copies the local file to a
+// new name next to it, then downloads the remote version.
+func (e *Engine) ResolveConflict(id int, resolution string) error {
+	conflict, err := e.db.GetConflict(id)
+	if err != nil {
+		return err
+	}
+	if conflict == nil {
+		return &NotFoundError{What: "conflict", ID: id}
+	}
+	if conflict.Resolved {
+		return fmt.Errorf("conflict %d is already resolved", id)
+	}
+
+	folder, err := e.db.GetSyncFolder(conflict.SyncFolderID)
+	if err != nil {
+		return err
+	}
+	if folder == nil {
+		return &NotFoundError{What: "folder", ID: conflict.SyncFolderID}
+	}
+	fullPath := filepath.Join(folder.LocalPath, conflict.RelativePath)
+
+	switch resolution {
+	case ResolveKeepLocal:
+		operation := "upload"
+		if _, err := os.Lstat(fullPath); os.IsNotExist(err) {
+			operation = "delete"
+		} else if err := e.recordLocalChange(folder.ID, conflict.RelativePath, fullPath); err != nil {
+			return err
+		}
+		if err := e.enqueueResolution(conflict, conflict.RelativePath, operation); err != nil {
+			return err
+		}
+	case ResolveKeepRemote:
+		if err := e.enqueueResolution(conflict, conflict.RelativePath, "download"); err != nil {
+			return err
+		}
+	case ResolveKeepBoth:
+		if _, err := os.Lstat(fullPath); err == nil {
+			copyPath := conflictCopyPath(fullPath, time.Now())
+			if err := copyFile(fullPath, copyPath); err != nil {
+				return fmt.Errorf("failed to keep local copy: %w", err)
+			}
+			relCopy, err := filepath.Rel(folder.LocalPath, copyPath)
+			if err != nil {
+				return err
+			}
+			if err := e.recordLocalChange(folder.ID, relCopy, copyPath); err != nil {
+				return err
+			}
+			if err := e.enqueueResolution(conflict, relCopy, "upload"); err != nil {
+				return err
+			}
+		}
+		if err := e.enqueueResolution(conflict, conflict.RelativePath, "download"); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unknown resolution %q (use keep_local, keep_remote or keep_both)", resolution)
+	}
+
+	if err := e.db.ResolveConflict(id, resolution); err != nil {
+		return err
+	}
+
+	return e.db.LogActivity(&db.Activity{
+		SyncFolderID: &folder.ID,
+		Operation:    "resolve_conflict",
+		Path:         conflict.RelativePath,
+		Status:       "success",
+		Details:      &resolution,
+	})
+}
+
+func (e *Engine) enqueueResolution(conflict *db.Conflict, relPath, operation string) error {
+	return e.db.EnqueueOperation(&db.QueueOperation{
+		SyncFolderID: conflict.SyncFolderID,
+		RelativePath: relPath,
+		Operation:    operation,
+		Priority:     resolvePriority,
+		MaxAttempts:  3,
+	})
+}
+
+// conflictCopyPath names the copy of a conflicting local file kept by
+// keep_both, e.g. "report (conflict 2024-05-01 120000).pdf"
+func conflictCopyPath(path string, at time.Time) string {
+	ext := filepath.Ext(path)
+	base := strings.TrimSuffix(path, ext)
+	return fmt.Sprintf("%s (conflict %s)%s", base, at.Format("2006-01-02 150405"), ext)
+}
+
+func copyFile(src, dest string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	info, err := in.Stat()
+	if err != nil {
+		return err
+	}
+
+	out, err := atomicfile.Create(dest)
+	if err != nil {
+		return err
+	}
+	n, err := io.Copy(out, in)
+	if err != nil {
+		out.Abort()
+		return err
+	}
+	if err := out.Commit(atomicfile.ExpectSize(n)); err != nil {
+		return err
+	}
+	return os.Chmod(dest, info.Mode().Perm())
+}
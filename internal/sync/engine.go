@@ -1,25 +1,129 @@
 package sync
 
 import (
+	"context"
 	"fmt"
 	"os"
+	"path"
 	"path/filepath"
+	gosync "sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/darkstorage/cli/internal/api"
+	"github.com/darkstorage/cli/internal/atomicfile"
 	"github.com/darkstorage/cli/internal/db"
+	"github.com/darkstorage/cli/internal/logging"
 )
 
+var logger = logging.For(logging.Engine)
+
+// ClientResolver returns the API client for a profile; "" is the default
+// account
+type ClientResolver func(profile string) (*api.Client, error)
+
 type Engine struct {
-	db     *db.DB
-	client *api.Client
+	db       *db.DB
+	client   *api.Client
+	resolver ClientResolver
+	detector *AnomalyDetector
+	observer Observer
+	workers  atomic.Int32
+	ctx      context.Context
+	cancel   context.CancelFunc
+
+	// draining stops workers taking new operations; inFlight counts the
+	// operations still running
+	draining atomic.Bool
+	inFlight atomic.Int32
+
+	// held is why no new operations start, "" while they may
+	held atomic.Pointer[string]
+
+	runsMu gosync.Mutex
+	runs   map[int]*run
+
+	limitersMu gosync.Mutex
+	limiters   map[int]*folderLimiters
 }
 
 func NewEngine(database *db.DB, client *api.Client) *Engine {
-	return &Engine{
-		db:     database,
-		client: client,
+	ctx, cancel := context.WithCancel(context.Background())
+	e := &Engine{
+		db:       database,
+		client:   client,
+		detector: NewAnomalyDetector(DefaultAnomalyConfig()),
+		observer: nopObserver{},
+		runs:     make(map[int]*run),
+		limiters: make(map[int]*folderLimiters),
+		ctx:      ctx,
+		cancel:   cancel,
+	}
+	e.workers.Store(1)
+	return e
+}
+
+// Stop cancels in-flight transfers. Interrupted operations are put back in
+// the queue for the next run.
+func (e *Engine) Stop() {
+	e.cancel()
+}
+
+// Shutdown stops taking operations from the queue and waits up to timeout
+// for those in flight to finish, then cancels any left as Stop does. It
+// reports whether everything finished in time.
+func (e *Engine) Shutdown(timeout time.Duration) bool {
+	e.draining.Store(true)
+	drained := e.waitIdle(timeout)
+	e.Stop()
+	if !drained {
+		// Give cancelled operations a moment to be put back in the queue
+		e.waitIdle(5 * time.Second)
+	}
+	return drained
+}
+
+func (e *Engine) waitIdle(timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+	for e.inFlight.Load() > 0 {
+		if time.Now().After(deadline) {
+			return false
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	return true
+}
+
+func (e *Engine) SetAnomalyConfig(config AnomalyConfig) {
+	e.detector.SetConfig(config)
+}
+
+// SetWorkers sets how many queued operations run in parallel. It takes
+// effect from the next ProcessQueue call.
+func (e *Engine) SetWorkers(n int) {
+	if n < 1 {
+		n = 1
 	}
+	e.workers.Store(int32(n))
+}
+
+// SetClientResolver makes each folder sync with the client for its
+// profile, looked up for every operation so replaced clients are picked
+// up. Without a resolver every folder uses the engine's client.
+func (e *Engine) SetClientResolver(resolver ClientResolver) {
+	e.resolver = resolver
+}
+
+// clientFor returns the client for folder's profile
+func (e *Engine) clientFor(folder *db.SyncFolder) (*api.Client, error) {
+	if e.resolver == nil {
+		return e.client, nil
+	}
+	client, err := e.resolver(folder.Profile)
+	if err != nil {
+		return nil, fmt.Errorf("profile %s: %w", folder.Profile, err)
+	}
+	return client, nil
 }
 
 func (e *Engine) SyncFolder(folderID int) error {
@@ -31,9 +135,12 @@ func (e *Engine) SyncFolder(folderID int) error {
 		return fmt.Errorf("folder not found: %d", folderID)
 	}
 
-	fmt.Printf("Syncing folder: %s -> %s\n", folder.LocalPath, folder.RemotePath)
+	logger.Info("syncing folder", "folder_id", folder.ID, "path", folder.LocalPath, "remote", folder.RemotePath)
 
-	return e.scanAndSync(folder)
+	if err := e.scanAndSync(folder); err != nil {
+		return err
+	}
+	return e.reconcileRemote(folder)
 }
 
 func (e *Engine) scanAndSync(folder *db.SyncFolder) error {
@@ -42,7 +149,7 @@ func (e *Engine) scanAndSync(folder *db.SyncFolder) error {
 			return err
 		}
 
-		if info.IsDir() {
+		if info.IsDir() || atomicfile.IsTemp(path) {
 			return nil
 		}
 
@@ -51,11 +158,25 @@ func (e *Engine) scanAndSync(folder *db.SyncFolder) error {
 			return err
 		}
 
-		hash, err := HashFile(path)
+		if e.isDehydrated(folder.ID, relPath, path) {
+			return nil
+		}
+
+		hash, err := HashPath(path)
 		if err != nil {
 			return err
 		}
 
+		e.detector.Baseline(folder.ID, relPath, path, info)
+
+		existing, err := e.db.GetFileState(folder.ID, relPath)
+		if err != nil {
+			return err
+		}
+		if existing != nil && existing.SyncStatus == StatusSynced && existing.LocalHash != nil && *existing.LocalHash == hash {
+			return nil
+		}
+
 		modTime := info.ModTime()
 		size := info.Size()
 
@@ -84,12 +205,65 @@ func (e *Engine) scanAndSync(folder *db.SyncFolder) error {
 }
 
 func (e *Engine) ProcessFileEvent(event *FileEvent, folderID int) error {
-	fmt.Printf("Processing event: %s %s\n", event.EventType, event.Path)
+	logger.Debug("processing event", "folder_id", folderID, "type", event.EventType.String(), "path", event.Path)
+
+	folder, err := e.db.GetSyncFolder(folderID)
+	if err != nil {
+		return err
+	}
+	if folder == nil {
+		return fmt.Errorf("folder not found: %d", folderID)
+	}
+
+	relPath := event.Path
+	if filepath.IsAbs(event.Path) {
+		relPath, err = filepath.Rel(folder.LocalPath, event.Path)
+		if err != nil {
+			return err
+		}
+	}
+	fullPath := filepath.Join(folder.LocalPath, relPath)
+
+	if atomicfile.IsTemp(fullPath) {
+		return nil
+	}
+
+	// Placeholders being created or removed are not user changes
+	if e.isDehydrated(folder.ID, relPath, fullPath) {
+		return nil
+	}
+
+	// A running download renames its file into place before the state
+	// says synced; that and anything else until it ends is its own doing
+	if e.downloading(folder.ID, relPath) {
+		return nil
+	}
+
+	// Our own downloads leave content matching the synced state
+	if event.EventType != EventDelete && e.isUnchanged(folder.ID, relPath, fullPath) {
+		return nil
+	}
+
+	if !folder.Paused {
+		if alert := e.detector.Observe(folderID, relPath, fullPath, event.EventType); alert != nil {
+			if err := e.pauseForAnomaly(folder, alert); err != nil {
+				return err
+			}
+		}
+	}
+
+	operation := "upload"
+	if _, err := os.Lstat(fullPath); os.IsNotExist(err) {
+		// Deleted, or the old name of a rename (the new name gets its own event)
+		operation = "delete"
+	} else if err := e.recordLocalChange(folderID, relPath, fullPath); err != nil {
+		return err
+	}
 
 	op := &db.QueueOperation{
 		SyncFolderID: folderID,
-		RelativePath: event.Path,
-		Operation:    "upload",
+		RelativePath: relPath,
+		Operation:    operation,
 		Priority:     0,
 		MaxAttempts:  3,
 	}
@@ -97,63 +271,365 @@ func (e *Engine) ProcessFileEvent(event *FileEvent, folderID int) error {
 	return e.db.EnqueueOperation(op)
 }
 
+// isUnchanged reports whether the file still matches its last synced content
+func (e *Engine) isUnchanged(folderID int, relPath, fullPath string) bool {
+	state, err := e.db.GetFileState(folderID, relPath)
+	if err != nil || state == nil || state.SyncStatus != StatusSynced || state.LocalHash == nil {
+		return false
+	}
+
+	hash, err := HashPath(fullPath)
+	return err == nil && hash == *state.LocalHash
+}
+
+// recordLocalChange stores the current content of a changed file as pending.
+// Files that no longer exist are left for the queued operation to handle.
+func (e *Engine) recordLocalChange(folderID int, relPath, fullPath string) error {
+	info, err := os.Lstat(fullPath)
+	if err != nil {
+		return nil
+	}
+	hash, err := HashPath(fullPath)
+	if err != nil {
+		return nil
+	}
+
+	state, err := e.db.GetFileState(folderID, relPath)
+	if err != nil {
+		return err
+	}
+	if state == nil {
+		state = &db.FileState{
+			SyncFolderID: folderID,
+			RelativePath: relPath,
+		}
+	}
+
+	modTime := info.ModTime()
+	size := info.Size()
+	state.LocalHash = &hash
+	state.LocalModifiedAt = &modTime
+	state.LocalSize = &size
+	state.SyncStatus = StatusPending
+	state.Dehydrated = false
+
+	return e.db.UpsertFileState(state)
+}
+
+// pauseForAnomaly stops uploads for a folder that looks like it is being
+// encrypted and records an alert. Queued operations stay pending until the
+// folder is explicitly resumed.
+func (e *Engine) pauseForAnomaly(folder *db.SyncFolder, alert *AnomalyAlert) error {
+	reason := "possible ransomware: " + alert.String()
+	logger.Warn("pausing uploads", "folder_id", folder.ID, "path", folder.LocalPath, "reason", reason)
+
+	if err := e.db.SetSyncFolderPaused(folder.ID, true, false, &reason); err != nil {
+		return err
+	}
+	folder.Paused = true
+	folder.PauseReason = &reason
+	e.observer.FolderPaused(folder.ID, reason)
+
+	return e.db.LogActivity(&db.Activity{
+		SyncFolderID: &folder.ID,
+		Operation:    "anomaly_detected",
+		Path:         folder.LocalPath,
+		Status:       "alert",
+		Details:      &reason,
+	})
+}
+
+// PauseFolder holds all of a folder's queued operations, downloads
+// included, until it is resumed. Changes keep being queued meanwhile. A
+// folder already paused by anomaly detection keeps that reason.
+func (e *Engine) PauseFolder(folderID int, reason string) error {
+	folder, err := e.db.GetSyncFolder(folderID)
+	if err != nil {
+		return err
+	}
+	if folder == nil {
+		return fmt.Errorf("folder not found: %d", folderID)
+	}
+	if folder.Paused && folder.PauseAll {
+		return nil
+	}
+	if folder.Paused && folder.PauseReason != nil {
+		reason = *folder.PauseReason
+	}
+
+	if err := e.db.SetSyncFolderPaused(folderID, true, true, &reason); err != nil {
+		return err
+	}
+	e.observer.FolderPaused(folderID, reason)
+
+	return e.db.LogActivity(&db.Activity{
+		SyncFolderID: &folder.ID,
+		Operation:    "pause",
+		Path:         folder.LocalPath,
+		Status:       "success",
+		Details:      &reason,
+	})
+}
+
+// ResumeFolder clears a pause (including one raised by anomaly detection)
+// so queued uploads for the folder are processed again. With
+// discardPending, the uploads and deletes queued meanwhile, such as those
+// of a suspicious burst, are cancelled instead; it returns how many were.
+func (e *Engine) ResumeFolder(folderID int, discardPending bool) (int, error) {
+	folder, err := e.db.GetSyncFolder(folderID)
+	if err != nil {
+		return 0, err
+	}
+	if folder == nil {
+		return 0, fmt.Errorf("folder not found: %d", folderID)
+	}
+	if !folder.Paused {
+		return 0, nil
+	}
+
+	// Cancelled while still paused, so no worker takes them first
+	discarded := 0
+	if discardPending {
+		if discarded, err = e.db.CancelPendingChanges(folderID); err != nil {
+			return 0, err
+		}
+		logger.Info("discarded pending changes", "folder_id", folderID, "operations", discarded)
+	}
+
+	if err := e.db.SetSyncFolderPaused(folderID, false, false, nil); err != nil {
+		return discarded, err
+	}
+
+	details := folder.PauseReason
+	if discardPending {
+		d := fmt.Sprintf("discarded %d pending operation(s)", discarded)
+		if details != nil {
+			d = *details + "; " + d
+		}
+		details = &d
+	}
+	return discarded, e.db.LogActivity(&db.Activity{
+		SyncFolderID: &folder.ID,
+		Operation:    "resume",
+		Path:         folder.LocalPath,
+		Status:       "success",
+		Details:      details,
+	})
+}
+
+// Hold stops operations starting in every folder, for reason, until
+// called again with ""; those running finish. Unlike PauseFolder it is
+// not stored, so it ends with the daemon.
+func (e *Engine) Hold(reason string) {
+	e.held.Store(&reason)
+}
+
+// HoldReason returns why operations are held, "" if they aren't
+func (e *Engine) HoldReason() string {
+	if reason := e.held.Load(); reason != nil {
+		return *reason
+	}
+	return ""
+}
+
+// ProcessQueue runs queued operations until the queue is empty, with up to
+// SetWorkers operations in flight
 func (e *Engine) ProcessQueue() error {
-	for {
-		op, err := e.db.DequeueOperation()
-		if err != nil {
-			return err
+	workers := int(e.workers.Load())
+	errs := make(chan error, workers)
+	for i := 0; i < workers; i++ {
+		go func() {
+			errs <- e.processQueueWorker()
+		}()
+	}
+
+	var firstErr error
+	for i := 0; i < workers; i++ {
+		if err := <-errs; err != nil && firstErr == nil {
+			firstErr = err
 		}
-		if op == nil {
-			break
+	}
+	return firstErr
+}
+
+func (e *Engine) processQueueWorker() error {
+	for e.ctx.Err() == nil {
+		done, err := e.processNext()
+		if err != nil || done {
+			return err
 		}
+	}
+	return nil
+}
+
+// processNext runs one queued operation. It reports done when the queue
+// is empty, operations are held or the engine is shutting down.
+func (e *Engine) processNext() (done bool, err error) {
+	// Counted before checking draining, so Shutdown can't miss an
+	// operation that is about to start
+	e.inFlight.Add(1)
+	defer e.inFlight.Add(-1)
+	if e.draining.Load() || e.HoldReason() != "" {
+		return true, nil
+	}
+
+	op, err := e.db.DequeueOperation()
+	if err != nil {
+		return true, err
+	}
+	if op == nil {
+		return true, nil
+	}
+
+	r := e.startRun(op)
+	defer e.endRun(r)
 
-		startTime := time.Now()
-		err = e.executeOperation(op)
-		duration := time.Since(startTime)
+	opErr := e.executeOperation(r)
+	duration := time.Since(r.startedAt)
+	// Observers hear of the operation once its status is stored
+	defer func() { e.observer.TransferCompleted(op, opErr) }()
 
-		activity := &db.Activity{
+	if opErr != nil && r.cancelled.Load() {
+		e.db.UpdateOperationStatus(op.ID, QueueCancelled, nil)
+		e.db.LogActivity(&db.Activity{
 			SyncFolderID: &op.SyncFolderID,
 			Operation:    op.Operation,
 			Path:         op.RelativePath,
+			Status:       "cancelled",
 			DurationMS:   intPtr(int(duration.Milliseconds())),
-		}
+		})
+		return false, nil
+	}
+	if opErr != nil && e.ctx.Err() != nil {
+		// Interrupted by Stop; leave it queued for the next run
+		e.db.UpdateOperationStatus(op.ID, QueuePending, nil)
+		return true, nil
+	}
 
-		if err != nil {
-			errMsg := err.Error()
-			activity.Status = "error"
-			activity.ErrorMessage = &errMsg
-			e.db.UpdateOperationStatus(op.ID, QueueFailed, &errMsg)
-		} else {
-			activity.Status = "success"
-			e.db.UpdateOperationStatus(op.ID, QueueCompleted, nil)
-		}
+	activity := &db.Activity{
+		SyncFolderID: &op.SyncFolderID,
+		Operation:    op.Operation,
+		Path:         op.RelativePath,
+		DurationMS:   intPtr(int(duration.Milliseconds())),
+	}
 
-		e.db.LogActivity(activity)
+	if opErr != nil {
+		errMsg := opErr.Error()
+		activity.Status = "error"
+		activity.ErrorMessage = &errMsg
+		e.db.UpdateOperationStatus(op.ID, QueueFailed, &errMsg)
+		logger.Error("operation failed", "folder_id", op.SyncFolderID, "operation", op.Operation,
+			"path", op.RelativePath, "error", opErr)
+	} else {
+		logger.Debug("operation completed", "folder_id", op.SyncFolderID, "operation", op.Operation,
+			"path", op.RelativePath, "duration", duration)
+		activity.Status = "success"
+		if op.Operation != "delete" {
+			bytes := r.bytes.Load()
+			activity.BytesTransferred = &bytes
+		}
+		e.db.UpdateOperationStatus(op.ID, QueueCompleted, nil)
+		e.markSynced(op)
+		e.recordVersion(op, r.versionID)
 	}
-	return nil
+
+	e.db.LogActivity(activity)
+	return false, nil
 }
 
-func (e *Engine) executeOperation(op *db.QueueOperation) error {
+func (e *Engine) executeOperation(r *run) error {
+	op := r.op
 	folder, err := e.db.GetSyncFolder(op.SyncFolderID)
 	if err != nil {
 		return err
 	}
+	if folder == nil {
+		return fmt.Errorf("folder not found: %d", op.SyncFolderID)
+	}
+
+	client, err := e.clientFor(folder)
+	if err != nil {
+		return err
+	}
 
 	localPath := filepath.Join(folder.LocalPath, op.RelativePath)
-	remotePath := filepath.Join(folder.RemotePath, op.RelativePath)
+	remotePath := path.Join(folder.RemotePath, filepath.ToSlash(op.RelativePath))
 
+	size := e.transferSize(op, localPath)
+	r.size.Store(size)
+	e.observer.TransferStarted(op, size)
+
+	limiter := e.folderLimiter(folder, op.Operation)
 	switch op.Operation {
 	case "upload":
-		return e.client.UploadFile(localPath, remotePath, nil)
+		r.versionID, err = client.UploadFile(r.ctx, localPath, remotePath, e.progressFunc(r), limiter)
+		return err
 	case "download":
-		return e.client.DownloadFile(remotePath, localPath, nil)
+		r.versionID, err = client.DownloadFile(r.ctx, remotePath, localPath, e.progressFunc(r), limiter)
+		return err
 	case "delete":
-		return e.client.DeleteFile(remotePath)
+		return client.DeleteFile(r.ctx, remotePath)
 	default:
 		return fmt.Errorf("unknown operation: %s", op.Operation)
 	}
 }
 
+// markSynced updates the file state after a successful transfer. A download
+// also turns a placeholder back into a regular, hydrated file and records
+// the new content, so the watcher events it causes are recognised as ours.
+func (e *Engine) markSynced(op *db.QueueOperation) {
+	state, err := e.db.GetFileState(op.SyncFolderID, op.RelativePath)
+	if err != nil || state == nil {
+		return
+	}
+
+	switch op.Operation {
+	case "download":
+		if folder, err := e.db.GetSyncFolder(op.SyncFolderID); err == nil && folder != nil {
+			fullPath := filepath.Join(folder.LocalPath, op.RelativePath)
+			ClearPlaceholderMarker(fullPath)
+			e.recordLocalChange(op.SyncFolderID, op.RelativePath, fullPath)
+		}
+	case "upload":
+		// The remote copy now matches what was hashed locally
+		state.RemoteHash = state.LocalHash
+		state.RemoteSize = state.LocalSize
+		e.db.UpsertFileState(state)
+	case "delete":
+		e.db.DeleteFileState(op.SyncFolderID, op.RelativePath)
+		return
+	}
+
+	e.db.UpdateSyncStatus(state.ID, StatusSynced)
+}
+
+// recordVersion appends the outcome of a completed operation to the folder's
+// file history so the tree can later be reconstructed at a point in time.
+// versionID is the remote version transferred, "" if unknown.
+func (e *Engine) recordVersion(op *db.QueueOperation, versionID string) {
+	version := &db.FileVersion{
+		SyncFolderID: op.SyncFolderID,
+		RelativePath: op.RelativePath,
+		Operation:    op.Operation,
+		Deleted:      op.Operation == "delete",
+	}
+	if versionID != "" {
+		version.VersionID = &versionID
+	}
+
+	if !version.Deleted {
+		state, err := e.db.GetFileState(op.SyncFolderID, op.RelativePath)
+		if err == nil && state != nil {
+			version.Hash = state.LocalHash
+			version.Size = state.LocalSize
+		}
+	}
+
+	if err := e.db.RecordFileVersion(version); err != nil {
+		logger.Error("failed to record history", "folder_id", op.SyncFolderID, "path", op.RelativePath, "error", err)
+	}
+}
+
 func intPtr(i int) *int {
 	return &i
 }
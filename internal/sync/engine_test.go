@@ -0,0 +1,46 @@
+package sync
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/darkstorage/cli/internal/db"
+)
+
+func TestProcessFileEventDuringDownload(t *testing.T) {
+	tests := []struct {
+		name string
+		// operation is running for the file when the event arrives, "" for none
+		operation string
+		event     EventType
+		want      []string
+	}{
+		{name: "local change", event: EventCreate, want: []string{"upload a.txt"}},
+		{name: "download renamed into place", operation: "download", event: EventCreate},
+		{name: "removed during a download", operation: "download", event: EventDelete},
+		{name: "upload running", operation: "upload", event: EventModify, want: []string{"upload a.txt"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, database, folder := newTestEngine(t)
+			if tt.event != EventDelete {
+				if err := os.WriteFile(filepath.Join(folder.LocalPath, "a.txt"), []byte("content"), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if tt.operation != "" {
+				r := e.startRun(&db.QueueOperation{ID: 1, SyncFolderID: folder.ID, RelativePath: "a.txt", Operation: tt.operation})
+				defer e.endRun(r)
+			}
+
+			if err := e.ProcessFileEvent(&FileEvent{Path: "a.txt", EventType: tt.event}, folder.ID); err != nil {
+				t.Fatalf("ProcessFileEvent() error = %v", err)
+			}
+			if got := queued(t, database, folder.ID); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("queued %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
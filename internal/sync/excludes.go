@@ -3,6 +3,8 @@ package sync
 import (
 	"path/filepath"
 	"strings"
+
+	"github.com/darkstorage/cli/internal/db"
 )
 
 type ExcludeRules struct {
@@ -15,6 +17,18 @@ func NewExcludeRules(patterns []string) *ExcludeRules {
 	}
 }
 
+// FolderExcludes returns the rules for a folder's exclude patterns, which
+// are stored one per line
+func FolderExcludes(folder *db.SyncFolder) *ExcludeRules {
+	var patterns []string
+	for _, p := range strings.Split(folder.ExcludePatterns, "\n") {
+		if p = strings.TrimSpace(p); p != "" {
+			patterns = append(patterns, p)
+		}
+	}
+	return NewExcludeRules(patterns)
+}
+
 func (r *ExcludeRules) ShouldExclude(path string) bool {
 	for _, pattern := range r.patterns {
 		matched, err := filepath.Match(pattern, filepath.Base(path))
@@ -22,6 +22,25 @@ func HashFile(path string) (string, error) {
 	return hex.EncodeToString(hasher.Sum(nil)), nil
 }
 
+// HashPath hashes a file's content, or a symlink's target so links are
+// synced as links rather than followed
+func HashPath(path string) (string, error) {
+	info, err := os.Lstat(path)
+	if err != nil {
+		return "", err
+	}
+	if info.Mode()&os.ModeSymlink == 0 {
+		return HashFile(path)
+	}
+
+	target, err := os.Readlink(path)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256([]byte("symlink:" + target))
+	return hex.EncodeToString(sum[:]), nil
+}
+
 func HashFileChunked(path string, chunkSize int) (string, error) {
 	return HashFile(path)
 }
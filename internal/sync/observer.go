@@ -0,0 +1,83 @@
+package sync
+
+import (
+	"os"
+	"time"
+
+	"github.com/darkstorage/cli/internal/db"
+)
+
+// progressInterval limits how often TransferProgress is reported for one
+// transfer
+const progressInterval = 500 * time.Millisecond
+
+// Observer is told about the engine's work as it happens, e.g. so the
+// daemon can stream it to subscribers. Calls come from sync goroutines and
+// must return quickly.
+type Observer interface {
+	// TransferStarted is called before an operation runs, with the size
+	// of the file if known
+	TransferStarted(op *db.QueueOperation, size int64)
+	TransferProgress(op *db.QueueOperation, bytes, size int64)
+	// TransferCompleted follows every operation taken from the queue,
+	// including one that failed before it started, once its new status
+	// is stored
+	TransferCompleted(op *db.QueueOperation, err error)
+	ConflictCreated(conflict *db.Conflict)
+	FolderPaused(folderID int, reason string)
+}
+
+type nopObserver struct{}
+
+func (nopObserver) TransferStarted(*db.QueueOperation, int64)         {}
+func (nopObserver) TransferProgress(*db.QueueOperation, int64, int64) {}
+func (nopObserver) TransferCompleted(*db.QueueOperation, error)       {}
+func (nopObserver) ConflictCreated(*db.Conflict)                      {}
+func (nopObserver) FolderPaused(int, string)                          {}
+
+// SetObserver reports the engine's work to o. Call it before syncing
+// starts.
+func (e *Engine) SetObserver(o Observer) {
+	e.observer = o
+}
+
+// RecordConflict stores a conflict and reports it to the observer
+func (e *Engine) RecordConflict(conflict *db.Conflict) error {
+	if err := e.db.CreateConflict(conflict); err != nil {
+		return err
+	}
+	e.observer.ConflictCreated(conflict)
+	return nil
+}
+
+// transferSize returns the size of the file op moves, or 0 if unknown
+func (e *Engine) transferSize(op *db.QueueOperation, localPath string) int64 {
+	switch op.Operation {
+	case "upload":
+		if info, err := os.Stat(localPath); err == nil {
+			return info.Size()
+		}
+	case "download":
+		state, err := e.db.GetFileState(op.SyncFolderID, op.RelativePath)
+		if err == nil && state != nil && state.RemoteSize != nil {
+			return *state.RemoteSize
+		}
+	}
+	return 0
+}
+
+// progressFunc returns a transfer progress callback that records the bytes
+// moved and reports them to the observer at most every progressInterval,
+// and always at the end
+func (e *Engine) progressFunc(r *run) func(int64) {
+	size := r.size.Load()
+	var last time.Time
+	return func(bytes int64) {
+		r.bytes.Store(bytes)
+		if (size == 0 || bytes < size) && time.Since(last) < progressInterval {
+			return
+		}
+		last = time.Now()
+		e.observer.TransferProgress(r.op, bytes, size)
+	}
+}
@@ -0,0 +1,65 @@
+package sync
+
+import (
+	"encoding/json"
+	"os"
+	"time"
+
+	"github.com/darkstorage/cli/internal/fsmeta"
+)
+
+// PlaceholderXattr marks zero-byte stand-ins for files that exist only remotely
+const PlaceholderXattr = "user.darkstorage.placeholder"
+
+// PlaceholderInfo is stored in the placeholder's extended attribute so other
+// tools can tell what the file would contain once hydrated
+type PlaceholderInfo struct {
+	Size       int64     `json:"size"`
+	Hash       string    `json:"hash,omitempty"`
+	ModifiedAt time.Time `json:"modified_at"`
+}
+
+// CreatePlaceholder writes a zero-byte file carrying the placeholder marker.
+// Filesystems without extended attribute support still get the empty file;
+// the dehydrated flag in file_states remains the source of truth.
+func CreatePlaceholder(path string, info *PlaceholderInfo) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(info)
+	if err != nil {
+		return err
+	}
+	if err := fsmeta.SetXattr(path, PlaceholderXattr, data); err != nil && err != fsmeta.ErrXattrUnsupported {
+		return err
+	}
+
+	if !info.ModifiedAt.IsZero() {
+		os.Chtimes(path, info.ModifiedAt, info.ModifiedAt)
+	}
+	return nil
+}
+
+// IsPlaceholder reports whether path is an empty file carrying the
+// placeholder marker
+func IsPlaceholder(path string) bool {
+	stat, err := os.Lstat(path)
+	if err != nil || !stat.Mode().IsRegular() || stat.Size() != 0 {
+		return false
+	}
+	_, err = fsmeta.GetXattr(path, PlaceholderXattr)
+	return err == nil
+}
+
+// ClearPlaceholderMarker removes the marker once real content is written
+func ClearPlaceholderMarker(path string) error {
+	if _, err := fsmeta.GetXattr(path, PlaceholderXattr); err != nil {
+		return nil
+	}
+	return fsmeta.RemoveXattr(path, PlaceholderXattr)
+}
@@ -0,0 +1,280 @@
+package sync
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/darkstorage/cli/internal/api"
+	"github.com/darkstorage/cli/internal/db"
+)
+
+// IncludeRules decides which parts of a remote tree are materialized
+// locally. An empty rule set includes everything.
+type IncludeRules struct {
+	paths []string
+}
+
+func NewIncludeRules(paths []string) *IncludeRules {
+	var cleaned []string
+	for _, p := range paths {
+		p = strings.TrimSpace(p)
+		if p == "" {
+			continue
+		}
+		cleaned = append(cleaned, filepath.Clean(strings.Trim(p, "/")))
+	}
+	return &IncludeRules{paths: cleaned}
+}
+
+// ParseIncludePaths splits the newline-separated include list stored on a
+// sync folder
+func ParseIncludePaths(value string) []string {
+	return strings.Split(value, "\n")
+}
+
+// FormatIncludePaths joins include paths for storage on a sync folder
+func FormatIncludePaths(paths []string) string {
+	return strings.Join(NewIncludeRules(paths).paths, "\n")
+}
+
+// ShouldMaterialize reports whether relPath is the same as, or below, one
+// of the included subpaths
+func (r *IncludeRules) ShouldMaterialize(relPath string) bool {
+	if len(r.paths) == 0 {
+		return true
+	}
+
+	relPath = filepath.Clean(relPath)
+	for _, p := range r.paths {
+		if p == "." || relPath == p || strings.HasPrefix(relPath, p+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+// reconcileRemote records files that exist only remotely. Included paths are
+// queued for download; everything else is tracked as dehydrated and, when the
+// folder asks for it, represented by a placeholder.
+func (e *Engine) reconcileRemote(folder *db.SyncFolder) error {
+	client, err := e.clientFor(folder)
+	if err != nil {
+		return err
+	}
+	remoteFiles, err := client.ListFiles(e.ctx, folder.RemotePath)
+	if err != nil {
+		return err
+	}
+
+	rules := NewIncludeRules(ParseIncludePaths(folder.IncludePaths))
+	prefix := strings.Trim(folder.RemotePath, "/")
+
+	for _, file := range remoteFiles {
+		relPath := strings.TrimPrefix(strings.Trim(file.Path, "/"), prefix)
+		relPath = filepath.FromSlash(strings.TrimPrefix(relPath, "/"))
+		if relPath == "" || relPath == "." {
+			continue
+		}
+
+		localPath := filepath.Join(folder.LocalPath, relPath)
+		if _, err := os.Lstat(localPath); err == nil {
+			continue
+		}
+
+		existing, err := e.db.GetFileState(folder.ID, relPath)
+		if err != nil {
+			return err
+		}
+
+		// Synced here before and now gone: the user deleted it while the
+		// daemon wasn't watching, so delete the remote copy as the watcher
+		// would have. A remote copy changed since is downloaded instead.
+		if existing != nil && existing.SyncStatus == StatusSynced && !existing.Dehydrated && remoteUnchanged(existing, file) {
+			if _, err := e.enqueueOnce(folder.ID, relPath, "delete", 0); err != nil {
+				return err
+			}
+			continue
+		}
+
+		hash, size, modTime := file.Hash, file.Size, file.ModifiedAt
+		state := &db.FileState{
+			SyncFolderID:     folder.ID,
+			RelativePath:     relPath,
+			RemoteHash:       &hash,
+			RemoteSize:       &size,
+			RemoteModifiedAt: &modTime,
+		}
+
+		// A file the user dehydrated stays dehydrated even if it is included
+		materialize := rules.ShouldMaterialize(relPath) && (existing == nil || !existing.Dehydrated)
+		if materialize {
+			state.SyncStatus = StatusPending
+			if err := e.db.UpsertFileState(state); err != nil {
+				return err
+			}
+			if _, err := e.enqueueOnce(folder.ID, relPath, "download", 0); err != nil {
+				return err
+			}
+			continue
+		}
+
+		state.SyncStatus = StatusSynced
+		state.Dehydrated = true
+		if err := e.db.UpsertFileState(state); err != nil {
+			return err
+		}
+
+		if folder.Placeholders {
+			if err := os.MkdirAll(filepath.Dir(localPath), 0755); err != nil {
+				return err
+			}
+			info := &PlaceholderInfo{Size: size, Hash: hash, ModifiedAt: modTime}
+			if err := CreatePlaceholder(localPath, info); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// remoteUnchanged reports whether file is still the remote copy state was
+// last synced with. Listings without hashes are compared by size.
+func remoteUnchanged(state *db.FileState, file api.RemoteFile) bool {
+	if file.Hash != "" && state.RemoteHash != nil && *state.RemoteHash != "" {
+		return file.Hash == *state.RemoteHash
+	}
+	return state.RemoteSize != nil && *state.RemoteSize == file.Size
+}
+
+// enqueueOnce queues operation on relPath unless an earlier pass already
+// queued it and it hasn't finished. It reports whether it queued it.
+func (e *Engine) enqueueOnce(folderID int, relPath, operation string, priority int) (bool, error) {
+	queued, err := e.db.IsQueued(folderID, relPath, operation)
+	if err != nil || queued {
+		return false, err
+	}
+	op := &db.QueueOperation{
+		SyncFolderID: folderID,
+		RelativePath: relPath,
+		Operation:    operation,
+		Priority:     priority,
+		MaxAttempts:  3,
+	}
+	return true, e.db.EnqueueOperation(op)
+}
+
+// isDehydrated reports whether the file at fullPath is only a stand-in for
+// remote content and must not be uploaded
+func (e *Engine) isDehydrated(folderID int, relPath, fullPath string) bool {
+	if IsPlaceholder(fullPath) {
+		return true
+	}
+
+	state, err := e.db.GetFileState(folderID, relPath)
+	if err != nil || state == nil || !state.Dehydrated {
+		return false
+	}
+
+	info, err := os.Stat(fullPath)
+	return err != nil || info.Size() == 0
+}
+
+// matchingStates returns the file states at relPath or, when relPath is a
+// directory, below it. An empty relPath matches the whole folder.
+func (e *Engine) matchingStates(folderID int, relPath string) ([]*db.FileState, error) {
+	states, err := e.db.ListFileStates(folderID, "")
+	if err != nil {
+		return nil, err
+	}
+
+	rules := NewIncludeRules([]string{relPath})
+	var matched []*db.FileState
+	for _, state := range states {
+		if rules.ShouldMaterialize(state.RelativePath) {
+			matched = append(matched, state)
+		}
+	}
+	return matched, nil
+}
+
+// Hydrate queues downloads for dehydrated files at or below relPath and
+// returns how many were queued. Files already waiting for a download are
+// skipped.
+func (e *Engine) Hydrate(folderID int, relPath string) (int, error) {
+	states, err := e.matchingStates(folderID, relPath)
+	if err != nil {
+		return 0, err
+	}
+
+	queued := 0
+	for _, state := range states {
+		if !state.Dehydrated {
+			continue
+		}
+		added, err := e.enqueueOnce(folderID, state.RelativePath, "download", 10)
+		if err != nil {
+			return queued, err
+		}
+		if added {
+			queued++
+		}
+	}
+	return queued, nil
+}
+
+// Dehydrate frees local space for synced files at or below relPath, leaving
+// a placeholder when the folder uses them. Files with local changes that
+// have not been uploaded yet are skipped.
+func (e *Engine) Dehydrate(folderID int, relPath string) (int, error) {
+	folder, err := e.db.GetSyncFolder(folderID)
+	if err != nil {
+		return 0, err
+	}
+	if folder == nil {
+		return 0, fmt.Errorf("folder not found: %d", folderID)
+	}
+
+	states, err := e.matchingStates(folderID, relPath)
+	if err != nil {
+		return 0, err
+	}
+
+	freed := 0
+	for _, state := range states {
+		if state.Dehydrated || state.SyncStatus != StatusSynced || state.LocalHash == nil {
+			continue
+		}
+
+		localPath := filepath.Join(folder.LocalPath, state.RelativePath)
+		hash, err := HashFile(localPath)
+		if err != nil || hash != *state.LocalHash {
+			continue
+		}
+
+		// Mark first so the watcher ignores the truncation below
+		if err := e.db.SetFileDehydrated(folderID, state.RelativePath, true); err != nil {
+			return freed, err
+		}
+
+		if folder.Placeholders {
+			info := &PlaceholderInfo{Hash: hash}
+			if state.LocalSize != nil {
+				info.Size = *state.LocalSize
+			}
+			if state.LocalModifiedAt != nil {
+				info.ModifiedAt = *state.LocalModifiedAt
+			}
+			err = CreatePlaceholder(localPath, info)
+		} else {
+			err = os.Remove(localPath)
+		}
+		if err != nil {
+			e.db.SetFileDehydrated(folderID, state.RelativePath, false)
+			return freed, err
+		}
+		freed++
+	}
+	return freed, nil
+}
@@ -0,0 +1,199 @@
+package sync
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/darkstorage/cli/internal/api"
+	"github.com/darkstorage/cli/internal/db"
+	"github.com/darkstorage/cli/internal/storage"
+)
+
+// listBackend serves a fixed listing; the engine calls nothing else here
+type listBackend struct {
+	storage.StorageBackend
+	files []storage.FileInfo
+}
+
+func (b *listBackend) List(ctx context.Context, prefix string, opts *storage.ListOptions) ([]storage.FileInfo, error) {
+	return b.files, nil
+}
+
+// remoteFile is an object below the test folder's remote path
+func remoteFile(relPath, hash string, size int64) storage.FileInfo {
+	file := storage.FileInfo{Path: "bucket/folder/" + relPath, Size: size}
+	if hash != "" {
+		file.Metadata = map[string]string{api.MetadataSHA256: hash}
+	}
+	return file
+}
+
+func newTestEngine(t *testing.T, files ...storage.FileInfo) (*Engine, *db.DB, *db.SyncFolder) {
+	t.Helper()
+	database, err := db.New(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { database.Close() })
+
+	folder := &db.SyncFolder{
+		LocalPath:          t.TempDir(),
+		RemotePath:         "bucket/folder",
+		Direction:          "bidirectional",
+		Enabled:            true,
+		ConflictResolution: "newest",
+	}
+	if err := database.CreateSyncFolder(folder); err != nil {
+		t.Fatal(err)
+	}
+
+	client := api.NewClient("http://localhost", "")
+	client.SetStorageBackend(&listBackend{files: files})
+	e := NewEngine(database, client)
+	t.Cleanup(e.Stop)
+	return e, database, folder
+}
+
+// queued lists the waiting operations as "operation path"
+func queued(t *testing.T, database *db.DB, folderID int) []string {
+	t.Helper()
+	ops, err := database.ListQueueOperations(folderID, "pending", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out []string
+	for _, op := range ops {
+		out = append(out, op.Operation+" "+op.RelativePath)
+	}
+	sort.Strings(out)
+	return out
+}
+
+func TestReconcileRemote(t *testing.T) {
+	str := func(s string) *string { return &s }
+	size := func(n int64) *int64 { return &n }
+
+	tests := []struct {
+		name   string
+		remote storage.FileInfo
+		// state is recorded for the file before reconciling, if not nil
+		state *db.FileState
+		// local creates the file locally
+		local bool
+		want  []string
+	}{
+		{
+			name:   "new remote file",
+			remote: remoteFile("new.txt", "h1", 10),
+			want:   []string{"download new.txt"},
+		},
+		{
+			name:   "present locally",
+			remote: remoteFile("here.txt", "h1", 10),
+			local:  true,
+		},
+		{
+			name:   "deleted locally while stopped",
+			remote: remoteFile("gone.txt", "h1", 10),
+			state:  &db.FileState{SyncStatus: StatusSynced, RemoteHash: str("h1"), RemoteSize: size(10)},
+			want:   []string{"delete gone.txt"},
+		},
+		{
+			name:   "deleted locally, listing without hashes",
+			remote: remoteFile("gone.txt", "", 10),
+			state:  &db.FileState{SyncStatus: StatusSynced, RemoteHash: str("h1"), RemoteSize: size(10)},
+			want:   []string{"delete gone.txt"},
+		},
+		{
+			name:   "deleted locally but changed remotely",
+			remote: remoteFile("gone.txt", "h2", 12),
+			state:  &db.FileState{SyncStatus: StatusSynced, RemoteHash: str("h1"), RemoteSize: size(10)},
+			want:   []string{"download gone.txt"},
+		},
+		{
+			name:   "download not finished yet",
+			remote: remoteFile("pending.txt", "h1", 10),
+			state:  &db.FileState{SyncStatus: StatusPending, RemoteHash: str("h1"), RemoteSize: size(10)},
+			want:   []string{"download pending.txt"},
+		},
+		{
+			name:   "dehydrated",
+			remote: remoteFile("cold.txt", "h1", 10),
+			state:  &db.FileState{SyncStatus: StatusSynced, Dehydrated: true, RemoteHash: str("h1"), RemoteSize: size(10)},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, database, folder := newTestEngine(t, tt.remote)
+			relPath := filepath.Base(tt.remote.Path)
+			if tt.state != nil {
+				tt.state.SyncFolderID, tt.state.RelativePath = folder.ID, relPath
+				if err := database.UpsertFileState(tt.state); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if tt.local {
+				if err := os.WriteFile(filepath.Join(folder.LocalPath, relPath), []byte("local"), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			// A second pass must not queue anything again
+			for pass := 0; pass < 2; pass++ {
+				if err := e.reconcileRemote(folder); err != nil {
+					t.Fatalf("reconcileRemote() error = %v", err)
+				}
+			}
+
+			got := queued(t, database, folder.ID)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("queued %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHydrate(t *testing.T) {
+	e, database, folder := newTestEngine(t)
+	for _, state := range []*db.FileState{
+		{RelativePath: "docs/a.txt", SyncStatus: StatusSynced, Dehydrated: true},
+		{RelativePath: "docs/b.txt", SyncStatus: StatusSynced, Dehydrated: true},
+		{RelativePath: "docs/c.txt", SyncStatus: StatusSynced},
+		{RelativePath: "other.txt", SyncStatus: StatusSynced, Dehydrated: true},
+	} {
+		state.SyncFolderID = folder.ID
+		if err := database.UpsertFileState(state); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name       string
+		relPath    string
+		wantQueued int
+	}{
+		{name: "dehydrated files below the path", relPath: "docs", wantQueued: 2},
+		{name: "again", relPath: "docs", wantQueued: 0},
+		{name: "whole folder", relPath: "", wantQueued: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n, err := e.Hydrate(folder.ID, tt.relPath)
+			if err != nil {
+				t.Fatalf("Hydrate() error = %v", err)
+			}
+			if n != tt.wantQueued {
+				t.Errorf("Hydrate() = %d, want %d", n, tt.wantQueued)
+			}
+		})
+	}
+
+	want := []string{"download docs/a.txt", "download docs/b.txt", "download other.txt"}
+	if got := queued(t, database, folder.ID); !reflect.DeepEqual(got, want) {
+		t.Errorf("queued %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,126 @@
+package sync
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync/atomic"
+	"time"
+
+	"github.com/darkstorage/cli/internal/db"
+)
+
+// Transfer is a snapshot of an operation being run
+type Transfer struct {
+	Op        *db.QueueOperation
+	Bytes     int64
+	Size      int64
+	StartedAt time.Time
+}
+
+// run tracks an operation from when a worker takes it until it finishes
+type run struct {
+	op        *db.QueueOperation
+	ctx       context.Context
+	cancel    context.CancelFunc
+	startedAt time.Time
+	bytes     atomic.Int64
+	size      atomic.Int64
+	// cancelled is set by CancelOperation, as opposed to Stop
+	cancelled atomic.Bool
+	// versionID is the remote version uploaded or downloaded, "" if the
+	// bucket keeps none
+	versionID string
+}
+
+func (e *Engine) startRun(op *db.QueueOperation) *run {
+	ctx, cancel := context.WithCancel(e.ctx)
+	r := &run{op: op, ctx: ctx, cancel: cancel, startedAt: time.Now()}
+
+	e.runsMu.Lock()
+	e.runs[op.ID] = r
+	e.runsMu.Unlock()
+	return r
+}
+
+func (e *Engine) endRun(r *run) {
+	e.runsMu.Lock()
+	delete(e.runs, r.op.ID)
+	e.runsMu.Unlock()
+	r.cancel()
+}
+
+// downloading reports whether a download of relPath is running; the run
+// lasts until the file state is marked synced
+func (e *Engine) downloading(folderID int, relPath string) bool {
+	e.runsMu.Lock()
+	defer e.runsMu.Unlock()
+	for _, r := range e.runs {
+		if r.op.SyncFolderID == folderID && r.op.RelativePath == relPath && r.op.Operation == "download" {
+			return true
+		}
+	}
+	return false
+}
+
+// Transfers returns the operations running now, oldest first
+func (e *Engine) Transfers() []Transfer {
+	e.runsMu.Lock()
+	transfers := make([]Transfer, 0, len(e.runs))
+	for _, r := range e.runs {
+		transfers = append(transfers, Transfer{
+			Op:        r.op,
+			Bytes:     r.bytes.Load(),
+			Size:      r.size.Load(),
+			StartedAt: r.startedAt,
+		})
+	}
+	e.runsMu.Unlock()
+
+	sort.Slice(transfers, func(i, j int) bool {
+		return transfers[i].StartedAt.Before(transfers[j].StartedAt)
+	})
+	return transfers
+}
+
+// CancelOperation cancels a queued operation, interrupting it if it is
+// running
+func (e *Engine) CancelOperation(id int) error {
+	ok, err := e.db.CancelQueuedOperation(id)
+	if err != nil || ok {
+		return err
+	}
+
+	e.runsMu.Lock()
+	r := e.runs[id]
+	e.runsMu.Unlock()
+	if r != nil {
+		r.cancelled.Store(true)
+		r.cancel()
+		return nil
+	}
+
+	return e.queueStateError(id, "cancelled")
+}
+
+// RetryOperation queues a failed or cancelled operation again with its
+// attempts reset
+func (e *Engine) RetryOperation(id int) error {
+	ok, err := e.db.RequeueOperation(id)
+	if err != nil || ok {
+		return err
+	}
+	return e.queueStateError(id, "retried")
+}
+
+// queueStateError explains why an operation could not be changed
+func (e *Engine) queueStateError(id int, action string) error {
+	op, err := e.db.GetQueueOperation(id)
+	if err != nil {
+		return err
+	}
+	if op == nil {
+		return &NotFoundError{What: "queue operation", ID: id}
+	}
+	return fmt.Errorf("operation %d is %s and can't be %s", id, op.Status, action)
+}
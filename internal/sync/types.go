@@ -1,6 +1,9 @@
 package sync
 
-import "time"
+import (
+	"fmt"
+	"time"
+)
 
 type EventType int
 
@@ -47,7 +50,19 @@ const (
 	QueueProcessing = "processing"
 	QueueCompleted  = "completed"
 	QueueFailed     = "failed"
+	QueueCancelled  = "cancelled"
 
 	DefaultDebounceDelay = 3 * time.Second
 	DefaultWorkerCount   = 4
 )
+
+// NotFoundError reports an ID that names no queue operation, conflict or
+// folder
+type NotFoundError struct {
+	What string
+	ID   int
+}
+
+func (e *NotFoundError) Error() string {
+	return fmt.Sprintf("%s not found: %d", e.What, e.ID)
+}
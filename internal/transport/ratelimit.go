@@ -0,0 +1,20 @@
+package transport
+
+import (
+	"net/http"
+
+	"github.com/darkstorage/cli/internal/bandwidth"
+)
+
+// RateLimit delays requests so they do not exceed the limiter's rate, one
+// token per request; build the limiter with bandwidth.NewBucket
+func RateLimit(limiter *bandwidth.Limiter) Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			if err := limiter.WaitN(req.Context(), 1); err != nil {
+				return nil, err
+			}
+			return next.RoundTrip(req)
+		})
+	}
+}
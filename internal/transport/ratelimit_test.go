@@ -0,0 +1,55 @@
+package transport
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/darkstorage/cli/internal/bandwidth"
+)
+
+func TestRateLimit(t *testing.T) {
+	base, attempts := fakeServer(200)
+	rt := Chain(base, RateLimit(bandwidth.NewBucket(20, 2)))
+
+	get := func(ctx context.Context) error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, "https://api.example/v1/files", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = rt.RoundTrip(req)
+		return err
+	}
+
+	// The burst passes at once
+	start := time.Now()
+	for i := 0; i < 2; i++ {
+		if err := get(context.Background()); err != nil {
+			t.Fatalf("request %d error = %v", i+1, err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed > 20*time.Millisecond {
+		t.Errorf("burst took %v, want no wait", elapsed)
+	}
+
+	// The next waits for a token, about 50ms at 20 a second
+	start = time.Now()
+	if err := get(context.Background()); err != nil {
+		t.Fatalf("request 3 error = %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 30*time.Millisecond {
+		t.Errorf("request after the burst took %v, want about 50ms", elapsed)
+	}
+
+	// A request whose context ends while waiting is not sent
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Millisecond)
+	defer cancel()
+	if err := get(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("request error = %v, want %v", err, context.DeadlineExceeded)
+	}
+	if *attempts != 3 {
+		t.Errorf("requests sent = %d, want 3", *attempts)
+	}
+}
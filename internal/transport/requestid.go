@@ -0,0 +1,30 @@
+package transport
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+)
+
+const RequestIDHeader = "X-Request-ID"
+
+// RequestID sets X-Request-ID on requests that do not already carry one, so
+// client logs and traces can be matched with server logs
+func RequestID() Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			if req.Header.Get(RequestIDHeader) == "" {
+				// RoundTrippers must not modify the caller's request
+				req = req.Clone(req.Context())
+				req.Header.Set(RequestIDHeader, NewRequestID())
+			}
+			return next.RoundTrip(req)
+		})
+	}
+}
+
+func NewRequestID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
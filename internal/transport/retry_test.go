@@ -0,0 +1,124 @@
+package transport
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeServer answers attempts with statuses in turn; 0 is a network error
+func fakeServer(statuses ...int) (http.RoundTripper, *int) {
+	attempts := 0
+	return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		status := statuses[min(attempts, len(statuses)-1)]
+		attempts++
+		if req.Body != nil {
+			io.ReadAll(req.Body)
+		}
+		if status == 0 {
+			return nil, errors.New("connection reset")
+		}
+		return &http.Response{
+			StatusCode: status,
+			Header:     http.Header{},
+			Body:       io.NopCloser(strings.NewReader("")),
+			Request:    req,
+		}, nil
+	}), &attempts
+}
+
+func TestRetry(t *testing.T) {
+	policy := RetryPolicy{MaxRetries: 2, BaseDelay: time.Millisecond, MaxDelay: 5 * time.Millisecond}
+
+	tests := []struct {
+		name     string
+		method   string
+		body     io.Reader
+		header   http.Header
+		statuses []int
+		// wantStatus is the status returned, 0 for an error
+		wantStatus   int
+		wantAttempts int
+	}{
+		{name: "success", method: http.MethodGet, statuses: []int{200}, wantStatus: 200, wantAttempts: 1},
+		{name: "server error then success", method: http.MethodGet, statuses: []int{503, 200}, wantStatus: 200, wantAttempts: 2},
+		{name: "network error then success", method: http.MethodGet, statuses: []int{0, 200}, wantStatus: 200, wantAttempts: 2},
+		{name: "gives up after max retries", method: http.MethodGet, statuses: []int{502}, wantStatus: 502, wantAttempts: 3},
+		{name: "client error not retried", method: http.MethodGet, statuses: []int{404}, wantStatus: 404, wantAttempts: 1},
+		{name: "POST server error not retried", method: http.MethodPost, body: strings.NewReader("{}"), statuses: []int{503, 200}, wantStatus: 503, wantAttempts: 1},
+		{name: "POST network error not retried", method: http.MethodPost, body: strings.NewReader("{}"), statuses: []int{0, 200}, wantAttempts: 1},
+		{name: "POST with idempotency key", method: http.MethodPost, body: strings.NewReader("{}"), header: http.Header{IdempotencyKeyHeader: {"k"}}, statuses: []int{503, 200}, wantStatus: 200, wantAttempts: 2},
+		{name: "POST too many requests", method: http.MethodPost, body: strings.NewReader("{}"), statuses: []int{429, 200}, wantStatus: 200, wantAttempts: 2},
+		{name: "body not replayable", method: http.MethodPut, body: io.MultiReader(strings.NewReader("data")), statuses: []int{503, 200}, wantStatus: 503, wantAttempts: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			base, attempts := fakeServer(tt.statuses...)
+			req, err := http.NewRequest(tt.method, "https://api.example/v1/files", tt.body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for k, v := range tt.header {
+				req.Header[k] = v
+			}
+
+			resp, err := Chain(base, Retry(policy)).RoundTrip(req)
+			if tt.wantStatus == 0 {
+				if err == nil {
+					t.Errorf("RoundTrip() status = %d, want an error", resp.StatusCode)
+				}
+			} else if err != nil {
+				t.Fatalf("RoundTrip() error = %v", err)
+			} else if resp.StatusCode != tt.wantStatus {
+				t.Errorf("RoundTrip() status = %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+			if *attempts != tt.wantAttempts {
+				t.Errorf("attempts = %d, want %d", *attempts, tt.wantAttempts)
+			}
+		})
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	tests := []struct {
+		name   string
+		value  string
+		want   time.Duration
+		wantOK bool
+	}{
+		{name: "absent"},
+		{name: "seconds", value: "7", want: 7 * time.Second, wantOK: true},
+		{name: "HTTP date", value: now.Add(90 * time.Second).Format(http.TimeFormat), want: 90 * time.Second, wantOK: true},
+		{name: "date in the past", value: now.Add(-time.Minute).Format(http.TimeFormat), want: 0, wantOK: true},
+		{name: "invalid", value: "soon"},
+		{name: "negative", value: "-3"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := &http.Response{Header: http.Header{}}
+			if tt.value != "" {
+				resp.Header.Set("Retry-After", tt.value)
+			}
+			got, ok := retryAfter(resp, now)
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("retryAfter() = %v, %v, want %v, %v", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	policy := RetryPolicy{BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second}
+	for attempt := 0; attempt < 8; attempt++ {
+		limit := min(policy.BaseDelay<<attempt, policy.MaxDelay)
+		for i := 0; i < 20; i++ {
+			if got := policy.backoff(attempt); got <= 0 || got > limit {
+				t.Fatalf("backoff(%d) = %v, want in (0, %v]", attempt, got, limit)
+			}
+		}
+	}
+}
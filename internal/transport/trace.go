@@ -0,0 +1,188 @@
+package transport
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Bodies larger than this are truncated in traces
+const maxTraceBody = 8 << 10
+
+const redacted = "REDACTED"
+
+var sensitiveHeaders = []string{
+	"Authorization",
+	"Proxy-Authorization",
+	"Cookie",
+	"Set-Cookie",
+	"X-Api-Key",
+	"X-Amz-Security-Token",
+}
+
+var sensitiveParams = []string{
+	"token", "access_token", "refresh_token", "api_key", "apikey",
+	"password", "secret", "client_secret", "code", "code_verifier", "device_code",
+	"x-amz-signature", "x-amz-credential", "x-amz-security-token",
+}
+
+// sensitiveJSON matches string values of credential-like JSON keys
+var sensitiveJSON = regexp.MustCompile(`(?i)("(?:[a-z_]*token|password|secret|api_key|apikey|client_secret|code_verifier|device_code|private_key)"\s*:\s*)"(?:[^"\\]|\\.)*"`)
+
+// Trace writes a dump of every request and response to w with credentials
+// redacted
+func Trace(w io.Writer) Middleware {
+	var mu sync.Mutex
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			reqDump := dumpRequest(req)
+
+			start := time.Now()
+			resp, err := next.RoundTrip(req)
+			elapsed := time.Since(start)
+
+			var respDump string
+			if err != nil {
+				respDump = fmt.Sprintf("error: %v\n", err)
+			} else {
+				respDump = dumpResponse(resp)
+			}
+
+			mu.Lock()
+			fmt.Fprintf(w, "--> %s %s [%s]\n%s\n<-- %s (%s)\n%s\n",
+				req.Method, redactURL(req.URL), req.Header.Get(RequestIDHeader), reqDump,
+				status(resp), elapsed.Round(time.Millisecond), respDump)
+			mu.Unlock()
+
+			return resp, err
+		})
+	}
+}
+
+func status(resp *http.Response) string {
+	if resp == nil {
+		return "failed"
+	}
+	return resp.Status
+}
+
+func dumpRequest(req *http.Request) string {
+	clone := req.Clone(req.Context())
+	clone.URL = redactURLCopy(req.URL)
+	redactHeaders(clone.Header)
+
+	var body []byte
+	if req.Body != nil && req.Body != http.NoBody {
+		if req.GetBody == nil {
+			// Reading it would consume the real request
+			clone.Body = nil
+			body = []byte("(body not replayable, omitted)")
+		} else if rc, err := req.GetBody(); err == nil {
+			body, _ = io.ReadAll(io.LimitReader(rc, maxTraceBody+1))
+			rc.Close()
+			clone.Body = nil
+		}
+	}
+	clone.ContentLength = 0
+
+	head, err := httputil.DumpRequestOut(clone, false)
+	if err != nil {
+		return fmt.Sprintf("(dump failed: %v)", err)
+	}
+	return string(head) + formatBody(body)
+}
+
+func dumpResponse(resp *http.Response) string {
+	clone := *resp
+	clone.Header = resp.Header.Clone()
+	redactHeaders(clone.Header)
+
+	head, err := httputil.DumpResponse(&clone, false)
+	if err != nil {
+		return fmt.Sprintf("(dump failed: %v)", err)
+	}
+
+	var body []byte
+	if resp.Body != nil && resp.Body != http.NoBody {
+		// Peek at the start of the body and hand the caller the same stream
+		body, _ = io.ReadAll(io.LimitReader(resp.Body, maxTraceBody+1))
+		resp.Body = &replayBody{
+			Reader: io.MultiReader(bytes.NewReader(body), resp.Body),
+			Closer: resp.Body,
+		}
+	}
+	return string(head) + formatBody(body)
+}
+
+type replayBody struct {
+	io.Reader
+	io.Closer
+}
+
+func formatBody(body []byte) string {
+	if len(body) == 0 {
+		return ""
+	}
+	truncated := len(body) > maxTraceBody
+	if truncated {
+		body = body[:maxTraceBody]
+	}
+	out := RedactBody(string(body))
+	if truncated {
+		out += "\n... (truncated)"
+	}
+	return out + "\n"
+}
+
+// RedactBody masks credential-like values in JSON or form bodies
+func RedactBody(body string) string {
+	body = sensitiveJSON.ReplaceAllString(body, `$1"`+redacted+`"`)
+	if values, err := url.ParseQuery(body); err == nil && strings.Contains(body, "=") && !strings.ContainsAny(body, "{[ \n") {
+		if redactValues(values) {
+			return values.Encode()
+		}
+	}
+	return body
+}
+
+func redactHeaders(h http.Header) {
+	for _, name := range sensitiveHeaders {
+		if h.Get(name) != "" {
+			h.Set(name, redacted)
+		}
+	}
+}
+
+func redactValues(values url.Values) bool {
+	changed := false
+	for key := range values {
+		for _, name := range sensitiveParams {
+			if strings.EqualFold(key, name) {
+				values.Set(key, redacted)
+				changed = true
+			}
+		}
+	}
+	return changed
+}
+
+func redactURLCopy(u *url.URL) *url.URL {
+	copy := *u
+	copy.User = nil
+	values := copy.Query()
+	if redactValues(values) {
+		copy.RawQuery = values.Encode()
+	}
+	return &copy
+}
+
+func redactURL(u *url.URL) string {
+	return redactURLCopy(u).String()
+}
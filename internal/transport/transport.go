@@ -0,0 +1,74 @@
+// Package transport provides http.RoundTripper middleware for the API
+// clients: retries with backoff, client-side rate limiting, request IDs and
+// redacted tracing.
+package transport
+
+import (
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/darkstorage/cli/internal/bandwidth"
+)
+
+// Middleware wraps a RoundTripper
+type Middleware func(http.RoundTripper) http.RoundTripper
+
+// Chain wraps base so that the first middleware sees each request first
+func Chain(base http.RoundTripper, middleware ...Middleware) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	rt := base
+	for i := len(middleware) - 1; i >= 0; i-- {
+		rt = middleware[i](rt)
+	}
+	return rt
+}
+
+// RoundTripperFunc adapts a function to http.RoundTripper
+type RoundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f RoundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+type Options struct {
+	Timeout time.Duration // whole request including retries; 0 means none
+	Retry   RetryPolicy
+	// RateLimit is the sustained requests per second; 0 disables limiting
+	RateLimit float64
+	Burst     int
+	// Trace receives redacted request/response dumps when set
+	Trace io.Writer
+}
+
+// DefaultOptions retries transient failures and does not rate limit
+func DefaultOptions() Options {
+	return Options{
+		Timeout: 2 * time.Minute,
+		Retry:   DefaultRetryPolicy(),
+	}
+}
+
+// New builds the standard chain: request ID, retries, rate limit, trace.
+// The limiter and tracer sit inside the retry loop so every attempt is
+// counted and logged; the request ID is shared by all attempts.
+func New(base http.RoundTripper, opts Options) http.RoundTripper {
+	middleware := []Middleware{RequestID(), Retry(opts.Retry)}
+	if opts.RateLimit > 0 {
+		middleware = append(middleware, RateLimit(bandwidth.NewBucket(opts.RateLimit, opts.Burst)))
+	}
+	if opts.Trace != nil {
+		middleware = append(middleware, Trace(opts.Trace))
+	}
+	return Chain(base, middleware...)
+}
+
+// NewClient returns an http.Client using the standard chain
+func NewClient(opts Options) *http.Client {
+	return &http.Client{
+		Transport: New(nil, opts),
+		Timeout:   opts.Timeout,
+	}
+}